@@ -15,6 +15,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -28,78 +29,234 @@ type hotfix struct {
 	Repo string `json:"repo"`
 	// Names of associated packages
 	Packages []string `json:"packages"`
+	// Exact packages, which the repo must serve
+	Pins []hotfixPin `json:"pins,omitempty"`
+}
+
+// hotfixPin pins a hotfix package to a NEVRA, and optionally to the
+// SHA-256 digest of the RPM.
+type hotfixPin struct {
+	NEVRA  string `json:"nevra"`
+	Sha256 string `json:"sha256,omitempty"`
 }
 
 type hotfixData struct {
 	Hotfixes []hotfix `json:"hotfixes"`
 }
 
+// splitNEVRA returns the name of the NEVRA and the rest of it, i.e. its
+// [epoch:]version-release.arch.
+func splitNEVRA(nevra string) (string, string, error) {
+	rel := strings.LastIndex(nevra, "-")
+	if rel < 0 {
+		return "", "", fmt.Errorf("invalid NEVRA %q", nevra)
+	}
+	ver := strings.LastIndex(nevra[:rel], "-")
+	if ver <= 0 || !strings.Contains(nevra[rel:], ".") {
+		return "", "", fmt.Errorf("invalid NEVRA %q", nevra)
+	}
+	return nevra[:ver], nevra[ver+1:], nil
+}
+
+// normalizeNEVRA returns the NEVRA with an explicit epoch, as queried with
+// %{EPOCHNUM}.
+func normalizeNEVRA(nevra string) (string, error) {
+	name, evra, err := splitNEVRA(nevra)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(evra, ":") {
+		return nevra, nil
+	}
+	return name + "-0:" + evra, nil
+}
+
+// validateHotfix returns an error if the pins of a hotfix are invalid, or
+// if a package is both listed and pinned, which would download it twice.
+func validateHotfix(fix hotfix) error {
+	listed := make(map[string]bool, len(fix.Packages))
+	for _, pkg := range fix.Packages {
+		listed[pkg] = true
+	}
+	for _, pin := range fix.Pins {
+		name, _, err := splitNEVRA(pin.NEVRA)
+		if err != nil {
+			return err
+		}
+		if listed[name] || listed[pin.NEVRA] {
+			return fmt.Errorf("package %s is both listed and pinned", name)
+		}
+	}
+	return nil
+}
+
+// resolveHotfixPackages returns the NEVRA and SHA-256 digest of the RPMs
+// in dir.
+func resolveHotfixPackages(dir string) ([]cosa.HotfixPackage, error) {
+	rpms, err := filepath.Glob(filepath.Join(dir, "*.rpm"))
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []cosa.HotfixPackage
+	for _, rpm := range rpms {
+		out, err := exec.Command("rpm", "-qp", "--nosignature", "--qf", "%{NAME}-%{EPOCHNUM}:%{VERSION}-%{RELEASE}.%{ARCH}", rpm).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", filepath.Base(rpm), err)
+		}
+		f, err := os.Open(rpm)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", rpm, err)
+		}
+		pkgs = append(pkgs, cosa.HotfixPackage{
+			Nevra:  strings.TrimSpace(string(out)),
+			Sha256: fmt.Sprintf("%x", hash.Sum(nil)),
+		})
+	}
+	return pkgs, nil
+}
+
+// checkHotfixPins returns an error if the resolved packages of a hotfix
+// don't match its pins.
+func checkHotfixPins(fix hotfix, pkgs []cosa.HotfixPackage) error {
+	resolved := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		resolved[pkg.Nevra] = pkg.Sha256
+	}
+	for _, pin := range fix.Pins {
+		nevra, err := normalizeNEVRA(pin.NEVRA)
+		if err != nil {
+			return err
+		}
+		digest, ok := resolved[nevra]
+		if !ok {
+			return fmt.Errorf("repo %s didn't serve pinned package %s", fix.Repo, pin.NEVRA)
+		}
+		if pin.Sha256 != "" && digest != pin.Sha256 {
+			return fmt.Errorf("pinned package %s: sha256 mismatch: expected %s, found %s", pin.NEVRA, pin.Sha256, digest)
+		}
+	}
+	return nil
+}
+
 // downloadHotfixes basically just accepts as input a declarative JSON file
 // format describing hotfixes, which are repo-locked RPM packages we want to download
-// but without any dependencies.
-func downloadHotfixes(srcdir, configpath, destdir string) error {
+// but without any dependencies. Packages can be pinned to a NEVRA and
+// SHA-256 digest, in which case the download fails if the repo doesn't
+// serve that exact RPM. It returns the hotfixes with the NEVRAs and digests
+// of their downloaded RPMs.
+func downloadHotfixes(srcdir, configpath, destdir string) ([]cosa.Hotfix, error) {
 	contents, err := os.ReadFile(configpath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var h hotfixData
 	if err := yaml.Unmarshal(contents, &h); err != nil {
-		return fmt.Errorf("failed to deserialize hotfixes: %w", err)
+		return nil, fmt.Errorf("failed to deserialize hotfixes: %w", err)
 	}
 
 	fmt.Println("Downloading hotfixes")
 
+	var resolved []cosa.Hotfix
 	for _, fix := range h.Hotfixes {
+		if err := validateHotfix(fix); err != nil {
+			return nil, fmt.Errorf("hotfix %s: %w", fix.Link, err)
+		}
 		fmt.Printf("Downloading content for hotfix: %s\n", fix.Link)
+		// Download each hotfix separately, so that we know which RPMs
+		// belong to it
+		dldir, err := os.MkdirTemp(destdir, "download")
+		if err != nil {
+			return nil, err
+		}
 		// Only enable the repos required for download
 		reposdir := filepath.Join(srcdir, "yumrepos")
-		argv := []string{"--disablerepo=*", fmt.Sprintf("--enablerepo=%s", fix.Repo), "--setopt=reposdir=" + reposdir, "download"}
+		argv := []string{"--disablerepo=*", fmt.Sprintf("--enablerepo=%s", fix.Repo), "--setopt=reposdir=" + reposdir, "download", "--destdir=" + dldir}
 		argv = append(argv, fix.Packages...)
+		for _, pin := range fix.Pins {
+			argv = append(argv, pin.NEVRA)
+		}
 		cmd := exec.Command("dnf", argv...)
 		cmd.Dir = destdir
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to invoke dnf download: %w", err)
+			return nil, fmt.Errorf("failed to invoke dnf download: %w", err)
 		}
+
+		pkgs, err := resolveHotfixPackages(dldir)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkHotfixPins(fix, pkgs); err != nil {
+			return nil, fmt.Errorf("hotfix %s: %w", fix.Link, err)
+		}
+		for _, pkg := range pkgs {
+			fmt.Printf("Resolved %s (sha256 %s)\n", pkg.Nevra, pkg.Sha256)
+		}
+		rpms, err := filepath.Glob(filepath.Join(dldir, "*.rpm"))
+		if err != nil {
+			return nil, err
+		}
+		for _, rpm := range rpms {
+			if err := os.Rename(rpm, filepath.Join(destdir, filepath.Base(rpm))); err != nil {
+				return nil, err
+			}
+		}
+		if err := os.Remove(dldir); err != nil {
+			return nil, err
+		}
+
+		resolved = append(resolved, cosa.Hotfix{
+			Link:     fix.Link,
+			OsMajor:  fix.OsMajor,
+			Repo:     fix.Repo,
+			Packages: pkgs,
+		})
 	}
 
 	serializedHotfixes, err := json.Marshal(h)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = os.WriteFile(filepath.Join(destdir, "hotfixes.json"), serializedHotfixes, 0o644)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return resolved, nil
 }
 
-func generateHotfixes() (string, error) {
+func generateHotfixes() (string, []cosa.Hotfix, error) {
 	hotfixesTmpdir, err := os.MkdirTemp("", "")
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	defer os.RemoveAll(hotfixesTmpdir)
 
 	variant, err := cosamodel.GetVariant()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	wd, err := os.Getwd()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	srcdir := filepath.Join(wd, "src")
 	p := fmt.Sprintf("%s/config/hotfixes-%s.yaml", srcdir, variant)
+	var hotfixes []cosa.Hotfix
 	if _, err := os.Stat(p); err == nil {
-		err := downloadHotfixes(srcdir, p, hotfixesTmpdir)
+		hotfixes, err = downloadHotfixes(srcdir, p, hotfixesTmpdir)
 		if err != nil {
-			return "", fmt.Errorf("failed to download hotfixes: %w", err)
+			return "", nil, fmt.Errorf("failed to download hotfixes: %w", err)
 		}
 	} else {
 		fmt.Printf("No %s found\n", p)
@@ -114,10 +271,10 @@ func generateHotfixes() (string, error) {
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return out, nil
+	return out, hotfixes, nil
 }
 
 func buildExtensionContainer() error {
@@ -128,7 +285,7 @@ func buildExtensionContainer() error {
 	buildID := cosaBuild.BuildID
 	fmt.Printf("Generating extensions container for build: %s\n", buildID)
 
-	hotfixPath, err := generateHotfixes()
+	hotfixPath, hotfixes, err := generateHotfixes()
 	if err != nil {
 		return fmt.Errorf("generating hotfixes failed: %w", err)
 	}
@@ -179,11 +336,12 @@ func buildExtensionContainer() error {
 	}
 	sha256sum := fmt.Sprintf("%x", hash.Sum(nil))
 
-	cosaBuild.BuildArtifacts.ExtensionsContainer = &cosa.Artifact{
+	cosaBuild.BuildArtifacts.ExtensionsContainer = &cosa.ExtensionsContainerArtifact{
 		Path:            targetname,
 		Sha256:          sha256sum,
 		SizeInBytes:     float64(stat.Size()),
 		SkipCompression: true,
+		Hotfixes:        hotfixes,
 	}
 	cosaBuild.MetaStamp = float64(time.Now().UnixNano())
 
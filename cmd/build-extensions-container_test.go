@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+)
+
+func TestNormalizeNEVRA(t *testing.T) {
+	tests := []struct {
+		nevra string
+		want  string
+		err   bool
+	}{
+		{nevra: "kernel-5.14.0-1.el9.x86_64", want: "kernel-0:5.14.0-1.el9.x86_64"},
+		{nevra: "kernel-0:5.14.0-1.el9.x86_64", want: "kernel-0:5.14.0-1.el9.x86_64"},
+		{nevra: "shim-x64-2:15.8-1.noarch", want: "shim-x64-2:15.8-1.noarch"},
+		{nevra: "python3-dnf-plugins-core-4.3.0-5.el9.noarch", want: "python3-dnf-plugins-core-0:4.3.0-5.el9.noarch"},
+		{nevra: "kernel-5.14.0-1.el9.aarch64", want: "kernel-0:5.14.0-1.el9.aarch64"},
+		{nevra: "kernel", err: true},
+		{nevra: "kernel-5.14.0", err: true},
+		{nevra: "kernel-5.14.0-1", err: true},
+		{nevra: "-5.14.0-1.x86_64", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nevra, func(t *testing.T) {
+			got, err := normalizeNEVRA(tt.nevra)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHotfix(t *testing.T) {
+	tests := []struct {
+		name string
+		fix  hotfix
+		err  string
+	}{
+		{
+			name: "packages and pins",
+			fix: hotfix{
+				Packages: []string{"ignition"},
+				Pins:     []hotfixPin{{NEVRA: "kernel-5.14.0-1.el9.x86_64"}},
+			},
+		},
+		{
+			name: "package listed and pinned",
+			fix: hotfix{
+				Packages: []string{"kernel"},
+				Pins:     []hotfixPin{{NEVRA: "kernel-1:5.14.0-1.el9.x86_64"}},
+			},
+			err: "package kernel is both listed and pinned",
+		},
+		{
+			name: "NEVRA listed and pinned",
+			fix: hotfix{
+				Packages: []string{"kernel-5.14.0-1.el9.x86_64"},
+				Pins:     []hotfixPin{{NEVRA: "kernel-5.14.0-1.el9.x86_64"}},
+			},
+			err: "package kernel is both listed and pinned",
+		},
+		{
+			name: "invalid pin",
+			fix: hotfix{
+				Pins: []hotfixPin{{NEVRA: "kernel"}},
+			},
+			err: "invalid NEVRA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHotfix(tt.fix)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckHotfixPins(t *testing.T) {
+	pkgs := []cosa.HotfixPackage{
+		{Nevra: "kernel-0:5.14.0-1.el9.x86_64", Sha256: "aaaa"},
+		{Nevra: "shim-x64-2:15.8-1.x86_64", Sha256: "bbbb"},
+		{Nevra: "ignition-0:2.17.0-1.el9.x86_64", Sha256: "cccc"},
+	}
+	tests := []struct {
+		name string
+		pins []hotfixPin
+		err  string
+	}{
+		{
+			name: "no pins",
+		},
+		{
+			name: "implicit epoch",
+			pins: []hotfixPin{{NEVRA: "kernel-5.14.0-1.el9.x86_64", Sha256: "aaaa"}},
+		},
+		{
+			name: "explicit epoch",
+			pins: []hotfixPin{{NEVRA: "shim-x64-2:15.8-1.x86_64"}},
+		},
+		{
+			name: "zero epoch",
+			pins: []hotfixPin{{NEVRA: "ignition-0:2.17.0-1.el9.x86_64", Sha256: "cccc"}},
+		},
+		{
+			name: "missing package",
+			pins: []hotfixPin{{NEVRA: "rpm-ostree-2024.1-1.el9.x86_64"}},
+			err:  "repo fixes didn't serve pinned package rpm-ostree-2024.1-1.el9.x86_64",
+		},
+		{
+			name: "wrong epoch",
+			pins: []hotfixPin{{NEVRA: "shim-x64-15.8-1.x86_64"}},
+			err:  "didn't serve pinned package shim-x64-15.8-1.x86_64",
+		},
+		{
+			name: "wrong arch",
+			pins: []hotfixPin{{NEVRA: "kernel-5.14.0-1.el9.aarch64"}},
+			err:  "didn't serve pinned package kernel-5.14.0-1.el9.aarch64",
+		},
+		{
+			name: "digest mismatch",
+			pins: []hotfixPin{{NEVRA: "kernel-5.14.0-1.el9.x86_64", Sha256: "dddd"}},
+			err:  "pinned package kernel-5.14.0-1.el9.x86_64: sha256 mismatch: expected dddd, found aaaa",
+		},
+		{
+			name: "invalid pin",
+			pins: []hotfixPin{{NEVRA: "kernel"}},
+			err:  "invalid NEVRA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHotfixPins(hotfix{Repo: "fixes", Pins: tt.pins}, pkgs)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
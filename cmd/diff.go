@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+	"github.com/spf13/cobra"
+)
+
+type DiffOptions struct {
+	Workdir string
+	Arch    string
+	JSON    bool
+}
+
+var (
+	diffOpts DiffOptions
+
+	cmdDiff = &cobra.Command{
+		Use:   "diff BUILDA BUILDB",
+		Short: "cosa diff [options] BUILDA BUILDB",
+		Long: "Compare two builds: package additions, removals and version " +
+			"changes, advisories, artifact sizes, and the kernel and config " +
+			"git revisions. Either build can be \"latest\".",
+		Args: cobra.ExactArgs(2),
+		RunE: runDiffCmd,
+	}
+)
+
+func init() {
+	cmdDiff.Flags().StringVar(&diffOpts.Workdir, "workdir", ".",
+		"Path to workdir")
+	cmdDiff.Flags().StringVar(&diffOpts.Arch, "arch", "",
+		"Architecture of the builds (default the builder arch)")
+	cmdDiff.Flags().BoolVar(&diffOpts.JSON, "json", false,
+		"Output the differences as JSON")
+}
+
+// readDiffBuild reads the metadata of a build of the workdir.
+func readDiffBuild(buildsDir, id, arch string) (*cosa.Build, *cosa.CommitMeta, error) {
+	if id == "latest" {
+		id = ""
+	}
+	b, dir, err := cosa.ReadBuild(buildsDir, id, arch)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := cosa.ReadCommitMeta(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, c, nil
+}
+
+func runDiffCmd(c *cobra.Command, args []string) error {
+	buildsDir := filepath.Join(diffOpts.Workdir, "builds")
+	from, fromCommit, err := readDiffBuild(buildsDir, args[0], diffOpts.Arch)
+	if err != nil {
+		return err
+	}
+	to, toCommit, err := readDiffBuild(buildsDir, args[1], diffOpts.Arch)
+	if err != nil {
+		return err
+	}
+
+	d := cosa.DiffBuilds(from, fromCommit, to, toCommit)
+	if diffOpts.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(d)
+	}
+	printBuildDiff(os.Stdout, d)
+	return nil
+}
+
+// formatSize returns a size in bytes in a human-readable form.
+func formatSize(size int64) string {
+	const unit = 1024
+	abs := size
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := abs / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatSizeDelta returns a signed change in size in a human-readable form.
+func formatSizeDelta(delta int64) string {
+	if delta > 0 {
+		return "+" + formatSize(delta)
+	}
+	return formatSize(delta)
+}
+
+func printBuildDiff(w io.Writer, d *cosa.BuildDiff) {
+	fmt.Fprintf(w, "Diff from %s to %s (%s)\n", d.From, d.To, d.Arch)
+	for _, v := range []struct {
+		title  string
+		change *cosa.ValueChange
+	}{
+		{"OSTree commit", d.OstreeCommit},
+		{"Config git revision", d.ConfigGitRev},
+		{"Kernel", d.Kernel},
+	} {
+		if v.change != nil {
+			fmt.Fprintf(w, "%s: %s -> %s\n", v.title, v.change.From, v.change.To)
+		}
+	}
+
+	p := d.Packages
+	fmt.Fprintf(w, "\nPackages: %d added, %d removed, %d changed\n", len(p.Added), len(p.Removed), len(p.Changed))
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, pkg := range p.Added {
+		fmt.Fprintf(tw, "  added\t%s\n", pkg.NEVRA())
+	}
+	for _, pkg := range p.Removed {
+		fmt.Fprintf(tw, "  removed\t%s\n", pkg.NEVRA())
+	}
+	for _, change := range p.Changed {
+		verb := "upgraded"
+		if change.Downgrade {
+			verb = "downgraded"
+		}
+		fmt.Fprintf(tw, "  %s\t%s.%s\t%s -> %s\n", verb, change.Name, change.Arch, change.From, change.To)
+	}
+	tw.Flush()
+
+	a := d.Advisories
+	fmt.Fprintf(w, "\nAdvisories: %d added, %d removed\n", len(a.Added), len(a.Removed))
+	for _, id := range a.Added {
+		fmt.Fprintf(w, "  added   %s\n", id)
+	}
+	for _, id := range a.Removed {
+		fmt.Fprintf(w, "  removed %s\n", id)
+	}
+
+	fmt.Fprintf(w, "\nArtifacts:\n")
+	tw = tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, change := range d.Artifacts {
+		switch {
+		case change.FromSize == 0:
+			fmt.Fprintf(tw, "  %s\tadded\t%s\n", change.Name, formatSize(change.ToSize))
+		case change.ToSize == 0:
+			fmt.Fprintf(tw, "  %s\tremoved\t%s\n", change.Name, formatSize(change.FromSize))
+		default:
+			fmt.Fprintf(tw, "  %s\t%s -> %s\t%+.1f%% (%s)\n", change.Name,
+				formatSize(change.FromSize), formatSize(change.ToSize),
+				float64(change.Delta())*100/float64(change.FromSize), formatSizeDelta(change.Delta()))
+		}
+	}
+	tw.Flush()
+}
+
+// execute the cmdDiff cobra command
+func runDiff(argv []string) error {
+	cmdDiff.SetArgs(argv)
+	return cmdDiff.Execute()
+}
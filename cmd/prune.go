@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+	"github.com/spf13/cobra"
+)
+
+// defaultKeepLastN is the number of untagged builds kept by default; `cosa
+// build` prunes with it too.
+const defaultKeepLastN = 3
+
+type PruneOptions struct {
+	Workdir           string
+	DryRun            bool
+	PkgCache          bool
+	KeepLastN         int
+	KeepNewerThan     string
+	KeepTagged        bool
+	Arches            []string
+	ArchKeepLastN     map[string]int
+	ArchKeepNewerThan map[string]string
+	Builds            []string
+}
+
+var (
+	pruneOpts PruneOptions
+
+	cmdPrune = &cobra.Command{
+		Use:   "prune",
+		Short: "cosa prune [options]",
+		Long: "Remove previous builds according to a retention policy. " +
+			"DO NOT USE on production pipelines.\n\n" +
+			"A build is kept if it is one of the --keep-last-n newest " +
+			"untagged builds, if it is younger than --keep-newer-than, or " +
+			"if it is tagged with `cosa tag`. The policy applies separately " +
+			"to each arch, and can be overridden per arch.",
+		Args: cobra.ExactArgs(0),
+		RunE: runPruneCmd,
+	}
+)
+
+func init() {
+	cmdPrune.Flags().StringVar(&pruneOpts.Workdir, "workdir", ".",
+		"Path to workdir")
+	cmdPrune.Flags().BoolVar(&pruneOpts.DryRun, "dry-run", false,
+		"Don't actually delete anything, show what would be pruned")
+	cmdPrune.Flags().BoolVar(&pruneOpts.PkgCache, "pkgcache", false,
+		"Prune refs packages from the pkgcache")
+	cmdPrune.Flags().IntVar(&pruneOpts.KeepLastN, "keep-last-n", defaultKeepLastN,
+		"Number of untagged builds to keep (0 for all unless --keep-newer-than is set)")
+	cmdPrune.Flags().StringVar(&pruneOpts.KeepNewerThan, "keep-newer-than", "",
+		"Keep builds younger than this age, e.g. 36h or 14d")
+	cmdPrune.Flags().BoolVar(&pruneOpts.KeepTagged, "keep-tagged", true,
+		"Keep tagged builds; with --keep-tagged=false, the tags of pruned builds are deleted")
+	cmdPrune.Flags().StringSliceVar(&pruneOpts.Arches, "arch", nil,
+		"Only prune builds of this arch; can be specified multiple times")
+	cmdPrune.Flags().StringToIntVar(&pruneOpts.ArchKeepLastN, "arch-keep-last-n", nil,
+		"Override --keep-last-n for an arch, e.g. aarch64=1")
+	cmdPrune.Flags().StringToStringVar(&pruneOpts.ArchKeepNewerThan, "arch-keep-newer-than", nil,
+		"Override --keep-newer-than for an arch, e.g. aarch64=7d")
+	cmdPrune.Flags().StringArrayVar(&pruneOpts.Builds, "build", nil,
+		"Explicitly prune BUILDID; can be specified multiple times")
+
+	cmdPrune.MarkFlagsMutuallyExclusive("build", "keep-last-n")
+	cmdPrune.MarkFlagsMutuallyExclusive("build", "keep-newer-than")
+}
+
+// parseAge parses a duration, accepting days with a d suffix.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// prunePolicy returns the policy described by the options.
+func prunePolicy(opts *PruneOptions) (cosa.PrunePolicy, error) {
+	p := cosa.PrunePolicy{
+		Arches:      opts.Arches,
+		PruneTagged: !opts.KeepTagged,
+		Builds:      opts.Builds,
+	}
+	if opts.KeepLastN < 0 {
+		return p, fmt.Errorf("invalid --keep-last-n %d", opts.KeepLastN)
+	}
+	p.KeepLastN = opts.KeepLastN
+	age, err := parseAge(opts.KeepNewerThan)
+	if err != nil {
+		return p, err
+	}
+	p.KeepNewerThan = age
+
+	p.ArchRetention = map[string]cosa.Retention{}
+	for arch, n := range opts.ArchKeepLastN {
+		r, ok := p.ArchRetention[arch]
+		if !ok {
+			r = p.Retention
+		}
+		r.KeepLastN = n
+		p.ArchRetention[arch] = r
+	}
+	for arch, s := range opts.ArchKeepNewerThan {
+		age, err := parseAge(s)
+		if err != nil {
+			return p, fmt.Errorf("--arch-keep-newer-than %s: %w", arch, err)
+		}
+		r, ok := p.ArchRetention[arch]
+		if !ok {
+			r = p.Retention
+		}
+		r.KeepNewerThan = age
+		p.ArchRetention[arch] = r
+	}
+	return p, nil
+}
+
+// printPrunePlan shows the builds that are pruned, prefixed with "-", kept,
+// or partially pruned, prefixed with "~".
+func printPrunePlan(w io.Writer, decisions []cosa.PruneDecision) {
+	for _, d := range decisions {
+		var tags string
+		if len(d.Tags) > 0 {
+			tags = fmt.Sprintf(" [tagged: %s]", strings.Join(d.Tags, ", "))
+		}
+		switch {
+		case d.Removed():
+			fmt.Fprintf(w, "- %s (%s)%s\n", d.ID, strings.Join(d.Prune, ", "), tags)
+		case len(d.Prune) > 0:
+			fmt.Fprintf(w, "~ %s (%s; pruning %s)%s\n", d.ID, strings.Join(d.Keep, ", "), strings.Join(d.Prune, ", "), tags)
+		default:
+			fmt.Fprintf(w, "  %s (%s)%s\n", d.ID, strings.Join(d.Keep, ", "), tags)
+		}
+	}
+}
+
+func runPruneCmd(c *cobra.Command, args []string) error {
+	if pruneOpts.PkgCache {
+		return prunePkgCache(pruneOpts.Workdir)
+	}
+
+	policy, err := prunePolicy(&pruneOpts)
+	if err != nil {
+		return err
+	}
+	buildsDir := filepath.Join(pruneOpts.Workdir, "builds")
+	if _, err := os.Stat(buildsDir); err != nil {
+		return fmt.Errorf("no builds/ dir found: %w", err)
+	}
+
+	plan := func(b *cosa.BuildsJSON) ([]cosa.PruneDecision, error) {
+		local, err := cosa.ScanLocalBuilds(buildsDir)
+		if err != nil {
+			return nil, err
+		}
+		return cosa.PlanPrune(local, b, policy, time.Now())
+	}
+
+	if pruneOpts.DryRun {
+		b, err := cosa.ReadBuildsJSON(buildsDir)
+		if err == cosa.ErrNoBuildsFound {
+			b = cosa.NewBuildsJSON()
+		} else if err != nil {
+			return err
+		}
+		decisions, err := plan(b)
+		if err != nil {
+			return err
+		}
+		printPrunePlan(os.Stdout, decisions)
+		return nil
+	}
+
+	var decisions []cosa.PruneDecision
+	var latest string
+	err = cosa.UpdateBuildsJSON(buildsDir, func(b *cosa.BuildsJSON) error {
+		var err error
+		decisions, err = plan(b)
+		if err != nil {
+			return err
+		}
+		b.ApplyPrune(decisions)
+		b.BumpTimestamp()
+		if len(b.Builds) > 0 {
+			latest = b.Builds[0].ID
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	latestLink := filepath.Join(buildsDir, "latest")
+	if latest != "" {
+		// replace the link atomically, like `ln -Tsf`
+		tmpLink := latestLink + ".tmp"
+		os.Remove(tmpLink)
+		if err := os.Symlink(latest, tmpLink); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpLink, latestLink); err != nil {
+			return err
+		}
+	} else if err := os.Remove(latestLink); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// now delete the pruned build dirs, which are no longer recorded
+	failed := false
+	for _, d := range decisions {
+		if len(d.Prune) == 0 {
+			continue
+		}
+		if len(d.Tags) > 0 && d.Removed() {
+			fmt.Printf("Deleted tags %s of build %s\n", strings.Join(d.Tags, ", "), d.ID)
+		}
+		if err := pruneBuild(pruneOpts.Workdir, d); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("failed to prune some builds")
+	}
+	return nil
+}
+
+// pruneBuild deletes the pruned arches of a build, and the ref of the build
+// in the local repo if it's pruned for the builder arch.
+func pruneBuild(workdir string, d cosa.PruneDecision) error {
+	buildDir := filepath.Join(workdir, "builds", d.ID)
+	var dirs []string
+	if d.Removed() {
+		fmt.Printf("Pruning build %s\n", d.ID)
+		dirs = []string{buildDir}
+	} else {
+		fmt.Printf("Pruning build %s for %s\n", d.ID, strings.Join(d.Prune, ", "))
+		for _, arch := range d.Prune {
+			dirs = append(dirs, filepath.Join(buildDir, arch))
+		}
+	}
+
+	for _, arch := range d.Prune {
+		if arch != cosa.BuilderArch() {
+			continue
+		}
+		cmd := exec.Command("ostree", "--repo="+filepath.Join(workdir, "tmp/repo"), "refs", "--delete", d.ID)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to delete ref of build %s: %w", d.ID, err)
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheBranch returns the pkgcache branch of a package, see
+// rpmostree_nevra_to_cache_branch() in rpm-ostree.
+func cacheBranch(pkg cosa.Package) string {
+	quote := func(s string) string {
+		var r strings.Builder
+		for _, c := range s {
+			switch {
+			case c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+				r.WriteRune(c)
+			case c == '_':
+				r.WriteString("__")
+			default:
+				fmt.Fprintf(&r, "%02X", c)
+			}
+		}
+		return r.String()
+	}
+
+	evr := pkg.Version + "-" + pkg.Release
+	if pkg.Epoch != "0" {
+		evr = pkg.Epoch + "_3A" + evr
+	}
+	return "rpmostree/pkg/" + pkg.Name + "/" + evr + "." + quote(pkg.Arch)
+}
+
+// prunePkgCache deletes the refs of the pkgcache not modified in 30 days,
+// except the packages of the latest build.
+func prunePkgCache(workdir string) error {
+	keep := map[string]bool{}
+	b, err := cosa.ReadBuildsJSON(filepath.Join(workdir, "builds"))
+	if err != nil && err != cosa.ErrNoBuildsFound {
+		return err
+	}
+	if err == nil && len(b.Builds) > 0 {
+		meta, err := cosa.ReadCommitMeta(filepath.Join(workdir, "builds/latest", cosa.BuilderArch()))
+		if err != nil {
+			return err
+		}
+		for _, pkg := range meta.Packages {
+			keep[cacheBranch(pkg)] = true
+		}
+	}
+
+	repo := filepath.Join(workdir, "cache/pkgcache-repo")
+	heads := filepath.Join(repo, "refs/heads")
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	return filepath.WalkDir(filepath.Join(heads, "rpmostree/pkg"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		// only prune the refs not modified recently, to go faster
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+		ref, err := filepath.Rel(heads, path)
+		if err != nil {
+			return err
+		}
+		if keep[ref] {
+			return nil
+		}
+		fmt.Printf("Deleted %s\n", ref)
+		cmd := exec.Command("sudo", "ostree", "refs", "--repo="+repo, "--delete", ref)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to delete %s: %v\n", ref, err)
+		}
+		return nil
+	})
+}
+
+// execute the cmdPrune cobra command
+func runPrune(argv []string) error {
+	cmdPrune.SetArgs(argv)
+	return cmdPrune.Execute()
+}
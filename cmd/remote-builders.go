@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// remoteSessionLabel labels the containers of remote sessions, so that
+// they can be told apart from other containers on the builders.
+const remoteSessionLabel = "coreos-assembler.remote-session"
+
+// remoteBuilder is an element in remote-builders.yaml, which describes a
+// podman remote shared for remote sessions.
+type remoteBuilder struct {
+	// Name of the builder
+	Name string `yaml:"name"`
+	// URL of the podman socket, e.g. ssh://core@host/run/podman/podman.sock
+	URL string `yaml:"url"`
+	// Path to the SSH key used to connect to the builder (optional)
+	Identity string `yaml:"identity,omitempty"`
+	// Architecture of the builder
+	Arch string `yaml:"arch"`
+	// Number of remote sessions the builder can run at once; 0 means
+	// unlimited
+	Capacity int `yaml:"capacity,omitempty"`
+}
+
+type remoteBuildersConfig struct {
+	Builders []remoteBuilder `yaml:"builders"`
+}
+
+// remoteSession is a remote session created on a builder of the pool. It's
+// recorded so that it can be resumed or cleaned up after its builder
+// rebooted.
+type remoteSession struct {
+	ID         string    `json:"id"`
+	Builder    string    `json:"builder"`
+	Arch       string    `json:"arch"`
+	Image      string    `json:"image"`
+	Expiration string    `json:"expiration"`
+	Workdir    string    `json:"workdir"`
+	Created    time.Time `json:"created"`
+}
+
+// remoteConfigDir returns the directory of the remote builders config and
+// of the remote sessions records.
+func remoteConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "coreos-assembler"), nil
+}
+
+// loadRemoteBuilders returns the builders of the pool, or nil if no pool
+// is configured. The config is read from $COREOS_ASSEMBLER_REMOTE_BUILDERS,
+// defaulting to remote-builders.yaml in the user config directory.
+func loadRemoteBuilders() ([]remoteBuilder, error) {
+	path := os.Getenv("COREOS_ASSEMBLER_REMOTE_BUILDERS")
+	if path == "" {
+		dir, err := remoteConfigDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(dir, "remote-builders.yaml")
+	}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var config remoteBuildersConfig
+	if err := yaml.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("failed to deserialize %s: %w", path, err)
+	}
+	names := map[string]bool{}
+	for i, b := range config.Builders {
+		if b.Name == "" || b.URL == "" || b.Arch == "" {
+			return nil, fmt.Errorf("%s: builders require a name, url and arch", path)
+		}
+		if names[b.Name] {
+			return nil, fmt.Errorf("%s: duplicate builder %s", path, b.Name)
+		}
+		names[b.Name] = true
+		if strings.HasPrefix(b.Identity, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			config.Builders[i].Identity = filepath.Join(home, b.Identity[2:])
+		}
+	}
+	return config.Builders, nil
+}
+
+func findRemoteBuilder(builders []remoteBuilder, name string) (remoteBuilder, error) {
+	for _, b := range builders {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return remoteBuilder{}, fmt.Errorf("remote builder %s not configured", name)
+}
+
+// podmanArgs returns the podman arguments to connect to the builder.
+func (b *remoteBuilder) podmanArgs() []string {
+	args := []string{"--remote", "--url", b.URL}
+	if b.Identity != "" {
+		args = append(args, "--identity", b.Identity)
+	}
+	return args
+}
+
+// load returns the number of remote sessions running on the builder.
+func (b *remoteBuilder) load() (int, error) {
+	args := append(b.podmanArgs(), "ps", "-q", "--filter=label="+remoteSessionLabel)
+	out, err := exec.Command("podman", args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions on %s: %w", b.Name, err)
+	}
+	return len(strings.Fields(string(out))), nil
+}
+
+// pickRemoteBuilder returns the least loaded builder of the architecture
+// with spare capacity, relative to its capacity. load holds the number of
+// sessions of each reachable builder; the others are skipped. Builders
+// without a capacity are never full, and are ranked like builders of
+// capacity 1. Ties go to the builder listed first.
+func pickRemoteBuilder(builders []remoteBuilder, arch string, load map[string]int) (remoteBuilder, error) {
+	var best *remoteBuilder
+	var bestLoad float64
+	found := false
+	for i := range builders {
+		b := &builders[i]
+		if b.Arch != arch {
+			continue
+		}
+		found = true
+		n, ok := load[b.Name]
+		if !ok || (b.Capacity > 0 && n >= b.Capacity) {
+			continue
+		}
+		capacity := b.Capacity
+		if capacity == 0 {
+			capacity = 1
+		}
+		l := float64(n) / float64(capacity)
+		if best == nil || l < bestLoad {
+			best = b
+			bestLoad = l
+		}
+	}
+	if !found {
+		return remoteBuilder{}, fmt.Errorf("no remote builder for arch %s", arch)
+	}
+	if best == nil {
+		return remoteBuilder{}, fmt.Errorf("all remote builders for arch %s are full or unreachable", arch)
+	}
+	return *best, nil
+}
+
+// selectRemoteBuilder queries the load of the builders of the architecture
+// and returns the least loaded one.
+func selectRemoteBuilder(builders []remoteBuilder, arch string) (remoteBuilder, error) {
+	load := map[string]int{}
+	for _, b := range builders {
+		if b.Arch != arch {
+			continue
+		}
+		n, err := b.load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping builder: %v\n", err)
+			continue
+		}
+		load[b.Name] = n
+	}
+	return pickRemoteBuilder(builders, arch, load)
+}
+
+// newRemoteSessionID returns the name of the container of a new remote
+// session. Unlike container IDs, it survives recreating the container.
+func newRemoteSessionID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "cosa-session-" + hex.EncodeToString(buf), nil
+}
+
+func remoteSessionsPath() (string, error) {
+	dir, err := remoteConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "remote-sessions.json"), nil
+}
+
+// loadRemoteSessions returns the recorded remote sessions.
+func loadRemoteSessions() ([]remoteSession, error) {
+	path, err := remoteSessionsPath()
+	if err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var sessions []remoteSession
+	if err := json.Unmarshal(contents, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to deserialize %s: %w", path, err)
+	}
+	return sessions, nil
+}
+
+// saveRemoteSessions replaces the recorded remote sessions. Callers must
+// hold the lock of the records, see updateRemoteSessions.
+func saveRemoteSessions(path string, sessions []remoteSession) error {
+	contents, err := json.MarshalIndent(sessions, "", "    ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// updateRemoteSessions calls update on the recorded remote sessions and
+// records the sessions it returns, if it changed them. Concurrent updates,
+// e.g. from sessions created at once on a pool, are serialized by an
+// exclusive flock(2) on remote-sessions.json.lock.
+func updateRemoteSessions(update func([]remoteSession) ([]remoteSession, bool)) error {
+	path, err := remoteSessionsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	// closing the file releases the lock
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", lock.Name(), err)
+	}
+
+	sessions, err := loadRemoteSessions()
+	if err != nil {
+		return err
+	}
+	sessions, changed := update(sessions)
+	if !changed {
+		return nil
+	}
+	return saveRemoteSessions(path, sessions)
+}
+
+// findRemoteSession returns the recorded remote session with the ID.
+func findRemoteSession(id string) (*remoteSession, error) {
+	sessions, err := loadRemoteSessions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i].ID == id {
+			return &sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// recordRemoteSession adds or replaces the record of a remote session.
+func recordRemoteSession(s remoteSession) error {
+	return updateRemoteSessions(func(sessions []remoteSession) ([]remoteSession, bool) {
+		return append(removeRemoteSession(sessions, s.ID), s), true
+	})
+}
+
+// forgetRemoteSessions removes the records of the remote sessions with the
+// IDs.
+func forgetRemoteSessions(ids map[string]bool) error {
+	return updateRemoteSessions(func(sessions []remoteSession) ([]remoteSession, bool) {
+		var kept []remoteSession
+		for _, s := range sessions {
+			if !ids[s.ID] {
+				kept = append(kept, s)
+			}
+		}
+		return kept, len(kept) != len(sessions)
+	})
+}
+
+func removeRemoteSession(sessions []remoteSession, id string) []remoteSession {
+	var ret []remoteSession
+	for _, s := range sessions {
+		if s.ID != id {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// remoteSessionRunning returns true if the container of the session is
+// running on its builder.
+func remoteSessionRunning(b remoteBuilder, id string) (bool, error) {
+	args := append(b.podmanArgs(), "ps", "-q", fmt.Sprintf("--filter=name=^%s$", id))
+	out, err := exec.Command("podman", args...).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to query %s on %s: %w", id, b.Name, err)
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
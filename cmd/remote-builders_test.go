@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPickRemoteBuilder(t *testing.T) {
+	builders := []remoteBuilder{
+		{Name: "x1", Arch: "x86_64", Capacity: 4},
+		{Name: "x2", Arch: "x86_64", Capacity: 2},
+		{Name: "x3", Arch: "x86_64"},
+		{Name: "a1", Arch: "aarch64", Capacity: 1},
+	}
+	tests := []struct {
+		name string
+		arch string
+		load map[string]int
+		want string
+		err  string
+	}{
+		{
+			name: "least loaded relative to capacity",
+			arch: "x86_64",
+			load: map[string]int{"x1": 2, "x2": 0, "x3": 1},
+			want: "x2",
+		},
+		{
+			name: "unlimited capacity ranks by sessions",
+			arch: "x86_64",
+			load: map[string]int{"x1": 1, "x2": 1, "x3": 0},
+			want: "x3",
+		},
+		{
+			name: "unlimited capacity ranks like capacity 1",
+			arch: "x86_64",
+			load: map[string]int{"x2": 1, "x3": 1},
+			want: "x2",
+		},
+		{
+			name: "tie goes to the first builder",
+			arch: "x86_64",
+			load: map[string]int{"x1": 2, "x2": 1},
+			want: "x1",
+		},
+		{
+			name: "full builders are skipped",
+			arch: "x86_64",
+			load: map[string]int{"x1": 3, "x2": 2},
+			want: "x1",
+		},
+		{
+			name: "unreachable builders are skipped",
+			arch: "x86_64",
+			load: map[string]int{"x2": 1},
+			want: "x2",
+		},
+		{
+			name: "arch filter",
+			arch: "aarch64",
+			load: map[string]int{"x1": 0, "x2": 0, "x3": 0, "a1": 0},
+			want: "a1",
+		},
+		{
+			name: "no builder for arch",
+			arch: "s390x",
+			load: map[string]int{"x1": 0},
+			err:  "no remote builder for arch s390x",
+		},
+		{
+			name: "all full or unreachable",
+			arch: "aarch64",
+			load: map[string]int{"a1": 1},
+			err:  "all remote builders for arch aarch64 are full or unreachable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := pickRemoteBuilder(builders, tt.arch, tt.load)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Name != tt.want {
+				t.Errorf("picked %s, want %s", b.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRemoteBuilders(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name     string
+		config   string
+		builders []remoteBuilder
+		err      string
+	}{
+		{
+			name: "valid",
+			config: `builders:
+  - name: x1
+    url: ssh://core@x1/run/podman/podman.sock
+    identity: ~/.ssh/id_x1
+    arch: x86_64
+    capacity: 2
+  - name: a1
+    url: ssh://core@a1/run/podman/podman.sock
+    identity: /etc/ssh/id_a1
+    arch: aarch64
+`,
+			builders: []remoteBuilder{
+				{Name: "x1", URL: "ssh://core@x1/run/podman/podman.sock", Identity: filepath.Join(home, ".ssh/id_x1"), Arch: "x86_64", Capacity: 2},
+				{Name: "a1", URL: "ssh://core@a1/run/podman/podman.sock", Identity: "/etc/ssh/id_a1", Arch: "aarch64"},
+			},
+		},
+		{
+			name: "missing url",
+			config: `builders:
+  - name: x1
+    arch: x86_64
+`,
+			err: "builders require a name, url and arch",
+		},
+		{
+			name: "missing arch",
+			config: `builders:
+  - name: x1
+    url: ssh://core@x1/run/podman/podman.sock
+`,
+			err: "builders require a name, url and arch",
+		},
+		{
+			name: "duplicate name",
+			config: `builders:
+  - name: x1
+    url: ssh://core@x1/run/podman/podman.sock
+    arch: x86_64
+  - name: x1
+    url: ssh://core@x2/run/podman/podman.sock
+    arch: x86_64
+`,
+			err: "duplicate builder x1",
+		},
+		{
+			name:   "malformed",
+			config: "builders: {",
+			err:    "failed to deserialize",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "remote-builders.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("COREOS_ASSEMBLER_REMOTE_BUILDERS", path)
+			builders, err := loadRemoteBuilders()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(builders) != fmt.Sprint(tt.builders) {
+				t.Errorf("got %+v, want %+v", builders, tt.builders)
+			}
+		})
+	}
+
+	t.Run("no config", func(t *testing.T) {
+		t.Setenv("COREOS_ASSEMBLER_REMOTE_BUILDERS", filepath.Join(t.TempDir(), "remote-builders.yaml"))
+		builders, err := loadRemoteBuilders()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if builders != nil {
+			t.Errorf("got %+v, want no builders", builders)
+		}
+	})
+}
+
+func TestRecordRemoteSessionConcurrently(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- recordRemoteSession(remoteSession{ID: fmt.Sprintf("cosa-session-%d", i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := loadRemoteSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != n {
+		t.Fatalf("recorded %d sessions, want %d", len(sessions), n)
+	}
+
+	if err := forgetRemoteSessions(map[string]bool{"cosa-session-0": true}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := findRemoteSession("cosa-session-0"); err != nil || s != nil {
+		t.Errorf("session still recorded: %v, %v", s, err)
+	}
+}
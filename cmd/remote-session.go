@@ -7,12 +7,18 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
 )
 
 type RemoteSessionOptions struct {
 	CreateImage      string
 	CreateExpiration string
 	CreateWorkdir    string
+	CreateArch       string
+	CreateBuilder    string
+	PSAll            bool
 	SyncQuiet        bool
 }
 
@@ -22,7 +28,10 @@ var (
 	cmdRemoteSession = &cobra.Command{
 		Use:   "remote-session",
 		Short: "cosa remote-session [command]",
-		Long:  "Initiate and use remote sessions for COSA execution.",
+		Long: "Initiate and use remote sessions for COSA execution. " +
+			"Sessions run on the podman remote in CONTAINER_HOST, or on a " +
+			"pool of builders listed in remote-builders.yaml in the user " +
+			"config directory (or the file in COREOS_ASSEMBLER_REMOTE_BUILDERS).",
 	}
 
 	cmdRemoteSessionCreate = &cobra.Command{
@@ -30,7 +39,9 @@ var (
 		Short: "Create a remote session",
 		Long: "Create a remote session. This command will print an ID to " +
 			"STDOUT that should be set in COREOS_ASSEMBLER_REMOTE_SESSION " +
-			"environment variable for later commands to use.",
+			"environment variable for later commands to use. If a pool of " +
+			"builders is configured, the session is created on the least " +
+			"loaded builder of the architecture.",
 		Args:    cobra.ExactArgs(0),
 		PreRunE: preRunCheckEnv,
 		RunE:    runCreate,
@@ -56,14 +67,37 @@ var (
 	}
 
 	cmdRemoteSessionPS = &cobra.Command{
-		Use:     "ps",
-		Short:   "Check if the remote session is running",
-		Long:    "Check if the remote session is running.",
+		Use:   "ps",
+		Short: "Check if the remote session is running",
+		Long: "Check if the remote session is running. With --all, list " +
+			"the remote sessions of all the builders.",
 		Args:    cobra.ExactArgs(0),
 		PreRunE: preRunCheckEnv,
 		RunE:    runPS,
 	}
 
+	cmdRemoteSessionResume = &cobra.Command{
+		Use:   "resume",
+		Short: "Resume a remote session",
+		Long: "Resume a remote session created on a builder of the pool, " +
+			"recreating its container if it's no longer running, e.g. " +
+			"after the builder rebooted. The content of the session's " +
+			"working directory is lost.",
+		Args:    cobra.ExactArgs(0),
+		PreRunE: preRunCheckEnv,
+		RunE:    runResume,
+	}
+
+	cmdRemoteSessionPrune = &cobra.Command{
+		Use:   "prune",
+		Short: "Clean up the remote sessions which stopped",
+		Long: "Clean up the remote sessions created on builders of the pool " +
+			"which are no longer running, e.g. after the builder rebooted.",
+		Args:    cobra.ExactArgs(0),
+		PreRunE: preRunCheckEnv,
+		RunE:    runPruneSessions,
+	}
+
 	cmdRemoteSessionSync = &cobra.Command{
 		Use:   "sync",
 		Short: "sync files/directories to/from the remote",
@@ -111,33 +145,64 @@ func envVarError(v string, required bool) error {
 // before each subcommand to perform the checks.
 func preRunCheckEnv(c *cobra.Command, args []string) error {
 	// We need to make sure that the CONTAINER_HOST env var
-	// is set for all commands. This is used for `podman --remote`.
+	// is set for all commands, unless a pool of builders is
+	// configured. This is used for `podman --remote`.
 	// We could also check `CONTAINER_SSHKEY` key here but it's not
 	// strictly required (user could be using ssh-agent).
-	if !envVarIsSet("CONTAINER_HOST") {
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return err
+	}
+	if len(builders) == 0 && !envVarIsSet("CONTAINER_HOST") {
 		return envVarError("CONTAINER_HOST", true)
 	}
 	// We need to check COREOS_ASSEMBLER_REMOTE_SESSION. For create
-	// we need to make sure it's not set. For all other commands we
+	// we need to make sure it's not set. For commands operating on
+	// all sessions it doesn't matter. For all other commands we
 	// need to make sure it is set.
 	remoteSessionVarIsSet := envVarIsSet("COREOS_ASSEMBLER_REMOTE_SESSION")
 	if c.Use == "create" && remoteSessionVarIsSet {
 		return envVarError("COREOS_ASSEMBLER_REMOTE_SESSION", false)
+	} else if c.Use == "prune" || (c.Use == "ps" && remoteSessionOpts.PSAll) {
+		return nil
 	} else if c.Use != "create" && !remoteSessionVarIsSet {
 		return envVarError("COREOS_ASSEMBLER_REMOTE_SESSION", true)
 	}
 	return nil
 }
 
-// Creates a "remote session" on the remote. This just creates a
-// container on the remote and prints to STDOUT the container ID.
-// The user is then expected to store this ID in the
-// COREOS_ASSEMBLER_REMOTE_SESSION environment variable.
-func runCreate(c *cobra.Command, args []string) error {
-	podmanargs := []string{"--remote", "run", "--rm", "-d",
+// remoteSessionPodmanArgs returns the podman arguments to connect to the
+// remote of the session: its builder if it was created on a builder of
+// the pool, else CONTAINER_HOST.
+func remoteSessionPodmanArgs(session string) ([]string, error) {
+	s, err := findRemoteSession(session)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		if !envVarIsSet("CONTAINER_HOST") {
+			return nil, envVarError("CONTAINER_HOST", true)
+		}
+		return []string{"--remote"}, nil
+	}
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return nil, err
+	}
+	b, err := findRemoteBuilder(builders, s.Builder)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", session, err)
+	}
+	return b.podmanArgs(), nil
+}
+
+// remoteSessionRunArgs returns the arguments of `podman run` to create
+// the container of a remote session. extra is passed before the image.
+func remoteSessionRunArgs(image, expiration, workdir string, extra ...string) []string {
+	args := []string{"run", "--rm", "-d",
 		"--pull=always", "--net=host", "--privileged", "--security-opt=label=disable",
-		"--volume", remoteSessionOpts.CreateWorkdir,
-		"--workdir", remoteSessionOpts.CreateWorkdir,
+		"--volume", workdir,
+		"--workdir", workdir,
 		// Mount required volume for buildextend-secex, it will be empty on
 		// non-s390x builders.
 		// See: https://github.com/coreos/coreos-assembler/blob/main/docs/cosa/buildextend-secex.md
@@ -145,33 +210,203 @@ func runCreate(c *cobra.Command, args []string) error {
 		"--uidmap=1000:0:1", "--uidmap=0:1:1000", "--uidmap=1001:1001:64536",
 		"--device=/dev/kvm", "--device=/dev/fuse", "--tmpfs=/tmp",
 		"--init", "--entrypoint=/usr/bin/sleep",
-		remoteSessionOpts.CreateImage,
-		remoteSessionOpts.CreateExpiration}
+		"--label=" + remoteSessionLabel}
+	args = append(args, extra...)
+	return append(args, image, expiration)
+}
+
+// startRemoteSession runs the container of a session recorded on a
+// builder of the pool.
+func startRemoteSession(b remoteBuilder, s remoteSession) error {
+	podmanargs := append(b.podmanArgs(), remoteSessionRunArgs(s.Image, s.Expiration, s.Workdir, "--name", s.ID)...)
 	cmd := exec.Command("podman", podmanargs...)
-	cmd.Stdout = os.Stdout
+	// The container ID isn't the session ID; don't print it
+	cmd.Stdout = io.Discard
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// Creates a "remote session" on the remote. This just creates a
+// container on the remote and prints to STDOUT the container ID.
+// The user is then expected to store this ID in the
+// COREOS_ASSEMBLER_REMOTE_SESSION environment variable.
+//
+// If a pool of builders is configured, the container is created on the
+// least loaded builder of the requested architecture, named after the
+// session ID, and the session is recorded.
+func runCreate(c *cobra.Command, args []string) error {
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return err
+	}
+	if len(builders) == 0 {
+		if remoteSessionOpts.CreateArch != "" || remoteSessionOpts.CreateBuilder != "" {
+			return fmt.Errorf("--arch and --builder require a pool of remote builders")
+		}
+		podmanargs := append([]string{"--remote"}, remoteSessionRunArgs(
+			remoteSessionOpts.CreateImage,
+			remoteSessionOpts.CreateExpiration,
+			remoteSessionOpts.CreateWorkdir)...)
+		cmd := exec.Command("podman", podmanargs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	var b remoteBuilder
+	if remoteSessionOpts.CreateBuilder != "" {
+		b, err = findRemoteBuilder(builders, remoteSessionOpts.CreateBuilder)
+		if err != nil {
+			return err
+		}
+		if remoteSessionOpts.CreateArch != "" && remoteSessionOpts.CreateArch != b.Arch {
+			return fmt.Errorf("remote builder %s is %s, not %s", b.Name, b.Arch, remoteSessionOpts.CreateArch)
+		}
+	} else {
+		arch := remoteSessionOpts.CreateArch
+		if arch == "" {
+			arch = cosa.BuilderArch()
+		}
+		b, err = selectRemoteBuilder(builders, arch)
+		if err != nil {
+			return err
+		}
+	}
+
+	id, err := newRemoteSessionID()
+	if err != nil {
+		return err
+	}
+	s := remoteSession{
+		ID:         id,
+		Builder:    b.Name,
+		Arch:       b.Arch,
+		Image:      remoteSessionOpts.CreateImage,
+		Expiration: remoteSessionOpts.CreateExpiration,
+		Workdir:    remoteSessionOpts.CreateWorkdir,
+		Created:    time.Now().UTC(),
+	}
+	fmt.Fprintf(os.Stderr, "Creating remote session on builder %s\n", b.Name)
+	if err := startRemoteSession(b, s); err != nil {
+		return err
+	}
+	if err := recordRemoteSession(s); err != nil {
+		return err
+	}
+	fmt.Println(s.ID)
+	return nil
+}
+
+// Resumes a "remote session" created on a builder of the pool,
+// recreating its container with the same name and options if
+// it's not running anymore.
+func runResume(c *cobra.Command, args []string) error {
+	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
+	s, err := findRemoteSession(session)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("session %s was not created on a builder of the pool", session)
+	}
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return err
+	}
+	b, err := findRemoteBuilder(builders, s.Builder)
+	if err != nil {
+		return err
+	}
+	running, err := remoteSessionRunning(b, s.ID)
+	if err != nil {
+		return err
+	}
+	if running {
+		fmt.Printf("Session %s is running on builder %s\n", s.ID, b.Name)
+		return nil
+	}
+	// A container left over by a reboot would conflict with the name
+	rmargs := append(b.podmanArgs(), "rm", "-f", "--ignore", s.ID)
+	if err := exec.Command("podman", rmargs...).Run(); err != nil {
+		return fmt.Errorf("failed to remove stopped session %s: %w", s.ID, err)
+	}
+	if err := startRemoteSession(b, *s); err != nil {
+		return err
+	}
+	fmt.Printf("Resumed session %s on builder %s\n", s.ID, b.Name)
+	return nil
+}
+
+// Removes the sessions created on builders of the pool which aren't
+// running anymore, along with their records. Sessions on unreachable
+// builders are kept.
+func runPruneSessions(c *cobra.Command, args []string) error {
+	sessions, err := loadRemoteSessions()
+	if err != nil {
+		return err
+	}
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return err
+	}
+	// the records are only locked while updated, so that sessions can be
+	// created while the builders are queried
+	removed := map[string]bool{}
+	for _, s := range sessions {
+		b, err := findRemoteBuilder(builders, s.Builder)
+		if err != nil {
+			fmt.Printf("Removing session %s: %v\n", s.ID, err)
+			removed[s.ID] = true
+			continue
+		}
+		running, err := remoteSessionRunning(b, s.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: keeping session %s: %v\n", s.ID, err)
+			continue
+		}
+		if running {
+			continue
+		}
+		fmt.Printf("Removing session %s: not running on builder %s\n", s.ID, b.Name)
+		rmargs := append(b.podmanArgs(), "rm", "-f", "--ignore", s.ID)
+		if err := exec.Command("podman", rmargs...).Run(); err != nil {
+			return fmt.Errorf("failed to remove stopped session %s: %w", s.ID, err)
+		}
+		removed[s.ID] = true
+	}
+	return forgetRemoteSessions(removed)
+}
+
 // Destroys the "remote session". In reality it just deletes
 // the container referenced by $COREOS_ASSEMBLER_REMOTE_SESSION.
 func runDestroy(c *cobra.Command, args []string) error {
 	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
-	podmanargs := []string{"--remote", "rm", "-f", session}
+	podmanargs, err := remoteSessionPodmanArgs(session)
+	if err != nil {
+		return err
+	}
+	podmanargs = append(podmanargs, "rm", "-f", session)
 	cmd := exec.Command("podman", podmanargs...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return forgetRemoteSessions(map[string]bool{session: true})
 }
 
 // Executes a command in the "remote session". Mostly just a
 // `podman --remote exec`.
 func runExec(c *cobra.Command, args []string) error {
-	podmanargs := []string{"--remote", "exec", "-i"}
+	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
+	podmanargs, err := remoteSessionPodmanArgs(session)
+	if err != nil {
+		return err
+	}
+	podmanargs = append(podmanargs, "exec", "-i")
 	if isatty() {
 		podmanargs = append(podmanargs, "-t")
 	}
-	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
 	podmanargs = append(podmanargs, session, "cosa")
 	podmanargs = append(podmanargs, args...)
 	cmd := exec.Command("podman", podmanargs...)
@@ -192,10 +427,24 @@ func runExec(c *cobra.Command, args []string) error {
 
 // Executes a `podman --remote ps -a --filter id=<container>`
 // to show the status of the remote running cosa container.
+// Sessions created on a builder of the pool are filtered by
+// name instead, since that's their ID.
 func runPS(c *cobra.Command, args []string) error {
+	if remoteSessionOpts.PSAll {
+		return runPSAll()
+	}
 	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
-	podmanargs := []string{"--remote", "ps", "-a",
-		fmt.Sprintf("--filter=id=%s", session)}
+	podmanargs, err := remoteSessionPodmanArgs(session)
+	if err != nil {
+		return err
+	}
+	filter := fmt.Sprintf("--filter=id=%s", session)
+	if s, err := findRemoteSession(session); err != nil {
+		return err
+	} else if s != nil {
+		filter = fmt.Sprintf("--filter=name=^%s$", session)
+	}
+	podmanargs = append(podmanargs, "ps", "-a", filter)
 	cmd := exec.Command("podman", podmanargs...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -203,6 +452,48 @@ func runPS(c *cobra.Command, args []string) error {
 	return cmd.Run()
 }
 
+// runPSAll shows the remote sessions of every builder of the pool,
+// or of CONTAINER_HOST if no pool is configured.
+func runPSAll() error {
+	builders, err := loadRemoteBuilders()
+	if err != nil {
+		return err
+	}
+	if len(builders) == 0 {
+		podmanargs := []string{"--remote", "ps", "-a", "--filter=label=" + remoteSessionLabel}
+		cmd := exec.Command("podman", podmanargs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+	failed := false
+	for _, b := range builders {
+		fmt.Printf("==> %s (%s, capacity %d)\n", b.Name, b.Arch, b.Capacity)
+		podmanargs := append(b.podmanArgs(), "ps", "-a", "--filter=label="+remoteSessionLabel)
+		cmd := exec.Command("podman", podmanargs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to list sessions on %s: %v\n", b.Name, err)
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("failed to list the sessions of some builders")
+	}
+	return nil
+}
+
+// quoteRsh joins args into a command for rsync --rsh, which splits it on
+// spaces and honors single and double quotes, but not backslashes.
+func quoteRsh(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
 // runSync provides an rsync-like interface that allows
 // files to be copied to/from the remote. It uses
 // `podman --remote exec` as the transport for rsync (see [1])
@@ -214,21 +505,26 @@ func runPS(c *cobra.Command, args []string) error {
 //
 // [1] https://github.com/moby/moby/issues/13660
 func runSync(c *cobra.Command, args []string) error {
+	session := os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION")
 	// check arguments. Need one with pre-pended ':'
 	found := 0
 	for index, arg := range args {
 		if strings.HasPrefix(arg, ":") {
-			args[index] = fmt.Sprintf("%s%s",
-				os.Getenv("COREOS_ASSEMBLER_REMOTE_SESSION"), arg)
+			args[index] = fmt.Sprintf("%s%s", session, arg)
 			found++
 		}
 	}
 	if found != 1 {
 		return fmt.Errorf("Must pass in a single arg with `:` prepended")
 	}
+	podmanargs, err := remoteSessionPodmanArgs(session)
+	if err != nil {
+		return err
+	}
+	rsh := quoteRsh(append(append([]string{"podman"}, podmanargs...), "exec", "-i"))
 	// build command and execute
 	rsyncargs := []string{"-ah", "--no-owner", "--no-group", "--mkpath", "--blocking-io",
-		"--compress", "--rsh", "podman --remote exec -i"}
+		"--compress", "--rsh", rsh}
 	if !remoteSessionOpts.SyncQuiet {
 		rsyncargs = append(rsyncargs, "-v")
 	}
@@ -245,6 +541,8 @@ func init() {
 	cmdRemoteSession.AddCommand(cmdRemoteSessionExec)
 	cmdRemoteSession.AddCommand(cmdRemoteSessionPS)
 	cmdRemoteSession.AddCommand(cmdRemoteSessionSync)
+	cmdRemoteSession.AddCommand(cmdRemoteSessionResume)
+	cmdRemoteSession.AddCommand(cmdRemoteSessionPrune)
 
 	// cmdRemoteSessionCreate options
 	cmdRemoteSessionCreate.Flags().StringVarP(
@@ -257,6 +555,17 @@ func init() {
 	cmdRemoteSessionCreate.Flags().StringVarP(
 		&remoteSessionOpts.CreateWorkdir, "workdir", "", "/srv",
 		"The COSA working directory to use inside the container")
+	cmdRemoteSessionCreate.Flags().StringVarP(
+		&remoteSessionOpts.CreateArch, "arch", "", "",
+		"The architecture of the builder of the pool to use (default the local arch)")
+	cmdRemoteSessionCreate.Flags().StringVarP(
+		&remoteSessionOpts.CreateBuilder, "builder", "", "",
+		"The builder of the pool to use, instead of the least loaded one")
+
+	// cmdRemoteSessionPS options
+	cmdRemoteSessionPS.Flags().BoolVarP(
+		&remoteSessionOpts.PSAll, "all", "", false,
+		"Show the remote sessions of all the builders")
 
 	// cmdRemoteSessionSync options
 	cmdRemoteSessionSync.Flags().BoolVarP(
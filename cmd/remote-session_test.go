@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuoteRsh(t *testing.T) {
+	got := quoteRsh([]string{"podman", "--identity", "/home/a b/.ssh/id_x1", "--url", "ssh://core@x1/run/podman/podman.sock?secure=it's", "exec", "-i"})
+	want := `'podman' '--identity' '/home/a b/.ssh/id_x1' '--url' 'ssh://core@x1/run/podman/podman.sock?secure=it'"'"'s' 'exec' '-i'`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	cosa "github.com/coreos/coreos-assembler/pkg/builds"
+	"github.com/spf13/cobra"
+)
+
+type VerifyOptions struct {
+	Workdir          string
+	URL              string
+	Build            string
+	Arch             string
+	Artifacts        []string
+	SkipUncompressed bool
+	JSON             bool
+}
+
+var (
+	verifyOpts VerifyOptions
+
+	cmdVerify = &cobra.Command{
+		Use:   "verify",
+		Short: "cosa verify [options]",
+		Long: "Verify the artifacts of a build against the sizes and SHA-256 " +
+			"checksums recorded in its meta.json, both compressed and " +
+			"uncompressed. The build is read from the workdir, or from the " +
+			"builds directory at --url (http://, https:// or s3://). For " +
+			"local builds, files of the build directory that meta.json " +
+			"doesn't reference are reported too.",
+		Args: cobra.ExactArgs(0),
+		RunE: runVerifyCmd,
+	}
+)
+
+func init() {
+	cmdVerify.Flags().StringVar(&verifyOpts.Workdir, "workdir", ".",
+		"Path to workdir")
+	cmdVerify.Flags().StringVar(&verifyOpts.URL, "url", "",
+		"URL of a remote builds directory, e.g. s3://bucket/prefix/builds")
+	cmdVerify.Flags().StringVar(&verifyOpts.Build, "build", "",
+		"Build ID (default latest)")
+	cmdVerify.Flags().StringVar(&verifyOpts.Arch, "arch", "",
+		"Architecture of the build (default the builder arch)")
+	cmdVerify.Flags().StringSliceVar(&verifyOpts.Artifacts, "artifact", nil,
+		"Only verify this artifact, e.g. qemu; can be specified multiple times")
+	cmdVerify.Flags().BoolVar(&verifyOpts.SkipUncompressed, "skip-uncompressed", false,
+		"Don't decompress artifacts to verify their uncompressed checksums")
+	cmdVerify.Flags().BoolVar(&verifyOpts.JSON, "json", false,
+		"Output the results as JSON")
+}
+
+// VerifyResult is the outcome of `cosa verify`.
+type VerifyResult struct {
+	Build     string               `json:"build"`
+	Arch      string               `json:"arch"`
+	Location  string               `json:"location"`
+	Artifacts []cosa.ArtifactCheck `json:"artifacts"`
+	// Unreferenced is only computed for local builds.
+	Unreferenced []string `json:"unreferenced,omitempty"`
+}
+
+// OK returns true if no problem was found.
+func (r *VerifyResult) OK() bool {
+	for _, c := range r.Artifacts {
+		if !c.OK() {
+			return false
+		}
+	}
+	return len(r.Unreferenced) == 0
+}
+
+// fileURLFunc returns the URL of a file of a remote builds directory.
+type fileURLFunc func(name string) (string, error)
+
+// remoteFileURL returns the fileURLFunc of the builds directory at rawURL.
+// Objects in S3 are accessed with presigned URLs, which are unsigned if no
+// AWS credentials are configured.
+func remoteFileURL(rawURL string) (fileURLFunc, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		base := strings.TrimSuffix(rawURL, "/") + "/"
+		return func(name string) (string, error) {
+			return base + name, nil
+		}, nil
+	case "s3":
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q, expected http, https or s3", u.Scheme)
+	}
+
+	bucket := u.Host
+	prefix := strings.Trim(u.Path, "/")
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sess.Config.Credentials.Get(); err != nil {
+		sess.Config.Credentials = credentials.AnonymousCredentials
+	}
+	region := aws.StringValue(sess.Config.Region)
+	if region == "" {
+		region, err = s3manager.GetBucketRegion(context.Background(), sess, bucket, "us-east-1")
+		if err != nil {
+			return nil, fmt.Errorf("failed to find region of bucket %s: %w", bucket, err)
+		}
+	}
+	svc := s3.New(sess, aws.NewConfig().WithRegion(region))
+	return func(name string) (string, error) {
+		req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(path.Join(prefix, name)),
+		})
+		return req.Presign(time.Hour)
+	}, nil
+}
+
+// httpGet returns the body of a successful GET of url.
+func httpGet(url string) (io.ReadCloser, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("received a %d error in http response for: %s", res.StatusCode, redactURL(url))
+	}
+	return res.Body, nil
+}
+
+// redactURL strips the query of a URL, which holds the signature of
+// presigned URLs.
+func redactURL(rawURL string) string {
+	if i := strings.Index(rawURL, "?"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// readRemoteBuild reads the meta.json of a build of a remote builds
+// directory, and returns an opener for the files of the build.
+func readRemoteBuild(fileURL fileURLFunc, buildID, arch string) (*cosa.Build, cosa.ArtifactOpener, error) {
+	if buildID == "" {
+		u, err := fileURL(cosa.CosaBuildsJSON)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := httpGet(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+		var b cosa.BuildsJSON
+		if err := json.NewDecoder(body).Decode(&b); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", cosa.CosaBuildsJSON, err)
+		}
+		latest, ok := b.Latest(arch)
+		if !ok {
+			return nil, nil, cosa.ErrNoBuildsFound
+		}
+		buildID = latest
+	}
+
+	u, err := fileURL(path.Join(buildID, arch, cosa.CosaMetaJSON))
+	if err != nil {
+		return nil, nil, err
+	}
+	build, err := cosa.FetchAndParseBuild(u)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read meta.json of build %s: %w", buildID, err)
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		u, err := fileURL(path.Join(buildID, arch, name))
+		if err != nil {
+			return nil, err
+		}
+		return httpGet(u)
+	}
+	return build, open, nil
+}
+
+func runVerifyCmd(c *cobra.Command, args []string) error {
+	arch := verifyOpts.Arch
+	if arch == "" {
+		arch = cosa.BuilderArch()
+	}
+
+	var build *cosa.Build
+	var open cosa.ArtifactOpener
+	var dir string
+	if verifyOpts.URL != "" {
+		fileURL, err := remoteFileURL(verifyOpts.URL)
+		if err != nil {
+			return err
+		}
+		build, open, err = readRemoteBuild(fileURL, verifyOpts.Build, arch)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		build, dir, err = cosa.ReadBuild(filepath.Join(verifyOpts.Workdir, "builds"), verifyOpts.Build, arch)
+		if err != nil {
+			return err
+		}
+		open = func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(dir, name))
+		}
+	}
+
+	result := VerifyResult{
+		Build:    build.BuildID,
+		Arch:     arch,
+		Location: verifyOpts.URL,
+	}
+	if dir != "" {
+		result.Location = dir
+	}
+	var err error
+	result.Artifacts, err = build.VerifyArtifacts(open, cosa.VerifyOptions{
+		Artifacts:        verifyOpts.Artifacts,
+		SkipUncompressed: verifyOpts.SkipUncompressed,
+	})
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		if result.Unreferenced, err = build.UnreferencedFiles(dir); err != nil {
+			return err
+		}
+	}
+
+	if verifyOpts.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printVerifyResult(os.Stdout, &result)
+	}
+	if !result.OK() {
+		return fmt.Errorf("verification of build %s failed", build.BuildID)
+	}
+	return nil
+}
+
+func printVerifyResult(w io.Writer, r *VerifyResult) {
+	fmt.Fprintf(w, "Verifying build %s (%s) at %s\n", r.Build, r.Arch, r.Location)
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, c := range r.Artifacts {
+		if c.OK() {
+			fmt.Fprintf(tw, "  ok\t%s\t%s\n", c.Name, c.Path)
+			continue
+		}
+		for _, e := range c.Errors {
+			fmt.Fprintf(tw, "  FAILED\t%s\t%s: %s\n", c.Name, c.Path, e)
+		}
+	}
+	tw.Flush()
+	if len(r.Unreferenced) > 0 {
+		fmt.Fprintf(w, "Files not referenced by meta.json:\n")
+		for _, f := range r.Unreferenced {
+			fmt.Fprintf(w, "  %s\n", f)
+		}
+	}
+}
+
+// execute the cmdVerify cobra command
+func runVerify(argv []string) error {
+	cmdVerify.SetArgs(argv)
+	return cmdVerify.Execute()
+}
@@ -24,6 +24,7 @@ require (
 	github.com/coreos/rpmostree-client-go v0.0.0-20231118154446-df964bc5419a
 	github.com/coreos/stream-metadata-go v0.4.4
 	github.com/coreos/vcontext v0.0.0-20230201181013-d72178a18687
+	github.com/digitalocean/go-libvirt v0.0.0-20200810224808-b9c702499bf7
 	github.com/digitalocean/go-qemu v0.0.0-20200529005954-1b453d036a9c
 	github.com/digitalocean/godo v1.33.0
 	github.com/frostschutz/go-fibmap v0.0.0-20160825162329-b32c231bfe6a
@@ -63,7 +64,6 @@ require (
 	github.com/containers/storage v1.50.1 // indirect
 	github.com/coreos/go-json v0.0.0-20230131223807-18775e0fb4fb // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/digitalocean/go-libvirt v0.0.0-20200810224808-b9c702499bf7 // indirect
 	github.com/godbus/dbus/v5 v5.0.4 // indirect
 	github.com/golang-jwt/jwt/v4 v4.4.2 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
@@ -0,0 +1,259 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/kola/bootperf"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+var (
+	cmdBootperf = &cobra.Command{
+		Use:   "bootperf",
+		Short: "Measure boot performance over several boots",
+		Long: `Boot the build --count times, one machine at a time, and measure each
+boot: the phases reported by systemd-analyze time, the time each unit took
+to start (systemd-analyze blame), the critical chain of the default target,
+when Ignition completed and how long the machine took to be reachable over
+SSH. The time to SSH is measured from the creation of the machine, so it
+includes provisioning it on the platform (e.g. starting the instance) and
+not only its boot. The measurements and their statistics (mean, median, 90th percentile,
+standard deviation) are written as JSON to --output.
+
+With --baseline, the results are compared against a previous output and
+the metrics whose median increased by at least --threshold and --min-delta
+with a significance of --alpha (one-sided Mann-Whitney U test, corrected
+for the number of metrics) are reported as regressions. Regressions of the
+boot phases, Ignition and SSH make the command fail; those of single units
+(blame and critical-chain) are only informational.
+`,
+		PreRunE: preRun,
+		RunE:    runBootperf,
+
+		SilenceUsage: true,
+	}
+
+	cmdBootperfCompare = &cobra.Command{
+		Use:   "compare BASELINE RESULTS",
+		Short: "Compare boot performance results",
+		Long: `Compare two outputs of kola bootperf, failing if a boot phase,
+Ignition or SSH regressed.
+See kola bootperf --help.
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: runBootperfCompare,
+
+		SilenceUsage: true,
+	}
+
+	bootperfCount    int
+	bootperfOutput   string
+	bootperfBaseline string
+	bootperfJSON     bool
+	bootperfTop      int
+	bootperfOptions  bootperf.CompareOptions
+)
+
+func init() {
+	root.AddCommand(cmdBootperf)
+	cmdBootperf.AddCommand(cmdBootperfCompare)
+	cmdBootperf.Flags().IntVar(&bootperfCount, "count", 10, "number of boots")
+	cmdBootperf.Flags().StringVar(&bootperfOutput, "output", "", "write the results to this file (default bootperf.json in the output directory)")
+	cmdBootperf.Flags().StringVar(&bootperfBaseline, "baseline", "", "compare the results against this previous output")
+	for _, cmd := range []*cobra.Command{cmdBootperf, cmdBootperfCompare} {
+		cmd.Flags().BoolVar(&bootperfJSON, "json", false, "print the comparison in JSON")
+		cmd.Flags().IntVar(&bootperfTop, "top", 10, "number of slowest units to show")
+		cmd.Flags().Float64Var(&bootperfOptions.Alpha, "alpha", 0.05, "significance level of regressions")
+		cmd.Flags().Float64Var(&bootperfOptions.Threshold, "threshold", 0.1, "minimum relative increase of the median of a regressed metric")
+		cmd.Flags().Float64Var(&bootperfOptions.MinDelta, "min-delta", 0.1, "minimum increase of the median of a regressed metric, in seconds")
+	}
+}
+
+func runBootperf(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errors.New("no args accepted")
+	}
+	if bootperfCount < 1 {
+		return errors.New("--count must be at least 1")
+	}
+	var baseline *bootperf.Results
+	if bootperfBaseline != "" {
+		var err error
+		if baseline, err = bootperf.Load(bootperfBaseline); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	outputDir, err = kola.SetupOutputDir(outputDir, kolaPlatform)
+	if err != nil {
+		return err
+	}
+	if bootperfOutput == "" {
+		bootperfOutput = filepath.Join(outputDir, "bootperf.json")
+	}
+
+	flight, err := kola.NewFlight(kolaPlatform)
+	if err != nil {
+		return errors.Wrapf(err, "creating flight")
+	}
+	defer flight.Destroy()
+
+	results := &bootperf.Results{
+		Platform: kolaPlatform,
+		Arch:     kola.Options.CosaBuildArch,
+	}
+	if kola.CosaBuild != nil {
+		results.Build = kola.CosaBuild.Meta.BuildID
+	}
+	for i := 0; i < bootperfCount; i++ {
+		boot, err := measureBoot(flight, i)
+		if err != nil {
+			return errors.Wrapf(err, "boot %d", i+1)
+		}
+		plog.Noticef("Boot %d/%d: %.3fs to SSH, %.3fs total", i+1, bootperfCount, boot.SSH, boot.Time["total"])
+		results.Boots = append(results.Boots, *boot)
+	}
+	if err := results.Save(bootperfOutput); err != nil {
+		return errors.Wrapf(err, "writing results")
+	}
+	plog.Noticef("Results written to %s", bootperfOutput)
+
+	if baseline == nil {
+		printBootperfStats(results)
+		return nil
+	}
+	return compareBootperf(baseline, results)
+}
+
+// measureBoot boots a machine in its own cluster, so that every boot starts
+// from the same state.
+func measureBoot(flight platform.Flight, i int) (*bootperf.Boot, error) {
+	c, err := flight.NewCluster(&platform.RuntimeConfig{
+		OutputDir: filepath.Join(outputDir, fmt.Sprintf("boot-%d", i+1)),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating cluster")
+	}
+	defer c.Destroy()
+
+	// the time to SSH includes creating the machine on the platform, as
+	// the start of its boot isn't observable on every platform
+	start := time.Now()
+	m, err := c.NewMachine(nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating machine")
+	}
+	ssh := time.Since(start)
+	defer m.Destroy()
+
+	boot, err := bootperf.Collect(m)
+	if err != nil {
+		return nil, err
+	}
+	boot.SSH = ssh.Seconds()
+	return boot, nil
+}
+
+func runBootperfCompare(cmd *cobra.Command, args []string) error {
+	baseline, err := bootperf.Load(args[0])
+	if err != nil {
+		return err
+	}
+	results, err := bootperf.Load(args[1])
+	if err != nil {
+		return err
+	}
+	return compareBootperf(baseline, results)
+}
+
+func printBootperfStats(r *bootperf.Results) {
+	stats := r.Stats
+	var metrics, units []string
+	for metric := range stats {
+		if bootperf.Gating(metric) {
+			metrics = append(metrics, metric)
+		} else if strings.HasPrefix(metric, "blame/") {
+			units = append(units, metric)
+		}
+	}
+	sort.Strings(metrics)
+	sort.Slice(units, func(i, j int) bool { return stats[units[i]].Median > stats[units[j]].Median })
+	if len(units) > bootperfTop {
+		units = units[:bootperfTop]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "Metric\tN\tMedian\tP90\tStddev\tMin\tMax")
+	for _, metric := range append(metrics, units...) {
+		s := stats[metric]
+		fmt.Fprintf(w, "%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", metric, s.N, s.Median, s.P90, s.Stddev, s.Min, s.Max)
+	}
+	w.Flush()
+}
+
+func compareBootperf(baseline, results *bootperf.Results) error {
+	cmps := bootperf.Compare(baseline, results, bootperfOptions)
+	var changed []bootperf.Comparison
+	regressions := 0
+	for _, c := range cmps {
+		if c.Regression && c.Gating {
+			regressions++
+		}
+		if c.Regression || c.Improvement {
+			changed = append(changed, c)
+		}
+	}
+
+	if bootperfJSON {
+		out, err := json.MarshalIndent(changed, "", "\t")
+		if err != nil {
+			return errors.Wrapf(err, "marshalling comparison")
+		}
+		fmt.Println(string(out))
+	} else if len(changed) == 0 {
+		fmt.Printf("No significant change from %s over %d metrics\n", baseline.Build, len(cmps))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+		fmt.Fprintln(w, "Metric\tBaseline\tCurrent\tChange\tp-value\t")
+		for _, c := range changed {
+			kind := "improvement"
+			if c.Regression && c.Gating {
+				kind = "REGRESSION"
+			} else if c.Regression {
+				kind = "regression (informational)"
+			}
+			fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%+.1f%%\t%.4f\t%s\n", c.Metric, c.Baseline.Median, c.Current.Median, c.Change*100, c.AdjustedPValue, kind)
+		}
+		w.Flush()
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d boot performance regressions from %s", regressions, baseline.Build)
+	}
+	return nil
+}
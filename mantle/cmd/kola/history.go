@@ -0,0 +1,180 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/kola/history"
+)
+
+var (
+	cmdHistory = &cobra.Command{
+		Use:   "history [glob pattern...]",
+		Short: "Show flakiness and last known good build of kola tests",
+		Long: `Analyze the results recorded by previous kola runs.
+
+Every run of kola run, kola run-upgrade and kola rerun appends its results
+to the history store (see --history-file). For each test, platform,
+architecture and stream this command reports the number of runs, the
+flakiness score (the fraction of consecutive runs whose result flipped
+between pass and fail), the number of builds on which the test both passed
+and failed, and the last build on which it passed.
+
+With --suggest-denylist, kola-denylist.yaml snooze entries are printed for
+tests whose flakiness is at or above --threshold; --write-denylist appends
+them to src/config/kola-denylist.yaml instead.
+`,
+		PreRunE: preRun,
+		RunE:    runHistory,
+
+		SilenceUsage: true,
+	}
+
+	historyJSON            bool
+	historyThreshold       float64
+	historyMinRuns         int
+	historySuggestDenylist bool
+	historyWriteDenylist   bool
+	historySnoozeDays      int
+)
+
+func init() {
+	root.AddCommand(cmdHistory)
+	cmdHistory.Flags().BoolVar(&historyJSON, "json", false, "format output in JSON")
+	cmdHistory.Flags().Float64Var(&historyThreshold, "threshold", 0.2, "flakiness score at or above which a test is considered flaky")
+	cmdHistory.Flags().IntVar(&historyMinRuns, "min-runs", 5, "minimum number of non-skipped runs before a test can be considered flaky")
+	cmdHistory.Flags().BoolVar(&historySuggestDenylist, "suggest-denylist", false, "print kola-denylist.yaml snooze entries for flaky tests")
+	cmdHistory.Flags().BoolVar(&historyWriteDenylist, "write-denylist", false, "append snooze entries for flaky tests to src/config/kola-denylist.yaml")
+	cmdHistory.Flags().IntVar(&historySnoozeDays, "snooze-days", 14, "number of days suggested denylist entries snooze a test for")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if kola.HistoryFile == "" {
+		return errors.New("no test history store; specify --history-file or --workdir")
+	}
+	records, err := history.NewStore(kola.HistoryFile).Load()
+	if err != nil {
+		return err
+	}
+
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	filterPlatform := cmd.Flags().Changed("platform")
+	var selected []history.Record
+	for _, r := range records {
+		if filterPlatform && r.Platform != kolaPlatform {
+			continue
+		}
+		if match, err := kola.MatchesPatterns(r.Test, patterns); err != nil {
+			return err
+		} else if match {
+			selected = append(selected, r)
+		}
+	}
+	stats := history.Analyze(selected)
+
+	if historySuggestDenylist || historyWriteDenylist {
+		return suggestDenylist(history.Flaky(stats, historyThreshold, historyMinRuns))
+	}
+
+	if historyJSON {
+		out, err := json.MarshalIndent(stats, "", "\t")
+		if err != nil {
+			return errors.Wrapf(err, "marshalling test history")
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "Test Name\tPlatform\tArch\tStream\tRuns\tFails\tFlakiness\tFlaky Builds\tLast Good Build")
+	for _, st := range stats {
+		flaky := ""
+		if st.Passes+st.Fails >= historyMinRuns && st.Flakiness >= historyThreshold {
+			flaky = " *"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%.2f%s\t%d\t%s\n", st.Test, st.Platform, st.Arch, st.Stream,
+			st.Runs, st.Fails, st.Flakiness, flaky, st.FlakyBuilds, st.LastGoodBuild)
+	}
+	return w.Flush()
+}
+
+// suggestDenylist prints or appends a kola-denylist.yaml snooze entry for
+// each flaky test.
+func suggestDenylist(flaky []history.TestStats) error {
+	if len(flaky) == 0 {
+		fmt.Fprintf(os.Stderr, "No tests with flakiness >= %.2f over at least %d runs\n", historyThreshold, historyMinRuns)
+		return nil
+	}
+	snooze := time.Now().AddDate(0, 0, historySnoozeDays).Format(kola.SnoozeFormat)
+
+	var buf []byte
+	for _, st := range flaky {
+		obj := kola.DenyListObj{
+			Pattern:    st.Test,
+			SnoozeDate: snooze,
+			Platforms:  []string{st.Platform},
+			Arches:     []string{st.Arch},
+		}
+		if st.Stream != "" {
+			obj.Streams = []string{st.Stream}
+		}
+		entry, err := yaml.Marshal([]kola.DenyListObj{obj})
+		if err != nil {
+			return err
+		}
+		comment := fmt.Sprintf("# flakiness %.2f over %d runs (%d fails)", st.Flakiness, st.Passes+st.Fails, st.Fails)
+		if st.LastGoodBuild != "" {
+			comment += fmt.Sprintf(", last good build %s", st.LastGoodBuild)
+		}
+		buf = append(buf, comment+"\n"...)
+		buf = append(buf, entry...)
+	}
+
+	if !historyWriteDenylist {
+		_, err := os.Stdout.Write(buf)
+		return err
+	}
+	if kola.Options.CosaWorkdir == "" {
+		return errors.New("--write-denylist requires a cosa workdir")
+	}
+	path := filepath.Join(kola.Options.CosaWorkdir, "src/config/kola-denylist.yaml")
+	if existing, err := os.ReadFile(path); err == nil && len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		buf = append([]byte("\n"), buf...)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return err
+	}
+	fmt.Printf("Appended %d snooze entries to %s\n", len(flaky), path)
+	return nil
+}
@@ -121,6 +121,8 @@ This can be useful for e.g. serving locally built OSTree repos to qemu.
 	listDistro         string
 	httpPort           int
 	findParentImage    bool
+	upgradeChainLength int
+	upgradeBarriers    bool
 	qemuImageDir       string
 	qemuImageDirIsTemp bool
 
@@ -150,6 +152,8 @@ func init() {
 
 	root.AddCommand(cmdRunUpgrade)
 	cmdRunUpgrade.Flags().BoolVar(&findParentImage, "find-parent-image", false, "automatically find parent image if not provided -- note on qemu, this will download the image")
+	cmdRunUpgrade.Flags().IntVar(&upgradeChainLength, "upgrade-chain", 0, "boot the N-th previous release and upgrade through each following release to the build (implies --find-parent-image)")
+	cmdRunUpgrade.Flags().BoolVar(&upgradeBarriers, "upgrade-chain-barriers", false, "like --upgrade-chain, but through the barrier releases of the update graph; with --upgrade-chain, only the last N")
 	cmdRunUpgrade.Flags().StringVar(&qemuImageDir, "qemu-image-dir", "", "directory in which to cache QEMU images if --fetch-parent-image is enabled")
 	cmdRunUpgrade.Flags().BoolVar(&runRerunFlag, "rerun", false, "re-run failed tests once")
 	cmdRunUpgrade.Flags().StringVar(&allowRerunSuccess, "allow-rerun-success", "", "Allow kola test run to be successful when tests with given 'tags=...[,...]' pass during re-run")
@@ -236,6 +240,15 @@ func runRerun(cmd *cobra.Command, args []string) error {
 			}
 		}
 	}
+	// The report may also come from `kola testiso`, whose scenarios are
+	// rerun through it instead
+	isTestIso := len(patterns) > 0
+	for _, pattern := range patterns {
+		isTestIso = isTestIso && isTestIsoScenario(pattern)
+	}
+	if isTestIso {
+		return runTestIso(cmd, patterns)
+	}
 	return kolaRunPatterns(patterns, false)
 }
 
@@ -411,20 +424,12 @@ func runList(cmd *cobra.Command, args []string) error {
 	if err := registerExternals(); err != nil {
 		return err
 	}
+	if err := kola.ParseMetadataOverrides(register.Tests, listPlatform); err != nil {
+		return err
+	}
 	var testlist []*item
 	for name, test := range register.Tests {
-		item := &item{
-			name,
-			test.Platforms,
-			test.ExcludePlatforms,
-			test.Architectures,
-			test.ExcludeArchitectures,
-			test.Distros,
-			test.ExcludeDistros,
-			test.Tags,
-			test.Description}
-		item.updateValues()
-		testlist = append(testlist, item)
+		testlist = append(testlist, newItem(name, test))
 	}
 
 	sort.Slice(testlist, func(i, j int) bool {
@@ -488,6 +493,26 @@ type item struct {
 	ExcludeDistros       []string `json:"-"`
 	Tags                 []string
 	Description          string
+
+	// The remaining metadata is only part of the JSON output; fields
+	// above take precedence over the identically named ones in here.
+	register.Metadata
+}
+
+func newItem(name string, test *register.Test) *item {
+	item := &item{
+		name,
+		test.Platforms,
+		test.ExcludePlatforms,
+		test.Architectures,
+		test.ExcludeArchitectures,
+		test.Distros,
+		test.ExcludeDistros,
+		test.Tags,
+		test.Description,
+		test.Metadata()}
+	item.updateValues()
+	return item
 }
 
 func (i *item) updateValues() {
@@ -554,7 +579,13 @@ func preRunUpgrade(cmd *cobra.Command, args []string) error {
 		return errors.New("Error: missing required argument --build")
 	}
 
-	if findParentImage {
+	if upgradeChainLength > 0 || upgradeBarriers {
+		err = syncUpgradeChainOptions()
+		if err != nil {
+			runUpgradeCleanup()
+			return err
+		}
+	} else if findParentImage {
 		err = syncFindParentImageOptions()
 		if err != nil {
 			runUpgradeCleanup()
@@ -608,8 +639,47 @@ func syncFindParentImageOptions() error {
 		return fmt.Errorf("--find-parent-image not yet supported for distro %s", kola.Options.Distribution)
 	}
 
-	var parentCosaBuild *cosa.Build
-	parentCosaBuild, err = cosa.FetchAndParseBuild(parentBaseURL + "meta.json")
+	return syncParentImageOptions(parentBaseURL, skipSignature)
+}
+
+// syncUpgradeChainOptions handles --upgrade-chain and --upgrade-chain-barriers:
+// it selects the releases to upgrade through and starts from the oldest one.
+func syncUpgradeChainOptions() error {
+	if kola.Options.Distribution != "fcos" {
+		return fmt.Errorf("--upgrade-chain not yet supported for distro %s", kola.Options.Distribution)
+	}
+	if kola.CosaBuild.Meta.BuildRef == "" {
+		return errors.New("no ref in build metadata")
+	}
+	stream := filepath.Base(kola.CosaBuild.Meta.BuildRef)
+
+	index, err := fcos.FetchAndParseCanonicalReleaseIndex(stream)
+	if err != nil {
+		return errors.Wrapf(err, "fetching release index of stream %s", stream)
+	}
+	updates, err := fcos.FetchAndParseCanonicalUpdates(stream)
+	if err != nil {
+		return errors.Wrapf(err, "fetching updates metadata of stream %s", stream)
+	}
+	chain, err := kola.SelectUpgradeChain(index, updates, kola.Options.CosaBuildArch,
+		kola.CosaBuild.Meta.FedoraCoreOsParentVersion, upgradeChainLength, upgradeBarriers)
+	if err != nil {
+		return err
+	}
+	var versions []string
+	for _, r := range chain {
+		versions = append(versions, r.Version)
+	}
+	plog.Noticef("Upgrade chain: %s -> %s", strings.Join(versions, " -> "), kola.CosaBuild.Meta.OstreeVersion)
+	kola.UpgradeChain = chain
+
+	return syncParentImageOptions(fcos.GetCosaBuildURL(stream, chain[0].Version, kola.Options.CosaBuildArch), false)
+}
+
+// syncParentImageOptions sets the platform-specific options to boot the
+// build at parentBaseURL.
+func syncParentImageOptions(parentBaseURL string, skipSignature bool) error {
+	parentCosaBuild, err := cosa.FetchAndParseBuild(parentBaseURL + "meta.json")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,88 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestListItemJSON(t *testing.T) {
+	defer func(v bool) { listJSON = v }(listJSON)
+	listJSON = true
+
+	test := &register.Test{
+		Name:             "ext.config.disk",
+		Description:      "Checks disks",
+		ExternalTest:     "/usr/lib/coreos-assembler/tests/kola/config/disk",
+		ExcludePlatforms: []string{"aws"},
+		Distros:          []string{"fcos"},
+		Tags:             []string{"disk"},
+		ClusterSize:      1,
+		MinMemory:        4096,
+		AdditionalDisks:  []string{"5G"},
+		Timeout:          10 * time.Minute,
+		Flags:            []register.Flag{register.NoEmergencyShellCheck},
+		NonExclusive:     true,
+	}
+	out, err := json.Marshal(newItem(test.Name, test))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// the listing fields are resolved against the known platforms
+	platforms, _ := got["Platforms"].([]interface{})
+	for _, p := range platforms {
+		if p == "aws" {
+			t.Errorf("excluded platform listed: %v", platforms)
+		}
+	}
+	if len(platforms) == 0 {
+		t.Errorf("no platforms listed")
+	}
+	want := map[string]interface{}{
+		"Name":             "ext.config.disk",
+		"Description":      "Checks disks",
+		"External":         "/usr/lib/coreos-assembler/tests/kola/config/disk",
+		"ExcludePlatforms": []interface{}{"aws"},
+		"Distros":          []interface{}{"fcos"},
+		"Tags":             []interface{}{"disk"},
+		"ClusterSize":      1.0,
+		"MinMemory":        4096.0,
+		"AdditionalDisks":  []interface{}{"5G"},
+		"Timeout":          "10m0s",
+		"Flags":            []interface{}{"NoEmergencyShellCheck"},
+		"Exclusive":        false,
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s is %#v, want %#v", k, got[k], v)
+		}
+	}
+	// unset optional metadata is omitted
+	for _, k := range []string{"NativeFuncs", "Conflicts", "DependsOn", "Fixtures", "AppendKernelArgs"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("unset %s exported", k)
+		}
+	}
+}
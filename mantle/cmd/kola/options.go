@@ -40,7 +40,7 @@ var (
 	kolaPlatform      string
 	kolaParallelArg   string
 	kolaArchitectures = []string{"amd64"}
-	kolaPlatforms     = []string{"aws", "azure", "do", "esx", "gcp", "openstack", "packet", "qemu", "qemu-iso"}
+	kolaPlatforms     = []string{"aws", "azure", "do", "esx", "gcp", "libvirt", "openstack", "packet", "qemu", "qemu-iso"}
 	kolaDistros       = []string{"fcos", "rhcos", "scos"}
 )
 
@@ -55,7 +55,13 @@ func init() {
 	root.PersistentFlags().StringVarP(&kolaPlatform, "platform", "p", "", "VM platform: "+strings.Join(kolaPlatforms, ", "))
 	root.PersistentFlags().StringVarP(&kola.Options.Distribution, "distro", "b", "", "Distribution: "+strings.Join(kolaDistros, ", "))
 	root.PersistentFlags().StringVarP(&kolaParallelArg, "parallel", "j", "1", "number of tests to run in parallel, or \"auto\" to match CPU count")
+	root.PersistentFlags().IntVar(&kola.MemoryBudget, "memory-budget", 0, "total memory in MB the machines of concurrently running tests may use (0 for unlimited)")
+	root.PersistentFlags().IntVar(&kola.CPUBudget, "cpu-budget", 0, "total vCPUs the machines of concurrently running tests may use (0 for unlimited)")
+	root.PersistentFlags().DurationVar(&kola.TimeBudget, "time-budget", 0, "stop starting new tests after this wall-clock time, e.g. \"2h\" (0 for unlimited)")
 	sv(&kola.TAPFile, "tapfile", "", "file to write TAP results to")
+	sv(&kola.JUnitFile, "junit-file", "", "file to write JUnit XML results to")
+	sv(&kola.MetadataOverridesFile, "metadata-overrides", "", "YAML file adjusting the metadata (memory, disks, timeout, ...) of registered tests")
+	sv(&kola.HistoryFile, "history-file", "", "test history store to append results to (default \"tmp/kola/history.jsonl\" in the cosa workdir, \"none\" to disable)")
 	root.PersistentFlags().BoolVarP(&kola.Options.UseWarnExitCode77, "on-warn-failure-exit-77", "", false, "Exit with code 77 if 'warn: true' tests fail")
 	sv(&kola.Options.BaseName, "basename", "kola", "Cluster name prefix")
 	ss("debug-systemd-unit", []string{}, "full-unit-name.service to enable SYSTEMD_LOG_LEVEL=debug on. Can be specified multiple times.")
@@ -90,6 +96,7 @@ func init() {
 	sv(&kola.AWSOptions.InstanceType, "aws-type", "", "AWS instance type")
 	sv(&kola.AWSOptions.SecurityGroup, "aws-sg", "kola", "AWS security group name")
 	sv(&kola.AWSOptions.IAMInstanceProfile, "aws-iam-profile", "kola", "AWS IAM instance profile name")
+	sv(&kola.AWSOptions.Endpoint, "aws-endpoint", "", "override the AWS endpoint, e.g. for an emulator")
 
 	// azure-specific options
 	sv(&kola.AzureOptions.AzureCredentials, "azure-credentials", "", "Azure credentials file location (default \"~/"+auth.AzureCredentialsPath+"\")")
@@ -126,6 +133,16 @@ func init() {
 	bv(&kola.GCPOptions.ServiceAuth, "gcp-service-auth", false, "for non-interactive auth when running within GCP")
 	sv(&kola.GCPOptions.JSONKeyFile, "gcp-json-key", "", "use a service account's JSON key for authentication (default \"~/"+auth.GCPConfigPath+"\")")
 	bv(&kola.GCPOptions.Confidential, "gcp-confidential-vm", false, "create confidential instances")
+	sv(&kola.GCPOptions.Endpoint, "gcp-endpoint", "", "override the Compute Engine API endpoint, e.g. for an emulator")
+
+	// libvirt-specific options
+	sv(&kola.LibvirtOptions.URI, "libvirt-uri", "qemu:///system", "libvirt connection URI; only local connections are supported")
+	sv(&kola.LibvirtOptions.Pool, "libvirt-pool", "default", "libvirt storage pool to upload disk images to")
+	sv(&kola.LibvirtOptions.DiskImage, "libvirt-image", "", "path to CoreOS qcow2 disk image")
+	sv(&kola.LibvirtOptions.Firmware, "libvirt-firmware", "", "Boot firmware: bios,uefi,uefi-secure (default bios)")
+	root.PersistentFlags().IntVar(&kola.LibvirtOptions.Memory, "libvirt-memory", 2048, "Default memory size in MiB")
+	root.PersistentFlags().IntVar(&kola.LibvirtOptions.CPUs, "libvirt-cpus", 2, "Number of virtual CPUs")
+	bv(&kola.LibvirtOptions.KeepFailed, "libvirt-keep-failed", false, "Leave the machines of failed tests running for inspection")
 
 	// openstack-specific options
 	sv(&kola.OpenStackOptions.ConfigPath, "openstack-config-file", "", "Path to a clouds.yaml formatted OpenStack config file. The underlying library defaults to ./clouds.yaml")
@@ -211,6 +228,11 @@ func syncOptionsImpl(useCosa bool) error {
 		}
 		kola.TestParallelism = int(parallel)
 	}
+	// With a memory or CPU budget, the number of tests running in parallel
+	// is only limited by --parallel if it was given explicitly
+	if (kola.MemoryBudget > 0 || kola.CPUBudget > 0) && !root.PersistentFlags().Changed("parallel") {
+		kola.TestParallelism = 0
+	}
 
 	// native 4k requires a UEFI bootloader
 	if kola.QEMUOptions.Native4k && kola.QEMUOptions.Firmware == "bios" {
@@ -320,6 +342,12 @@ func syncOptionsImpl(useCosa bool) error {
 		}
 	}
 
+	if kola.HistoryFile == "" {
+		kola.HistoryFile = kola.DefaultHistoryFile()
+	} else if kola.HistoryFile == "none" {
+		kola.HistoryFile = ""
+	}
+
 	if foundCosa && useCosa {
 		if err := syncCosaOptions(); err != nil {
 			return err
@@ -328,6 +356,7 @@ func syncOptionsImpl(useCosa bool) error {
 	// Currently the `--arch` option is defined in terms of coreos-assembler, but
 	// we also unconditionally use it for qemu if present.
 	kola.QEMUOptions.Arch = kola.Options.CosaBuildArch
+	kola.LibvirtOptions.Arch = kola.Options.CosaBuildArch
 
 	units, _ := root.PersistentFlags().GetStringSlice("debug-systemd-units")
 	for _, unit := range units {
@@ -369,6 +398,10 @@ func syncCosaOptions() error {
 		if kola.QEMUOptions.DiskImage == "" && kola.CosaBuild.Meta.BuildArtifacts.Qemu != nil {
 			kola.QEMUOptions.DiskImage = filepath.Join(kola.CosaBuild.Dir, kola.CosaBuild.Meta.BuildArtifacts.Qemu.Path)
 		}
+	case "libvirt":
+		if kola.LibvirtOptions.DiskImage == "" && kola.CosaBuild.Meta.BuildArtifacts.Qemu != nil {
+			kola.LibvirtOptions.DiskImage = filepath.Join(kola.CosaBuild.Dir, kola.CosaBuild.Meta.BuildArtifacts.Qemu.Path)
+		}
 	case "qemu-iso":
 		if kola.QEMUIsoOptions.IsoPath == "" && kola.CosaBuild.Meta.BuildArtifacts.LiveIso != nil {
 			kola.QEMUIsoOptions.IsoPath = filepath.Join(kola.CosaBuild.Dir, kola.CosaBuild.Meta.BuildArtifacts.LiveIso.Path)
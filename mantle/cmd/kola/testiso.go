@@ -26,6 +26,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 
 	"github.com/coreos/coreos-assembler/mantle/kola"
 	"github.com/coreos/coreos-assembler/mantle/platform"
@@ -57,90 +59,10 @@ var (
 	pxeKernelArgs   []string
 
 	console bool
-
-	addNmKeyfile     bool
-	enable4k         bool
-	enableMultipath  bool
-	enableUefi       bool
-	enableUefiSecure bool
-	isOffline        bool
-	isISOFromRAM     bool
-
-	// These tests only run on RHCOS
-	tests_RHCOS_uefi = []string{
-		"iso-fips.uefi",
-	}
-
-	// The iso-as-disk tests are only supported in x86_64 because other
-	// architectures don't have the required hybrid partition table.
-	tests_x86_64 = []string{
-		"iso-as-disk.bios",
-		"iso-as-disk.uefi",
-		"iso-as-disk.uefi-secure",
-		"iso-as-disk.4k.uefi",
-		"iso-install.bios",
-		"iso-live-login.bios",
-		"iso-live-login.uefi",
-		"iso-live-login.uefi-secure",
-		"iso-live-login.4k.uefi",
-		"iso-offline-install.bios",
-		"iso-offline-install.mpath.bios",
-		"iso-offline-install-fromram.4k.uefi",
-		"miniso-install.bios",
-		"miniso-install.nm.bios",
-		"miniso-install.4k.uefi",
-		"miniso-install.4k.nm.uefi",
-		"pxe-offline-install.bios",
-		"pxe-offline-install.4k.uefi",
-		"pxe-online-install.bios",
-		"pxe-online-install.4k.uefi",
-	}
-	tests_s390x = []string{
-		"iso-live-login.s390fw",
-		"iso-offline-install.s390fw",
-		// https://github.com/coreos/fedora-coreos-tracker/issues/1434
-		// "iso-offline-install.mpath.s390fw",
-		// https://github.com/coreos/fedora-coreos-tracker/issues/1261
-		// "iso-offline-install.4k.s390fw",
-		"pxe-online-install.s390fw",
-		"pxe-offline-install.s390fw",
-		"miniso-install.s390fw",
-		"miniso-install.nm.s390fw",
-		"miniso-install.4k.nm.s390fw",
-	}
-	tests_ppc64le = []string{
-		"iso-live-login.ppcfw",
-		"iso-offline-install.ppcfw",
-		"iso-offline-install.mpath.ppcfw",
-		"iso-offline-install-fromram.4k.ppcfw",
-		"miniso-install.ppcfw",
-		"miniso-install.nm.ppcfw",
-		"miniso-install.4k.ppcfw",
-		"miniso-install.4k.nm.ppcfw",
-		"pxe-online-install.ppcfw",
-		"pxe-offline-install.4k.ppcfw",
-	}
-	tests_aarch64 = []string{
-		"iso-live-login.uefi",
-		"iso-live-login.4k.uefi",
-		"iso-offline-install.uefi",
-		"iso-offline-install.mpath.uefi",
-		"iso-offline-install-fromram.4k.uefi",
-		"miniso-install.uefi",
-		"miniso-install.nm.uefi",
-		"miniso-install.4k.uefi",
-		"miniso-install.4k.nm.uefi",
-		"pxe-offline-install.uefi",
-		"pxe-offline-install.4k.uefi",
-		"pxe-online-install.uefi",
-		"pxe-online-install.4k.uefi",
-	}
 )
 
 const (
 	installTimeoutMins = 10
-	// https://github.com/coreos/fedora-coreos-config/pull/2544
-	liveISOFromRAMKarg = "coreos.liveiso.fromram"
 )
 
 var liveOKSignal = "live-test-OK"
@@ -267,61 +189,13 @@ ExecStart=bash -c "if mountpoint /run/media/iso 2>/dev/null; then exit 1; fi"
 [Install]
 RequiredBy=coreos-installer.target`
 
-var nmConnectionId = "CoreOS DHCP"
-var nmConnectionFile = "coreos-dhcp.nmconnection"
-var nmConnection = fmt.Sprintf(`[connection]
-id=%s
-type=ethernet
-# add wait-device-timeout here so we make sure NetworkManager-wait-online.service will
-# wait for a device to be present before exiting. See
-# https://github.com/coreos/fedora-coreos-tracker/issues/1275#issuecomment-1231605438
-wait-device-timeout=20000
-
-[ipv4]
-method=auto
-`, nmConnectionId)
-
-var nmstateConfigFile = "/etc/nmstate/br-ex.yml"
-var nmstateConfig = `interfaces:
- - name: br-ex
-   type: linux-bridge
-   state: up
-   ipv4:
-     enabled: false
-   ipv6:
-     enabled: false
-   bridge:
-     port: []
-`
-
-// This is used to verify *both* the live and the target system in the `--add-nm-keyfile` path.
-var verifyNmKeyfile = fmt.Sprintf(`[Unit]
-Description=TestISO Verify NM Keyfile Propagation
-OnFailure=emergency.target
-OnFailureJobMode=isolate
-Wants=network-online.target
-After=network-online.target
-Before=live-signal-ok.service
-Before=coreos-test-installer.service
-[Service]
-Type=oneshot
-RemainAfterExit=yes
-ExecStart=/usr/bin/journalctl -u nm-initrd --no-pager --grep "policy: set '%[1]s' (.*) as default .* routing and DNS"
-ExecStart=/usr/bin/journalctl -u NetworkManager --no-pager --grep "policy: set '%[1]s' (.*) as default .* routing and DNS"
-ExecStart=/usr/bin/grep "%[1]s" /etc/NetworkManager/system-connections/%[2]s
-# Also verify nmstate config
-ExecStart=/usr/bin/nmcli c show br-ex
-[Install]
-# for live system
-RequiredBy=coreos-installer.target
-# for target system
-RequiredBy=multi-user.target`, nmConnectionId, nmConnectionFile)
-
 func init() {
 	cmdTestIso.Flags().BoolVarP(&instInsecure, "inst-insecure", "S", false, "Do not verify signature on metal image")
 	cmdTestIso.Flags().BoolVar(&console, "console", false, "Connect qemu console to terminal, turn off automatic initramfs failure checking")
 	cmdTestIso.Flags().BoolVar(&pxeAppendRootfs, "pxe-append-rootfs", false, "Append rootfs to PXE initrd instead of fetching at runtime")
 	cmdTestIso.Flags().StringSliceVar(&pxeKernelArgs, "pxe-kargs", nil, "Additional kernel arguments for PXE")
+	cmdTestIso.Flags().BoolVar(&runRerunFlag, "rerun", false, "re-run failed tests once")
+	cmdTestIso.Flags().StringVar(&allowRerunSuccess, "allow-rerun-success", "", "Allow the run to be successful when tests with given 'tags=...[,...]' pass during re-run")
 
 	root.AddCommand(cmdTestIso)
 }
@@ -334,31 +208,13 @@ func liveArtifactExistsInBuild() error {
 	return nil
 }
 
-func getAllTests(build *util.LocalBuild) []string {
-	arch := coreosarch.CurrentRpmArch()
-	var tests []string
-	switch arch {
-	case "x86_64":
-		tests = tests_x86_64
-	case "ppc64le":
-		tests = tests_ppc64le
-	case "s390x":
-		tests = tests_s390x
-	case "aarch64":
-		tests = tests_aarch64
-	}
-	if kola.CosaBuild.Meta.Name == "rhcos" && arch != "s390x" && arch != "ppc64le" {
-		tests = append(tests, tests_RHCOS_uefi...)
-	}
-	return tests
-}
-
-func newBaseQemuBuilder(outdir string) (*platform.QemuBuilder, error) {
+func newBaseQemuBuilder(s *kola.IsoScenario, outdir string) (*platform.QemuBuilder, error) {
 	builder := platform.NewMetalQemuBuilderDefault()
-	if enableUefiSecure {
-		builder.Firmware = "uefi-secure"
-	} else if enableUefi {
-		builder.Firmware = "uefi"
+	if s.Firmware != "" {
+		builder.Firmware = s.Firmware
+	}
+	if s.Network.Disabled {
+		builder.Append("-net", "none")
 	}
 
 	if err := os.MkdirAll(outdir, 0755); err != nil {
@@ -373,8 +229,8 @@ func newBaseQemuBuilder(outdir string) (*platform.QemuBuilder, error) {
 	return builder, nil
 }
 
-func newQemuBuilder(outdir string) (*platform.QemuBuilder, *conf.Conf, error) {
-	builder, err := newBaseQemuBuilder(outdir)
+func newQemuBuilder(s *kola.IsoScenario, outdir string) (*platform.QemuBuilder, *conf.Conf, error) {
+	builder, err := newBaseQemuBuilder(s, outdir)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -402,22 +258,17 @@ func newQemuBuilder(outdir string) (*platform.QemuBuilder, *conf.Conf, error) {
 	return builder, config, nil
 }
 
-func newQemuBuilderWithDisk(outdir string) (*platform.QemuBuilder, *conf.Conf, error) {
-	builder, config, err := newQemuBuilder(outdir)
+func newQemuBuilderWithDisk(s *kola.IsoScenario, outdir string) (*platform.QemuBuilder, *conf.Conf, error) {
+	builder, config, err := newQemuBuilder(s, outdir)
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	sectorSize := 0
-	if enable4k {
-		sectorSize = 4096
-	}
-
 	disk := platform.Disk{
 		Size:          "12G", // Arbitrary
-		SectorSize:    sectorSize,
-		MultiPathDisk: enableMultipath,
+		SectorSize:    s.SectorSize,
+		MultiPathDisk: s.Multipath,
 	}
 
 	//TBD: see if we can remove this and just use AddDisk and inject bootindex during startup
@@ -435,11 +286,27 @@ func newQemuBuilderWithDisk(outdir string) (*platform.QemuBuilder, *conf.Conf, e
 	return builder, config, nil
 }
 
+// getAllTests returns the testiso scenarios which apply to the current
+// architecture and to build.
+func getAllTests(build *util.LocalBuild) ([]kola.IsoScenario, error) {
+	scenarios, err := kola.ParseTestIsoYaml()
+	if err != nil {
+		return nil, err
+	}
+	var tests []kola.IsoScenario
+	for _, s := range scenarios {
+		if s.AppliesTo(coreosarch.CurrentRpmArch(), build.Meta.Name) {
+			tests = append(tests, s)
+		}
+	}
+	return tests, nil
+}
+
 // See similar semantics in the `filterTests` of `kola.go`.
-func filterTests(tests []string, patterns []string) ([]string, error) {
-	r := []string{}
+func filterTests(tests []kola.IsoScenario, patterns []string) ([]kola.IsoScenario, error) {
+	r := []kola.IsoScenario{}
 	for _, test := range tests {
-		if matches, err := kola.MatchesPatterns(test, patterns); err != nil {
+		if matches, err := kola.MatchesPatterns(test.Name, patterns); err != nil {
 			return nil, err
 		} else if matches {
 			r = append(r, test)
@@ -453,7 +320,10 @@ func runTestIso(cmd *cobra.Command, args []string) error {
 	if kola.CosaBuild == nil {
 		return fmt.Errorf("Must provide --build")
 	}
-	tests := getAllTests(kola.CosaBuild)
+	tests, err := getAllTests(kola.CosaBuild)
+	if err != nil {
+		return err
+	}
 	if len(args) != 0 {
 		if tests, err = filterTests(tests, args); err != nil {
 			return err
@@ -462,27 +332,14 @@ func runTestIso(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Call `ParseDenyListYaml` to populate the `kola.DenylistedTests` var
-	err = kola.ParseDenyListYaml("qemu")
-	if err != nil {
-		plog.Fatal(err)
+	// All of these tests require buildextend-live to have been run
+	if err := liveArtifactExistsInBuild(); err != nil {
+		return err
 	}
 
-	finalTests := []string{}
-	for _, test := range tests {
-		if !kola.HasString(test, kola.DenylistedTests) {
-			matchTest, err := kola.MatchesPatterns(test, kola.DenylistedTests)
-			if err != nil {
-				return err
-
-			}
-			if !matchTest {
-				finalTests = append(finalTests, test)
-			}
-		}
+	rerunSuccessTags, err := parseRerunSuccess()
+	if err != nil {
+		return err
 	}
 
 	// note this reassigns a *global*
@@ -494,7 +351,6 @@ func runTestIso(cmd *cobra.Command, args []string) error {
 	baseInst := platform.Install{
 		CosaBuild:       kola.CosaBuild,
 		PxeAppendRootfs: pxeAppendRootfs,
-		NmKeyfiles:      make(map[string]string),
 	}
 
 	if instInsecure {
@@ -509,83 +365,123 @@ func runTestIso(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Detected development build; disabling signature verification\n")
 	}
 
-	var duration time.Duration
+	// Only one machine at a time can be connected to the terminal
+	if console {
+		kola.TestParallelism = 1
+	}
 
-	atLeastOneFailed := false
-	for _, test := range finalTests {
+	var isoTests []kola.StandaloneTest
+	for _, test := range tests {
+		test := test // for the closure
+		isoTests = append(isoTests, kola.StandaloneTest{
+			Name: test.Name,
+			Tags: test.Tags,
+			Run: func(h *harness.H) {
+				runIsoTest(h, baseInst, &test)
+			},
+		})
+	}
+
+	// The denylist entries for testiso are scoped to the qemu platform
+	return kola.RunStandaloneTests(isoTests, runRerunFlag, rerunSuccessTags, "qemu", outputDir)
+}
 
-		// All of these tests require buildextend-live to have been run
-		err = liveArtifactExistsInBuild()
-		if err != nil {
-			return err
+// isTestIsoScenario returns true if test names one of the testiso
+// scenarios, on any architecture.
+func isTestIsoScenario(test string) bool {
+	scenarios, err := kola.ParseTestIsoYaml()
+	if err != nil {
+		return false
+	}
+	for _, s := range scenarios {
+		if s.Name == test {
+			return true
 		}
+	}
+	return false
+}
 
-		addNmKeyfile = false
-		enable4k = false
-		enableMultipath = false
-		enableUefi = false
-		enableUefiSecure = false
-		isOffline = false
-		inst := baseInst // Pretend this is Rust and I wrote .copy()
+// runIsoTest runs the testiso scenario s as a harness test.
+func runIsoTest(h *harness.H, baseInst platform.Install, s *kola.IsoScenario) {
+	inst := baseInst // Pretend this is Rust and I wrote .copy()
+	inst.NmKeyfiles = make(map[string]string)
+	for name, contents := range s.Network.Keyfiles {
+		inst.NmKeyfiles[name] = contents
+	}
+	inst.Native4k = s.SectorSize == 4096
+	inst.MultiPathDisk = s.Multipath
 
-		fmt.Printf("Running test: %s\n", test)
-		components := strings.Split(test, ".")
+	ctx := h.Context()
+	outdir := h.OutputDir()
 
-		if kola.HasString("4k", components) {
-			enable4k = true
-			inst.Native4k = true
-		}
-		if kola.HasString("nm", components) {
-			addNmKeyfile = true
-		}
-		if kola.HasString("mpath", components) {
-			enableMultipath = true
-			inst.MultiPathDisk = true
-		}
-		if kola.HasString("uefi-secure", components) {
-			enableUefiSecure = true
-		} else if kola.HasString("uefi", components) {
-			enableUefi = true
-		}
-		// For offline it is a part of the first component. i.e. for
-		// iso-offline-install.bios we need to search for 'offline' in
-		// iso-offline-install, which is currently in components[0].
-		if kola.HasString("offline", strings.Split(components[0], "-")) {
-			isOffline = true
-		}
-		// For fromram it is a part of the first component. i.e. for
-		// iso-offline-install-fromram.uefi we need to search for 'fromram' in
-		// iso-offline-install-fromram, which is currently in components[0].
-		if kola.HasString("fromram", strings.Split(components[0], "-")) {
-			isISOFromRAM = true
-		}
+	var duration time.Duration
+	var err error
+	switch s.Medium {
+	case "pxe":
+		duration, err = testPXE(ctx, s, inst, outdir)
+	case "iso-as-disk":
+		duration, err = testAsDisk(ctx, s, outdir)
+	case "live-login":
+		duration, err = testLiveLogin(ctx, s, outdir)
+	case "live":
+		duration, err = testLive(ctx, s, outdir)
+	case "iso":
+		duration, err = testLiveIso(ctx, s, inst, outdir, false)
+	case "miniso":
+		duration, err = testLiveIso(ctx, s, inst, outdir, true)
+	default:
+		h.Fatalf("Unknown medium: %s", s.Medium)
+	}
+	if err != nil {
+		h.Fatal(err)
+	}
+	h.Logf("Completed in %s", duration.Round(time.Millisecond))
+}
 
-		switch components[0] {
-		case "pxe-offline-install", "pxe-online-install":
-			duration, err = testPXE(ctx, inst, filepath.Join(outputDir, test))
-		case "iso-as-disk":
-			duration, err = testAsDisk(ctx, filepath.Join(outputDir, test))
-		case "iso-live-login":
-			duration, err = testLiveLogin(ctx, filepath.Join(outputDir, test))
-		case "iso-fips":
-			duration, err = testLiveFIPS(ctx, filepath.Join(outputDir, test))
-		case "iso-install", "iso-offline-install", "iso-offline-install-fromram":
-			duration, err = testLiveIso(ctx, inst, filepath.Join(outputDir, test), false)
-		case "miniso-install":
-			duration, err = testLiveIso(ctx, inst, filepath.Join(outputDir, test), true)
-		default:
-			plog.Fatalf("Unknown test name:%s", test)
+// addScenarioConfig adds the units, files and install kargs of s to the
+// configs of the live and, if any, the installed system.
+func addScenarioConfig(s *kola.IsoScenario, liveConfig, targetConfig *conf.Conf) error {
+	for _, name := range sortedKeys(s.LiveUnits) {
+		liveConfig.AddSystemdUnit(name, s.LiveUnits[name], conf.Enable)
+	}
+	for _, path := range sortedKeys(s.LiveFiles) {
+		liveConfig.AddFile(path, s.LiveFiles[path], 0644)
+	}
+	if len(s.InstallKargs) > 0 {
+		// merged by coreos-installer with the other configs in installer.d
+		buf, err := yaml.Marshal(map[string][]string{"append-karg": s.InstallKargs})
+		if err != nil {
+			return err
+		}
+		liveConfig.AddFile("/etc/coreos/installer.d/testiso.yaml", string(buf), 0644)
+	}
+	if targetConfig != nil {
+		for _, name := range sortedKeys(s.TargetUnits) {
+			targetConfig.AddSystemdUnit(name, s.TargetUnits[name], conf.Enable)
 		}
-		if printResult(test, duration, err) {
-			atLeastOneFailed = true
+		for _, path := range sortedKeys(s.TargetFiles) {
+			targetConfig.AddFile(path, s.TargetFiles[path], 0644)
 		}
 	}
+	return nil
+}
 
-	if atLeastOneFailed {
-		return harness.SuiteFailed
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	return nil
+// scenarioSignals returns the completion signals expected for s, or
+// defaults if it doesn't override them.
+func scenarioSignals(s *kola.IsoScenario, defaults ...string) []string {
+	if len(s.Signals) > 0 {
+		return s.Signals
+	}
+	return defaults
 }
 
 func awaitCompletion(ctx context.Context, inst *platform.QemuInstance, outdir string, qchan *os.File, booterrchan chan error, expected []string) (time.Duration, error) {
@@ -666,23 +562,7 @@ func awaitCompletion(ctx context.Context, inst *platform.QemuInstance, outdir st
 	return time.Since(start), err
 }
 
-func printResult(test string, duration time.Duration, err error) bool {
-	result := "PASS"
-	if err != nil {
-		result = "FAIL"
-	}
-	fmt.Printf("%s: %s (%s)\n", result, test, duration.Round(time.Millisecond).String())
-	if err != nil {
-		fmt.Printf("    %s\n", err)
-		return true
-	}
-	return false
-}
-
-func testPXE(ctx context.Context, inst platform.Install, outdir string) (time.Duration, error) {
-	if addNmKeyfile {
-		return 0, errors.New("--add-nm-keyfile not yet supported for PXE")
-	}
+func testPXE(ctx context.Context, s *kola.IsoScenario, inst platform.Install, outdir string) (time.Duration, error) {
 	tmpd, err := os.MkdirTemp("", "kola-testiso")
 	if err != nil {
 		return 0, errors.Wrapf(err, "creating tempdir")
@@ -694,7 +574,7 @@ func testPXE(ctx context.Context, inst platform.Install, outdir string) (time.Du
 		return 0, errors.Wrapf(err, "creating SSH AuthorizedKey")
 	}
 
-	builder, virtioJournalConfig, err := newQemuBuilderWithDisk(outdir)
+	builder, virtioJournalConfig, err := newQemuBuilderWithDisk(s, outdir)
 	if err != nil {
 		return 0, errors.Wrapf(err, "creating QemuBuilder")
 	}
@@ -712,7 +592,7 @@ func testPXE(ctx context.Context, inst platform.Install, outdir string) (time.Du
 	liveConfig.AddSystemdUnit("live-signal-ok.service", liveSignalOKUnit, conf.Enable)
 	liveConfig.AddSystemdUnit("coreos-test-entered-emergency-target.service", signalFailureUnit, conf.Enable)
 
-	if isOffline {
+	if s.Offline {
 		contents := fmt.Sprintf(downloadCheck, kola.CosaBuild.Meta.BuildID, kola.CosaBuild.Meta.OstreeCommit)
 		liveConfig.AddSystemdUnit("coreos-installer-offline-check.service", contents, conf.Enable)
 	}
@@ -722,7 +602,12 @@ func testPXE(ctx context.Context, inst platform.Install, outdir string) (time.Du
 	targetConfig.AddSystemdUnit("coreos-test-entered-emergency-target.service", signalFailureUnit, conf.Enable)
 	targetConfig.AddSystemdUnit("coreos-test-installer-no-ignition.service", checkNoIgnition, conf.Enable)
 
-	mach, err := inst.PXE(pxeKernelArgs, liveConfig, targetConfig, isOffline)
+	if err := addScenarioConfig(s, &liveConfig, &targetConfig); err != nil {
+		return 0, err
+	}
+	kargs := append(append([]string{}, pxeKernelArgs...), s.LiveKargs...)
+
+	mach, err := inst.PXE(kargs, liveConfig, targetConfig, s.Offline)
 	if err != nil {
 		return 0, errors.Wrapf(err, "running PXE")
 	}
@@ -732,10 +617,10 @@ func testPXE(ctx context.Context, inst platform.Install, outdir string) (time.Du
 		}
 	}()
 
-	return awaitCompletion(ctx, mach.QemuInst, outdir, completionChannel, mach.BootStartedErrorChannel, []string{liveOKSignal, signalCompleteString})
+	return awaitCompletion(ctx, mach.QemuInst, outdir, completionChannel, mach.BootStartedErrorChannel, scenarioSignals(s, liveOKSignal, signalCompleteString))
 }
 
-func testLiveIso(ctx context.Context, inst platform.Install, outdir string, minimal bool) (time.Duration, error) {
+func testLiveIso(ctx context.Context, s *kola.IsoScenario, inst platform.Install, outdir string, minimal bool) (time.Duration, error) {
 	tmpd, err := os.MkdirTemp("", "kola-testiso")
 	if err != nil {
 		return 0, err
@@ -747,7 +632,7 @@ func testLiveIso(ctx context.Context, inst platform.Install, outdir string, mini
 		return 0, err
 	}
 
-	builder, virtioJournalConfig, err := newQemuBuilderWithDisk(outdir)
+	builder, virtioJournalConfig, err := newQemuBuilderWithDisk(s, outdir)
 	if err != nil {
 		return 0, err
 	}
@@ -757,7 +642,7 @@ func testLiveIso(ctx context.Context, inst platform.Install, outdir string, mini
 		return 0, err
 	}
 
-	var isoKernelArgs []string
+	isoKernelArgs := append([]string{}, s.LiveKargs...)
 	var keys []string
 	keys = append(keys, strings.TrimSpace(string(sshPubKeyBuf)))
 	virtioJournalConfig.AddAuthorizedKeys("core", keys)
@@ -776,18 +661,10 @@ func testLiveIso(ctx context.Context, inst platform.Install, outdir string, mini
 		targetConfig.AddSystemdUnit("coreos-test-installer-multipathed.service", multipathedRoot, conf.Enable)
 	}
 
-	if addNmKeyfile {
-		liveConfig.AddSystemdUnit("coreos-test-nm-keyfile.service", verifyNmKeyfile, conf.Enable)
-		targetConfig.AddSystemdUnit("coreos-test-nm-keyfile.service", verifyNmKeyfile, conf.Enable)
-		// NM keyfile via `iso network embed`
-		inst.NmKeyfiles[nmConnectionFile] = nmConnection
-		// nmstate config via live Ignition config, propagated via
-		// --copy-network, which is enabled by inst.NmKeyfiles
-		liveConfig.AddFile(nmstateConfigFile, nmstateConfig, 0644)
-	}
-
-	if isISOFromRAM {
-		isoKernelArgs = append(isoKernelArgs, liveISOFromRAMKarg)
+	// NM keyfiles are embedded with `iso network embed` via
+	// inst.NmKeyfiles, which also enables --copy-network
+	if err := addScenarioConfig(s, &liveConfig, &targetConfig); err != nil {
+		return 0, err
 	}
 
 	// Sometimes the logs that stream from various virtio streams can be
@@ -798,7 +675,7 @@ func testLiveIso(ctx context.Context, inst platform.Install, outdir string, mini
 		isoKernelArgs = append(isoKernelArgs, "systemd.log_color=0 systemd.log_level=debug systemd.log_target=console")
 	}
 
-	mach, err := inst.InstallViaISOEmbed(isoKernelArgs, liveConfig, targetConfig, outdir, isOffline, minimal)
+	mach, err := inst.InstallViaISOEmbed(isoKernelArgs, liveConfig, targetConfig, outdir, s.Offline, minimal)
 	if err != nil {
 		return 0, errors.Wrapf(err, "running iso install")
 	}
@@ -808,20 +685,15 @@ func testLiveIso(ctx context.Context, inst platform.Install, outdir string, mini
 		}
 	}()
 
-	return awaitCompletion(ctx, mach.QemuInst, outdir, completionChannel, mach.BootStartedErrorChannel, []string{liveOKSignal, signalCompleteString})
+	return awaitCompletion(ctx, mach.QemuInst, outdir, completionChannel, mach.BootStartedErrorChannel, scenarioSignals(s, liveOKSignal, signalCompleteString))
 }
 
-// testLiveFIPS verifies that adding fips=1 to the ISO results in a FIPS mode system
-func testLiveFIPS(ctx context.Context, outdir string) (time.Duration, error) {
-	tmpd, err := os.MkdirTemp("", "kola-testiso")
-	if err != nil {
-		return 0, err
-	}
-	defer os.RemoveAll(tmpd)
-
+// testLive boots the live ISO with the config and kernel arguments of the
+// scenario, e.g. fips=1 to verify that it results in a FIPS mode system.
+func testLive(ctx context.Context, s *kola.IsoScenario, outdir string) (time.Duration, error) {
 	builddir := kola.CosaBuild.Dir
 	isopath := filepath.Join(builddir, kola.CosaBuild.Meta.BuildArtifacts.LiveIso.Path)
-	builder, config, err := newQemuBuilder(outdir)
+	builder, config, err := newQemuBuilder(s, outdir)
 	if err != nil {
 		return 0, err
 	}
@@ -829,37 +701,18 @@ func testLiveFIPS(ctx context.Context, outdir string) (time.Duration, error) {
 	if err := builder.AddIso(isopath, "", false); err != nil {
 		return 0, err
 	}
-
-	// This is the core change under test - adding the `fips=1` kernel argument via
-	// coreos-installer iso kargs modify should enter fips mode.
-	// Removing this line should cause this test to fail.
-	builder.AppendKernelArgs = "fips=1"
+	builder.AppendKernelArgs = strings.Join(s.LiveKargs, " ")
 
 	completionChannel, err := builder.VirtioChannelRead("testisocompletion")
 	if err != nil {
 		return 0, err
 	}
 
-	config.AddSystemdUnit("fips-verify.service", `
-[Unit]
-OnFailure=emergency.target
-OnFailureJobMode=isolate
-Before=fips-signal-ok.service
-
-[Service]
-Type=oneshot
-RemainAfterExit=yes
-ExecStart=grep 1 /proc/sys/crypto/fips_enabled
-ExecStart=grep FIPS etc/crypto-policies/config
-
-[Install]
-RequiredBy=fips-signal-ok.service
-`, conf.Enable)
-	config.AddSystemdUnit("fips-signal-ok.service", liveSignalOKUnit, conf.Enable)
-	config.AddSystemdUnit("fips-emergency-target.service", signalFailureUnit, conf.Enable)
-
-	// Just for reliability, we'll run fully offline
-	builder.Append("-net", "none")
+	config.AddSystemdUnit("live-signal-ok.service", liveSignalOKUnit, conf.Enable)
+	config.AddSystemdUnit("coreos-test-entered-emergency-target.service", signalFailureUnit, conf.Enable)
+	if err := addScenarioConfig(s, config, nil); err != nil {
+		return 0, err
+	}
 
 	builder.SetConfig(config)
 	mach, err := builder.Exec()
@@ -868,13 +721,13 @@ RequiredBy=fips-signal-ok.service
 	}
 	defer mach.Destroy()
 
-	return awaitCompletion(ctx, mach, outdir, completionChannel, nil, []string{liveOKSignal})
+	return awaitCompletion(ctx, mach, outdir, completionChannel, nil, scenarioSignals(s, liveOKSignal))
 }
 
-func testLiveLogin(ctx context.Context, outdir string) (time.Duration, error) {
+func testLiveLogin(ctx context.Context, s *kola.IsoScenario, outdir string) (time.Duration, error) {
 	builddir := kola.CosaBuild.Dir
 	isopath := filepath.Join(builddir, kola.CosaBuild.Meta.BuildArtifacts.LiveIso.Path)
-	builder, err := newBaseQemuBuilder(outdir)
+	builder, err := newBaseQemuBuilder(s, outdir)
 	if err != nil {
 		return 0, err
 	}
@@ -889,9 +742,6 @@ func testLiveLogin(ctx context.Context, outdir string) (time.Duration, error) {
 		return 0, err
 	}
 
-	// No network device to test https://github.com/coreos/fedora-coreos-config/pull/326
-	builder.Append("-net", "none")
-
 	mach, err := builder.Exec()
 	if err != nil {
 		return 0, errors.Wrapf(err, "running iso")
@@ -901,10 +751,10 @@ func testLiveLogin(ctx context.Context, outdir string) (time.Duration, error) {
 	return awaitCompletion(ctx, mach, outdir, completionChannel, nil, []string{"coreos-liveiso-success"})
 }
 
-func testAsDisk(ctx context.Context, outdir string) (time.Duration, error) {
+func testAsDisk(ctx context.Context, s *kola.IsoScenario, outdir string) (time.Duration, error) {
 	builddir := kola.CosaBuild.Dir
 	isopath := filepath.Join(builddir, kola.CosaBuild.Meta.BuildArtifacts.LiveIso.Path)
-	builder, config, err := newQemuBuilder(outdir)
+	builder, config, err := newQemuBuilder(s, outdir)
 	if err != nil {
 		return 0, err
 	}
@@ -913,6 +763,7 @@ func testAsDisk(ctx context.Context, outdir string) (time.Duration, error) {
 	if err := builder.AddIso(isopath, "", true); err != nil {
 		return 0, err
 	}
+	builder.AppendKernelArgs = strings.Join(s.LiveKargs, " ")
 
 	completionChannel, err := builder.VirtioChannelRead("testisocompletion")
 	if err != nil {
@@ -921,6 +772,9 @@ func testAsDisk(ctx context.Context, outdir string) (time.Duration, error) {
 
 	config.AddSystemdUnit("live-signal-ok.service", liveSignalOKUnit, conf.Enable)
 	config.AddSystemdUnit("verify-no-efi-boot-entry.service", verifyNoEFIBootEntry, conf.Enable)
+	if err := addScenarioConfig(s, config, nil); err != nil {
+		return 0, err
+	}
 	builder.SetConfig(config)
 
 	mach, err := builder.Exec()
@@ -929,5 +783,5 @@ func testAsDisk(ctx context.Context, outdir string) (time.Duration, error) {
 	}
 	defer mach.Destroy()
 
-	return awaitCompletion(ctx, mach, outdir, completionChannel, nil, []string{liveOKSignal})
+	return awaitCompletion(ctx, mach, outdir, completionChannel, nil, scenarioSignals(s, liveOKSignal))
 }
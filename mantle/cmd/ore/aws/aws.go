@@ -39,6 +39,7 @@ var (
 	profileName     string
 	accessKeyID     string
 	secretAccessKey string
+	endpoint        string
 )
 
 func init() {
@@ -49,9 +50,10 @@ func init() {
 
 	AWS.PersistentFlags().StringVar(&credentialsFile, "credentials-file", "", "AWS credentials file")
 	AWS.PersistentFlags().StringVar(&profileName, "profile", "", "AWS profile name")
-	AWS.PersistentFlags().StringVar(&accessKeyID, "access-id", "", "AWS access key")
-	AWS.PersistentFlags().StringVar(&secretAccessKey, "secret-key", "", "AWS secret key")
+	AWS.PersistentFlags().StringVar(&accessKeyID, "access-id", "", "AWS access key, overriding the credentials file, profile and environment")
+	AWS.PersistentFlags().StringVar(&secretAccessKey, "secret-key", "", "AWS secret key, used with --access-id")
 	AWS.PersistentFlags().StringVar(&region, "region", defaultRegion, "AWS region")
+	AWS.PersistentFlags().StringVar(&endpoint, "endpoint", "", "override the AWS endpoint, e.g. for an emulator")
 	cli.WrapPreRun(AWS, preflightCheck)
 }
 
@@ -61,6 +63,9 @@ func preflightCheck(cmd *cobra.Command, args []string) error {
 		Region:          region,
 		CredentialsFile: credentialsFile,
 		Profile:         profileName,
+		AccessKeyID:     accessKeyID,
+		SecretKey:       secretAccessKey,
+		Endpoint:        endpoint,
 		Options:         &platform.Options{},
 	})
 	if err != nil {
@@ -0,0 +1,114 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/aws"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/fakecloud"
+)
+
+// execute runs ore aws with args against fake and returns its standard
+// output.
+func execute(t *testing.T, fake *fakecloud.AWS, args ...string) []byte {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan []byte)
+	go func() {
+		buf, _ := io.ReadAll(r)
+		out <- buf
+	}()
+
+	AWS.SetArgs(append(args, "--endpoint", fake.Endpoint(), "--access-id", "AKIAFAKECLOUD",
+		"--secret-key", "secret", "--region", "us-east-1"))
+	err = AWS.Execute()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
+
+func TestUploadAndGC(t *testing.T) {
+	// a CA bundle makes the SDK modify http.DefaultClient, see
+	// platform/api/aws
+	t.Setenv("AWS_CA_BUNDLE", "")
+	fake := fakecloud.NewAWS()
+	defer fake.Close()
+	opts := &aws.Options{
+		Options:      &platform.Options{},
+		Region:       "us-east-1",
+		AccessKeyID:  "AKIAFAKECLOUD",
+		SecretKey:    "secret",
+		Endpoint:     fake.Endpoint(),
+		InstanceType: "t3.small",
+	}
+	api, err := aws.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.InitializeBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "fedora-coreos-aws.x86_64.vmdk")
+	if err := os.WriteFile(file, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := execute(t, fake, "upload", "--bucket", "s3://bucket/prefix", "--file", file,
+		"--name", "fedora-coreos-1", "--ami-name", "fedora-coreos-1", "--arch", "x86_64",
+		"--tags", "stream=testing")
+	var result struct {
+		HVM        string
+		SnapshotID string
+		S3Object   string
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("parsing %q: %v", out, err)
+	}
+	if result.S3Object != "s3://bucket/prefix/fedora-coreos-aws.x86_64.vmdk" {
+		t.Errorf("unexpected S3 object %s", result.S3Object)
+	}
+	if id, err := api.FindImage("fedora-coreos-1"); err != nil || id != result.HVM {
+		t.Errorf("expected image %s, got %q, %v", result.HVM, id, err)
+	}
+	// the object is deleted once the snapshot is created
+	if _, err := api.DownloadFile("bucket", "prefix/fedora-coreos-aws.x86_64.vmdk"); err == nil {
+		t.Error("expected the uploaded object to be deleted")
+	}
+
+	opts.AMI = result.HVM
+	instances, err := api.CreateInstances("kola-test", "", "", 2, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execute(t, fake, "gc", "--duration", "0")
+	for _, instance := range instances {
+		if state := fake.InstanceState("us-east-1", *instance.InstanceId); state != "terminated" {
+			t.Errorf("instance %s is %s", *instance.InstanceId, state)
+		}
+	}
+}
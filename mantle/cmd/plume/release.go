@@ -33,6 +33,7 @@ import (
 
 var (
 	awsCredentialsFile string
+	awsEndpoint        string
 
 	specProfile string
 	specRegion  string
@@ -58,6 +59,7 @@ var (
 
 func init() {
 	cmdMakeAmisPublic.Flags().StringVar(&awsCredentialsFile, "aws-credentials", "", "AWS credentials file")
+	cmdMakeAmisPublic.Flags().StringVar(&awsEndpoint, "aws-endpoint", "", "override the AWS endpoint, e.g. for an emulator")
 	cmdMakeAmisPublic.Flags().StringVar(&specBucketPrefix, "bucket-prefix", "", "S3 bucket and prefix")
 	cmdMakeAmisPublic.Flags().StringVar(&specProfile, "profile", "default", "AWS profile")
 	cmdMakeAmisPublic.Flags().StringVar(&specRegion, "region", "us-east-1", "S3 bucket region")
@@ -66,6 +68,7 @@ func init() {
 	root.AddCommand(cmdMakeAmisPublic)
 
 	cmdUpdateReleaseIndex.Flags().StringVar(&awsCredentialsFile, "aws-credentials", "", "AWS credentials file")
+	cmdUpdateReleaseIndex.Flags().StringVar(&awsEndpoint, "aws-endpoint", "", "override the AWS endpoint, e.g. for an emulator")
 	cmdUpdateReleaseIndex.Flags().StringVar(&specBucketPrefix, "bucket-prefix", "", "S3 bucket and prefix")
 	cmdUpdateReleaseIndex.Flags().StringVar(&specProfile, "profile", "default", "AWS profile")
 	cmdUpdateReleaseIndex.Flags().StringVar(&specRegion, "region", "us-east-1", "S3 bucket region")
@@ -115,6 +118,7 @@ func getAWSApi() *aws.API {
 		CredentialsFile: awsCredentialsFile,
 		Profile:         specProfile,
 		Region:          specRegion,
+		Endpoint:        awsEndpoint,
 	})
 	if err != nil {
 		plog.Fatalf("creating aws client: %v", err)
@@ -170,6 +174,7 @@ func makeReleaseAMIsPublic(rel release.Release) bool {
 				CredentialsFile: awsCredentialsFile,
 				Profile:         specProfile,
 				Region:          region,
+				Endpoint:        awsEndpoint,
 			})
 			if err != nil {
 				plog.Warningf("creating AWS API for region %s modifying launch permissions: %v", region, err)
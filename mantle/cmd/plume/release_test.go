@@ -0,0 +1,125 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/stream-metadata-go/release"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	awsapi "github.com/coreos/coreos-assembler/mantle/platform/api/aws"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/fakecloud"
+)
+
+func TestMakeAmisPublicAndUpdateReleaseIndex(t *testing.T) {
+	// a CA bundle makes the SDK modify http.DefaultClient, see
+	// platform/api/aws
+	t.Setenv("AWS_CA_BUNDLE", "")
+	fake := fakecloud.NewAWS()
+	defer fake.Close()
+	credentials := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credentials, []byte("[default]\naws_access_key_id = AKIAFAKECLOUD\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create an AMI in two regions and the release metadata pointing to them
+	api, err := awsapi.New(&awsapi.Options{
+		Options:         &platform.Options{},
+		Region:          "us-east-1",
+		CredentialsFile: credentials,
+		Profile:         "default",
+		Endpoint:        fake.Endpoint(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.InitializeBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.UploadObject(bytes.NewReader([]byte("image")), "bucket", "image.vmdk", false); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := api.CreateSnapshot("image", "s3://bucket/image.vmdk", awsapi.EC2ImageFormatVmdk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ami, err := api.CreateHVMImage(snapshot.SnapshotID, 16, "image", "", "x86_64", "gp3", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]release.CloudImage{"us-east-1": {Image: ami}}
+	err = api.CopyImage(ami, []string{"us-west-2"}, func(region string, data awsapi.ImageData) {
+		images[region] = release.CloudImage{Image: data.AMI}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := release.Release{
+		Release: "40.20260101.3.0",
+		Stream:  "testing",
+		Architectures: map[string]release.Arch{
+			"x86_64": {
+				Commit: "abcdef",
+				Media:  release.Media{Aws: &release.PlatformAws{Images: images}},
+			},
+		},
+	}
+	buf, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.UploadObject(bytes.NewReader(buf), "bucket", "prod/streams/testing/builds/40.20260101.3.0/release.json", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{"make-amis-public", "update-release-index"} {
+		root.SetArgs([]string{command, "--aws-endpoint", fake.Endpoint(), "--aws-credentials", credentials,
+			"--bucket-prefix", "bucket/prod/streams/testing", "--stream", "testing", "--version", "40.20260101.3.0"})
+		if err := root.Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for region, image := range images {
+		if ec2Image := fake.Image(region, image.Image); ec2Image == nil || !aws.BoolValue(ec2Image.Public) {
+			t.Errorf("image %s in %s is not public", image.Image, region)
+		}
+	}
+	f, err := api.DownloadFile("bucket", "prod/streams/testing/releases.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf, err = io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index release.Index
+	if err := json.Unmarshal(buf, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Releases) != 1 || index.Releases[0].Version != "40.20260101.3.0" ||
+		len(index.Releases[0].Commits) != 1 || index.Releases[0].Commits[0].Checksum != "abcdef" {
+		t.Errorf("unexpected release index %+v", index)
+	}
+}
@@ -59,6 +59,54 @@ func FetchAndParseCanonicalReleaseIndex(stream string) (*release.Index, error) {
 	return index, nil
 }
 
+// Updates models the updates metadata of a stream, from which the update
+// graph is built:
+// https://github.com/coreos/fedora-coreos-tracker/blob/main/Design.md#update-metadata
+type Updates struct {
+	Stream   string           `json:"stream"`
+	Metadata release.Metadata `json:"metadata"`
+	Releases []UpdatesRelease `json:"releases"`
+}
+
+// UpdatesRelease is the update metadata of a release
+type UpdatesRelease struct {
+	Version  string                 `json:"version"`
+	Metadata UpdatesReleaseMetadata `json:"metadata"`
+}
+
+// UpdatesReleaseMetadata holds the update hints of a release
+type UpdatesReleaseMetadata struct {
+	Barrier *UpdatesBarrier `json:"barrier,omitempty"`
+	Deadend *UpdatesDeadend `json:"deadend,omitempty"`
+}
+
+// UpdatesBarrier marks a release which older releases must upgrade through
+type UpdatesBarrier struct {
+	Reason string `json:"reason"`
+}
+
+// UpdatesDeadend marks a release which can't be upgraded from
+type UpdatesDeadend struct {
+	Reason string `json:"reason"`
+}
+
+// FetchAndParseCanonicalUpdates returns the updates metadata of a stream
+func FetchAndParseCanonicalUpdates(stream string) (*Updates, error) {
+	u := fcosinternals.GetBaseURL()
+	u.Path = fmt.Sprintf("updates/%s.json", stream)
+	body, err := fetchURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates *Updates
+	if err = json.Unmarshal(body, &updates); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
 // FetchAndParseCanonicalStreamMetadata returns a stream
 func FetchAndParseCanonicalStreamMetadata(streamName string) (*stream.Stream, error) {
 	url := fedoracoreos.GetStreamURL(streamName)
@@ -123,6 +123,24 @@ func (t *H) Release() {
 	}
 }
 
+// Suspend runs f, which is expected to block e.g. waiting for another
+// test to finish, while giving up the parallel slot held by the test, so
+// that other tests can run in the meantime. The slot is reacquired once f
+// returns. The time spent in f is not counted in the test's duration.
+func (t *H) Suspend(f func()) {
+	suspended := time.Now()
+	defer func() {
+		t.start = t.start.Add(time.Since(suspended))
+	}()
+	if !t.isParallel || t.released {
+		f()
+		return
+	}
+	t.suite.release()
+	defer t.suite.waitParallel()
+	f()
+}
+
 func (t *H) StartExecTimer() {
 	ctx, cancel := context.WithCancel(context.Background())
 	t.timeoutContext = ctx
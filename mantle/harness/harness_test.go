@@ -490,3 +490,29 @@ func TestTempFile(t *testing.T) {
 		t.Errorf("%q missing %q prefix", second, "second")
 	}
 }
+
+func TestSuspend(t *testing.T) {
+	suite := NewSuite(Options{Parallel: 1}, nil)
+	h := &H{suite: suite, isParallel: true}
+	// h holds the only parallel slot
+	suite.waitParallel()
+
+	started := make(chan bool)
+	go func() {
+		suite.waitParallel()
+		started <- true
+		suite.release()
+	}()
+
+	h.Suspend(func() {
+		select {
+		case <-started:
+		case <-time.After(10 * time.Second):
+			t.Error("suspended test kept its parallel slot")
+		}
+	})
+	if suite.running != 1 || suite.waiting != 0 {
+		t.Errorf("running: %d, waiting: %d after resuming", suite.running, suite.waiting)
+	}
+	suite.release()
+}
@@ -0,0 +1,259 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+// junitExcerptLines is the number of trailing lines of each machine
+// console and journal log that are attached to a testcase.
+const junitExcerptLines = 100
+
+type junitReporter struct {
+	tests    []junitTest
+	result   testresult.TestResult
+	filename string
+
+	// Context variables
+	platform string
+	version  string
+
+	mutex sync.Mutex
+}
+
+type junitTest struct {
+	name     string
+	result   testresult.TestResult
+	duration time.Duration
+	output   string
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// NewJUnitReporter creates a Reporter which writes JUnit XML to filename
+// in the reports directory. Subtests are recorded as testcases whose
+// classname is the name of their parent test, and warn-only failures are
+// recorded as passing testcases carrying a "result" property of WARN.
+func NewJUnitReporter(filename, platform, version string) *junitReporter {
+	return &junitReporter{
+		platform: platform,
+		version:  version,
+		filename: filename,
+		mutex:    sync.Mutex{},
+	}
+}
+
+func (r *junitReporter) ReportTest(name string, subtests []string, result testresult.TestResult, duration time.Duration, b []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tests = append(r.tests, junitTest{
+		name:     name,
+		result:   result,
+		duration: duration,
+		output:   string(b),
+	})
+}
+
+// Output writes the JUnit XML file into path. The harness passes the
+// "reports" directory of the suite output, so machine logs of a test
+// are found under ../<test name>/<machine id>/.
+func (r *junitReporter) Output(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	doc := r.build(filepath.Dir(path))
+	f, err := os.Create(filepath.Join(path, r.filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\n")
+	return err
+}
+
+func (r *junitReporter) SetResult(result testresult.TestResult) {
+	r.result = result
+}
+
+// build assembles the XML document. One testsuite is created per
+// top-level test; the testsuite holds a testcase for the test itself
+// and one for each of its (transitive) subtests.
+func (r *junitReporter) build(outputDir string) junitTestSuites {
+	suites := map[string]*junitTestSuite{}
+	var total time.Duration
+	for _, t := range r.tests {
+		top := strings.SplitN(t.name, "/", 2)[0]
+		suite, ok := suites[top]
+		if !ok {
+			suite = &junitTestSuite{Name: top}
+			suites[top] = suite
+		}
+
+		classname := r.platform
+		name := t.name
+		if i := strings.LastIndex(t.name, "/"); i >= 0 {
+			classname = t.name[:i]
+			name = t.name[i+1:]
+		}
+		tc := junitTestCase{
+			Name:      name,
+			Classname: classname,
+			Time:      junitSeconds(t.duration),
+			SystemOut: t.output + machineExcerpts(filepath.Join(outputDir, t.name)),
+		}
+		switch t.result {
+		case testresult.Fail:
+			tc.Failure = &junitMessage{Message: "test failed", Type: "FAIL"}
+			suite.Failures++
+		case testresult.Skip:
+			tc.Skipped = &junitMessage{Message: "test skipped"}
+			suite.Skipped++
+		case testresult.Warn:
+			tc.Properties = []junitProperty{{Name: "result", Value: string(testresult.Warn)}}
+			tc.SystemErr = "test failed but is marked as warn-only"
+		}
+		suite.Tests++
+		if t.name == top {
+			suite.Time = junitSeconds(t.duration)
+			total += t.duration
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	doc := junitTestSuites{
+		Name: "kola",
+		Time: junitSeconds(total),
+	}
+	var names []string
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite := suites[name]
+		suite.Properties = []junitProperty{
+			{Name: "platform", Value: r.platform},
+			{Name: "version", Value: r.version},
+		}
+		sort.SliceStable(suite.Cases, func(i, j int) bool {
+			return suite.Cases[i].Classname+"/"+suite.Cases[i].Name < suite.Cases[j].Classname+"/"+suite.Cases[j].Name
+		})
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Suites = append(doc.Suites, *suite)
+	}
+	return doc
+}
+
+// machineExcerpts returns the tail of the console and journal logs
+// written by a test, either directly into its output directory or
+// into one subdirectory per machine.
+func machineExcerpts(testDir string) string {
+	var buf strings.Builder
+	for _, log := range []string{"console.txt", "journal.txt"} {
+		paths, err := filepath.Glob(filepath.Join(testDir, "*", log))
+		if err != nil {
+			continue
+		}
+		sort.Strings(paths)
+		if _, err := os.Stat(filepath.Join(testDir, log)); err == nil {
+			paths = append([]string{filepath.Join(testDir, log)}, paths...)
+		}
+		for _, p := range paths {
+			data, err := os.ReadFile(p)
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			rel, err := filepath.Rel(testDir, p)
+			if err != nil {
+				rel = p
+			}
+			fmt.Fprintf(&buf, "\n===== %s (last %d lines) =====\n%s", rel, junitExcerptLines, tailLines(string(data), junitExcerptLines))
+		}
+	}
+	return buf.String()
+}
+
+func tailLines(s string, n int) string {
+	lines := strings.SplitAfter(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "") + "\n"
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
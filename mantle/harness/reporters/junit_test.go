@@ -0,0 +1,90 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	dir := t.TempDir()
+	reportDir := filepath.Join(dir, "reports")
+	if err := os.Mkdir(reportDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	machDir := filepath.Join(dir, "basic", "machine0")
+	if err := os.MkdirAll(machDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(machDir, "console.txt"), []byte("booting\nlogin:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewJUnitReporter("junit.xml", "qemu", "39.20260101.dev.0")
+	r.ReportTest("basic/sub1", nil, testresult.Pass, time.Second, []byte("ok"))
+	r.ReportTest("basic/sub2", nil, testresult.Warn, time.Second, []byte("flaky"))
+	r.ReportTest("basic", []string{"sub1", "sub2"}, testresult.Pass, 3*time.Second, nil)
+	r.ReportTest("skipped", nil, testresult.Skip, 0, nil)
+	r.ReportTest("broken", nil, testresult.Fail, 2*time.Second, []byte("boom"))
+	r.SetResult(testresult.Fail)
+	if err := r.Output(reportDir); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(reportDir, "junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parsing junit.xml: %v", err)
+	}
+
+	if doc.Tests != 5 || doc.Failures != 1 || doc.Skipped != 1 {
+		t.Errorf("unexpected totals: tests=%d failures=%d skipped=%d", doc.Tests, doc.Failures, doc.Skipped)
+	}
+	if doc.Time != "5.000" {
+		t.Errorf("expected total time 5.000, got %s", doc.Time)
+	}
+	if len(doc.Suites) != 3 {
+		t.Fatalf("expected 3 testsuites, got %d", len(doc.Suites))
+	}
+	basic := doc.Suites[0]
+	if basic.Name != "basic" || len(basic.Cases) != 3 {
+		t.Fatalf("unexpected basic testsuite: %+v", basic)
+	}
+	if basic.Cases[0].Classname != "basic" || basic.Cases[0].Name != "sub1" {
+		t.Errorf("subtest not nested under parent: %+v", basic.Cases[0])
+	}
+	if !strings.Contains(basic.Cases[2].SystemOut, "machine0/console.txt") || !strings.Contains(basic.Cases[2].SystemOut, "login:") {
+		t.Errorf("console excerpt missing from system-out: %q", basic.Cases[2].SystemOut)
+	}
+	if basic.Cases[1].Failure != nil || len(basic.Cases[1].Properties) != 1 || basic.Cases[1].Properties[0].Value != "WARN" {
+		t.Errorf("warn-only failure not recorded as WARN property: %+v", basic.Cases[1])
+	}
+	if doc.Suites[1].Name != "broken" || doc.Suites[1].Cases[0].Failure == nil {
+		t.Errorf("failure not recorded: %+v", doc.Suites[1])
+	}
+	if doc.Suites[2].Name != "skipped" || doc.Suites[2].Cases[0].Skipped == nil {
+		t.Errorf("skip not recorded: %+v", doc.Suites[2])
+	}
+}
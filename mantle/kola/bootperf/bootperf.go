@@ -0,0 +1,341 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bootperf collects boot time measurements of machines, summarizes
+// them over several boots and compares them against a baseline.
+package bootperf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+// Results are the measurements of several boots of a build.
+type Results struct {
+	Build    string `json:"build,omitempty"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch,omitempty"`
+	Boots    []Boot `json:"boots"`
+	// Stats are the statistics of each metric over the boots, see
+	// Boot.Metrics.
+	Stats map[string]Stats `json:"stats"`
+}
+
+// Boot are the measurements of a single boot, in seconds.
+type Boot struct {
+	// Time holds the boot phases reported by `systemd-analyze time`
+	// (e.g. kernel, initrd, userspace), the total, and the time after
+	// which the default target was reached in userspace.
+	Time map[string]float64 `json:"time"`
+	// Blame holds the time each unit took to start, as reported by
+	// `systemd-analyze blame`.
+	Blame map[string]float64 `json:"blame"`
+	// CriticalChain is the critical chain of the default target, as
+	// reported by `systemd-analyze critical-chain`, starting with it.
+	CriticalChain []ChainUnit `json:"critical-chain"`
+	// Ignition is the time since the kernel started after which
+	// ignition-complete.target was reached, if it was.
+	Ignition float64 `json:"ignition,omitempty"`
+	// SSH is the time after which the machine could be reached over SSH,
+	// from its creation: it includes provisioning the machine on the
+	// platform, not only its boot.
+	SSH float64 `json:"ssh"`
+}
+
+// ChainUnit is a unit of a critical chain.
+type ChainUnit struct {
+	Unit string `json:"unit"`
+	// At is the time the unit became active or started.
+	At float64 `json:"at"`
+	// Duration is the time the unit took to start, if known.
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Metrics returns the measurements of the boot by metric name:
+// time/<phase>, blame/<unit>, critical-chain/<unit> (when the unit became
+// active), ignition and ssh.
+func (b *Boot) Metrics() map[string]float64 {
+	metrics := make(map[string]float64)
+	for phase, v := range b.Time {
+		metrics["time/"+phase] = v
+	}
+	for unit, v := range b.Blame {
+		metrics["blame/"+unit] = v
+	}
+	for _, u := range b.CriticalChain {
+		metrics["critical-chain/"+u.Unit] = u.At
+	}
+	if b.Ignition > 0 {
+		metrics["ignition"] = b.Ignition
+	}
+	metrics["ssh"] = b.SSH
+	return metrics
+}
+
+// Collect measures the boot of the machine, once startup has finished.
+// The time to SSH readiness isn't known to the machine and is left to the
+// caller.
+func Collect(m platform.Machine) (*Boot, error) {
+	run := func(cmd string) (string, error) {
+		out, stderr, err := m.SSH(cmd)
+		if err != nil {
+			return "", fmt.Errorf("running %q: %s: %v", cmd, stderr, err)
+		}
+		return string(out), nil
+	}
+
+	// systemd-analyze time fails until startup has finished, which
+	// `is-system-running --wait` waits for; it exits non-zero if the
+	// system is degraded, which doesn't matter here
+	_, _, _ = m.SSH("systemctl is-system-running --wait")
+	var timeOut string
+	if err := util.Retry(30, 2*time.Second, func() error {
+		var err error
+		timeOut, err = run("systemd-analyze time")
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	var boot Boot
+	var err error
+	if boot.Time, err = ParseTime(timeOut); err != nil {
+		return nil, err
+	}
+	out, err := run("systemd-analyze blame --no-pager")
+	if err != nil {
+		return nil, err
+	}
+	if boot.Blame, err = ParseBlame(out); err != nil {
+		return nil, err
+	}
+	out, err = run("systemd-analyze critical-chain --no-pager")
+	if err != nil {
+		return nil, err
+	}
+	if boot.CriticalChain, err = ParseCriticalChain(out); err != nil {
+		return nil, err
+	}
+	out, err = run("journalctl -b --no-pager -o json UNIT=ignition-complete.target JOB_RESULT=done")
+	if err != nil {
+		return nil, err
+	}
+	if boot.Ignition, err = parseIgnition(out); err != nil {
+		return nil, err
+	}
+	return &boot, nil
+}
+
+var (
+	timespanPartRe = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)(y|month|w|d|h|min|s|ms|us|µs|μs)$`)
+	timespanUnits  = map[string]float64{
+		"y":     31557600,
+		"month": 2629800,
+		"w":     604800,
+		"d":     86400,
+		"h":     3600,
+		"min":   60,
+		"s":     1,
+		"ms":    1e-3,
+		"us":    1e-6,
+		"µs":    1e-6,
+		"μs":    1e-6,
+	}
+)
+
+// parseTimespan parses a timespan formatted by systemd, e.g. "1min 2.345s",
+// into seconds.
+func parseTimespan(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty timespan")
+	}
+	var secs float64
+	for _, f := range fields {
+		m := timespanPartRe.FindStringSubmatch(f)
+		if m == nil {
+			return 0, fmt.Errorf("invalid timespan %q", s)
+		}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timespan %q: %v", s, err)
+		}
+		secs += v * timespanUnits[m[2]]
+	}
+	return secs, nil
+}
+
+var (
+	timePhaseRe  = regexp.MustCompile(`^(.+) \((.+)\)$`)
+	timeTargetRe = regexp.MustCompile(`^(\S+) reached after (.+) in userspace`)
+)
+
+// ParseTime parses the output of `systemd-analyze time`, e.g.
+//
+//	Startup finished in 1.263s (kernel) + 2.634s (initrd) + 8.329s (userspace) = 12.227s
+//	multi-user.target reached after 8.311s in userspace.
+//
+// into the time of each phase, the total and the time of the target.
+func ParseTime(out string) (map[string]float64, error) {
+	times := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		line = strings.TrimSpace(line)
+		if m := timeTargetRe.FindStringSubmatch(line); m != nil {
+			v, err := parseTimespan(m[2])
+			if err != nil {
+				return nil, err
+			}
+			times[m[1]] = v
+			continue
+		}
+		if !strings.HasPrefix(line, "Startup finished in ") {
+			continue
+		}
+		phases := strings.TrimPrefix(line, "Startup finished in ")
+		phases, total, ok := strings.Cut(phases, " = ")
+		if !ok {
+			return nil, fmt.Errorf("no total in %q", line)
+		}
+		for _, p := range strings.Split(phases, " + ") {
+			m := timePhaseRe.FindStringSubmatch(p)
+			if m == nil {
+				return nil, fmt.Errorf("invalid boot phase %q", p)
+			}
+			v, err := parseTimespan(m[1])
+			if err != nil {
+				return nil, err
+			}
+			times[m[2]] = v
+		}
+		v, err := parseTimespan(total)
+		if err != nil {
+			return nil, err
+		}
+		times["total"] = v
+	}
+	if _, ok := times["total"]; !ok {
+		return nil, fmt.Errorf("no startup time in %q", out)
+	}
+	return times, nil
+}
+
+// ParseBlame parses the output of `systemd-analyze blame`, e.g.
+//
+//	1min 2.345s ignition-fetch.service
+//	    345ms systemd-udevd.service
+//
+// into the time each unit took to start.
+func ParseBlame(out string) (map[string]float64, error) {
+	blame := make(map[string]float64)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		unit := fields[len(fields)-1]
+		v, err := parseTimespan(strings.Join(fields[:len(fields)-1], " "))
+		if err != nil {
+			return nil, fmt.Errorf("parsing blame of %s: %v", unit, err)
+		}
+		blame[unit] = v
+	}
+	return blame, nil
+}
+
+var chainUnitRe = regexp.MustCompile(`^(\S+) @(.+?)(?: \+(.+))?$`)
+
+// ParseCriticalChain parses the output of `systemd-analyze critical-chain`,
+// e.g.
+//
+//	multi-user.target @8.311s
+//	└─zincati.service @6.122s +2.188s
+//	  └─basic.target @5.999s
+//
+// skipping its header.
+func ParseCriticalChain(out string) ([]ChainUnit, error) {
+	var chain []ChainUnit
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, " │├└─"))
+		m := chainUnitRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		u := ChainUnit{Unit: m[1]}
+		var err error
+		if u.At, err = parseTimespan(m[2]); err != nil {
+			return nil, fmt.Errorf("parsing critical chain of %s: %v", u.Unit, err)
+		}
+		if m[3] != "" {
+			if u.Duration, err = parseTimespan(m[3]); err != nil {
+				return nil, fmt.Errorf("parsing critical chain of %s: %v", u.Unit, err)
+			}
+		}
+		chain = append(chain, u)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no critical chain in %q", out)
+	}
+	return chain, nil
+}
+
+// parseIgnition returns the monotonic time of the first journal entry in
+// out, if any.
+func parseIgnition(out string) (float64, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	if line == "" {
+		return 0, nil
+	}
+	var entry struct {
+		Monotonic string `json:"__MONOTONIC_TIMESTAMP"`
+	}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return 0, fmt.Errorf("parsing journal entry: %v", err)
+	}
+	usecs, err := strconv.ParseInt(entry.Monotonic, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing monotonic timestamp %q: %v", entry.Monotonic, err)
+	}
+	return float64(usecs) / 1e6, nil
+}
+
+// Load reads results written by Save.
+func Load(path string) (*Results, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Results
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return &r, nil
+}
+
+// Save summarizes the results and writes them to path.
+func (r *Results) Save(path string) error {
+	r.Stats = Summarize(r.Boots)
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
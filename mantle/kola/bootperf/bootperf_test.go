@@ -0,0 +1,245 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootperf
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseTime(t *testing.T) {
+	out := `Startup finished in 1.263s (kernel) + 2.634s (initrd) + 1min 8.329s (userspace) = 1min 12.226s
+multi-user.target reached after 8.311s in userspace.
+`
+	times, err := ParseTime(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{
+		"kernel":            1.263,
+		"initrd":            2.634,
+		"userspace":         68.329,
+		"total":             72.226,
+		"multi-user.target": 8.311,
+	}
+	if len(times) != len(expected) {
+		t.Errorf("unexpected times %v", times)
+	}
+	for k, v := range expected {
+		if !near(times[k], v) {
+			t.Errorf("%s: expected %v, got %v", k, v, times[k])
+		}
+	}
+
+	if _, err := ParseTime("Bootup is not yet finished."); err == nil {
+		t.Error("expected an error for an unfinished boot")
+	}
+}
+
+func TestParseBlame(t *testing.T) {
+	out := `1min 2.345s ignition-fetch.service
+    345ms systemd-udevd.service
+     12us dev-hugepages.mount
+`
+	blame, err := ParseBlame(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blame) != 3 || !near(blame["ignition-fetch.service"], 62.345) ||
+		!near(blame["systemd-udevd.service"], 0.345) || !near(blame["dev-hugepages.mount"], 12e-6) {
+		t.Errorf("unexpected blame %v", blame)
+	}
+
+	if _, err := ParseBlame("soon foo.service"); err == nil {
+		t.Error("expected an error for an invalid timespan")
+	}
+}
+
+func TestParseCriticalChain(t *testing.T) {
+	out := `The time when unit became active or started is printed after the "@" character.
+The time the unit took to start is printed after the "+" character.
+
+multi-user.target @8.311s
+└─zincati.service @6.122s +2.188s
+  └─basic.target @5.999s
+`
+	chain, err := ParseCriticalChain(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ChainUnit{
+		{Unit: "multi-user.target", At: 8.311},
+		{Unit: "zincati.service", At: 6.122, Duration: 2.188},
+		{Unit: "basic.target", At: 5.999},
+	}
+	if len(chain) != len(expected) {
+		t.Fatalf("unexpected chain %v", chain)
+	}
+	for i, u := range expected {
+		if chain[i].Unit != u.Unit || !near(chain[i].At, u.At) || !near(chain[i].Duration, u.Duration) {
+			t.Errorf("expected %v, got %v", u, chain[i])
+		}
+	}
+}
+
+func TestParseIgnition(t *testing.T) {
+	v, err := parseIgnition(`{"__MONOTONIC_TIMESTAMP":"4512345","UNIT":"ignition-complete.target"}` + "\n")
+	if err != nil || !near(v, 4.512345) {
+		t.Errorf("expected 4.512345, got %v %v", v, err)
+	}
+	if v, err := parseIgnition(""); err != nil || v != 0 {
+		t.Errorf("expected no time without entries, got %v %v", v, err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var boots []Boot
+	for _, v := range []float64{5, 1, 4, 2, 3} {
+		boots = append(boots, Boot{SSH: v, Blame: map[string]float64{"a.service": v}})
+	}
+	boots[0].Blame["b.service"] = 1
+	stats := Summarize(boots)
+	s := stats["ssh"]
+	if s.N != 5 || !near(s.Mean, 3) || !near(s.Median, 3) || !near(s.P90, 4.6) ||
+		!near(s.Stddev, math.Sqrt(2.5)) || s.Min != 1 || s.Max != 5 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if stats["blame/a.service"] != s {
+		t.Errorf("expected the same stats for a.service, got %+v", stats["blame/a.service"])
+	}
+	if b := stats["blame/b.service"]; b.N != 1 || b.Stddev != 0 || b.Median != 1 {
+		t.Errorf("unexpected stats for a single value %+v", b)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	results := func(ssh, ignition []float64) *Results {
+		r := &Results{Platform: "qemu"}
+		for i := range ssh {
+			r.Boots = append(r.Boots, Boot{SSH: ssh[i], Ignition: ignition[i], Blame: map[string]float64{"noise.service": 0.01}})
+		}
+		return r
+	}
+	baseline := results([]float64{10, 10.2, 9.9, 10.1, 10}, []float64{5, 5.1, 4.9, 5, 5.2})
+	current := results([]float64{12, 12.3, 11.9, 12.1, 12.2}, []float64{5.1, 4.9, 5, 5.2, 5})
+	opts := CompareOptions{Alpha: 0.05, Threshold: 0.1, MinDelta: 0.1}
+
+	cmps := Compare(baseline, current, opts)
+	if len(cmps) != 3 || cmps[0].Metric != "blame/noise.service" || cmps[1].Metric != "ignition" || cmps[2].Metric != "ssh" {
+		t.Fatalf("unexpected comparisons %+v", cmps)
+	}
+	if cmps[0].Gating || !cmps[1].Gating || !cmps[2].Gating {
+		t.Errorf("only the units should be informational: %+v", cmps)
+	}
+	if cmps[0].Regression || cmps[0].Improvement {
+		t.Errorf("unchanged metric flagged: %+v", cmps[0])
+	}
+	if cmps[1].Regression || cmps[1].PValue < 0.05 {
+		t.Errorf("noise flagged as a regression: %+v", cmps[1])
+	}
+	if !cmps[2].Regression || !near(cmps[2].Change, 0.21) || cmps[2].AdjustedPValue >= 0.05 {
+		t.Errorf("regression not flagged: %+v", cmps[2])
+	}
+	if !near(cmps[2].AdjustedPValue, 2*cmps[2].PValue) {
+		t.Errorf("p-value of ssh not corrected for ignition: %+v", cmps[2])
+	}
+
+	cmps = Compare(current, baseline, opts)
+	if !cmps[2].Improvement || cmps[2].Regression {
+		t.Errorf("improvement not flagged: %+v", cmps[2])
+	}
+
+	// a significant but small increase isn't a regression
+	opts.Threshold = 0.5
+	if cmps = Compare(baseline, current, opts); cmps[2].Regression {
+		t.Errorf("increase below the threshold flagged: %+v", cmps[2])
+	}
+}
+
+func TestAdjustPValues(t *testing.T) {
+	p := []float64{0.01, 0.04, 0.03, 0.005}
+	for _, tt := range []struct {
+		name   string
+		method func([]float64) []float64
+		want   []float64
+	}{
+		{"holm", holm, []float64{0.03, 0.06, 0.06, 0.02}},
+		{"benjamini-hochberg", benjaminiHochberg, []float64{0.02, 0.04, 0.04, 0.02}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.method(p)
+			for i := range tt.want {
+				if !near(got[i], tt.want[i]) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareManyUnits(t *testing.T) {
+	// a unit slower in every boot, among many unchanged units: its
+	// p-value is significant on its own, but not once corrected for the
+	// number of units
+	results := func(slow []float64) *Results {
+		r := &Results{Platform: "qemu"}
+		for i := range slow {
+			blame := map[string]float64{"slow.service": slow[i]}
+			for j := 0; j < 100; j++ {
+				blame[fmt.Sprintf("unit%d.service", j)] = 1 + float64((i+j)%3)/10
+			}
+			r.Boots = append(r.Boots, Boot{Blame: blame})
+		}
+		return r
+	}
+	baseline := results([]float64{1, 1.2, 1.4, 1.6})
+	current := results([]float64{1.7, 1.8, 1.9, 2})
+	opts := CompareOptions{Alpha: 0.05, Threshold: 0.1, MinDelta: 0.1}
+
+	found := false
+	for _, cmp := range Compare(baseline, current, opts) {
+		if cmp.Metric != "blame/slow.service" {
+			continue
+		}
+		found = true
+		if cmp.PValue >= 0.05 || cmp.AdjustedPValue < 0.05 || cmp.Regression {
+			t.Errorf("uncorrected regression: %+v", cmp)
+		}
+	}
+	if !found {
+		t.Error("blame/slow.service not compared")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bootperf.json")
+	in := &Results{Build: "41.1", Platform: "qemu", Boots: []Boot{{SSH: 2, Time: map[string]float64{"total": 1}}}}
+	if err := in.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Build != "41.1" || len(out.Boots) != 1 || out.Stats["time/total"].Median != 1 {
+		t.Errorf("unexpected results %+v", out)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootperf
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Stats are the statistics of a metric over several boots, in seconds.
+type Stats struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Stddev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// samples returns the values of each metric over the boots.
+func samples(boots []Boot) map[string][]float64 {
+	ret := make(map[string][]float64)
+	for i := range boots {
+		for metric, v := range boots[i].Metrics() {
+			ret[metric] = append(ret[metric], v)
+		}
+	}
+	return ret
+}
+
+// Summarize returns the statistics of each metric over the boots. Metrics
+// missing from some boots, e.g. units which didn't start on every boot, are
+// summarized over the boots which have them.
+func Summarize(boots []Boot) map[string]Stats {
+	ret := make(map[string]Stats)
+	for metric, values := range samples(boots) {
+		ret[metric] = summarize(values)
+	}
+	return ret
+}
+
+func summarize(values []float64) Stats {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	s := Stats{
+		N:      len(sorted),
+		Median: percentile(sorted, 0.5),
+		P90:    percentile(sorted, 0.9),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+	}
+	for _, v := range sorted {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+	if s.N > 1 {
+		var sq float64
+		for _, v := range sorted {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.Stddev = math.Sqrt(sq / float64(s.N-1))
+	}
+	return s
+}
+
+// percentile interpolates the p-th percentile of sorted values linearly.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// CompareOptions set when a change of a metric is reported.
+type CompareOptions struct {
+	// Alpha is the significance level of the changes.
+	Alpha float64
+	// Threshold is the minimum relative change of the median, e.g. 0.1
+	// for 10%.
+	Threshold float64
+	// MinDelta is the minimum absolute change of the median, in seconds,
+	// so that noise on units taking a few milliseconds isn't reported.
+	MinDelta float64
+}
+
+// Comparison is the change of a metric between a baseline and the current
+// results.
+type Comparison struct {
+	Metric   string `json:"metric"`
+	Baseline Stats  `json:"baseline"`
+	Current  Stats  `json:"current"`
+	// Change is the relative change of the median.
+	Change float64 `json:"change"`
+	// PValue is the probability of the current values being at least as
+	// much larger (for a regression) or smaller (for an improvement) than
+	// the baseline ones by chance, from a one-sided Mann-Whitney U test.
+	PValue float64 `json:"p-value"`
+	// AdjustedPValue is PValue corrected for comparing many metrics at
+	// once: with the Holm-Bonferroni method among the gating metrics, and
+	// the Benjamini-Hochberg method among the informational ones.
+	AdjustedPValue float64 `json:"adjusted-p-value"`
+	// Gating is set if a regression of the metric fails the comparison,
+	// see Gating.
+	Gating      bool `json:"gating,omitempty"`
+	Regression  bool `json:"regression,omitempty"`
+	Improvement bool `json:"improvement,omitempty"`
+}
+
+// Gating returns whether a regression of the metric fails a comparison.
+// Only the boot phases, Ignition and SSH do: the units of blame and
+// critical-chain are too many and too noisy, and their changes are
+// informational.
+func Gating(metric string) bool {
+	return strings.HasPrefix(metric, "time/") || metric == "ignition" || metric == "ssh"
+}
+
+// Compare compares the metrics measured in both results, sorted by name. A
+// metric regressed (or improved) if its median increased (or decreased) by
+// at least opts.Threshold and opts.MinDelta, and the increase (or decrease)
+// is significant at opts.Alpha once corrected for the number of metrics,
+// see Comparison.AdjustedPValue.
+func Compare(baseline, current *Results, opts CompareOptions) []Comparison {
+	base, cur := samples(baseline.Boots), samples(current.Boots)
+	var ret []Comparison
+	for metric, b := range base {
+		c, ok := cur[metric]
+		if !ok {
+			continue
+		}
+		cmp := Comparison{
+			Metric:   metric,
+			Baseline: summarize(b),
+			Current:  summarize(c),
+			Gating:   Gating(metric),
+		}
+		delta := cmp.Current.Median - cmp.Baseline.Median
+		if cmp.Baseline.Median != 0 {
+			cmp.Change = delta / cmp.Baseline.Median
+		}
+		if delta >= 0 {
+			cmp.PValue = mannWhitneyGreater(c, b)
+		} else {
+			cmp.PValue = mannWhitneyGreater(b, c)
+		}
+		ret = append(ret, cmp)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Metric < ret[j].Metric })
+
+	var gating, informational []int
+	for i := range ret {
+		if ret[i].Gating {
+			gating = append(gating, i)
+		} else {
+			informational = append(informational, i)
+		}
+	}
+	adjust(ret, gating, holm)
+	adjust(ret, informational, benjaminiHochberg)
+
+	for i := range ret {
+		cmp := &ret[i]
+		delta := cmp.Current.Median - cmp.Baseline.Median
+		large := math.Abs(delta) >= opts.MinDelta && math.Abs(cmp.Change) >= opts.Threshold
+		significant := large && cmp.AdjustedPValue < opts.Alpha
+		cmp.Regression = significant && delta >= 0
+		cmp.Improvement = significant && delta < 0
+	}
+	return ret
+}
+
+// adjust sets the adjusted p-values of the comparisons with the indexes,
+// as corrected together by method.
+func adjust(cmps []Comparison, indexes []int, method func([]float64) []float64) {
+	p := make([]float64, len(indexes))
+	for i, idx := range indexes {
+		p[i] = cmps[idx].PValue
+	}
+	for i, adjusted := range method(p) {
+		cmps[indexes[i]].AdjustedPValue = adjusted
+	}
+}
+
+// ascending returns the indexes of p sorted by increasing p-value.
+func ascending(p []float64) []int {
+	order := make([]int, len(p))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return p[order[i]] < p[order[j]] })
+	return order
+}
+
+// holm returns the p-values adjusted with the Holm-Bonferroni method, which
+// controls the probability of any false positive.
+func holm(p []float64) []float64 {
+	m := len(p)
+	ret := make([]float64, m)
+	max := 0.0
+	for rank, i := range ascending(p) {
+		max = math.Max(max, math.Min(1, float64(m-rank)*p[i]))
+		ret[i] = max
+	}
+	return ret
+}
+
+// benjaminiHochberg returns the p-values adjusted with the
+// Benjamini-Hochberg method, which controls the expected proportion of
+// false positives among the positives.
+func benjaminiHochberg(p []float64) []float64 {
+	m := len(p)
+	ret := make([]float64, m)
+	order := ascending(p)
+	min := 1.0
+	for rank := m - 1; rank >= 0; rank-- {
+		i := order[rank]
+		min = math.Min(min, float64(m)/float64(rank+1)*p[i])
+		ret[i] = min
+	}
+	return ret
+}
+
+// mannWhitneyGreater returns the p-value of the one-sided Mann-Whitney U
+// test of the values of x being larger than the values of y, using the
+// normal approximation with tie and continuity corrections.
+func mannWhitneyGreater(x, y []float64) float64 {
+	n1, n2 := float64(len(x)), float64(len(y))
+	type sample struct {
+		v float64
+		x bool
+	}
+	var all []sample
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// sum the ranks of x, averaging the ranks of ties
+	var rx, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].x {
+				rx += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := rx - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := (u - mean - 0.5) / math.Sqrt(variance)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/network/ntp"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+// Clock controls the time served to the machines of the cluster by its NTP
+// server, which chrony on the machines is pointed to. Machines follow the
+// changes of the served time within a few seconds; Sync waits for that.
+type Clock struct {
+	server *ntp.Server
+}
+
+// Clock returns the clock of the cluster, which requires the test to have
+// the ControlledClock flag. The test is skipped on platforms which can't
+// serve time to their machines.
+func (t *TestCluster) Clock() *Clock {
+	cc, ok := t.Cluster.(platform.ClockCluster)
+	if !ok {
+		t.Skipf("controlling the clock is not supported on platform %s", t.Platform())
+	}
+	server, _ := cc.NTPServer()
+	if server == nil {
+		t.Fatal("the cluster has no NTP server; does the test have the ControlledClock flag?")
+	}
+	return &Clock{server: server}
+}
+
+// Now returns the time currently served.
+func (c *Clock) Now() time.Time {
+	return c.server.Now()
+}
+
+// Set makes the server serve time from now on, e.g. a date after the
+// expiry of a certificate. The zero time goes back to the real time.
+func (c *Clock) Set(now time.Time) {
+	c.server.SetTime(now)
+}
+
+// Jump moves the served time forward, or backward if d is negative.
+func (c *Clock) Jump(d time.Duration) {
+	c.server.SetTime(c.server.Now().Add(d))
+}
+
+// Skew makes the served time run faster (positive skew) or slower
+// (negative skew) than the real time by the given fraction, e.g. 1e-4 for
+// 100ppm.
+func (c *Clock) Skew(skew float64) {
+	c.server.SetSkew(skew)
+}
+
+// Reset serves the real time again.
+func (c *Clock) Reset() {
+	c.server.SetSkew(0)
+	c.server.SetTime(time.Time{})
+	c.server.SetLeapSecond(time.Time{}, ntp.LEAP_NONE)
+}
+
+// InsertLeapSecond announces a leap second inserted right before at, which
+// must be midnight UTC on January or July 1st: chrony ignores leap seconds
+// on other days. Set the time shortly before it to see it happen.
+func (c *Clock) InsertLeapSecond(at time.Time) error {
+	if err := checkLeapSecond(at); err != nil {
+		return err
+	}
+	c.server.SetLeapSecond(at, ntp.LEAP_ADD)
+	return nil
+}
+
+// DeleteLeapSecond announces a leap second deleted right before at, see
+// InsertLeapSecond.
+func (c *Clock) DeleteLeapSecond(at time.Time) error {
+	if err := checkLeapSecond(at); err != nil {
+		return err
+	}
+	c.server.SetLeapSecond(at, ntp.LEAP_SUB)
+	return nil
+}
+
+func checkLeapSecond(at time.Time) error {
+	at = at.UTC()
+	if at.Truncate(24*time.Hour) != at || at.Day() != 1 || (at.Month() != time.January && at.Month() != time.July) {
+		return fmt.Errorf("leap second at %s is not at midnight UTC on January or July 1st", at)
+	}
+	return nil
+}
+
+// Offset returns how far the time of the machine is from the served time.
+// The SSH round-trip is accounted for.
+func (c *Clock) Offset(m platform.Machine) (time.Duration, error) {
+	before := c.Now()
+	out, stderr, err := m.SSH("date -u +%s.%N")
+	after := c.Now()
+	if err != nil {
+		return 0, fmt.Errorf("getting the time of %s: %s: %v", m.ID(), stderr, err)
+	}
+	secs, nsecs, ok := strings.Cut(strings.TrimSpace(string(out)), ".")
+	s, err1 := strconv.ParseInt(secs, 10, 64)
+	ns, err2 := strconv.ParseInt(nsecs, 10, 64)
+	if !ok || err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("parsing the time of %s: %q", m.ID(), out)
+	}
+	served := before.Add(after.Sub(before) / 2)
+	return time.Unix(s, ns).Sub(served), nil
+}
+
+// Sync waits for the time of the machine to be within tolerance of the
+// served time, e.g. after Set or Jump.
+func (c *Clock) Sync(m platform.Machine, tolerance time.Duration) error {
+	return util.Retry(30, 2*time.Second, func() error {
+		// poll the server now rather than at the next interval
+		if _, stderr, err := m.SSH("sudo chronyc -a 'burst 4/4'"); err != nil {
+			return fmt.Errorf("chronyc burst on %s: %s: %v", m.ID(), stderr, err)
+		}
+		offset, err := c.Offset(m)
+		if err != nil {
+			return err
+		}
+		if offset > tolerance || offset < -tolerance {
+			return fmt.Errorf("time of %s is %s off the served time", m.ID(), offset)
+		}
+		return nil
+	})
+}
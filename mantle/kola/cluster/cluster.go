@@ -44,6 +44,35 @@ type TestCluster struct {
 	// If set to true and a sub-test fails all future sub-tests will be skipped
 	FailFast   bool
 	hasFailure bool
+
+	// Values of the fixtures the test requires, see register.Fixture
+	Fixtures map[string]interface{}
+	// Output directories of the tests this test depends on
+	DependencyDirs map[string]string
+}
+
+// Fixture returns the value of a fixture listed in the test's Fixtures.
+func (t *TestCluster) Fixture(name string) interface{} {
+	v, ok := t.Fixtures[name]
+	if !ok {
+		t.Fatalf("fixture %s is not available; is it listed in the test's Fixtures?", name)
+	}
+	return v
+}
+
+// DependencyDir returns the output directory of a test listed in the
+// test's DependsOn, which can be used to retrieve artifacts it produced.
+func (t *TestCluster) DependencyDir(name string) string {
+	dir, ok := t.DependencyDirs[name]
+	if !ok {
+		t.Fatalf("test %s is not a dependency of this test", name)
+	}
+	return dir
+}
+
+// sub returns a TestCluster for a subtest running under h.
+func (t *TestCluster) sub(h *harness.H) TestCluster {
+	return TestCluster{H: h, Cluster: t.Cluster, Fixtures: t.Fixtures, DependencyDirs: t.DependencyDirs}
 }
 
 // Run runs f as a subtest and reports whether f succeeded.
@@ -52,11 +81,11 @@ func (t *TestCluster) Run(name string, f func(c TestCluster)) bool {
 		return t.H.Run(name, func(h *harness.H) {
 			func(c TestCluster) {
 				c.Skip("A previous test has already failed")
-			}(TestCluster{H: h, Cluster: t.Cluster})
+			}(t.sub(h))
 		})
 	}
 	t.hasFailure = !t.H.Run(name, func(h *harness.H) {
-		f(TestCluster{H: h, Cluster: t.Cluster})
+		f(t.sub(h))
 	})
 	return !t.hasFailure
 
@@ -72,6 +101,22 @@ func (t *TestCluster) RunLogged(name string, f func(c TestCluster)) bool {
 	return t.Run(name, f)
 }
 
+// RunFromSnapshot runs f as a subtest after bringing m back to the state
+// saved by SaveSnapshot under snapshot, so that several subtests can start
+// from the same provisioned machine. It is only supported on QEMU.
+func (t *TestCluster) RunFromSnapshot(m platform.Machine, snapshot, name string, f func(c TestCluster)) bool {
+	return t.Run(name, func(c TestCluster) {
+		qm, ok := m.(platform.QEMUMachine)
+		if !ok {
+			c.Skipf("snapshots are not supported on platform %s", c.Platform())
+		}
+		if err := qm.RestoreSnapshot(snapshot); err != nil {
+			c.Fatalf("restoring snapshot %s: %v", snapshot, err)
+		}
+		f(c)
+	})
+}
+
 // RunNative runs a registered NativeFunc on a remote machine
 func (t *TestCluster) RunNative(funcName string, m platform.Machine) bool {
 	command := fmt.Sprintf("./kolet run %q %q", t.H.Name(), funcName)
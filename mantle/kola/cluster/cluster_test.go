@@ -0,0 +1,125 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/machine/fake"
+)
+
+// snapshotMachine is a QEMU machine whose disk is a set of files, which
+// snapshots save and restore. Its other QEMUMachine methods aren't
+// implemented.
+type snapshotMachine struct {
+	platform.QEMUMachine
+	files     map[string]string
+	snapshots map[string]map[string]string
+}
+
+func copyFiles(files map[string]string) map[string]string {
+	c := make(map[string]string, len(files))
+	for k, v := range files {
+		c[k] = v
+	}
+	return c
+}
+
+func (m *snapshotMachine) SaveSnapshot(name string) error {
+	m.snapshots[name] = copyFiles(m.files)
+	return nil
+}
+
+func (m *snapshotMachine) RestoreSnapshot(name string) error {
+	files, ok := m.snapshots[name]
+	if !ok {
+		return fmt.Errorf("Snapshot '%s' does not exist in one or more devices", name)
+	}
+	m.files = copyFiles(files)
+	return nil
+}
+
+func (m *snapshotMachine) DeleteSnapshot(name string) error {
+	delete(m.snapshots, name)
+	return nil
+}
+
+func TestRunFromSnapshot(t *testing.T) {
+	flight, dir := fake.NewTestFlight(t, &fake.Options{})
+	outputDir := filepath.Join(dir, "output")
+
+	// files seen by each branch when it starts
+	seen := map[string]map[string]string{}
+	results := map[string]bool{}
+	var tests harness.Tests
+	tests.Add("snapshot", func(h *harness.H) {
+		c, err := flight.NewCluster(&platform.RuntimeConfig{OutputDir: h.OutputDir()})
+		if err != nil {
+			h.Fatal(err)
+		}
+		defer c.Destroy()
+		tc := TestCluster{H: h, Cluster: c}
+
+		m := &snapshotMachine{
+			files:     map[string]string{"/etc/provisioned": "yes"},
+			snapshots: map[string]map[string]string{},
+		}
+		if err := m.SaveSnapshot("provisioned"); err != nil {
+			h.Fatal(err)
+		}
+		// each branch changes the state of the machine, which the
+		// next one doesn't see
+		for _, branch := range []string{"a", "b", "c"} {
+			branch := branch
+			results[branch] = tc.RunFromSnapshot(m, "provisioned", branch, func(c TestCluster) {
+				seen[branch] = copyFiles(m.files)
+				m.files["/etc/branch"] = branch
+				delete(m.files, "/etc/provisioned")
+			})
+		}
+		results["missing"] = tc.RunFromSnapshot(m, "missing", "missing", func(c TestCluster) {
+			seen["missing"] = copyFiles(m.files)
+		})
+
+		// machines of other platforms skip the subtest
+		fm, err := c.NewMachine(nil)
+		if err != nil {
+			h.Fatal(err)
+		}
+		results["fake"] = tc.RunFromSnapshot(fm, "provisioned", "fake", func(c TestCluster) {
+			seen["fake"] = nil
+		})
+	}, time.Minute)
+	suite := harness.NewSuite(harness.Options{OutputDir: outputDir}, tests)
+	if err := suite.Run(); err == nil {
+		t.Error("expected the suite to fail for the missing snapshot")
+	}
+
+	provisioned := map[string]string{"/etc/provisioned": "yes"}
+	expected := map[string]map[string]string{"a": provisioned, "b": provisioned, "c": provisioned}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected every branch to start from the snapshot, got %v", seen)
+	}
+	expectedResults := map[string]bool{"a": true, "b": true, "c": true, "missing": false, "fake": true}
+	if !reflect.DeepEqual(results, expectedResults) {
+		t.Errorf("expected results %v, got %v", expectedResults, results)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// StepAction is what a scenario step requests to happen to the machine
+// before the next step runs.
+type StepAction int
+
+const (
+	StepContinue StepAction = iota // run the next step right away
+	StepReboot                     // reboot the machine first
+	StepKexec                      // kexec into the default deployment first
+)
+
+func (a StepAction) String() string {
+	switch a {
+	case StepContinue:
+		return "continue"
+	case StepReboot:
+		return "reboot"
+	case StepKexec:
+		return "kexec"
+	default:
+		return fmt.Sprintf("StepAction(%d)", int(a))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler for scenario.json.
+func (a StepAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Step is one step of a scenario run by RunScenario. S is the type of the
+// state passed from step to step.
+type Step[S any] struct {
+	Name string
+	// Run performs the step on the machine, updating the state for the
+	// following steps, and returns what should happen before the next
+	// step. Like a subtest, it can fail or skip the step with c.
+	Run func(c TestCluster, m platform.Machine, state *S) StepAction
+}
+
+// StepReport records how a scenario step went; the reports of all steps
+// are written to scenario.json in the output directory of the test.
+type StepReport struct {
+	Name     string        `json:"name"`
+	BootID   string        `json:"bootId"`
+	Result   string        `json:"result"`
+	Action   StepAction    `json:"action"`
+	Duration time.Duration `json:"duration"`
+	// time taken by the reboot or kexec requested by the step
+	RebootDuration time.Duration `json:"rebootDuration,omitempty"`
+}
+
+// kexecCmd loads the kernel of the default deployment (the Boot Loader
+// Spec entry with the highest version) and kexecs into it.
+const kexecCmd = `set -euo pipefail
+entry=$(grep -H '^version ' /boot/loader/entries/*.conf | sort -t' ' -k2 -n | tail -n1 | cut -d: -f1)
+linux=$(sed -n 's/^linux //p' "${entry}")
+initrd=$(sed -n 's/^initrd //p' "${entry}")
+options=$(sed -n 's/^options //p' "${entry}")
+kexec -l "/boot${linux}" --initrd="/boot${initrd}" --command-line="${options}"
+systemctl kexec`
+
+// RunScenario runs steps one after the other on m as subtests of the test,
+// passing them state. A step can request a reboot or kexec of the machine,
+// after which the scenario resumes with the next step. The state is
+// checkpointed as JSON after each step and the next step gets it from the
+// checkpoint, so like the autopkgtest reboot mark it must only rely on
+// exported, serializable fields. Once a step fails the remaining ones are
+// skipped. The final state is returned.
+func RunScenario[S any](c TestCluster, m platform.Machine, state S, steps ...Step[S]) S {
+	checkpoint, err := json.Marshal(state)
+	if err != nil {
+		c.Fatalf("serializing initial scenario state: %v", err)
+	}
+
+	var reports []StepReport
+	writeReports := func() {
+		buf, err := json.MarshalIndent(reports, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(c.OutputDir(), "scenario.json"), buf, 0644)
+		}
+		if err != nil {
+			plog.Errorf("writing scenario.json for %s: %v", c.H.Name(), err)
+		}
+	}
+	defer writeReports()
+
+	failed := false
+	for i, step := range steps {
+		report := StepReport{Name: step.Name, Result: "SKIP"}
+		if failed {
+			c.Run(step.Name, func(c TestCluster) {
+				c.Skip("A previous step has already failed")
+			})
+			reports = append(reports, report)
+			continue
+		}
+
+		if bootID, err := platform.GetMachineBootId(m); err == nil {
+			report.BootID = bootID
+		} else {
+			plog.Warningf("getting boot id of %s: %v", m.ID(), err)
+		}
+		marker := fmt.Sprintf("%s/%s (step %d/%d)", c.H.Name(), step.Name, i+1, len(steps))
+
+		start := time.Now()
+		ok := c.Run(step.Name, func(c TestCluster) {
+			var s S
+			if err := json.Unmarshal(checkpoint, &s); err != nil {
+				c.Fatalf("restoring scenario state: %v", err)
+			}
+			c.JournalLog(m, "=== RUN: %s ===", marker)
+			report.Action = step.Run(c, m, &s)
+			c.JournalLog(m, "=== DONE: %s ===", marker)
+			buf, err := json.Marshal(s)
+			if err != nil {
+				c.Fatalf("serializing scenario state: %v", err)
+			}
+			checkpoint = buf
+			if err := os.WriteFile(filepath.Join(c.OutputDir(), "state.json"), buf, 0644); err != nil {
+				c.Fatalf("writing scenario state: %v", err)
+			}
+		})
+		report.Duration = time.Since(start)
+		if !ok {
+			report.Result = "FAIL"
+			reports = append(reports, report)
+			failed = true
+			continue
+		}
+		report.Result = "PASS"
+
+		if report.Action != StepContinue && i < len(steps)-1 {
+			start = time.Now()
+			if err := scenarioReboot(m, report.Action, report.BootID); err != nil {
+				report.Result = "FAIL"
+				reports = append(reports, report)
+				c.Errorf("%s after step %s: %v", report.Action, step.Name, err)
+				failed = true
+				continue
+			}
+			report.RebootDuration = time.Since(start)
+			c.Logf("Step %s: %v, %s: %v", step.Name, report.Duration.Round(time.Millisecond),
+				report.Action, report.RebootDuration.Round(time.Millisecond))
+		} else {
+			c.Logf("Step %s: %v", step.Name, report.Duration.Round(time.Millisecond))
+		}
+		reports = append(reports, report)
+	}
+
+	var final S
+	if err := json.Unmarshal(checkpoint, &final); err != nil {
+		c.Fatalf("restoring scenario state: %v", err)
+	}
+	return final
+}
+
+// scenarioReboot reboots or kexecs m and waits for it to come back.
+func scenarioReboot(m platform.Machine, action StepAction, bootID string) error {
+	switch action {
+	case StepReboot:
+		return m.Reboot()
+	case StepKexec:
+		if bootID == "" {
+			var err error
+			if bootID, err = platform.GetMachineBootId(m); err != nil {
+				return err
+			}
+		}
+		out, stderr, err := m.SSH(shellquote.Join("sudo", "bash", "-c", kexecCmd))
+		if _, ok := err.(*ssh.ExitMissingError); ok {
+			// A terminated session is perfectly normal during kexec.
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("issuing kexec failed: %s: %v: %s", out, err, stderr)
+		}
+		return m.WaitForReboot(120*time.Second, bootID)
+	default:
+		return fmt.Errorf("unknown step action %v", action)
+	}
+}
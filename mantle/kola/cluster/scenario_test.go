@@ -0,0 +1,209 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/machine/fake"
+)
+
+type scenarioState struct {
+	Steps []string
+	Boots []string
+}
+
+// record returns a step appending its name and the boot id to the state.
+func record(name string, action StepAction) Step[scenarioState] {
+	return Step[scenarioState]{
+		Name: name,
+		Run: func(c TestCluster, m platform.Machine, s *scenarioState) StepAction {
+			s.Steps = append(s.Steps, name)
+			s.Boots = append(s.Boots, m.(*fake.Machine).BootID())
+			return action
+		},
+	}
+}
+
+// stepReport is a StepReport as read back from scenario.json.
+type stepReport struct {
+	Name   string `json:"name"`
+	BootID string `json:"bootId"`
+	Result string `json:"result"`
+	Action string `json:"action"`
+}
+
+type scenarioResult struct {
+	final   scenarioState
+	reports []stepReport
+	// subtests run by the scenario test
+	subtests []string
+	journal  string
+	err      error
+}
+
+// runScenario runs the steps with RunScenario as the test "scenario" of a
+// suite, on a machine of the fake platform.
+func runScenario(t *testing.T, steps ...Step[scenarioState]) scenarioResult {
+	opts := &fake.Options{Script: &fake.Script{}}
+	opts.Script.Handle(`(?s)sudo bash -c .*systemctl kexec.*`, func(*fake.Command) fake.Response {
+		return fake.Response{Disconnect: true, Reboot: true}
+	})
+	flight, dir := fake.NewTestFlight(t, opts)
+	outputDir := filepath.Join(dir, "output")
+
+	var res scenarioResult
+	var tests harness.Tests
+	tests.Add("scenario", func(h *harness.H) {
+		c, err := flight.NewCluster(&platform.RuntimeConfig{OutputDir: h.OutputDir()})
+		if err != nil {
+			h.Fatal(err)
+		}
+		m, err := c.NewMachine(nil)
+		if err != nil {
+			h.Fatal(err)
+		}
+		defer func() {
+			c.Destroy()
+			res.journal = m.JournalOutput()
+		}()
+		res.final = RunScenario(TestCluster{H: h, Cluster: c}, m, scenarioState{}, steps...)
+	}, time.Minute)
+	suite := harness.NewSuite(harness.Options{
+		OutputDir: outputDir,
+		Reporters: reporters.Reporters{reporters.NewJSONReporter("report.json", "fake", "")},
+	}, tests)
+	res.err = suite.Run()
+
+	buf, err := os.ReadFile(filepath.Join(outputDir, "scenario", "scenario.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf, &res.reports); err != nil {
+		t.Fatal(err)
+	}
+	report, err := reporters.DeserialiseReport(filepath.Join(outputDir, "reports", "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range report.Tests {
+		if test.Name == "scenario" {
+			res.subtests = test.Subtests
+		}
+	}
+	return res
+}
+
+func (r scenarioResult) results() []string {
+	var results []string
+	for _, report := range r.reports {
+		results = append(results, report.Name+":"+report.Result)
+	}
+	return results
+}
+
+func TestRunScenario(t *testing.T) {
+	res := runScenario(t,
+		record("setup", StepReboot),
+		record("update", StepKexec),
+		record("verify", StepContinue),
+	)
+	if res.err != nil {
+		t.Fatalf("scenario failed: %v", res.err)
+	}
+
+	// the steps run in order, passing the state along
+	if want := []string{"setup", "update", "verify"}; !reflect.DeepEqual(res.final.Steps, want) {
+		t.Errorf("steps ran in order %v, want %v", res.final.Steps, want)
+	}
+	// each step after a reboot or kexec runs in a new boot
+	boots := res.final.Boots
+	if len(boots) != 3 || boots[0] == boots[1] || boots[1] == boots[2] {
+		t.Errorf("expected a new boot for each step, got %v", boots)
+	}
+
+	if want := []string{"setup:PASS", "update:PASS", "verify:PASS"}; !reflect.DeepEqual(res.results(), want) {
+		t.Errorf("got step results %v, want %v", res.results(), want)
+	}
+	for i, report := range res.reports {
+		if report.BootID != boots[i] {
+			t.Errorf("step %s reported boot %s, ran in %s", report.Name, report.BootID, boots[i])
+		}
+	}
+	if res.reports[0].Action != "reboot" || res.reports[1].Action != "kexec" || res.reports[2].Action != "continue" {
+		t.Errorf("unexpected actions in %+v", res.reports)
+	}
+
+	// steps are subtests named after them, and marked in the journal
+	if want := []string{"setup", "update", "verify"}; !reflect.DeepEqual(res.subtests, want) {
+		t.Errorf("got subtests %v, want %v", res.subtests, want)
+	}
+	for _, marker := range []string{
+		"=== RUN: scenario/setup (step 1/3) ===",
+		"=== DONE: scenario/update (step 2/3) ===",
+		"=== RUN: scenario/verify (step 3/3) ===",
+	} {
+		if !strings.Contains(res.journal, marker) {
+			t.Errorf("journal lacks %q", marker)
+		}
+	}
+}
+
+func TestRunScenarioFailure(t *testing.T) {
+	ran := false
+	res := runScenario(t,
+		record("setup", StepReboot),
+		Step[scenarioState]{
+			Name: "check",
+			Run: func(c TestCluster, m platform.Machine, s *scenarioState) StepAction {
+				s.Steps = append(s.Steps, "check")
+				c.Fatal("check failed")
+				return StepReboot
+			},
+		},
+		Step[scenarioState]{
+			Name: "verify",
+			Run: func(c TestCluster, m platform.Machine, s *scenarioState) StepAction {
+				ran = true
+				return StepContinue
+			},
+		},
+	)
+	if res.err != harness.SuiteFailed {
+		t.Fatalf("expected the scenario to fail, got %v", res.err)
+	}
+	if ran {
+		t.Error("step after the failed one ran")
+	}
+	if want := []string{"setup:PASS", "check:FAIL", "verify:SKIP"}; !reflect.DeepEqual(res.results(), want) {
+		t.Errorf("got step results %v, want %v", res.results(), want)
+	}
+	// the state of the failed step isn't checkpointed
+	if want := []string{"setup"}; !reflect.DeepEqual(res.final.Steps, want) {
+		t.Errorf("final state has steps %v, want %v", res.final.Steps, want)
+	}
+	if want := []string{"setup", "check", "verify"}; !reflect.DeepEqual(res.subtests, want) {
+		t.Errorf("got subtests %v, want %v", res.subtests, want)
+	}
+}
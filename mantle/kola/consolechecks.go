@@ -0,0 +1,218 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// consoleCheck is a pattern which indicates a problem when found on the
+// console or in the journal of a machine.
+type consoleCheck struct {
+	desc              string
+	match             *regexp.Regexp
+	warnOnly          bool
+	allowRerunSuccess bool
+	skipFlag          *register.Flag
+	// if set, the check only applies to tests matching one of these patterns
+	tests []string
+}
+
+// consoleCheckOverride changes the severity of a console check.
+type consoleCheckOverride struct {
+	desc              string
+	warnOnly          *bool
+	allowRerunSuccess *bool
+	tests             []string
+}
+
+// ConsoleCheckObj is an entry of src/config/kola-console-checks.yaml. An
+// entry with a match regexp adds a check; an entry without one overrides
+// the warnOnly and allowRerunSuccess settings of the built-in, journal or
+// previously defined check with the same desc. Like kola-denylist.yaml
+// entries, they can be scoped to some arches, platforms and streams, and
+// additionally to tests matching some patterns.
+type ConsoleCheckObj struct {
+	Desc              string   `yaml:"desc"`
+	Match             string   `yaml:"match,omitempty"`
+	WarnOnly          *bool    `yaml:"warnOnly,omitempty"`
+	AllowRerunSuccess *bool    `yaml:"allowRerunSuccess,omitempty"`
+	Tracker           string   `yaml:"tracker,omitempty"`
+	Arches            []string `yaml:"arches,omitempty"`
+	Platforms         []string `yaml:"platforms,omitempty"`
+	Streams           []string `yaml:"streams,omitempty"`
+	Tests             []string `yaml:"tests,omitempty"`
+}
+
+var (
+	// console checks and overrides from kola-console-checks.yaml
+	configConsoleChecks   []consoleCheck
+	consoleCheckOverrides []consoleCheckOverride
+)
+
+// ParseConsoleChecksYaml loads src/config/kola-console-checks.yaml, if
+// any, keeping the entries relevant to pltfrm and the current arch and
+// stream.
+func ParseConsoleChecksYaml(pltfrm string) error {
+	path := filepath.Join(Options.CosaWorkdir, "src/config/kola-console-checks.yaml")
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var objs []ConsoleCheckObj
+	if err := yaml.UnmarshalStrict(buf, &objs); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var stream string
+	if manifest, err := readManifest(); err == nil {
+		stream = manifest.Variables.Stream
+	}
+	checks, overrides, err := compileConsoleChecks(objs, pltfrm, Options.CosaBuildArch, stream)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	configConsoleChecks, consoleCheckOverrides = checks, overrides
+	plog.Debugf("Loaded %d console checks and %d overrides from %s", len(checks), len(overrides), path)
+	return nil
+}
+
+func compileConsoleChecks(objs []ConsoleCheckObj, pltfrm, arch, stream string) ([]consoleCheck, []consoleCheckOverride, error) {
+	var checks []consoleCheck
+	var overrides []consoleCheckOverride
+	known := make(map[string]bool)
+	for _, check := range consoleChecks {
+		known[check.desc] = true
+	}
+	// journal checks can be overridden too
+	for _, check := range journalChecks {
+		known[check.desc] = true
+	}
+	for _, obj := range objs {
+		if obj.Desc == "" {
+			return nil, nil, fmt.Errorf("console check without desc")
+		}
+		for _, pattern := range obj.Tests {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, nil, fmt.Errorf("console check %q: %w", obj.Desc, err)
+			}
+		}
+		if len(obj.Arches) > 0 && !HasString(arch, obj.Arches) {
+			continue
+		}
+		if len(obj.Platforms) > 0 && !HasString(pltfrm, obj.Platforms) {
+			continue
+		}
+		if len(stream) > 0 && len(obj.Streams) > 0 && !HasString(stream, obj.Streams) {
+			continue
+		}
+
+		if obj.Match == "" {
+			if !known[obj.Desc] {
+				return nil, nil, fmt.Errorf("console check override %q doesn't match any check", obj.Desc)
+			}
+			overrides = append(overrides, consoleCheckOverride{
+				desc:              obj.Desc,
+				warnOnly:          obj.WarnOnly,
+				allowRerunSuccess: obj.AllowRerunSuccess,
+				tests:             obj.Tests,
+			})
+			continue
+		}
+
+		match, err := regexp.Compile(obj.Match)
+		if err != nil {
+			return nil, nil, fmt.Errorf("console check %q: %w", obj.Desc, err)
+		}
+		check := consoleCheck{
+			desc:  obj.Desc,
+			match: match,
+			tests: obj.Tests,
+		}
+		if obj.WarnOnly != nil {
+			check.warnOnly = *obj.WarnOnly
+		}
+		if obj.AllowRerunSuccess != nil {
+			check.allowRerunSuccess = *obj.AllowRerunSuccess
+		}
+		checks = append(checks, check)
+		known[obj.Desc] = true
+	}
+	return checks, overrides, nil
+}
+
+// consoleCheckAppliesTo returns whether a check or override scoped to
+// tests matching patterns applies to t.
+func consoleCheckAppliesTo(t *register.Test, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	// the console of a non-exclusive wrapper is that of its tests
+	names := []string{t.Name}
+	if nonexclusiveWrapperMatch.MatchString(t.Name) {
+		names = t.Subtests
+	}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if match, _ := filepath.Match(pattern, name); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyConsoleCheckOverrides updates the severity of the check desc for t
+// according to kola-console-checks.yaml.
+func applyConsoleCheckOverrides(t *register.Test, desc string, warnOnly, allowRerunSuccess *bool) {
+	for _, o := range consoleCheckOverrides {
+		if o.desc != desc || !consoleCheckAppliesTo(t, o.tests) {
+			continue
+		}
+		if o.warnOnly != nil {
+			*warnOnly = *o.warnOnly
+		}
+		if o.allowRerunSuccess != nil {
+			*allowRerunSuccess = *o.allowRerunSuccess
+		}
+	}
+}
+
+// effectiveConsoleChecks returns the console checks applying to t (or to
+// no test in particular if t is nil) with their overrides applied.
+func effectiveConsoleChecks(t *register.Test) []consoleCheck {
+	var ret []consoleCheck
+	all := append(append([]consoleCheck(nil), consoleChecks...), configConsoleChecks...)
+	for _, check := range all {
+		if !consoleCheckAppliesTo(t, check.tests) {
+			continue
+		}
+		applyConsoleCheckOverrides(t, check.desc, &check.warnOnly, &check.allowRerunSuccess)
+		ret = append(ret, check)
+	}
+	return ret
+}
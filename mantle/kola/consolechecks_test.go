@@ -0,0 +1,64 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestConfigConsoleChecks(t *testing.T) {
+	config := `
+- desc: known bad message
+  match: "frobnicator: (timeout)"
+  warnOnly: true
+  streams: [testing-devel]
+- desc: other stream
+  match: "frobnicator"
+  streams: [stable]
+- desc: kernel oops
+  warnOnly: true
+  tests: [ext.config.kdump.*]
+`
+	var objs []ConsoleCheckObj
+	if err := yaml.UnmarshalStrict([]byte(config), &objs); err != nil {
+		t.Fatal(err)
+	}
+	checks, overrides, err := compileConsoleChecks(objs, "qemu", "x86_64", "testing-devel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configConsoleChecks, consoleCheckOverrides = checks, overrides
+	defer func() {
+		configConsoleChecks, consoleCheckOverrides = nil, nil
+	}()
+
+	warnOnly, badlines := CheckConsole([]byte("frobnicator: timeout\nOops: 0000"), nil)
+	if warnOnly || len(badlines) != 2 || badlines[1] != "known bad message (timeout)" {
+		t.Errorf("unexpected result without test: %v %v", warnOnly, badlines)
+	}
+	kdump := &register.Test{Name: "ext.config.kdump.crash"}
+	warnOnly, badlines = CheckConsole([]byte("frobnicator: timeout\nOops: 0000"), kdump)
+	if !warnOnly || len(badlines) != 2 {
+		t.Errorf("unexpected result for kdump test: %v %v", warnOnly, badlines)
+	}
+
+	if _, _, err := compileConsoleChecks([]ConsoleCheckObj{{Desc: "no such check"}}, "qemu", "x86_64", ""); err == nil {
+		t.Error("expected an error for an override of an unknown check")
+	}
+}
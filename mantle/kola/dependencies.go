@@ -0,0 +1,269 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// testDependencies tracks the completion of the tests of the current
+// kola run, so that tests can wait for the tests listed in their
+// DependsOn.
+var testDependencies = newDependencyTracker(nil)
+
+type dependencyState struct {
+	done      chan struct{}
+	finished  bool
+	passed    bool
+	outputDir string
+}
+
+type dependencyTracker struct {
+	mu    sync.Mutex
+	tests map[string]*dependencyState
+}
+
+// newDependencyTracker tracks the given tests. Dependencies on other
+// tests are considered failed.
+func newDependencyTracker(names []string) *dependencyTracker {
+	d := &dependencyTracker{tests: make(map[string]*dependencyState)}
+	for _, name := range names {
+		d.tests[name] = &dependencyState{done: make(chan struct{})}
+	}
+	return d
+}
+
+// finish records the result of the test, unblocking its dependents.
+func (d *dependencyTracker) finish(name string, h *harness.H) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.tests[name]
+	if !ok || st.finished {
+		return
+	}
+	st.finished = true
+	st.passed = !h.Failed() && !h.Skipped()
+	st.outputDir = h.OutputDir()
+	close(st.done)
+}
+
+// wait blocks until the given tests have finished, without holding a
+// parallel slot of the harness.
+func (d *dependencyTracker) wait(h *harness.H, names []string) {
+	var pending []chan struct{}
+	d.mu.Lock()
+	for _, name := range names {
+		if st, ok := d.tests[name]; ok && !st.finished {
+			pending = append(pending, st.done)
+		}
+	}
+	d.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	plog.Debugf("%s waiting for %s", h.Name(), strings.Join(names, ", "))
+	h.Suspend(func() {
+		for _, ch := range pending {
+			<-ch
+		}
+	})
+}
+
+// finished returns true if the given tests finished or aren't tracked.
+func (d *dependencyTracker) finished(names []string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range names {
+		if st, ok := d.tests[name]; ok && !st.finished {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns the output directories of the given finished tests, or
+// an error if one of them did not pass or will not run.
+func (d *dependencyTracker) check(names []string) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dirs := make(map[string]string)
+	for _, name := range names {
+		st, ok := d.tests[name]
+		if !ok {
+			return nil, fmt.Errorf("dependency %s is not part of this run", name)
+		}
+		if !st.finished || !st.passed {
+			return nil, fmt.Errorf("dependency %s did not pass", name)
+		}
+		dirs[name] = st.outputDir
+	}
+	return dirs, nil
+}
+
+// addDependencies adds to tests the dependencies of its tests which
+// weren't selected, as long as they can run on this platform and aren't
+// denylisted. Tests whose dependencies can't be added will be skipped.
+func addDependencies(tests, testsBank map[string]*register.Test, pltfrm string) error {
+	queue := make([]*register.Test, 0, len(tests))
+	for _, t := range tests {
+		queue = append(queue, t)
+	}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		for _, dep := range t.DependsOn {
+			if _, ok := tests[dep]; ok {
+				continue
+			}
+			depTest, ok := testsBank[dep]
+			if !ok {
+				plog.Warningf("Test %s depends on unknown test %s", t.Name, dep)
+				continue
+			}
+			filtered, err := filterTests(map[string]*register.Test{dep: depTest}, []string{dep}, pltfrm)
+			if err != nil {
+				return err
+			}
+			if filtered, err = filterDenylistedTests(filtered); err != nil {
+				return err
+			}
+			if len(filtered) == 0 {
+				plog.Warningf("Test %s depends on %s, which cannot run here", t.Name, dep)
+				continue
+			}
+			plog.Debugf("Adding %s as a dependency of %s", dep, t.Name)
+			tests[dep] = depTest
+			queue = append(queue, depTest)
+		}
+	}
+	return nil
+}
+
+// sortByDependencies orders tests so that each test comes after the
+// tests it depends on, when they are part of the list. Tests are otherwise
+// kept in name order so that buckets are deterministic.
+func sortByDependencies(tests []*register.Test) ([]*register.Test, error) {
+	byName := make(map[string]*register.Test)
+	for _, t := range tests {
+		byName[t.Name] = t
+	}
+	sorted := append([]*register.Test(nil), tests...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var ret []*register.Test
+	state := make(map[string]int) // 1: visiting, 2: done
+	var visit func(t *register.Test) error
+	visit = func(t *register.Test) error {
+		switch state[t.Name] {
+		case 1:
+			return fmt.Errorf("dependency cycle involving test %s", t.Name)
+		case 2:
+			return nil
+		}
+		state[t.Name] = 1
+		for _, dep := range t.DependsOn {
+			if d, ok := byName[dep]; ok {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		state[t.Name] = 2
+		ret = append(ret, t)
+		return nil
+	}
+	for _, t := range sorted {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// externalDependencies returns the dependencies of the given tests which
+// are not part of them, i.e. those the bucket running them must wait for.
+func externalDependencies(tests []*register.Test) []string {
+	var ret []string
+	for _, t := range tests {
+		for _, dep := range t.DependsOn {
+			inBucket := false
+			for _, other := range tests {
+				if other.Name == dep {
+					inBucket = true
+					break
+				}
+			}
+			if !inBucket && !HasString(dep, ret) {
+				ret = append(ret, dep)
+			}
+		}
+	}
+	return ret
+}
+
+// checkDependencyCycles makes sure that the tests about to be run by the
+// harness won't wait on each other. subtests maps the name of each
+// non-exclusive wrapper to the tests it runs.
+func checkDependencyCycles(tests map[string]*register.Test, subtests map[string][]*register.Test) error {
+	unitOf := make(map[string]string)
+	for name := range tests {
+		unitOf[name] = name
+	}
+	for wrapper, bucket := range subtests {
+		for _, t := range bucket {
+			unitOf[t.Name] = wrapper
+		}
+	}
+	var units []*register.Test
+	for name, t := range tests {
+		unit := &register.Test{Name: name}
+		for _, dep := range t.DependsOn {
+			if u, ok := unitOf[dep]; ok && u != name {
+				unit.DependsOn = append(unit.DependsOn, u)
+			}
+		}
+		units = append(units, unit)
+	}
+	_, err := sortByDependencies(units)
+	return err
+}
+
+// withDependencies returns tests along with the tests from testsBank they
+// transitively depend on.
+func withDependencies(tests, testsBank map[string]*register.Test) map[string]*register.Test {
+	ret := make(map[string]*register.Test)
+	var add func(t *register.Test)
+	add = func(t *register.Test) {
+		if _, ok := ret[t.Name]; ok {
+			return
+		}
+		ret[t.Name] = t
+		for _, dep := range t.DependsOn {
+			if d, ok := testsBank[dep]; ok {
+				add(d)
+			}
+		}
+	}
+	for _, t := range tests {
+		add(t)
+	}
+	return ret
+}
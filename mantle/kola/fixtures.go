@@ -0,0 +1,289 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// runFixtures holds the RunScope fixtures of the current kola run
+var runFixtures *fixturePool
+
+// fixturePool holds the fixture instances shared by a set of tests: all
+// the tests of the run for RunScope fixtures, or all the tests sharing a
+// cluster for BucketScope fixtures.
+//
+// Each test which will use a fixture of the pool takes a reference
+// upfront with plan() and drops it with release() once it's done, whether
+// or not it actually got to acquire() the fixture. Instances are set up
+// by the first acquire() and torn down by the last release(), so they are
+// set up at most once and torn down as soon as they are no longer needed.
+type fixturePool struct {
+	flight    platform.Flight
+	outputDir string
+
+	mu        sync.Mutex
+	instances map[string]*fixtureInstance
+	order     []*fixtureInstance // in setup order
+}
+
+type fixtureInstance struct {
+	mu        sync.Mutex
+	fixture   *register.Fixture
+	outputDir string
+	refs      int
+	setUp     bool
+	tornDown  bool
+	value     interface{}
+	values    map[string]interface{} // values of the required fixtures
+	err       error
+}
+
+func newFixturePool(flight platform.Flight, outputDir string) *fixturePool {
+	return &fixturePool{
+		flight:    flight,
+		outputDir: outputDir,
+		instances: make(map[string]*fixtureInstance),
+	}
+}
+
+func (p *fixturePool) instance(f *register.Fixture) *fixtureInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inst, ok := p.instances[f.Name]
+	if !ok {
+		inst = &fixtureInstance{
+			fixture:   f,
+			outputDir: filepath.Join(p.outputDir, "fixtures", f.Name),
+		}
+		p.instances[f.Name] = inst
+	}
+	return inst
+}
+
+// plan takes a reference on each of the fixtures.
+func (p *fixturePool) plan(fixtures []*register.Fixture) {
+	for _, f := range fixtures {
+		inst := p.instance(f)
+		inst.mu.Lock()
+		inst.refs++
+		inst.mu.Unlock()
+	}
+}
+
+// acquire sets up the fixture unless that was already done and stores
+// its value in values, which must already hold the values of the
+// fixtures it requires.
+func (p *fixturePool) acquire(f *register.Fixture, tc cluster.TestCluster, values map[string]interface{}) error {
+	inst := p.instance(f)
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if !inst.setUp {
+		inst.setUp = true
+		// in case Setup exits the goroutine, e.g. via tc.Fatal()
+		inst.err = fmt.Errorf("setup did not complete")
+		inst.values = make(map[string]interface{})
+		for _, req := range f.Requires {
+			inst.values[req] = values[req]
+		}
+		if err := os.MkdirAll(inst.outputDir, 0777); err != nil {
+			inst.err = err
+			return err
+		}
+		plog.Infof("Setting up %s fixture %s", f.Scope, f.Name)
+		inst.value, inst.err = f.Setup(p.context(inst, tc))
+		if inst.err == nil {
+			p.mu.Lock()
+			p.order = append(p.order, inst)
+			p.mu.Unlock()
+		}
+	}
+	if inst.err != nil {
+		return errors.Wrapf(inst.err, "setting up fixture %s", f.Name)
+	}
+	values[f.Name] = inst.value
+	return nil
+}
+
+// release drops a reference to the fixture and tears it down if it was
+// the last one.
+func (p *fixturePool) release(f *register.Fixture, tc cluster.TestCluster) error {
+	inst := p.instance(f)
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	inst.refs--
+	if inst.refs > 0 {
+		return nil
+	}
+	return p.teardown(inst, tc)
+}
+
+// teardownAll tears down the instances still set up, e.g. because a
+// test exited without releasing its references, in the reverse order of
+// setup.
+func (p *fixturePool) teardownAll(tc cluster.TestCluster) {
+	p.mu.Lock()
+	order := p.order
+	p.mu.Unlock()
+	for i := len(order) - 1; i >= 0; i-- {
+		inst := order[i]
+		inst.mu.Lock()
+		if err := p.teardown(inst, tc); err != nil {
+			plog.Errorf("%v", err)
+		}
+		inst.mu.Unlock()
+	}
+}
+
+// teardown must be called with inst.mu held.
+func (p *fixturePool) teardown(inst *fixtureInstance, tc cluster.TestCluster) error {
+	if !inst.setUp || inst.err != nil || inst.tornDown {
+		return nil
+	}
+	inst.tornDown = true
+	if inst.fixture.Teardown == nil {
+		return nil
+	}
+	plog.Infof("Tearing down %s fixture %s", inst.fixture.Scope, inst.fixture.Name)
+	if err := inst.fixture.Teardown(p.context(inst, tc), inst.value); err != nil {
+		return errors.Wrapf(err, "tearing down fixture %s", inst.fixture.Name)
+	}
+	return nil
+}
+
+func (p *fixturePool) context(inst *fixtureInstance, tc cluster.TestCluster) *register.FixtureContext {
+	ctx := &register.FixtureContext{
+		Flight:    p.flight,
+		OutputDir: inst.outputDir,
+		Values:    inst.values,
+	}
+	if inst.fixture.Scope != register.RunScope {
+		ctx.Cluster = tc
+	}
+	return ctx
+}
+
+// fixturesOfScope returns the fixtures with one of the given scopes,
+// preserving their order.
+func fixturesOfScope(fixtures []*register.Fixture, scopes ...register.FixtureScope) []*register.Fixture {
+	var ret []*register.Fixture
+	for _, f := range fixtures {
+		for _, s := range scopes {
+			if f.Scope == s {
+				ret = append(ret, f)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// fixtureUnion returns the names of the fixtures required by any of the
+// given tests.
+func fixtureUnion(tests []*register.Test) []string {
+	var ret []string
+	for _, t := range tests {
+		for _, f := range t.Fixtures {
+			if !HasString(f, ret) {
+				ret = append(ret, f)
+			}
+		}
+	}
+	return ret
+}
+
+// planRunFixtures creates runFixtures for the given tests, which are the
+// tests about to be run by the harness, and takes their references.
+func planRunFixtures(tests map[string]*register.Test, flight platform.Flight, outputDir string) error {
+	runFixtures = newFixturePool(flight, outputDir)
+	for _, t := range tests {
+		fixtures, err := register.ResolveFixtures(t.Fixtures)
+		if err != nil {
+			return errors.Wrapf(err, "test %s", t.Name)
+		}
+		runFixtures.plan(fixturesOfScope(fixtures, register.RunScope))
+	}
+	return nil
+}
+
+// fixtureScope acquires fixtures from the pools for their scope and
+// releases them, in reverse order, when done. Both must be called from
+// the goroutine of the test owning tc.
+type fixtureScope struct {
+	fixtures []*register.Fixture
+	pools    map[register.FixtureScope]*fixturePool
+	released bool
+}
+
+// newFixtureScope plans the given fixtures in their pool. Those without
+// a pool are skipped, because a wider scope takes care of them.
+func newFixtureScope(fixtures []*register.Fixture, pools map[register.FixtureScope]*fixturePool) *fixtureScope {
+	s := &fixtureScope{pools: pools}
+	for _, f := range fixtures {
+		if pool, ok := pools[f.Scope]; ok {
+			s.fixtures = append(s.fixtures, f)
+			if f.Scope != register.RunScope {
+				// RunScope references are taken by planRunFixtures
+				pool.plan([]*register.Fixture{f})
+			}
+		}
+	}
+	return s
+}
+
+// acquire sets up the fixtures and makes them available in tc.
+func (s *fixtureScope) acquire(tc *cluster.TestCluster) error {
+	values := make(map[string]interface{})
+	for k, v := range tc.Fixtures {
+		values[k] = v
+	}
+	for _, f := range s.fixtures {
+		if err := s.pools[f.Scope].acquire(f, *tc, values); err != nil {
+			tc.Fixtures = values
+			return err
+		}
+	}
+	tc.Fixtures = values
+	return nil
+}
+
+// release drops the references to the fixtures, including the ones
+// which weren't acquired, and reports teardown failures to tc. Only the
+// first call has an effect.
+func (s *fixtureScope) release(tc cluster.TestCluster) {
+	if s.released {
+		return
+	}
+	s.released = true
+	for i := len(s.fixtures) - 1; i >= 0; i-- {
+		f := s.fixtures[i]
+		if err := s.pools[f.Scope].release(f, tc); err != nil {
+			if tc.H != nil {
+				tc.Errorf("%v", err)
+			} else {
+				plog.Errorf("%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func TestFixturePool(t *testing.T) {
+	var events []string
+	fixture := func(name string, requires ...string) *register.Fixture {
+		return &register.Fixture{
+			Name:     name,
+			Scope:    register.RunScope,
+			Requires: requires,
+			Setup: func(ctx *register.FixtureContext) (interface{}, error) {
+				events = append(events, "setup "+name)
+				return name + "-value", nil
+			},
+			Teardown: func(ctx *register.FixtureContext, v interface{}) error {
+				events = append(events, "teardown "+name)
+				return nil
+			},
+		}
+	}
+	base := fixture("base")
+	top := fixture("top", "base")
+	fixtures := []*register.Fixture{base, top}
+
+	pool := newFixturePool(nil, t.TempDir())
+	// two tests use both fixtures
+	pool.plan(fixtures)
+	pool.plan(fixtures)
+	pools := map[register.FixtureScope]*fixturePool{register.RunScope: pool}
+
+	for i := 0; i < 2; i++ {
+		s := newFixtureScope(fixtures, pools)
+		var tc cluster.TestCluster
+		if err := s.acquire(&tc); err != nil {
+			t.Fatal(err)
+		}
+		if tc.Fixtures["top"] != "top-value" || tc.Fixtures["base"] != "base-value" {
+			t.Errorf("unexpected fixture values %v", tc.Fixtures)
+		}
+		s.release(tc)
+		s.release(tc)
+	}
+	expected := []string{"setup base", "setup top", "teardown top", "teardown base"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestCreateTestBucketsDependencies(t *testing.T) {
+	tests := []*register.Test{
+		{Name: "c", NonExclusive: true, DependsOn: []string{"b"}},
+		{Name: "b", NonExclusive: true, DependsOn: []string{"a"}},
+		{Name: "a", NonExclusive: true},
+		{Name: "d", NonExclusive: true, Conflicts: []string{"a"}},
+	}
+	buckets := createTestBuckets(tests)
+	var names [][]string
+	for _, bucket := range buckets {
+		var bucketNames []string
+		for _, test := range bucket {
+			bucketNames = append(bucketNames, test.Name)
+		}
+		names = append(names, bucketNames)
+	}
+	expected := [][]string{{"a", "b", "c"}, {"d"}}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if deps := externalDependencies(buckets[0]); len(deps) != 0 {
+		t.Errorf("unexpected external dependencies %v", deps)
+	}
+}
@@ -44,6 +44,7 @@ import (
 	doapi "github.com/coreos/coreos-assembler/mantle/platform/api/do"
 	esxapi "github.com/coreos/coreos-assembler/mantle/platform/api/esx"
 	gcloudapi "github.com/coreos/coreos-assembler/mantle/platform/api/gcloud"
+	libvirtapi "github.com/coreos/coreos-assembler/mantle/platform/api/libvirt"
 	openstackapi "github.com/coreos/coreos-assembler/mantle/platform/api/openstack"
 	packetapi "github.com/coreos/coreos-assembler/mantle/platform/api/packet"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
@@ -51,7 +52,9 @@ import (
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/azure"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/do"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/esx"
+	"github.com/coreos/coreos-assembler/mantle/platform/machine/fake"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/gcloud"
+	"github.com/coreos/coreos-assembler/mantle/platform/machine/libvirt"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/openstack"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/packet"
 	"github.com/coreos/coreos-assembler/mantle/platform/machine/qemu"
@@ -97,7 +100,7 @@ const defaultPlatformIndependentPlatform = "qemu"
 const SkipBaseChecksTag = "skip-base-checks"
 
 // Date format for snooze date specified in kola-denylist.yaml (YYYY-MM-DD)
-const snoozeFormat = "2006-01-02"
+const SnoozeFormat = "2006-01-02"
 
 // SkipConsoleWarningsTag will cause kola not to check console for kernel errors.
 // This overlaps with SkipBaseChecksTag above, but is really a special flag for kola-denylist.yaml.
@@ -112,16 +115,22 @@ var (
 	DOOptions        = doapi.Options{Options: &Options}        // glue to set platform options from main
 	ESXOptions       = esxapi.Options{Options: &Options}       // glue to set platform options from main
 	GCPOptions       = gcloudapi.Options{Options: &Options}    // glue to set platform options from main
+	LibvirtOptions   = libvirtapi.Options{Options: &Options}   // glue to set platform options from main
 	OpenStackOptions = openstackapi.Options{Options: &Options} // glue to set platform options from main
 	PacketOptions    = packetapi.Options{Options: &Options}    // glue to set platform options from main
 	QEMUOptions      = qemu.Options{Options: &Options}         // glue to set platform options from main
 	QEMUIsoOptions   = qemuiso.Options{Options: &Options}      // glue to set platform options from main
+	FakeOptions      = fake.Options{Options: &Options}         // scripted by unit tests, not exposed in the CLI
 
 	CosaBuild *util.LocalBuild // this is a parsed cosa build
 
 	TestParallelism int    //glue var to set test parallelism from main
 	TAPFile         string // if not "", write TAP results here
+	JUnitFile       string // if not "", write JUnit XML results here
+	HistoryFile     string // if not "", append test results to this history store
 	NoNet           bool   // Disable tests requiring Internet
+	// MetadataOverridesFile is a YAML file of MetadataOverrideObj entries
+	MetadataOverridesFile string
 	// ForceRunPlatformIndependent will cause tests that claim platform-independence to run
 	ForceRunPlatformIndependent bool
 
@@ -136,17 +145,12 @@ var (
 
 	extTestNum  = 1 // Assigns a unique number to each non-exclusive external test
 	testResults protectedTestResults
+	inRerun     bool // true while re-running failed tests
 
 	nonexclusivePrefixMatch  = regexp.MustCompile(`^non-exclusive-test-bucket-[0-9]/`)
 	nonexclusiveWrapperMatch = regexp.MustCompile(`^non-exclusive-test-bucket-[0-9]$`)
 
-	consoleChecks = []struct {
-		desc              string
-		match             *regexp.Regexp
-		warnOnly          bool
-		allowRerunSuccess bool
-		skipFlag          *register.Flag
-	}{
+	consoleChecks = []consoleCheck{
 		{
 			desc:              "emergency shell",
 			match:             regexp.MustCompile("Press Enter for emergency shell|Starting Emergency Shell|You are in emergency mode"),
@@ -298,6 +302,8 @@ func NewFlight(pltfrm string) (flight platform.Flight, err error) {
 		flight, err = esx.NewFlight(&ESXOptions)
 	case "gcp":
 		flight, err = gcloud.NewFlight(&GCPOptions)
+	case "libvirt":
+		flight, err = libvirt.NewFlight(&LibvirtOptions)
 	case "openstack":
 		flight, err = openstack.NewFlight(&OpenStackOptions)
 	case "packet":
@@ -306,6 +312,8 @@ func NewFlight(pltfrm string) (flight platform.Flight, err error) {
 		flight, err = qemu.NewFlight(&QEMUOptions)
 	case "qemu-iso":
 		flight, err = qemuiso.NewFlight(&QEMUIsoOptions)
+	case "fake":
+		flight, err = fake.NewFlight(&FakeOptions)
 	default:
 		err = fmt.Errorf("invalid platform %q", pltfrm)
 	}
@@ -353,13 +361,13 @@ func markTestForRerunSuccess(test *register.Test, msg string) {
 
 type DenyListObj struct {
 	Pattern    string   `yaml:"pattern"`
-	Tracker    string   `yaml:"tracker"`
-	Streams    []string `yaml:"streams"`
-	Arches     []string `yaml:"arches"`
-	Platforms  []string `yaml:"platforms"`
-	SnoozeDate string   `yaml:"snooze"`
-	OsVersion  []string `yaml:"osversion"`
-	Warn       bool     `yaml:"warn"`
+	Tracker    string   `yaml:"tracker,omitempty"`
+	Streams    []string `yaml:"streams,omitempty"`
+	Arches     []string `yaml:"arches,omitempty"`
+	Platforms  []string `yaml:"platforms,omitempty"`
+	SnoozeDate string   `yaml:"snooze,omitempty"`
+	OsVersion  []string `yaml:"osversion,omitempty"`
+	Warn       bool     `yaml:"warn,omitempty"`
 }
 
 type ManifestData struct {
@@ -373,27 +381,9 @@ type InitConfigData struct {
 	ConfigVariant string `json:"coreos-assembler.config-variant"`
 }
 
-func ParseDenyListYaml(pltfrm string) error {
-	var objs []DenyListObj
-
-	// Parse kola-denylist into structs
-	pathToDenyList := filepath.Join(Options.CosaWorkdir, "src/config/kola-denylist.yaml")
-	denyListFile, err := os.ReadFile(pathToDenyList)
-	if os.IsNotExist(err) {
-		return nil
-	} else if err != nil {
-		return err
-	}
-
-	plog.Debug("Found kola-denylist.yaml. Processing listed denials.")
-	err = yaml.Unmarshal(denyListFile, &objs)
-	if err != nil {
-		return err
-	}
-
-	plog.Debug("Parsed kola-denylist.yaml")
-
-	// Look for the right manifest, taking into account the variant
+// readManifest parses the manifest of the config repo in the cosa
+// workdir, taking into account the variant.
+func readManifest() (*ManifestData, error) {
 	var manifest ManifestData
 	var pathToManifest string
 	pathToInitConfig := filepath.Join(Options.CosaWorkdir, "src/config.json")
@@ -403,21 +393,48 @@ func ParseDenyListYaml(pltfrm string) error {
 		pathToManifest = filepath.Join(Options.CosaWorkdir, "src/config/manifest.yaml")
 	} else if err != nil {
 		// Unexpected error
-		return err
+		return nil, err
 	} else {
 		// Figure out the variant and read the corresponding manifests
 		var initConfig InitConfigData
 		err = json.Unmarshal(initConfigFile, &initConfig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		pathToManifest = filepath.Join(Options.CosaWorkdir, fmt.Sprintf("src/config/manifest-%s.yaml", initConfig.ConfigVariant))
 	}
 	manifestFile, err := os.ReadFile(pathToManifest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = yaml.Unmarshal(manifestFile, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func ParseDenyListYaml(pltfrm string) error {
+	var objs []DenyListObj
+
+	// Parse kola-denylist into structs
+	pathToDenyList := filepath.Join(Options.CosaWorkdir, "src/config/kola-denylist.yaml")
+	denyListFile, err := os.ReadFile(pathToDenyList)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	plog.Debug("Found kola-denylist.yaml. Processing listed denials.")
+	err = yaml.Unmarshal(denyListFile, &objs)
+	if err != nil {
+		return err
+	}
+
+	plog.Debug("Parsed kola-denylist.yaml")
+
+	manifest, err := readManifest()
 	if err != nil {
 		return err
 	}
@@ -458,7 +475,7 @@ func ParseDenyListYaml(pltfrm string) error {
 		}
 
 		if obj.SnoozeDate != "" {
-			snoozeDate, err := time.Parse(snoozeFormat, obj.SnoozeDate)
+			snoozeDate, err := time.Parse(SnoozeFormat, obj.SnoozeDate)
 			if err != nil {
 				return err
 			}
@@ -700,6 +717,16 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		plog.Fatal(err)
 	}
 
+	// Load additional console checks from kola-console-checks.yaml
+	if err := ParseConsoleChecksYaml(pltfrm); err != nil {
+		plog.Fatal(err)
+	}
+
+	// Adjust test requirements from --metadata-overrides
+	if err := ParseMetadataOverrides(testsBank, pltfrm); err != nil {
+		plog.Fatal(err)
+	}
+
 	// Make sure all given patterns by the user match at least one test
 	for _, pattern := range patterns {
 		match, err := patternMatchesTests(pattern, testsBank)
@@ -730,6 +757,11 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		return nil
 	}
 
+	// Pull in the tests the selected tests depend on
+	if err := addDependencies(tests, testsBank, pltfrm); err != nil {
+		plog.Fatal(err)
+	}
+
 	flight, err := NewFlight(pltfrm)
 	if err != nil {
 		plog.Fatalf("Flight failed: %v", err)
@@ -747,6 +779,8 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		}
 	}
 
+	// The tests run by each non-exclusive wrapper
+	bucketTests := make(map[string][]*register.Test)
 	if len(nonExclusiveTests) == 1 {
 		// If there is only one test then it can just be run by itself
 		// so add it back to the tests map.
@@ -779,11 +813,16 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 				numBuckets++
 			} else {
 				tests[nonExclusiveWrapper.Name] = &nonExclusiveWrapper
+				bucketTests[nonExclusiveWrapper.Name] = buckets[i]
 				i++ // Move to the next bucket to evaluate
 			}
 		}
 	}
 
+	if err := checkDependencyCycles(tests, bucketTests); err != nil {
+		plog.Fatal(err)
+	}
+
 	if multiply > 1 {
 		newTests := make(map[string]*register.Test)
 		for name, t := range tests {
@@ -792,6 +831,14 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 				newName := fmt.Sprintf("%s%d", name, i)
 				newT := *t
 				newT.Name = newName
+				// Each copy depends on the matching copy of its dependencies
+				newT.DependsOn = nil
+				for _, dep := range t.DependsOn {
+					if _, ok := tests[dep]; ok {
+						dep = fmt.Sprintf("%s%d", dep, i)
+					}
+					newT.DependsOn = append(newT.DependsOn, dep)
+				}
 				newTests[newName] = &newT
 				register.RegisterTest(&newT)
 			}
@@ -813,6 +860,30 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 			reporters.NewJSONReporter("report.json", pltfrm, versionStr),
 		},
 	}
+	if JUnitFile != "" {
+		opts.Reporters = append(opts.Reporters, reporters.NewJUnitReporter("junit.xml", pltfrm, versionStr))
+	}
+
+	var trackedTests []string
+	for name := range tests {
+		trackedTests = append(trackedTests, name)
+	}
+	for _, bucket := range bucketTests {
+		for _, t := range bucket {
+			trackedTests = append(trackedTests, t.Name)
+		}
+	}
+	testDependencies = newDependencyTracker(trackedTests)
+	if err := planRunFixtures(tests, flight, outputDir); err != nil {
+		plog.Fatal(err)
+	}
+
+	testScheduler = nil
+	if MemoryBudget > 0 || CPUBudget > 0 || TimeBudget > 0 {
+		testScheduler = newScheduler(tests, bucketTests, pltfrm)
+		// The scheduler limits parallelism itself
+		opts.Parallel = len(tests)
+	}
 
 	var htests harness.Tests
 	for _, test := range tests {
@@ -821,6 +892,7 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 			defer func() {
 				// Keep track of failed tests for a rerun
 				testResults.add(h)
+				testDependencies.finish(test.Name, h)
 			}()
 			// We launch a seperate cluster for each kola test
 			// At the end of the test, its cluster is destroyed
@@ -829,29 +901,19 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 		htests.Add(test.Name, run, (test.Timeout*time.Duration(100+(Options.ExtendTimeoutPercent)))/100)
 	}
 
-	handleSuiteErrors := func(outputDir string, suiteErr error) error {
-		caughtTestError := suiteErr != nil
-
-		if TAPFile != "" {
-			src := filepath.Join(outputDir, "test.tap")
-			err := system.CopyRegularFile(src, TAPFile)
-			if suiteErr == nil && err != nil {
-				return err
-			}
-		}
-
-		if caughtTestError {
-			fmt.Printf("FAIL, output in %v\n", outputDir)
-		} else {
-			fmt.Printf("PASS, output in %v\n", outputDir)
-		}
-
-		return suiteErr
-	}
-
 	suite := harness.NewSuite(opts, htests)
 	runErr := suite.Run()
+	// Tear down the fixtures leaked by tests which didn't finish cleanly
+	runFixtures.teardownAll(cluster.TestCluster{})
+	if testScheduler != nil {
+		if err := testScheduler.reportNotRun(outputDir); err != nil {
+			plog.Warningf("Failed to write the list of tests not run: %v", err)
+		}
+	}
 	runErr = handleSuiteErrors(outputDir, runErr)
+	if err := recordHistory(outputDir, pltfrm, inRerun); err != nil {
+		plog.Warningf("Failed to record test history in %s: %v", HistoryFile, err)
+	}
 
 	detectedFailedWarnTrueTests := len(getWarnTrueFailedTests(testResults.getResults())) != 0
 
@@ -860,7 +922,9 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 	if len(testsToRerun) > 0 && rerun {
 		newOutputDir := filepath.Join(outputDir, "rerun")
 		fmt.Printf("\n\n======== Re-running failed tests (flake detection) ========\n\n")
-		reRunErr := runProvidedTests(testsToRerun, []string{"*"}, multiply, false, rerunSuccessTags, pltfrm, newOutputDir)
+		inRerun = true
+		reRunErr := runProvidedTests(withDependencies(testsToRerun, testsBank), []string{"*"}, multiply, false, rerunSuccessTags, pltfrm, newOutputDir)
+		inRerun = false
 		if reRunErr == nil && allTestsAllowRerunSuccess(testsToRerun, rerunSuccessTags) {
 			runErr = nil       // reset to success since all tests allowed rerun success
 			numFailedTests = 0 // zero out the tally of failed tests
@@ -878,6 +942,36 @@ func runProvidedTests(testsBank map[string]*register.Test, patterns []string, mu
 	}
 }
 
+// handleSuiteErrors copies the TAP and JUnit reports of a harness run to
+// the locations given by --tap and --junit-file and prints the outcome.
+func handleSuiteErrors(outputDir string, suiteErr error) error {
+	caughtTestError := suiteErr != nil
+
+	if TAPFile != "" {
+		src := filepath.Join(outputDir, "test.tap")
+		err := system.CopyRegularFile(src, TAPFile)
+		if suiteErr == nil && err != nil {
+			return err
+		}
+	}
+
+	if JUnitFile != "" {
+		src := filepath.Join(outputDir, "reports", "junit.xml")
+		err := system.CopyRegularFile(src, JUnitFile)
+		if suiteErr == nil && err != nil {
+			return err
+		}
+	}
+
+	if caughtTestError {
+		fmt.Printf("FAIL, output in %v\n", outputDir)
+	} else {
+		fmt.Printf("PASS, output in %v\n", outputDir)
+	}
+
+	return suiteErr
+}
+
 func getWarnTrueFailedTests(tests []*harness.H) []string {
 	var warnTrueFailedTests []string
 	for _, test := range tests {
@@ -896,6 +990,16 @@ func getWarnTrueFailedTests(tests []*harness.H) []string {
 }
 
 func allTestsAllowRerunSuccess(testsToRerun map[string]*register.Test, rerunSuccessTags []string) bool {
+	var testTags [][]string
+	for _, test := range testsToRerun {
+		testTags = append(testTags, test.Tags)
+	}
+	return rerunSuccessAllowed(testTags, rerunSuccessTags)
+}
+
+// rerunSuccessAllowed reports whether every one of the re-ran tests, given
+// by their tags, allows the run to succeed when it passes during the rerun.
+func rerunSuccessAllowed(testTags [][]string, rerunSuccessTags []string) bool {
 	// Always consider the special AllowRerunSuccessTag that is added
 	// by the test harness in some failure scenarios.
 	rerunSuccessTags = append(rerunSuccessTags, AllowRerunSuccessTag)
@@ -911,9 +1015,9 @@ func allTestsAllowRerunSuccess(testsToRerun map[string]*register.Test, rerunSucc
 	}
 	// Iterate over the tests that were re-ran. If any of them don't
 	// allow rerun success then just exit early.
-	for _, test := range testsToRerun {
+	for _, tags := range testTags {
 		testAllowsRerunSuccess := false
-		for _, tag := range test.Tags {
+		for _, tag := range tags {
 			if rerunSuccessTagMap[tag] {
 				testAllowsRerunSuccess = true
 			}
@@ -924,6 +1028,7 @@ func allTestsAllowRerunSuccess(testsToRerun map[string]*register.Test, rerunSucc
 	}
 	return true
 }
+
 func GetBaseTestName(testName string) string {
 	// If this is a non-exclusive wrapper then just return the empty string
 	if nonexclusiveWrapperMatch.MatchString(testName) {
@@ -1031,6 +1136,8 @@ type externalTestMeta struct {
 	AllowConfigWarnings       bool     `json:"allowConfigWarnings"                 yaml:"allowConfigWarnings"`
 	NoInstanceCreds           bool     `json:"noInstanceCreds"                     yaml:"noInstanceCreds"`
 	Description               string   `json:"description"                         yaml:"description"`
+	DependsOn                 []string `json:"dependsOn,omitempty"                 yaml:"dependsOn,omitempty"`
+	Fixtures                  []string `json:"fixtures,omitempty"                  yaml:"fixtures,omitempty"`
 }
 
 // metadataFromTestBinary extracts JSON-in-comment like:
@@ -1240,6 +1347,8 @@ ExecStart=%s
 		AppendFirstbootKernelArgs: targetMeta.AppendFirstbootKernelArgs,
 		NonExclusive:              !targetMeta.Exclusive,
 		Conflicts:                 targetMeta.Conflicts,
+		DependsOn:                 targetMeta.DependsOn,
+		Fixtures:                  targetMeta.Fixtures,
 
 		Run: func(c cluster.TestCluster) {
 			mach := c.Machines()[0]
@@ -1489,6 +1598,12 @@ func collectLogsExternalTest(h *harness.H, t *register.Test, tcluster cluster.Te
 
 func createTestBuckets(tests []*register.Test) [][]*register.Test {
 
+	// Order the tests so that dependencies within a bucket run first
+	tests, err := sortByDependencies(tests)
+	if err != nil {
+		plog.Fatal(err)
+	}
+
 	// Make an array of maps. Each entry in the array represents a
 	// test bucket. Each corresponding map is the test.Name -> *register.Test
 	// mapping for tests to be executed
@@ -1517,9 +1632,13 @@ func createTestBuckets(tests []*register.Test) [][]*register.Test {
 	// Distribute into buckets. Start by creating a bucket with the
 	// first test in it and then going from there.
 	bucketInfo = append(bucketInfo, map[string]*register.Test{tests[0].Name: tests[0]})
-mainloop:
 	for _, test := range tests[1:] {
-		for _, bucket := range bucketInfo {
+		// Prefer a bucket which already has one of the test's
+		// dependencies or shares a fixture with it, so that the
+		// dependency doesn't need to be waited for and the fixture can be
+		// set up only once. Otherwise take the first eligible bucket.
+		chosen := -1
+		for i, bucket := range bucketInfo {
 			// Check if this bucket is being used by a conflicting test
 			foundConflict := false
 			for _, conflict := range test.Conflicts {
@@ -1527,31 +1646,60 @@ mainloop:
 					foundConflict = true
 				}
 			}
-			if !foundConflict {
-				// No Conflict here. Assign the test and continue.
-				bucket[test.Name] = test
-				continue mainloop
+			if foundConflict {
+				continue
+			}
+			if chosen < 0 {
+				chosen = i
 			}
+			if bucketHasAffinity(bucket, test) {
+				chosen = i
+				break
+			}
+		}
+		if chosen >= 0 {
+			// No Conflict here. Assign the test and continue.
+			bucketInfo[chosen][test.Name] = test
+		} else {
+			// No eligible buckets found for test. Create a new bucket.
+			bucketInfo = append(bucketInfo, map[string]*register.Test{test.Name: test})
 		}
-		// No eligible buckets found for test. Create a new bucket.
-		bucketInfo = append(bucketInfo, map[string]*register.Test{test.Name: test})
 	}
 
 	// Convert the bucketInfo array of maps into an two dimensional
 	// array of register.Test objects. This is the format the caller
-	// is expecting the data in.
-	var buckets [][]*register.Test
-	for _, bucket := range bucketInfo {
-		var bucketTests []*register.Test
-		for _, test := range bucket {
-			bucketTests = append(bucketTests, test)
+	// is expecting the data in. Tests keep their dependency order.
+	buckets := make([][]*register.Test, len(bucketInfo))
+	for _, test := range tests {
+		for i, bucket := range bucketInfo {
+			if _, found := bucket[test.Name]; found {
+				buckets[i] = append(buckets[i], test)
+				break
+			}
 		}
-		buckets = append(buckets, bucketTests)
 	}
 
 	return buckets
 }
 
+// bucketHasAffinity returns true if the bucket contains a dependency of
+// test or a test sharing one of its fixtures.
+func bucketHasAffinity(bucket map[string]*register.Test, test *register.Test) bool {
+	for _, dep := range test.DependsOn {
+		if _, found := bucket[dep]; found {
+			return true
+		}
+	}
+	for _, other := range bucket {
+		for _, f := range test.Fixtures {
+			if HasString(f, other.Fixtures) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // shardTests filters tests to a particular shard - i.e. a group of tests
 // whose name hashes to the same value.
 func shardTests(tests map[string]*register.Test, sharding string) (map[string]*register.Test, error) {
@@ -1610,6 +1758,9 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 		if test.HasFlag(register.AllowConfigWarnings) {
 			plog.Fatalf("Non-exclusive test %v cannot have AllowConfigWarnings flag", test.Name)
 		}
+		if test.HasFlag(register.ControlledClock) {
+			plog.Fatalf("Non-exclusive test %v cannot have ControlledClock flag", test.Name)
+		}
 		if test.AppendKernelArgs != "" {
 			plog.Fatalf("Non-exclusive test %v cannot have AppendKernelArgs", test.Name)
 		}
@@ -1637,6 +1788,17 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 		plog.Fatalf("Error merging configs: %v", err)
 	}
 
+	// The wrapper sets up the run and bucket scoped fixtures of its
+	// tests, the tests themselves only their test scoped ones
+	fixtures, err := register.ResolveFixtures(fixtureUnion(tests))
+	if err != nil {
+		plog.Fatalf("Error resolving fixtures: %v", err)
+	}
+	var bucketFixtures []string
+	for _, f := range fixturesOfScope(fixtures, register.RunScope, register.BucketScope) {
+		bucketFixtures = append(bucketFixtures, f.Name)
+	}
+
 	nonExclusiveWrapper := register.Test{
 		Name: fmt.Sprintf("non-exclusive-test-bucket-%v", bucket),
 		Run: func(tcluster cluster.TestCluster) {
@@ -1645,6 +1807,7 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 				run := func(h *harness.H) {
 					tcluster.H.NonExclusiveTestStarted()
 					testResults.add(h)
+					defer testDependencies.finish(t.Name, h)
 					// tcluster has a reference to the wrapper's harness
 					// We need a new TestCluster that has a reference to the
 					// subtest being ran
@@ -1652,8 +1815,27 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 					// functions such as TestCluster.SSH, since these functions
 					// internally use harness.RunWithExecTimeoutCheck
 					newTC := cluster.TestCluster{
-						H:       h,
-						Cluster: tcluster.Cluster,
+						H:        h,
+						Cluster:  tcluster.Cluster,
+						Fixtures: tcluster.Fixtures,
+					}
+					// The wrapper already waited for dependencies outside
+					// of the bucket, and the ones inside ran before
+					dirs, err := testDependencies.check(t.DependsOn)
+					if err != nil {
+						h.Skipf("Skipping: %v", err)
+					}
+					newTC.DependencyDirs = dirs
+					testFixtures, err := register.ResolveFixtures(t.Fixtures)
+					if err != nil {
+						h.Fatal(err)
+					}
+					fscope := newFixtureScope(testFixtures, map[register.FixtureScope]*fixturePool{
+						register.TestScope: newFixturePool(flight, h.OutputDir()),
+					})
+					defer fscope.release(newTC)
+					if err := fscope.acquire(&newTC); err != nil {
+						h.Fatal(err)
 					}
 					// Install external test executable
 					if t.ExternalTest != "" {
@@ -1682,6 +1864,8 @@ func makeNonExclusiveTest(bucket int, tests []*register.Test, flight platform.Fl
 		ClusterSize:   1,
 		Tags:          tags,
 		DependencyDir: dependencyDirs,
+		DependsOn:     externalDependencies(tests),
+		Fixtures:      bucketFixtures,
 	}
 
 	return nonExclusiveWrapper
@@ -1694,23 +1878,67 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	h.Parallel()
 	h.SetSubtests(t.Subtests)
 
+	if testScheduler != nil {
+		// Return the test's resources, or withdraw it if it ends
+		// before being admitted
+		defer testScheduler.release(t.Name)
+	}
+
+	// Non-exclusive wrappers only wait for the dependencies of their
+	// tests, which each check for themselves whether theirs passed.
+	testDependencies.wait(h, t.DependsOn)
+	var dependencyDirs map[string]string
+	if !nonexclusiveWrapperMatch.MatchString(t.Name) {
+		dirs, err := testDependencies.check(t.DependsOn)
+		if err != nil {
+			h.Skipf("Skipping: %v", err)
+		}
+		dependencyDirs = dirs
+	}
+
+	if testScheduler != nil {
+		var err error
+		h.Suspend(func() {
+			err = testScheduler.acquire(t.Name)
+		})
+		if err != nil {
+			h.Skipf("Not run: %v", err)
+		}
+	}
+
+	fixtures, err := register.ResolveFixtures(t.Fixtures)
+	if err != nil {
+		h.Fatal(err)
+	}
+	// For exclusive tests the bucket is the test
+	clusterFixtures := newFixturePool(flight, h.OutputDir())
+	fscope := newFixtureScope(fixtures, map[register.FixtureScope]*fixturePool{
+		register.RunScope:    runFixtures,
+		register.BucketScope: clusterFixtures,
+		register.TestScope:   clusterFixtures,
+	})
+	// Drop the references if the test ends before its cluster is set up
+	defer fscope.release(cluster.TestCluster{H: h})
+
 	rconf := &platform.RuntimeConfig{
 		AllowFailedUnits:   testSkipBaseChecks(t),
 		InternetAccess:     testRequiresInternet(t),
 		NoInstanceCreds:    t.HasFlag(register.NoInstanceCreds),
 		NoSSHKeyInMetadata: t.HasFlag(register.NoSSHKeyInMetadata),
 		NoSSHKeyInUserData: t.HasFlag(register.NoSSHKeyInUserData),
+		NTPServer:          t.HasFlag(register.ControlledClock),
 		OutputDir:          h.OutputDir(),
 		SSHOnTestFailure:   Options.SSHOnTestFailure,
 		WarningsAction:     conf.FailWarnings,
 		EarlyRelease:       h.Release,
+		TestFailed:         h.Failed,
 	}
 	if t.HasFlag(register.AllowConfigWarnings) {
 		rconf.WarningsAction = conf.IgnoreWarnings
 	}
 
 	var c platform.Cluster
-	c, err := flight.NewCluster(rconf)
+	c, err = flight.NewCluster(rconf)
 	if err != nil {
 		h.Fatalf("Cluster failed: %v", err)
 	}
@@ -1744,6 +1972,30 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 		for id, output := range c.JournalOutput() {
 			handleConsoleChecks("journal", id, output)
 		}
+		// the IDs of the machines come from their recorded journals, as
+		// machines may have no console output
+		ids, err := platform.RecordedJournals(h.OutputDir())
+		if err != nil {
+			plog.Warningf("Skipping journal checks: %v", err)
+		}
+		for _, id := range ids {
+			entries, err := platform.ReadJournalEntries(filepath.Join(h.OutputDir(), id))
+			if err != nil {
+				plog.Warningf("Skipping journal checks on machine %s: %v", id, err)
+				continue
+			}
+			warnOnly, findings := CheckJournal(entries, t)
+			if SkipConsoleWarnings {
+				warnOnly = true
+			}
+			for _, finding := range findings {
+				if warnOnly {
+					plog.Warningf("Found %s on machine %s journal", finding, id)
+				} else {
+					h.Errorf("Found %s on machine %s journal", finding, id)
+				}
+			}
+		}
 	}()
 
 	if t.ClusterSize > 0 {
@@ -1787,11 +2039,14 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 
 	// Cluster -> TestCluster
 	tcluster := cluster.TestCluster{
-		H:           h,
-		Cluster:     c,
-		NativeFuncs: names,
-		FailFast:    t.FailFast,
+		H:              h,
+		Cluster:        c,
+		NativeFuncs:    names,
+		FailFast:       t.FailFast,
+		DependencyDirs: dependencyDirs,
 	}
+	// Tear down the fixtures before the cluster is destroyed
+	defer fscope.release(tcluster)
 
 	if IsWarningOnFailure(t.Name) {
 		tcluster.H.WarningOnFailure()
@@ -1861,6 +2116,10 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 		time.Sleep(2 * time.Second)
 	}()
 
+	if err := fscope.acquire(&tcluster); err != nil {
+		h.Fatal(err)
+	}
+
 	// run test
 	t.Run(tcluster)
 }
@@ -1898,7 +2157,7 @@ func scpKolet(machines []platform.Machine) error {
 func CheckConsole(output []byte, t *register.Test) (bool, []string) {
 	var badlines []string
 	warnOnly, allowRerunSuccess := true, true
-	for _, check := range consoleChecks {
+	for _, check := range effectiveConsoleChecks(t) {
 		if check.skipFlag != nil && t != nil && t.HasFlag(*check.skipFlag) {
 			continue
 		}
@@ -0,0 +1,214 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform/machine/fake"
+)
+
+// runFakeTest runs test through runTest on the fake platform, calling
+// setup with each of its machines before they start, and returns the output
+// of the test along with the result of the suite.
+func runFakeTest(t *testing.T, test *register.Test, setup func(m *fake.Machine) error) (string, error) {
+	defer func(baseName string) { Options.BaseName = baseName }(Options.BaseName)
+	Options.BaseName = "kola"
+
+	flight, dir := fake.NewTestFlight(t, &fake.Options{Setup: setup, Options: &Options})
+	outputDir := filepath.Join(dir, "output")
+
+	testDependencies = newDependencyTracker([]string{test.Name})
+	if err := planRunFixtures(map[string]*register.Test{test.Name: test}, flight, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	var htests harness.Tests
+	htests.Add(test.Name, func(h *harness.H) {
+		runTest(h, test, "fake", flight)
+	}, time.Minute)
+	opts := harness.Options{
+		OutputDir: outputDir,
+		Parallel:  1,
+		Reporters: reporters.Reporters{reporters.NewJSONReporter("report.json", "fake", "")},
+	}
+	suiteErr := harness.NewSuite(opts, htests).Run()
+
+	report, err := reporters.DeserialiseReport(filepath.Join(outputDir, "reports", "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tests) != 1 {
+		t.Fatalf("expected a report of 1 test, got %d", len(report.Tests))
+	}
+	return report.Tests[0].Output, suiteErr
+}
+
+func TestRunTestJournalChecks(t *testing.T) {
+	test := &register.Test{
+		Name:        "fake.journal",
+		ClusterSize: 1,
+		Run: func(c cluster.TestCluster) {
+			m := c.Machines()[0].(*fake.Machine)
+			m.Log("kernel", "Out of memory: Killed process 42 (stress)")
+		},
+	}
+	var machine *fake.Machine
+	out, err := runFakeTest(t, test, func(m *fake.Machine) error {
+		machine = m
+		return nil
+	})
+	if err != harness.SuiteFailed {
+		t.Fatalf("expected the test to fail, got %v:\n%s", err, out)
+	}
+	// the finding comes from the journal entries, not the console
+	if console := machine.ConsoleOutput(); strings.Contains(console, "Out of memory") {
+		t.Fatalf("unexpected console output %q", console)
+	}
+	if !strings.Contains(out, "Found OOM kill in kernel") {
+		t.Errorf("journal check finding not reported:\n%s", out)
+	}
+
+	// tests can opt out of the check
+	test.Flags = []register.Flag{register.NoOOMKillCheck}
+	if out, err := runFakeTest(t, test, nil); err != nil {
+		t.Errorf("expected NoOOMKillCheck to skip the OOM kill check, got %v:\n%s", err, out)
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		run  func(c cluster.TestCluster)
+		// boots is the number of boots of the machine of the test
+		boots int
+		// failure is the reported failure, if the test should fail
+		failure string
+	}{
+		{
+			name: "fake.ssh",
+			run: func(c cluster.TestCluster) {
+				if out := c.MustSSH(c.Machines()[0], "echo hello"); string(out) != "hello" {
+					c.Fatalf("unexpected output %q", out)
+				}
+			},
+			boots: 1,
+		},
+		{
+			name: "fake.reboot",
+			run: func(c cluster.TestCluster) {
+				if err := c.Machines()[0].Reboot(); err != nil {
+					c.Fatalf("rebooting: %v", err)
+				}
+			},
+			boots: 2,
+		},
+		{
+			name: "fake.fatal",
+			run: func(c cluster.TestCluster) {
+				c.Fatal("broken on purpose")
+			},
+			boots:   1,
+			failure: "broken on purpose",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			register.RegisterTest(&register.Test{
+				Name:        tt.name,
+				ClusterSize: 1,
+				Run:         tt.run,
+			})
+			defer delete(register.Tests, tt.name)
+
+			var machine *fake.Machine
+			out, err := runFakeTest(t, register.Tests[tt.name], func(m *fake.Machine) error {
+				machine = m
+				m.Script().Respond(`echo hello`, "hello\n")
+				return nil
+			})
+			if tt.failure == "" && err != nil {
+				t.Fatalf("test failed: %v\n%s", err, out)
+			}
+			if tt.failure != "" && (err != harness.SuiteFailed || !strings.Contains(out, tt.failure)) {
+				t.Fatalf("expected the test to fail with %q, got %v:\n%s", tt.failure, err, out)
+			}
+			if machine == nil || machine.Boots() != tt.boots {
+				t.Errorf("expected a machine booted %d times", tt.boots)
+			}
+		})
+	}
+}
+
+func TestRunExternalTestReboot(t *testing.T) {
+	test := &register.Test{
+		Name:        "fake.ext",
+		ClusterSize: 1,
+		Run: func(c cluster.TestCluster) {
+			if err := runExternalTest(c, c.Machines()[0], 0); err != nil {
+				c.Fatal(err)
+			}
+		},
+	}
+	var machine *fake.Machine
+	// the reboot marks in the environment of each run of the test unit
+	var marks []string
+	out, err := runFakeTest(t, test, func(m *fake.Machine) error {
+		machine = m
+		var env string
+		m.Script().Respond(`sudo mkdir -p /run`, "")
+		m.Script().Handle(regexp.QuoteMeta("sudo install -m 0755 /dev/stdin /run/kola-runext-env"), func(c *fake.Command) fake.Response {
+			buf, err := io.ReadAll(c.Stdin)
+			if err != nil {
+				return fake.Response{Stderr: err.Error(), ExitStatus: 1}
+			}
+			env = string(buf)
+			return fake.Response{}
+		})
+		// the test requests a reboot on its first two boots
+		m.Script().Handle(regexp.QuoteMeta("sudo ./kolet run-test-unit kola-runext.service"), func(c *fake.Command) fake.Response {
+			marks = append(marks, env)
+			env = ""
+			if boots := c.Machine.Boots(); boots < 3 {
+				return fake.Response{Stdout: fmt.Sprintf(`{"Reboot": "mark-%d"}`, boots)}
+			}
+			return fake.Response{}
+		})
+		// kolet reboots once the request is acknowledged
+		m.Script().Handle(regexp.QuoteMeta("sudo /bin/sh -c 'systemctl stop sshd && echo > "+KoletRebootAckFifo+"'"), func(*fake.Command) fake.Response {
+			return fake.Response{Reboot: true}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("test failed: %v\n%s", err, out)
+	}
+	if machine.Boots() != 3 {
+		t.Errorf("expected 3 boots, got %d", machine.Boots())
+	}
+	expected := []string{"", "AUTOPKGTEST_REBOOT_MARK='mark-1'", "AUTOPKGTEST_REBOOT_MARK='mark-2'"}
+	if !reflect.DeepEqual(marks, expected) {
+		t.Errorf("expected the reboot marks %q, got %q", expected, marks)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/kola/history"
+)
+
+// DefaultHistoryFile returns the test history store used when
+// --history-file isn't given: a file next to the per-run output
+// directories in the cosa workdir, or "" if there is no workdir.
+func DefaultHistoryFile() string {
+	if Options.CosaWorkdir == "" || Options.CosaWorkdir == "none" {
+		return ""
+	}
+	return filepath.Join(Options.CosaWorkdir, "tmp/kola/history.jsonl")
+}
+
+// testedBuildID returns the ID of the build under test, if known.
+func testedBuildID() string {
+	if Options.CosaBuildId == "" && CosaBuild != nil {
+		return CosaBuild.Meta.BuildID
+	}
+	return Options.CosaBuildId
+}
+
+// recordHistory appends the results of the suite whose output is in
+// outputDir to HistoryFile. Non-exclusive test wrappers are skipped; the
+// tests they contain are recorded under their own names.
+func recordHistory(outputDir, pltfrm string, rerun bool) error {
+	if HistoryFile == "" {
+		return nil
+	}
+	report, err := reporters.DeserialiseReport(filepath.Join(outputDir, "reports/report.json"))
+	if err != nil {
+		return err
+	}
+
+	var stream string
+	if manifest, err := readManifest(); err == nil {
+		stream = manifest.Variables.Stream
+	}
+	buildID := testedBuildID()
+
+	now := time.Now().UTC()
+	var records []history.Record
+	for _, t := range report.Tests {
+		name := GetBaseTestName(t.Name)
+		if name == "" {
+			continue
+		}
+		records = append(records, history.Record{
+			Test:      name,
+			Platform:  pltfrm,
+			Arch:      Options.CosaBuildArch,
+			Stream:    stream,
+			BuildID:   buildID,
+			Result:    t.Result,
+			Duration:  t.Duration,
+			Rerun:     rerun,
+			Timestamp: now,
+		})
+	}
+	return history.NewStore(HistoryFile).Append(records)
+}
@@ -0,0 +1,254 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history implements a persistent, append-only record of kola
+// test results which can be used to detect flaky tests.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+// Record is a single test result. The store is a file of JSON-encoded
+// Records, one per line.
+type Record struct {
+	Test      string                `json:"test"`
+	Platform  string                `json:"platform"`
+	Arch      string                `json:"arch"`
+	Stream    string                `json:"stream,omitempty"`
+	BuildID   string                `json:"build,omitempty"`
+	Result    testresult.TestResult `json:"result"`
+	Duration  time.Duration         `json:"duration"`
+	Rerun     bool                  `json:"rerun,omitempty"`
+	Timestamp time.Time             `json:"timestamp"`
+}
+
+// Store is an append-only JSONL file of Records. Writers take an
+// exclusive lock on the file so concurrent kola invocations sharing a
+// store don't interleave lines.
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append adds records to the store, creating it if needed.
+func (s *Store) Append(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("locking %s: %w", s.path, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Load returns all records in the store, in the order they were
+// appended. A missing store is treated as empty.
+func (s *Store) Load() ([]Record, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH); err != nil {
+		return nil, fmt.Errorf("locking %s: %w", s.path, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("parsing %s line %d: %w", s.path, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// Key identifies the series of results a flakiness score is computed
+// over.
+type Key struct {
+	Test     string `json:"test"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+	Stream   string `json:"stream,omitempty"`
+}
+
+// TestStats summarizes the history of one test on one platform, arch
+// and stream.
+type TestStats struct {
+	Key
+	Runs   int `json:"runs"`
+	Passes int `json:"passes"`
+	Fails  int `json:"fails"`
+	Skips  int `json:"skips"`
+	// Flakiness is the fraction of consecutive (non-skipped) runs whose
+	// result differs from the previous one; 0 means the test always
+	// produced the same result, 1 means it alternated every run.
+	Flakiness float64 `json:"flakiness"`
+	// FlakyBuilds counts builds on which the test both passed and failed,
+	// e.g. a failure followed by a successful rerun.
+	FlakyBuilds       int           `json:"flakyBuilds"`
+	MeanDuration      time.Duration `json:"meanDuration"`
+	LastResult        string        `json:"lastResult"`
+	LastRun           time.Time     `json:"lastRun"`
+	LastGoodBuild     string        `json:"lastGoodBuild,omitempty"`
+	LastGoodTimestamp time.Time     `json:"lastGoodTimestamp,omitempty"`
+}
+
+func failed(r testresult.TestResult) bool {
+	return r == testresult.Fail || r == testresult.Warn
+}
+
+// Analyze groups records by Key and computes statistics for each group.
+// The result is sorted by decreasing flakiness, then by name.
+func Analyze(records []Record) []TestStats {
+	groups := make(map[Key][]Record)
+	for _, r := range records {
+		k := Key{Test: r.Test, Platform: r.Platform, Arch: r.Arch, Stream: r.Stream}
+		groups[k] = append(groups[k], r)
+	}
+
+	var stats []TestStats
+	for k, rs := range groups {
+		sort.SliceStable(rs, func(i, j int) bool {
+			return rs[i].Timestamp.Before(rs[j].Timestamp)
+		})
+		st := TestStats{Key: k}
+		var total time.Duration
+		var prev testresult.TestResult
+		flips, compared := 0, 0
+		perBuild := make(map[string][2]bool) // [passed, failed]
+		for _, r := range rs {
+			st.Runs++
+			st.LastResult = string(r.Result)
+			st.LastRun = r.Timestamp
+			if r.Result == testresult.Skip {
+				st.Skips++
+				continue
+			}
+			total += r.Duration
+			if failed(r.Result) {
+				st.Fails++
+			} else {
+				st.Passes++
+				st.LastGoodBuild = r.BuildID
+				st.LastGoodTimestamp = r.Timestamp
+			}
+			if prev != "" {
+				compared++
+				if failed(prev) != failed(r.Result) {
+					flips++
+				}
+			}
+			prev = r.Result
+			if r.BuildID != "" {
+				b := perBuild[r.BuildID]
+				if failed(r.Result) {
+					b[1] = true
+				} else {
+					b[0] = true
+				}
+				perBuild[r.BuildID] = b
+			}
+		}
+		if compared > 0 {
+			st.Flakiness = float64(flips) / float64(compared)
+		}
+		for _, b := range perBuild {
+			if b[0] && b[1] {
+				st.FlakyBuilds++
+			}
+		}
+		if executed := st.Passes + st.Fails; executed > 0 {
+			st.MeanDuration = total / time.Duration(executed)
+		}
+		stats = append(stats, st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Flakiness != stats[j].Flakiness {
+			return stats[i].Flakiness > stats[j].Flakiness
+		}
+		if stats[i].Test != stats[j].Test {
+			return stats[i].Test < stats[j].Test
+		}
+		if stats[i].Platform != stats[j].Platform {
+			return stats[i].Platform < stats[j].Platform
+		}
+		if stats[i].Arch != stats[j].Arch {
+			return stats[i].Arch < stats[j].Arch
+		}
+		return stats[i].Stream < stats[j].Stream
+	})
+	return stats
+}
+
+// Flaky returns the stats whose flakiness is at least threshold and
+// which have at least minRuns non-skipped runs.
+func Flaky(stats []TestStats, threshold float64, minRuns int) []TestStats {
+	var ret []TestStats
+	for _, st := range stats {
+		if st.Passes+st.Fails < minRuns {
+			continue
+		}
+		if st.Flakiness >= threshold {
+			ret = append(ret, st)
+		}
+	}
+	return ret
+}
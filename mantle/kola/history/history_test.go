@@ -0,0 +1,97 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "sub", "history.jsonl"))
+	if records, err := store.Load(); err != nil || len(records) != 0 {
+		t.Fatalf("expected empty store, got %v %v", records, err)
+	}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := []Record{
+		{Test: "basic", Platform: "qemu", Arch: "x86_64", BuildID: "1", Result: testresult.Pass, Duration: time.Minute, Timestamp: base},
+		{Test: "basic", Platform: "qemu", Arch: "x86_64", BuildID: "2", Result: testresult.Fail, Duration: time.Minute, Timestamp: base.Add(time.Hour)},
+	}
+	if err := store.Append(in[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append(in[1:]); err != nil {
+		t.Fatal(err)
+	}
+	out, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].BuildID != "1" || out[1].Result != testresult.Fail || !out[1].Timestamp.Equal(in[1].Timestamp) {
+		t.Errorf("unexpected records: %+v", out)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := func(test, build string, result testresult.TestResult, hour int) Record {
+		return Record{Test: test, Platform: "qemu", Arch: "x86_64", Stream: "testing-devel",
+			BuildID: build, Result: result, Duration: time.Minute, Timestamp: base.Add(time.Duration(hour) * time.Hour)}
+	}
+	records := []Record{
+		rec("stable", "1", testresult.Pass, 0),
+		rec("stable", "2", testresult.Pass, 1),
+		rec("stable", "3", testresult.Pass, 2),
+		rec("flaky", "1", testresult.Pass, 0),
+		rec("flaky", "2", testresult.Fail, 1),
+		rec("flaky", "2", testresult.Pass, 2), // rerun
+		rec("flaky", "3", testresult.Skip, 3),
+		rec("flaky", "4", testresult.Fail, 4),
+	}
+
+	stats := Analyze(records)
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(stats))
+	}
+	flaky := stats[0]
+	if flaky.Test != "flaky" {
+		t.Fatalf("expected flakiest test first, got %s", flaky.Test)
+	}
+	if flaky.Runs != 5 || flaky.Passes != 2 || flaky.Fails != 2 || flaky.Skips != 1 {
+		t.Errorf("unexpected counts: %+v", flaky)
+	}
+	if flaky.Flakiness != 1 {
+		t.Errorf("expected flakiness 1, got %v", flaky.Flakiness)
+	}
+	if flaky.FlakyBuilds != 1 {
+		t.Errorf("expected 1 flaky build, got %d", flaky.FlakyBuilds)
+	}
+	if flaky.LastGoodBuild != "2" || flaky.LastResult != string(testresult.Fail) {
+		t.Errorf("unexpected last results: %+v", flaky)
+	}
+	if stats[1].Flakiness != 0 || stats[1].LastGoodBuild != "3" {
+		t.Errorf("unexpected stats for stable test: %+v", stats[1])
+	}
+
+	if got := Flaky(stats, 0.5, 4); len(got) != 1 || got[0].Test != "flaky" {
+		t.Errorf("unexpected flaky tests: %+v", got)
+	}
+	if got := Flaky(stats, 0.5, 5); len(got) != 0 {
+		t.Errorf("min runs not respected: %+v", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/network/journal"
+)
+
+// journalCheck is a set of field patterns which indicate a problem when
+// all of them match a journal entry of a machine.
+type journalCheck struct {
+	desc string
+	// journal field name to pattern the field value must match; an entry
+	// lacking one of the fields doesn't match
+	fields            map[string]*regexp.Regexp
+	warnOnly          bool
+	allowRerunSuccess bool
+	skipFlag          *register.Flag
+}
+
+var journalChecks = []journalCheck{
+	{
+		desc:     "coredump",
+		skipFlag: &[]register.Flag{register.NoCoredumpCheck}[0],
+		fields: map[string]*regexp.Regexp{
+			// SD_MESSAGE_COREDUMP, logged by systemd-coredump
+			journal.FIELD_MESSAGE_ID: regexp.MustCompile("^fc2e22bc6ee647b6b90729ab34a250b1$"),
+		},
+	},
+	{
+		desc:     "SELinux AVC denial",
+		skipFlag: &[]register.Flag{register.NoSELinuxDenialCheck}[0],
+		fields: map[string]*regexp.Regexp{
+			journal.FIELD_TRANSPORT: regexp.MustCompile("^(audit|kernel)$"),
+			journal.FIELD_MESSAGE:   regexp.MustCompile(`avc:\s+denied`),
+		},
+	},
+	{
+		// Only the global OOM killer; tests may well exceed the memory
+		// limit of a cgroup on purpose.
+		desc:     "OOM kill",
+		skipFlag: &[]register.Flag{register.NoOOMKillCheck}[0],
+		fields: map[string]*regexp.Regexp{
+			journal.FIELD_TRANSPORT: regexp.MustCompile("^kernel$"),
+			journal.FIELD_MESSAGE:   regexp.MustCompile("^Out of memory: Kill(ed)? process"),
+		},
+	},
+	{
+		desc:     "segfault",
+		skipFlag: &[]register.Flag{register.NoSegfaultCheck}[0],
+		fields: map[string]*regexp.Regexp{
+			journal.FIELD_TRANSPORT: regexp.MustCompile("^kernel$"),
+			journal.FIELD_MESSAGE:   regexp.MustCompile(`segfault at [0-9a-f]+ ip`),
+		},
+	},
+}
+
+// journalFinding is a journal entry matching a journal check.
+type journalFinding struct {
+	desc    string
+	unit    string
+	time    time.Time
+	message string
+}
+
+func (f journalFinding) String() string {
+	s := f.desc
+	if f.unit != "" {
+		s += fmt.Sprintf(" in %s", f.unit)
+	}
+	if !f.time.IsZero() {
+		s += fmt.Sprintf(" at %s", f.time.UTC().Format(time.RFC3339))
+	}
+	if f.message != "" {
+		s += fmt.Sprintf(": %s", f.message)
+	}
+	return s
+}
+
+func (check *journalCheck) matches(entry journal.Entry) bool {
+	for field, pattern := range check.fields {
+		value, ok := entry[field]
+		if !ok || !pattern.Match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// newJournalFinding describes entry, naming the unit it is about: the
+// crashed unit for a coredump, else the unit or program which logged it.
+func newJournalFinding(desc string, entry journal.Entry) journalFinding {
+	var unit string
+	for _, field := range []string{journal.FIELD_COREDUMP_UNIT, journal.FIELD_SYSTEMD_UNIT, journal.FIELD_SYSLOG_IDENTIFIER} {
+		if value, ok := entry[field]; ok && len(value) > 0 {
+			unit = string(value)
+			break
+		}
+	}
+	// coredump messages continue with a stack trace
+	message := entry[journal.FIELD_MESSAGE]
+	if i := bytes.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+	}
+	return journalFinding{
+		desc:    desc,
+		unit:    unit,
+		time:    entry.Realtime(),
+		message: string(bytes.TrimSpace(message)),
+	}
+}
+
+// CheckJournal checks the journal entries of a machine for badness and
+// returns descriptions of the entries it finds, with their unit and
+// timestamp, along with a boolean indicating if the configuration has the
+// matching checks marked as warnOnly or not. If t is specified, its flags
+// are respected and tags possibly updated for rerun success, like
+// CheckConsole.
+func CheckJournal(entries []journal.Entry, t *register.Test) (bool, []string) {
+	var findings []string
+	warnOnly, allowRerunSuccess := true, true
+	for _, check := range journalChecks {
+		if check.skipFlag != nil && t != nil && t.HasFlag(*check.skipFlag) {
+			continue
+		}
+		applyConsoleCheckOverrides(t, check.desc, &check.warnOnly, &check.allowRerunSuccess)
+		found := false
+		for _, entry := range entries {
+			if check.matches(entry) {
+				findings = append(findings, newJournalFinding(check.desc, entry).String())
+				found = true
+			}
+		}
+		if found {
+			if !check.warnOnly {
+				warnOnly = false
+			}
+			if !check.allowRerunSuccess {
+				allowRerunSuccess = false
+			}
+		}
+	}
+	if len(findings) > 0 && allowRerunSuccess && t != nil {
+		markTestForRerunSuccess(t, "CheckJournal:")
+	}
+	return warnOnly, findings
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+const testJournalExport = `__CURSOR=s=1;i=1
+__REALTIME_TIMESTAMP=1767225600000000
+_TRANSPORT=journal
+_SYSTEMD_UNIT=systemd-coredump@0-1-0.service
+SYSLOG_IDENTIFIER=systemd-coredump
+MESSAGE_ID=fc2e22bc6ee647b6b90729ab34a250b1
+COREDUMP_UNIT=zincati.service
+MESSAGE=Process 1234 (zincati) of user 0 dumped core.
+
+__CURSOR=s=1;i=2
+__REALTIME_TIMESTAMP=1767225601000000
+_TRANSPORT=kernel
+SYSLOG_IDENTIFIER=kernel
+MESSAGE=Memory cgroup out of memory: Killed process 42 (stress)
+
+__CURSOR=s=1;i=3
+__REALTIME_TIMESTAMP=1767225602000000
+_TRANSPORT=journal
+_SYSTEMD_UNIT=sshd.service
+MESSAGE=Accepted publickey for core
+
+`
+
+func TestCheckJournal(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "journal-raw.txt.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the journal is recorded again from the start after a reboot
+	for i := 0; i < 2; i++ {
+		z := gzip.NewWriter(f)
+		if _, err := z.Write([]byte(testJournalExport)); err != nil {
+			t.Fatal(err)
+		}
+		if err := z.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	entries, err := platform.ReadJournalEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	warnOnly, findings := CheckJournal(entries, nil)
+	expected := "coredump in zincati.service at 2026-01-01T00:00:00Z: Process 1234 (zincati) of user 0 dumped core."
+	if warnOnly || len(findings) != 1 || findings[0] != expected {
+		t.Errorf("unexpected result: %v %q", warnOnly, findings)
+	}
+
+	if _, findings := CheckJournal(entries, &register.Test{Name: "crash", Flags: []register.Flag{register.NoCoredumpCheck}}); len(findings) != 0 {
+		t.Errorf("expected NoCoredumpCheck to skip the coredump check, got %q", findings)
+	}
+
+	consoleCheckOverrides = []consoleCheckOverride{{desc: "coredump", warnOnly: &[]bool{true}[0], tests: []string{"ext.*"}}}
+	defer func() { consoleCheckOverrides = nil }()
+	warnOnly, _ = CheckJournal(entries, &register.Test{Name: "ext.config.crash"})
+	if !warnOnly {
+		t.Error("expected override to make the coredump check warnOnly")
+	}
+	warnOnly, _ = CheckJournal(entries, &register.Test{Name: "basic"})
+	if warnOnly {
+		t.Error("expected override not to apply to other tests")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+// MetadataOverrideObj adjusts the metadata of every registered test
+// matching Pattern, without recompiling kola for native tests or
+// editing the `## kola:` header of external tests. Unset fields are left
+// alone. Like kola-denylist.yaml entries, an override can be scoped to
+// some arches, platforms and streams.
+type MetadataOverrideObj struct {
+	Pattern   string   `yaml:"pattern"`
+	Arches    []string `yaml:"arches,omitempty"`
+	Platforms []string `yaml:"platforms,omitempty"`
+	Streams   []string `yaml:"streams,omitempty"`
+
+	MinMemory                 *int     `yaml:"minMemory,omitempty"`
+	MinDiskSize               *int     `yaml:"minDisk,omitempty"`
+	AdditionalDisks           []string `yaml:"additionalDisks,omitempty"`
+	AdditionalNics            *int     `yaml:"additionalNics,omitempty"`
+	AppendKernelArgs          *string  `yaml:"appendKernelArgs,omitempty"`
+	AppendFirstbootKernelArgs *string  `yaml:"appendFirstbootKernelArgs,omitempty"`
+	TimeoutMin                *int     `yaml:"timeoutMin,omitempty"`
+	Tags                      []string `yaml:"tags,omitempty"`
+}
+
+// ParseMetadataOverrides reads MetadataOverridesFile and applies the
+// entries relevant to pltfrm, the current arch and stream to tests.
+// Entries are applied in order, so later entries win.
+func ParseMetadataOverrides(tests map[string]*register.Test, pltfrm string) error {
+	if MetadataOverridesFile == "" {
+		return nil
+	}
+	buf, err := os.ReadFile(MetadataOverridesFile)
+	if err != nil {
+		return err
+	}
+	var objs []MetadataOverrideObj
+	if err := yaml.UnmarshalStrict(buf, &objs); err != nil {
+		return fmt.Errorf("parsing %s: %w", MetadataOverridesFile, err)
+	}
+
+	var stream string
+	if manifest, err := readManifest(); err == nil {
+		stream = manifest.Variables.Stream
+	}
+	return applyMetadataOverrides(objs, tests, pltfrm, Options.CosaBuildArch, stream)
+}
+
+func applyMetadataOverrides(objs []MetadataOverrideObj, tests map[string]*register.Test, pltfrm, arch, stream string) error {
+	for _, obj := range objs {
+		if obj.Pattern == "" {
+			return fmt.Errorf("metadata override without pattern")
+		}
+		if len(obj.Arches) > 0 && !HasString(arch, obj.Arches) {
+			continue
+		}
+		if len(obj.Platforms) > 0 && !HasString(pltfrm, obj.Platforms) {
+			continue
+		}
+		if len(stream) > 0 && len(obj.Streams) > 0 && !HasString(stream, obj.Streams) {
+			continue
+		}
+
+		matched := false
+		for name, t := range tests {
+			match, err := filepath.Match(obj.Pattern, name)
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			matched = true
+			obj.apply(t)
+		}
+		if !matched {
+			plog.Debugf("Metadata override pattern %q matched no tests", obj.Pattern)
+		}
+	}
+	return nil
+}
+
+func (obj *MetadataOverrideObj) apply(t *register.Test) {
+	plog.Debugf("Applying metadata override %q to %s", obj.Pattern, t.Name)
+	if obj.MinMemory != nil {
+		t.MinMemory = *obj.MinMemory
+	}
+	if obj.MinDiskSize != nil {
+		t.MinDiskSize = *obj.MinDiskSize
+	}
+	if obj.AdditionalDisks != nil {
+		t.AdditionalDisks = obj.AdditionalDisks
+	}
+	if obj.AdditionalNics != nil {
+		t.AdditionalNics = *obj.AdditionalNics
+	}
+	if obj.AppendKernelArgs != nil {
+		t.AppendKernelArgs = *obj.AppendKernelArgs
+	}
+	if obj.AppendFirstbootKernelArgs != nil {
+		t.AppendFirstbootKernelArgs = *obj.AppendFirstbootKernelArgs
+	}
+	if obj.TimeoutMin != nil {
+		t.Timeout = time.Duration(*obj.TimeoutMin) * time.Minute
+	}
+	for _, tag := range obj.Tags {
+		if !HasString(tag, t.Tags) {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+}
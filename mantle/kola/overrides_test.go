@@ -0,0 +1,243 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+)
+
+func overrideTests() map[string]*register.Test {
+	return map[string]*register.Test{
+		"ext.config.disk":  {Name: "ext.config.disk", MinMemory: 1024, Tags: []string{"disk"}},
+		"ext.config.net":   {Name: "ext.config.net", MinMemory: 1024},
+		"coreos.boot-time": {Name: "coreos.boot-time", Timeout: 5 * time.Minute},
+	}
+}
+
+func intp(i int) *int       { return &i }
+func strp(s string) *string { return &s }
+
+func TestApplyMetadataOverrides(t *testing.T) {
+	tests := []struct {
+		name   string
+		objs   []MetadataOverrideObj
+		pltfrm string
+		arch   string
+		stream string
+		// want maps test names to the expected MinMemory
+		want map[string]int
+	}{
+		{
+			name: "pattern",
+			objs: []MetadataOverrideObj{{Pattern: "ext.config.*", MinMemory: intp(4096)}},
+			want: map[string]int{"ext.config.disk": 4096, "ext.config.net": 4096, "coreos.boot-time": 0},
+		},
+		{
+			name: "later entries win",
+			objs: []MetadataOverrideObj{
+				{Pattern: "ext.config.*", MinMemory: intp(4096)},
+				{Pattern: "ext.config.net", MinMemory: intp(2048)},
+			},
+			want: map[string]int{"ext.config.disk": 4096, "ext.config.net": 2048},
+		},
+		{
+			name:   "matching platform",
+			objs:   []MetadataOverrideObj{{Pattern: "ext.config.disk", Platforms: []string{"aws", "qemu"}, MinMemory: intp(4096)}},
+			pltfrm: "qemu",
+			want:   map[string]int{"ext.config.disk": 4096},
+		},
+		{
+			name:   "other platform",
+			objs:   []MetadataOverrideObj{{Pattern: "ext.config.disk", Platforms: []string{"aws"}, MinMemory: intp(4096)}},
+			pltfrm: "qemu",
+			want:   map[string]int{"ext.config.disk": 1024},
+		},
+		{
+			name: "matching arch",
+			objs: []MetadataOverrideObj{{Pattern: "ext.config.disk", Arches: []string{"s390x"}, MinMemory: intp(4096)}},
+			arch: "s390x",
+			want: map[string]int{"ext.config.disk": 4096},
+		},
+		{
+			name: "other arch",
+			objs: []MetadataOverrideObj{{Pattern: "ext.config.disk", Arches: []string{"s390x"}, MinMemory: intp(4096)}},
+			arch: "x86_64",
+			want: map[string]int{"ext.config.disk": 1024},
+		},
+		{
+			name:   "matching stream",
+			objs:   []MetadataOverrideObj{{Pattern: "ext.config.disk", Streams: []string{"rawhide"}, MinMemory: intp(4096)}},
+			stream: "rawhide",
+			want:   map[string]int{"ext.config.disk": 4096},
+		},
+		{
+			name:   "other stream",
+			objs:   []MetadataOverrideObj{{Pattern: "ext.config.disk", Streams: []string{"rawhide"}, MinMemory: intp(4096)}},
+			stream: "stable",
+			want:   map[string]int{"ext.config.disk": 1024},
+		},
+		{
+			// like the denylist, streams are ignored if the stream
+			// isn't known
+			name: "unknown stream",
+			objs: []MetadataOverrideObj{{Pattern: "ext.config.disk", Streams: []string{"rawhide"}, MinMemory: intp(4096)}},
+			want: map[string]int{"ext.config.disk": 4096},
+		},
+		{
+			name: "no match",
+			objs: []MetadataOverrideObj{{Pattern: "ext.nope.*", MinMemory: intp(4096)}},
+			want: map[string]int{"ext.config.disk": 1024, "ext.config.net": 1024},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registered := overrideTests()
+			if err := applyMetadataOverrides(tt.objs, registered, tt.pltfrm, tt.arch, tt.stream); err != nil {
+				t.Fatal(err)
+			}
+			for name, mem := range tt.want {
+				if got := registered[name].MinMemory; got != mem {
+					t.Errorf("%s: MinMemory is %d, want %d", name, got, mem)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyMetadataOverridesFields(t *testing.T) {
+	registered := overrideTests()
+	objs := []MetadataOverrideObj{{
+		Pattern:                   "ext.config.disk",
+		MinDiskSize:               intp(20),
+		AdditionalDisks:           []string{"5G"},
+		AdditionalNics:            intp(2),
+		AppendKernelArgs:          strp("console=ttyS1"),
+		AppendFirstbootKernelArgs: strp("rd.debug"),
+		TimeoutMin:                intp(30),
+		Tags:                      []string{"disk", "slow"},
+	}}
+	if err := applyMetadataOverrides(objs, registered, "qemu", "x86_64", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := register.Test{
+		Name:                      "ext.config.disk",
+		MinMemory:                 1024,
+		MinDiskSize:               20,
+		AdditionalDisks:           []string{"5G"},
+		AdditionalNics:            2,
+		AppendKernelArgs:          "console=ttyS1",
+		AppendFirstbootKernelArgs: "rd.debug",
+		Timeout:                   30 * time.Minute,
+		Tags:                      []string{"disk", "slow"},
+	}
+	if got := *registered["ext.config.disk"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// unset fields are left alone
+	if got := registered["coreos.boot-time"].Timeout; got != 5*time.Minute {
+		t.Errorf("timeout of unmatched test changed to %v", got)
+	}
+}
+
+func TestApplyMetadataOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  MetadataOverrideObj
+		err  string
+	}{
+		{
+			name: "missing pattern",
+			obj:  MetadataOverrideObj{MinMemory: intp(4096)},
+			err:  "metadata override without pattern",
+		},
+		{
+			name: "malformed pattern",
+			obj:  MetadataOverrideObj{Pattern: "ext.[", MinMemory: intp(4096)},
+			err:  "syntax error in pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applyMetadataOverrides([]MetadataOverrideObj{tt.obj}, overrideTests(), "qemu", "x86_64", "")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseMetadataOverrides(t *testing.T) {
+	defer func(file, arch string) {
+		MetadataOverridesFile = file
+		Options.CosaBuildArch = arch
+	}(MetadataOverridesFile, Options.CosaBuildArch)
+	Options.CosaBuildArch = "x86_64"
+
+	write := func(t *testing.T, contents string) {
+		MetadataOverridesFile = filepath.Join(t.TempDir(), "overrides.yaml")
+		if err := os.WriteFile(MetadataOverridesFile, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		write(t, `
+- pattern: ext.config.*
+  arches: [x86_64]
+  minMemory: 4096
+- pattern: coreos.boot-time
+  arches: [aarch64]
+  minMemory: 4096
+`)
+		registered := overrideTests()
+		if err := ParseMetadataOverrides(registered, "qemu"); err != nil {
+			t.Fatal(err)
+		}
+		if got := registered["ext.config.net"].MinMemory; got != 4096 {
+			t.Errorf("MinMemory of ext.config.net is %d", got)
+		}
+		if got := registered["coreos.boot-time"].MinMemory; got != 0 {
+			t.Errorf("MinMemory of coreos.boot-time is %d", got)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		write(t, "- pattern: ext.config.*\n  minMemroy: 4096\n")
+		if err := ParseMetadataOverrides(overrideTests(), "qemu"); err == nil || !strings.Contains(err.Error(), "minMemroy") {
+			t.Fatalf("got error %v, want one about the unknown field", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		MetadataOverridesFile = filepath.Join(t.TempDir(), "overrides.yaml")
+		if err := ParseMetadataOverrides(overrideTests(), "qemu"); err == nil {
+			t.Fatal("missing overrides file didn't fail")
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		MetadataOverridesFile = ""
+		if err := ParseMetadataOverrides(overrideTests(), "qemu"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
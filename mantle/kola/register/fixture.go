@@ -0,0 +1,146 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"fmt"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// FixtureScope determines how long a fixture lives and how widely it is
+// shared.
+type FixtureScope int
+
+const (
+	// RunScope fixtures are set up once per kola run, the first time a
+	// test requiring them starts, and torn down once the last test
+	// requiring them has finished. They do not have access to the
+	// cluster of a test and typically create their own using Flight.
+	RunScope FixtureScope = iota
+	// BucketScope fixtures are set up once per cluster: for exclusive
+	// tests this is the same as TestScope, while all the tests of a
+	// non-exclusive bucket share one instance.
+	BucketScope
+	// TestScope fixtures are set up for each test requiring them.
+	TestScope
+)
+
+func (s FixtureScope) String() string {
+	switch s {
+	case RunScope:
+		return "run"
+	case BucketScope:
+		return "bucket"
+	case TestScope:
+		return "test"
+	}
+	return fmt.Sprintf("FixtureScope(%d)", int(s))
+}
+
+// FixtureContext is passed to the Setup and Teardown functions of a
+// Fixture.
+type FixtureContext struct {
+	// Cluster the fixture is being set up on. Only set for BucketScope
+	// and TestScope fixtures.
+	Cluster cluster.TestCluster
+	// Flight of the run, which RunScope fixtures can use to create
+	// their own clusters.
+	Flight platform.Flight
+	// OutputDir is a directory reserved for the fixture's logs.
+	OutputDir string
+	// Values of the fixtures listed in Requires.
+	Values map[string]interface{}
+}
+
+// Fixture is an expensive piece of state (e.g. a pulled container image,
+// a formatted disk or a running service) shared by the tests listing it
+// in their Fixtures. Fixtures are reference-counted: each instance is set
+// up before the first test of its scope needing it and torn down right
+// after the last one finished, in the reverse order of setup.
+type Fixture struct {
+	Name  string // should be unique
+	Scope FixtureScope
+	// Requires lists fixtures this one is built upon. They must have the
+	// same scope or a wider one.
+	Requires []string
+	// Setup creates the fixture and returns a value made available to
+	// tests via TestCluster.Fixture().
+	Setup func(ctx *FixtureContext) (interface{}, error)
+	// Teardown, if set, destroys the fixture. Failures are reported
+	// against the test which released the last reference.
+	Teardown func(ctx *FixtureContext, value interface{}) error
+}
+
+// Registered fixtures live here. Mapping of names to fixtures.
+var Fixtures = map[string]*Fixture{}
+
+// RegisterFixture is usually called via init() functions. Panics if a
+// fixture with the same name is already registered or if it requires a
+// registered fixture of narrower scope.
+func RegisterFixture(f *Fixture) {
+	if _, ok := Fixtures[f.Name]; ok {
+		panic(fmt.Sprintf("fixture %v already registered", f.Name))
+	}
+	if f.Setup == nil {
+		panic(fmt.Sprintf("fixture %v has no setup function", f.Name))
+	}
+	for _, req := range f.Requires {
+		if r, ok := Fixtures[req]; ok && r.Scope > f.Scope {
+			panic(fmt.Sprintf("%s fixture %v cannot require %s fixture %v", f.Scope, f.Name, r.Scope, req))
+		}
+	}
+	Fixtures[f.Name] = f
+}
+
+// ResolveFixtures returns the given fixtures and all the fixtures they
+// transitively require, ordered so that each fixture comes after its
+// requirements.
+func ResolveFixtures(names []string) ([]*Fixture, error) {
+	var ret []*Fixture
+	state := make(map[string]int) // 1: visiting, 2: done
+	var visit func(name string, from *Fixture) error
+	visit = func(name string, from *Fixture) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("fixture %v requires itself", name)
+		case 2:
+			return nil
+		}
+		f, ok := Fixtures[name]
+		if !ok {
+			return fmt.Errorf("unknown fixture %v", name)
+		}
+		if from != nil && f.Scope > from.Scope {
+			return fmt.Errorf("%s fixture %v cannot require %s fixture %v", from.Scope, from.Name, f.Scope, name)
+		}
+		state[name] = 1
+		for _, req := range f.Requires {
+			if err := visit(req, f); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ret = append(ret, f)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"fmt"
+	"sort"
+)
+
+var flagNames = map[Flag]string{
+	NoSSHKeyInUserData:    "NoSSHKeyInUserData",
+	NoSSHKeyInMetadata:    "NoSSHKeyInMetadata",
+	NoInstanceCreds:       "NoInstanceCreds",
+	NoEmergencyShellCheck: "NoEmergencyShellCheck",
+	NoCoredumpCheck:       "NoCoredumpCheck",
+	NoSELinuxDenialCheck:  "NoSELinuxDenialCheck",
+	NoOOMKillCheck:        "NoOOMKillCheck",
+	NoSegfaultCheck:       "NoSegfaultCheck",
+	AllowConfigWarnings:   "AllowConfigWarnings",
+	ControlledClock:       "ControlledClock",
+}
+
+func (f Flag) String() string {
+	if name, ok := flagNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Flag(%d)", int(f))
+}
+
+// Metadata is the declarative part of a Test, i.e. everything except
+// the functions and the Ignition config. It is the same for native
+// tests and for external tests, whose metadata is parsed from their
+// `## kola:` YAML header.
+type Metadata struct {
+	Name                      string
+	Description               string
+	External                  string   `json:",omitempty"`
+	NativeFuncs               []string `json:",omitempty"`
+	Subtests                  []string `json:",omitempty"`
+	ClusterSize               int
+	Platforms                 []string `json:",omitempty"`
+	ExcludePlatforms          []string `json:",omitempty"`
+	Firmwares                 []string `json:",omitempty"`
+	ExcludeFirmwares          []string `json:",omitempty"`
+	Distros                   []string `json:",omitempty"`
+	ExcludeDistros            []string `json:",omitempty"`
+	Architectures             []string `json:",omitempty"`
+	ExcludeArchitectures      []string `json:",omitempty"`
+	Flags                     []string `json:",omitempty"`
+	Tags                      []string `json:",omitempty"`
+	RequiredTag               string   `json:",omitempty"`
+	Timeout                   string   `json:",omitempty"`
+	MultiPathDisk             bool
+	AdditionalDisks           []string `json:",omitempty"`
+	InjectContainer           bool
+	MinMemory                 int
+	MinDiskSize               int
+	AdditionalNics            int
+	AppendKernelArgs          string `json:",omitempty"`
+	AppendFirstbootKernelArgs string `json:",omitempty"`
+	FailFast                  bool
+	Exclusive                 bool
+	Conflicts                 []string `json:",omitempty"`
+	DependsOn                 []string `json:",omitempty"`
+	Fixtures                  []string `json:",omitempty"`
+}
+
+// Metadata returns the effective metadata of the test.
+func (t *Test) Metadata() Metadata {
+	m := Metadata{
+		Name:                      t.Name,
+		Description:               t.Description,
+		External:                  t.ExternalTest,
+		Subtests:                  t.Subtests,
+		ClusterSize:               t.ClusterSize,
+		Platforms:                 t.Platforms,
+		ExcludePlatforms:          t.ExcludePlatforms,
+		Firmwares:                 t.Firmwares,
+		ExcludeFirmwares:          t.ExcludeFirmwares,
+		Distros:                   t.Distros,
+		ExcludeDistros:            t.ExcludeDistros,
+		Architectures:             t.Architectures,
+		ExcludeArchitectures:      t.ExcludeArchitectures,
+		Tags:                      t.Tags,
+		RequiredTag:               t.RequiredTag,
+		MultiPathDisk:             t.MultiPathDisk,
+		AdditionalDisks:           t.AdditionalDisks,
+		InjectContainer:           t.InjectContainer,
+		MinMemory:                 t.MinMemory,
+		MinDiskSize:               t.MinDiskSize,
+		AdditionalNics:            t.AdditionalNics,
+		AppendKernelArgs:          t.AppendKernelArgs,
+		AppendFirstbootKernelArgs: t.AppendFirstbootKernelArgs,
+		FailFast:                  t.FailFast,
+		Exclusive:                 !t.NonExclusive,
+		Conflicts:                 t.Conflicts,
+		DependsOn:                 t.DependsOn,
+		Fixtures:                  t.Fixtures,
+	}
+	if t.Timeout != 0 {
+		m.Timeout = t.Timeout.String()
+	}
+	for name := range t.NativeFuncs {
+		m.NativeFuncs = append(m.NativeFuncs, name)
+	}
+	sort.Strings(m.NativeFuncs)
+	for _, f := range t.Flags {
+		m.Flags = append(m.Flags, f.String())
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	test := &Test{
+		Name: "coreos.example",
+		NativeFuncs: map[string]NativeFuncWrap{
+			"Verify": CreateNativeFuncWrap(func() error { return nil }),
+			"Check":  CreateNativeFuncWrap(func() error { return nil }),
+		},
+		Platforms:      []string{"qemu"},
+		Flags:          []Flag{NoSSHKeyInUserData, ControlledClock},
+		Timeout:        90 * time.Second,
+		DependsOn:      []string{"coreos.base"},
+		Fixtures:       []string{"registry"},
+		AdditionalNics: 1,
+	}
+	want := Metadata{
+		Name:           "coreos.example",
+		NativeFuncs:    []string{"Check", "Verify"},
+		Platforms:      []string{"qemu"},
+		Flags:          []string{"NoSSHKeyInUserData", "ControlledClock"},
+		Timeout:        "1m30s",
+		Exclusive:      true,
+		DependsOn:      []string{"coreos.base"},
+		Fixtures:       []string{"registry"},
+		AdditionalNics: 1,
+	}
+	if got := test.Metadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// the timeout is omitted when unset and NonExclusive is inverted
+	test = &Test{Name: "coreos.other", NonExclusive: true}
+	if got := test.Metadata(); got.Timeout != "" || got.Exclusive {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestFlagString(t *testing.T) {
+	if s := AllowConfigWarnings.String(); s != "AllowConfigWarnings" {
+		t.Errorf("got %s", s)
+	}
+	if s := Flag(1000).String(); s != "Flag(1000)" {
+		t.Errorf("got %s", s)
+	}
+}
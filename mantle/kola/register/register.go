@@ -29,7 +29,12 @@ const (
 	NoSSHKeyInMetadata                // don't add SSH key to platform metadata
 	NoInstanceCreds                   // don't grant credentials (AWS instance profile, GCP service account) to the instance
 	NoEmergencyShellCheck             // don't check console output for emergency shell invocation
+	NoCoredumpCheck                   // don't check the journal for coredumps
+	NoSELinuxDenialCheck              // don't check the journal for SELinux AVC denials
+	NoOOMKillCheck                    // don't check the journal for OOM kills
+	NoSegfaultCheck                   // don't check the journal for segfaults
 	AllowConfigWarnings               // ignore Ignition and Butane warnings instead of failing
+	ControlledClock                   // serve the time of the machines from an NTP server controlled by the test, see TestCluster.Clock() (QEMU only)
 )
 
 // NativeFuncWrap is a wrapper for the NativeFunc which includes an optional string of arches and/or distributions to
@@ -112,6 +117,16 @@ type Test struct {
 	// Conflicts is non-empty iff nonexclusive is true
 	// Contains the tests that conflict with this particular test
 	Conflicts []string
+
+	// DependsOn lists tests which must have passed before this test
+	// starts, e.g. because they produce an artifact it consumes. Their
+	// output directories are available via TestCluster.DependencyDir().
+	// If one of them fails or is not run, this test is skipped.
+	DependsOn []string
+
+	// Fixtures lists registered fixtures the test requires. Their values
+	// are available via TestCluster.Fixture().
+	Fixtures []string
 }
 
 // Registered tests that run as part of `kola run` live here. Mapping of names
@@ -133,6 +148,11 @@ func Register(m map[string]*Test, t *Test) {
 	if ok {
 		panic(fmt.Sprintf("test %v already registered", t.Name))
 	}
+	for _, dep := range t.DependsOn {
+		if dep == t.Name {
+			panic(fmt.Sprintf("test %v depends on itself", t.Name))
+		}
+	}
 	m[t.Name] = t
 }
 
@@ -0,0 +1,309 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+	"github.com/coreos/coreos-assembler/mantle/kola/history"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	coreosarch "github.com/coreos/stream-metadata-go/arch"
+)
+
+var (
+	// MemoryBudget is the total memory in MB the machines of concurrently
+	// running tests may use; 0 means unlimited.
+	MemoryBudget int
+	// CPUBudget is the total number of vCPUs the machines of concurrently
+	// running tests may use; 0 means unlimited.
+	CPUBudget int
+	// TimeBudget is the wall-clock time after which no new test is
+	// started; 0 means unlimited.
+	TimeBudget time.Duration
+
+	// testScheduler admits the tests of the current run, if budgets are set
+	testScheduler      *scheduler
+	timeBudgetDeadline time.Time
+)
+
+// scheduledTest is a test as seen by the scheduler, i.e. a test run by the
+// harness, which can be a non-exclusive wrapper.
+type scheduledTest struct {
+	name      string
+	dependsOn []string
+	subtests  []string // of a non-exclusive wrapper
+	memory    int
+	cpus      int
+	duration  time.Duration // expected
+}
+
+// scheduler decides when the tests of a run may start, based on the
+// memory and CPUs their machines need, the budgets given by the user and
+// their expected durations: among the tests which are ready to run, the
+// longest ones start first, and shorter ones fill the remaining room.
+type scheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	memoryBudget int
+	cpuBudget    int
+	maxParallel  int // 0 means unlimited
+	deadline     time.Time
+
+	pending    []*scheduledTest // not yet started, longest first
+	started    map[string]*scheduledTest
+	running    int
+	usedMemory int
+	usedCPUs   int
+	notRun     []string
+}
+
+// newScheduler creates a scheduler for the tests about to be run by the
+// harness. Expected durations come from the test history store.
+func newScheduler(tests map[string]*register.Test, subtests map[string][]*register.Test, pltfrm string) *scheduler {
+	s := &scheduler{
+		memoryBudget: MemoryBudget,
+		cpuBudget:    CPUBudget,
+		maxParallel:  TestParallelism,
+		started:      make(map[string]*scheduledTest),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	if TimeBudget > 0 {
+		// reruns share the budget of the main run
+		if !inRerun || timeBudgetDeadline.IsZero() {
+			timeBudgetDeadline = time.Now().Add(TimeBudget)
+		}
+		s.deadline = timeBudgetDeadline
+		// wake up waiting tests so they notice the budget is exhausted
+		time.AfterFunc(time.Until(s.deadline), s.cond.Broadcast)
+	}
+
+	durations, err := historicalDurations(pltfrm)
+	if err != nil {
+		plog.Warningf("Failed to read test durations from %s: %v", HistoryFile, err)
+	}
+	// Tests without history are assumed to take an average time
+	var fallback time.Duration
+	if len(durations) > 0 {
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		fallback = total / time.Duration(len(durations))
+	}
+	expected := func(t *register.Test) time.Duration {
+		if d, ok := durations[t.Name]; ok {
+			return d
+		}
+		return fallback
+	}
+
+	for name, t := range tests {
+		st := &scheduledTest{
+			name:      name,
+			dependsOn: t.DependsOn,
+			memory:    estimateMemory(t),
+			cpus:      estimateCPUs(t),
+		}
+		if bucket, ok := subtests[name]; ok {
+			for _, sub := range bucket {
+				st.duration += expected(sub)
+				st.subtests = append(st.subtests, sub.Name)
+			}
+		} else {
+			st.duration = expected(t)
+		}
+		s.pending = append(s.pending, st)
+	}
+	sort.Slice(s.pending, func(i, j int) bool {
+		if s.pending[i].duration != s.pending[j].duration {
+			return s.pending[i].duration > s.pending[j].duration
+		}
+		return s.pending[i].name < s.pending[j].name
+	})
+	return s
+}
+
+// acquire blocks until the test may start and reserves its resources.
+// It returns an error if the test must not run because the time budget
+// is exhausted.
+func (s *scheduler) acquire(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a test becoming ready may change which test is next
+	s.cond.Broadcast()
+	for {
+		idx := s.find(name)
+		if idx < 0 {
+			return fmt.Errorf("test %s is not scheduled", name)
+		}
+		if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+			if st := s.pending[idx]; len(st.subtests) > 0 {
+				s.notRun = append(s.notRun, st.subtests...)
+			} else {
+				s.notRun = append(s.notRun, name)
+			}
+			s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+			s.cond.Broadcast()
+			return fmt.Errorf("time budget of %v exhausted", TimeBudget)
+		}
+		if next := s.next(); next != nil && next.name == name {
+			s.started[name] = next
+			s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+			s.running++
+			s.usedMemory += next.memory
+			s.usedCPUs += next.cpus
+			plog.Debugf("Starting %s (expected %v, %d MB, %d CPUs); %d MB and %d CPUs in use",
+				name, next.duration, next.memory, next.cpus, s.usedMemory, s.usedCPUs)
+			// other tests may also fit
+			s.cond.Broadcast()
+			return nil
+		}
+		s.cond.Wait()
+	}
+}
+
+// release returns the resources of a started test, or withdraws a test
+// which didn't start, e.g. because it was skipped.
+func (s *scheduler) release(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx := s.find(name); idx >= 0 {
+		s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+	} else if st, ok := s.started[name]; ok {
+		delete(s.started, name)
+		s.running--
+		s.usedMemory -= st.memory
+		s.usedCPUs -= st.cpus
+	}
+	s.cond.Broadcast()
+}
+
+func (s *scheduler) find(name string) int {
+	for i, st := range s.pending {
+		if st.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// next returns the longest pending test whose dependencies finished and
+// which fits in the remaining budgets. A test exceeding a budget on its
+// own may only run when nothing else is running. Must be called with
+// s.mu held.
+func (s *scheduler) next() *scheduledTest {
+	if s.maxParallel > 0 && s.running >= s.maxParallel {
+		return nil
+	}
+	for _, st := range s.pending {
+		if !testDependencies.finished(st.dependsOn) {
+			continue
+		}
+		if s.running == 0 {
+			return st
+		}
+		if s.memoryBudget > 0 && s.usedMemory+st.memory > s.memoryBudget {
+			continue
+		}
+		if s.cpuBudget > 0 && s.usedCPUs+st.cpus > s.cpuBudget {
+			continue
+		}
+		return st
+	}
+	return nil
+}
+
+// reportNotRun prints and writes to not-run.txt in outputDir the tests
+// which weren't started because the time budget was exhausted.
+func (s *scheduler) reportNotRun(outputDir string) error {
+	s.mu.Lock()
+	notRun := append([]string(nil), s.notRun...)
+	s.mu.Unlock()
+	if len(notRun) == 0 {
+		return nil
+	}
+	sort.Strings(notRun)
+	fmt.Printf("%d tests were not run because the time budget of %v was exhausted:\n", len(notRun), TimeBudget)
+	for _, name := range notRun {
+		fmt.Printf("  %s\n", name)
+	}
+	return os.WriteFile(filepath.Join(outputDir, "not-run.txt"), []byte(strings.Join(notRun, "\n")+"\n"), 0644)
+}
+
+// estimateMemory returns the memory in MB used by the machines of a test.
+func estimateMemory(t *register.Test) int {
+	memory := t.MinMemory
+	if QEMUOptions.Memory != "" {
+		if m, err := strconv.Atoi(QEMUOptions.Memory); err == nil {
+			memory = m
+		}
+	}
+	if memory == 0 {
+		// See QemuBuilder.finalize()
+		memory = 1024
+		switch coreosarch.CurrentRpmArch() {
+		case "aarch64", "s390x", "ppc64le":
+			memory = 2048
+		}
+	}
+	return memory * clusterSize(t)
+}
+
+// estimateCPUs returns the number of vCPUs used by the machines of a test.
+func estimateCPUs(t *register.Test) int {
+	return clusterSize(t)
+}
+
+func clusterSize(t *register.Test) int {
+	if t.ClusterSize < 1 {
+		// tests without machines still need some room to run
+		return 1
+	}
+	return t.ClusterSize
+}
+
+// historicalDurations returns the mean duration of the tests which were
+// run on this platform and architecture according to HistoryFile.
+func historicalDurations(pltfrm string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	if HistoryFile == "" {
+		return durations, nil
+	}
+	records, err := history.NewStore(HistoryFile).Load()
+	if err != nil {
+		return durations, err
+	}
+	var relevant []history.Record
+	for _, r := range records {
+		if r.Platform == pltfrm && r.Arch == Options.CosaBuildArch && r.Result != testresult.Skip {
+			// streams are merged
+			r.Stream = ""
+			relevant = append(relevant, r)
+		}
+	}
+	for _, st := range history.Analyze(relevant) {
+		durations[st.Test] = st.MeanDuration
+	}
+	return durations, nil
+}
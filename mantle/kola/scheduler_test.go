@@ -0,0 +1,100 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerPacking(t *testing.T) {
+	s := &scheduler{
+		memoryBudget: 4096,
+		started:      make(map[string]*scheduledTest),
+		pending: []*scheduledTest{
+			{name: "long", memory: 2048, duration: 30 * time.Minute},
+			{name: "big", memory: 4096, duration: 20 * time.Minute},
+			{name: "medium", memory: 1024, duration: 10 * time.Minute},
+			{name: "short", memory: 1024, duration: time.Minute},
+		},
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	order := func() []string {
+		var names []string
+		for _, st := range s.pending {
+			names = append(names, st.name)
+		}
+		return names
+	}
+	if err := s.acquire("long"); err != nil {
+		t.Fatal(err)
+	}
+	// "big" doesn't fit next to "long", the others do
+	if err := s.acquire("medium"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.acquire("short"); err != nil {
+		t.Fatal(err)
+	}
+	if s.usedMemory != 4096 || s.running != 3 {
+		t.Errorf("unexpected usage: %d MB, %d running", s.usedMemory, s.running)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		if err := s.acquire("big"); err != nil {
+			t.Error(err)
+		}
+		close(started)
+	}()
+	s.release("long")
+	s.release("medium")
+	select {
+	case <-started:
+		t.Fatalf("big started before enough memory was available; pending %v", order())
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.release("short")
+	<-started
+	s.release("big")
+	if s.usedMemory != 0 || s.running != 0 || len(s.pending) != 0 {
+		t.Errorf("unexpected final state: %d MB, %d running, pending %v", s.usedMemory, s.running, order())
+	}
+}
+
+func TestSchedulerTimeBudget(t *testing.T) {
+	TimeBudget = time.Minute
+	defer func() { TimeBudget = 0 }()
+	s := &scheduler{
+		deadline: time.Now().Add(-time.Second),
+		started:  make(map[string]*scheduledTest),
+		pending: []*scheduledTest{
+			{name: "wrapper", subtests: []string{"a", "b"}},
+			{name: "test"},
+		},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	if err := s.acquire("wrapper"); err == nil {
+		t.Error("expected wrapper not to run")
+	}
+	if err := s.acquire("test"); err == nil {
+		t.Error("expected test not to run")
+	}
+	if len(s.notRun) != 3 {
+		t.Errorf("unexpected tests not run: %v", s.notRun)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+)
+
+// StandaloneTest is a test which brings up its own machines instead of
+// running against a cluster created by kola, like the testiso scenarios.
+// Unlike for registered tests, kola doesn't time the machines out: the test
+// has to, e.g. testiso waits for the completion of a scenario for at most
+// --install-timeout.
+type StandaloneTest struct {
+	Name string
+	Tags []string // used to match --allow-rerun-success
+	Run  func(h *harness.H)
+}
+
+// RunStandaloneTests runs tests concurrently through the harness. Like
+// runProvidedTests it skips the tests denylisted for pltfrm, writes the
+// JSON, JUnit and TAP reports, re-runs the failed tests into outputDir/rerun
+// if rerun is set and returns ErrWarnOnTestFail when only warn:true tests
+// failed.
+func RunStandaloneTests(tests []StandaloneTest, rerun bool, rerunSuccessTags []string, pltfrm, outputDir string) error {
+	// Add denylisted tests in kola-denylist.yaml to DenylistedTests
+	if err := ParseDenyListYaml(pltfrm); err != nil {
+		plog.Fatal(err)
+	}
+
+	var selected []StandaloneTest
+	for _, t := range tests {
+		denylisted, err := MatchesPatterns(t.Name, DenylistedTests)
+		if err != nil {
+			return err
+		}
+		if denylisted {
+			plog.Debugf("Skipping denylisted test %s", t.Name)
+			continue
+		}
+		selected = append(selected, t)
+	}
+	if len(selected) == 0 {
+		fmt.Printf("There are no tests to run because all tests are denylisted. Output in %v\n", outputDir)
+		return nil
+	}
+
+	opts := harness.Options{
+		OutputDir: outputDir,
+		Parallel:  TestParallelism,
+		Verbose:   true,
+		Reporters: reporters.Reporters{
+			reporters.NewJSONReporter("report.json", pltfrm, testedBuildID()),
+		},
+	}
+	if JUnitFile != "" {
+		opts.Reporters = append(opts.Reporters, reporters.NewJUnitReporter("junit.xml", pltfrm, testedBuildID()))
+	}
+
+	var results protectedTestResults
+	var htests harness.Tests
+	for _, t := range selected {
+		t := t // for the closure
+		run := func(h *harness.H) {
+			defer results.add(h)
+			h.Parallel()
+			if IsWarningOnFailure(t.Name) {
+				h.WarningOnFailure()
+			}
+			t.Run(h)
+		}
+		htests.Add(t.Name, run, harness.DefaultTimeoutFlag)
+	}
+
+	runErr := harness.NewSuite(opts, htests).Run()
+	runErr = handleSuiteErrors(outputDir, runErr)
+	if err := recordHistory(outputDir, pltfrm, inRerun); err != nil {
+		plog.Warningf("Failed to record test history in %s: %v", HistoryFile, err)
+	}
+
+	detectedFailedWarnTrueTests := len(getWarnTrueFailedTests(results.getResults())) != 0
+
+	failed := make(map[string]bool)
+	for _, h := range results.getResults() {
+		if h.Failed() {
+			failed[h.Name()] = true
+		}
+	}
+	var testsToRerun []StandaloneTest
+	var testTags [][]string
+	for _, t := range selected {
+		if failed[t.Name] {
+			testsToRerun = append(testsToRerun, t)
+			testTags = append(testTags, t.Tags)
+		}
+	}
+	sort.Slice(testsToRerun, func(i, j int) bool { return testsToRerun[i].Name < testsToRerun[j].Name })
+	numFailedTests := len(testsToRerun)
+	if len(testsToRerun) > 0 && rerun {
+		newOutputDir := filepath.Join(outputDir, "rerun")
+		fmt.Printf("\n\n======== Re-running failed tests (flake detection) ========\n\n")
+		inRerun = true
+		reRunErr := RunStandaloneTests(testsToRerun, false, rerunSuccessTags, pltfrm, newOutputDir)
+		inRerun = false
+		if reRunErr == nil && rerunSuccessAllowed(testTags, rerunSuccessTags) {
+			runErr = nil       // reset to success since all tests allowed rerun success
+			numFailedTests = 0 // zero out the tally of failed tests
+		} else {
+			runErr = reRunErr
+		}
+	}
+
+	// Return ErrWarnOnTestFail when ONLY tests with warn:true feature failed
+	if detectedFailedWarnTrueTests && numFailedTests == 0 {
+		return ErrWarnOnTestFail
+	}
+	return runErr
+}
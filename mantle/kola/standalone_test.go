@@ -0,0 +1,84 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coreos/coreos-assembler/mantle/harness"
+	"github.com/coreos/coreos-assembler/mantle/harness/reporters"
+	"github.com/coreos/coreos-assembler/mantle/harness/testresult"
+)
+
+func TestRunStandaloneTests(t *testing.T) {
+	Options.CosaWorkdir = t.TempDir()
+	Options.CosaBuildId = "41.20260101.0"
+	DenylistedTests = []string{"denied.*"}
+	defer func() {
+		Options.CosaWorkdir = ""
+		Options.CosaBuildId = ""
+		DenylistedTests = nil
+	}()
+
+	var flakyRuns int32
+	tests := []StandaloneTest{
+		{Name: "pass", Run: func(h *harness.H) {}},
+		{Name: "flaky", Tags: []string{"flaky"}, Run: func(h *harness.H) {
+			if atomic.AddInt32(&flakyRuns, 1) == 1 {
+				h.Fatal("first run fails")
+			}
+		}},
+		{Name: "denied.test", Run: func(h *harness.H) {
+			h.Fatal("denylisted test ran")
+		}},
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	if err := RunStandaloneTests(tests, true, []string{"flaky"}, "qemu", outputDir); err != nil {
+		t.Fatalf("expected success after rerun, got %v", err)
+	}
+
+	report, err := reporters.DeserialiseReport(filepath.Join(outputDir, "reports/report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]testresult.TestResult)
+	for _, test := range report.Tests {
+		results[test.Name] = test.Result
+	}
+	if len(results) != 2 || results["pass"] != testresult.Pass || results["flaky"] != testresult.Fail {
+		t.Errorf("unexpected results %v", results)
+	}
+	if report.Version != "41.20260101.0" {
+		t.Errorf("expected the build ID as version, got %q", report.Version)
+	}
+
+	rerunReport, err := reporters.DeserialiseReport(filepath.Join(outputDir, "rerun/reports/report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rerunReport.Tests) != 1 || rerunReport.Tests[0].Name != "flaky" || rerunReport.Tests[0].Result != testresult.Pass {
+		t.Errorf("unexpected rerun report %+v", rerunReport.Tests)
+	}
+
+	broken := []StandaloneTest{{Name: "broken", Tags: []string{"flaky"}, Run: func(h *harness.H) {
+		h.Fatal("always fails")
+	}}}
+	if err := RunStandaloneTests(broken, true, []string{"flaky"}, "qemu", outputDir); err == nil {
+		t.Error("expected failure when the rerun fails too")
+	}
+}
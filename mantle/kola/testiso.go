@@ -0,0 +1,187 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// builtinIsoScenarios are the scenarios run by `kola testiso`.
+//
+//go:embed testiso.yaml
+var builtinIsoScenarios []byte
+
+// IsoScenario is a `kola testiso` scenario, defined in testiso.yaml or in
+// src/config/kola-testiso.yaml.
+type IsoScenario struct {
+	Name string `yaml:"name"`
+	// Medium is how the system under test is booted: "iso" or "miniso" to
+	// install from the full or minimal live ISO, "pxe" to install via PXE,
+	// "iso-as-disk" to boot the live ISO as a disk, "live" to boot the live
+	// ISO with the scenario's config and "live-login" to boot it without
+	// any config until the login prompt.
+	Medium string `yaml:"medium"`
+	// Firmware is "bios", "uefi" or "uefi-secure"; the default of the
+	// architecture if empty.
+	Firmware string `yaml:"firmware,omitempty"`
+	// SectorSize of the disk installed to, 512 or 4096.
+	SectorSize int  `yaml:"sectorSize,omitempty"`
+	Multipath  bool `yaml:"multipath,omitempty"`
+	// Offline installs use the image embedded in the live media instead
+	// of fetching it.
+	Offline bool       `yaml:"offline,omitempty"`
+	Network IsoNetwork `yaml:"network,omitempty"`
+	// LiveKargs are appended to the kernel command line of the live system.
+	LiveKargs []string `yaml:"liveKargs,omitempty"`
+	// InstallKargs are appended to the kernel command line of the
+	// installed system, via coreos-installer.
+	InstallKargs []string `yaml:"installKargs,omitempty"`
+	// Additional systemd units and files for the live and installed
+	// systems, by unit name or path.
+	LiveUnits   map[string]string `yaml:"liveUnits,omitempty"`
+	LiveFiles   map[string]string `yaml:"liveFiles,omitempty"`
+	TargetUnits map[string]string `yaml:"targetUnits,omitempty"`
+	TargetFiles map[string]string `yaml:"targetFiles,omitempty"`
+	// Signals are the lines expected on the testisocompletion virtio
+	// channel, in order. The medium's defaults are used if empty.
+	Signals []string `yaml:"signals,omitempty"`
+	// Arches and Distros restrict where the scenario runs; everywhere if
+	// empty.
+	Arches  []string `yaml:"arches,omitempty"`
+	Distros []string `yaml:"distros,omitempty"`
+	// Tags are matched by --allow-rerun-success, like the tags of tests.
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+// IsoNetwork is the network configuration of a testiso scenario.
+type IsoNetwork struct {
+	// Disabled boots the live system without a network device.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Keyfiles are NetworkManager keyfiles embedded in the live ISO with
+	// `coreos-installer iso network embed`, by file name.
+	Keyfiles map[string]string `yaml:"keyfiles,omitempty"`
+}
+
+// AppliesTo returns true if the scenario runs on arch for distro.
+func (s *IsoScenario) AppliesTo(arch, distro string) bool {
+	if len(s.Arches) > 0 && !HasString(arch, s.Arches) {
+		return false
+	}
+	if len(s.Distros) > 0 && !HasString(distro, s.Distros) {
+		return false
+	}
+	return true
+}
+
+func (s *IsoScenario) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario without name")
+	}
+	install := false
+	switch s.Medium {
+	case "iso", "miniso", "pxe":
+		install = true
+	case "iso-as-disk", "live", "live-login":
+	default:
+		return fmt.Errorf("scenario %s: unknown medium %q", s.Name, s.Medium)
+	}
+	switch s.Firmware {
+	case "", "bios", "uefi", "uefi-secure":
+	default:
+		return fmt.Errorf("scenario %s: unknown firmware %q", s.Name, s.Firmware)
+	}
+	switch s.SectorSize {
+	case 0, 512, 4096:
+	default:
+		return fmt.Errorf("scenario %s: unsupported sector size %d", s.Name, s.SectorSize)
+	}
+	if !install && (s.Multipath || s.Offline || len(s.InstallKargs) > 0 || len(s.TargetUnits) > 0 || len(s.TargetFiles) > 0) {
+		return fmt.Errorf("scenario %s: medium %s does not install a system", s.Name, s.Medium)
+	}
+	if s.Medium == "miniso" && s.Offline {
+		return fmt.Errorf("scenario %s: the minimal ISO cannot install offline", s.Name)
+	}
+	if s.Medium != "iso" && s.Medium != "miniso" && len(s.Network.Keyfiles) > 0 {
+		return fmt.Errorf("scenario %s: NM keyfiles are only supported when installing from an ISO", s.Name)
+	}
+	if s.Offline && len(s.Network.Keyfiles) > 0 {
+		return fmt.Errorf("scenario %s: NM keyfiles cannot be used with offline installs", s.Name)
+	}
+	if install && s.Network.Disabled {
+		return fmt.Errorf("scenario %s: the network cannot be disabled when installing", s.Name)
+	}
+	if s.Medium == "live-login" && (len(s.LiveUnits) > 0 || len(s.LiveFiles) > 0 || len(s.Signals) > 0) {
+		return fmt.Errorf("scenario %s: medium live-login does not take a config", s.Name)
+	}
+	return nil
+}
+
+func parseIsoScenarios(buf []byte) ([]IsoScenario, error) {
+	var scenarios []IsoScenario
+	if err := yaml.UnmarshalStrict(buf, &scenarios); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range scenarios {
+		if err := scenarios[i].validate(); err != nil {
+			return nil, err
+		}
+		if seen[scenarios[i].Name] {
+			return nil, fmt.Errorf("duplicate scenario %s", scenarios[i].Name)
+		}
+		seen[scenarios[i].Name] = true
+	}
+	return scenarios, nil
+}
+
+// ParseTestIsoYaml returns the built-in testiso scenarios together with
+// the ones in src/config/kola-testiso.yaml, if any. A scenario in the
+// latter replaces the built-in one of the same name.
+func ParseTestIsoYaml() ([]IsoScenario, error) {
+	scenarios, err := parseIsoScenarios(builtinIsoScenarios)
+	if err != nil {
+		return nil, fmt.Errorf("parsing built-in testiso scenarios: %w", err)
+	}
+
+	path := filepath.Join(Options.CosaWorkdir, "src/config/kola-testiso.yaml")
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return scenarios, nil
+	} else if err != nil {
+		return nil, err
+	}
+	extra, err := parseIsoScenarios(buf)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	index := make(map[string]int)
+	for i, s := range scenarios {
+		index[s.Name] = i
+	}
+	for _, s := range extra {
+		if i, ok := index[s.Name]; ok {
+			scenarios[i] = s
+		} else {
+			scenarios = append(scenarios, s)
+		}
+	}
+	plog.Debugf("Loaded %d testiso scenarios from %s", len(extra), path)
+	return scenarios, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTestIsoYaml(t *testing.T) {
+	Options.CosaWorkdir = t.TempDir()
+	defer func() { Options.CosaWorkdir = "" }()
+
+	scenarios, err := ParseTestIsoYaml()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(arch, distro string) int {
+		n := 0
+		for _, s := range scenarios {
+			if s.AppliesTo(arch, distro) {
+				n++
+			}
+		}
+		return n
+	}
+	for _, c := range []struct {
+		arch, distro string
+		expected     int
+	}{
+		{"x86_64", "fedora-coreos", 20},
+		{"x86_64", "rhcos", 21},
+		{"aarch64", "fedora-coreos", 13},
+		{"aarch64", "rhcos", 14},
+		{"s390x", "rhcos", 7},
+		{"ppc64le", "rhcos", 10},
+	} {
+		if n := count(c.arch, c.distro); n != c.expected {
+			t.Errorf("expected %d scenarios for %s %s, got %d", c.expected, c.distro, c.arch, n)
+		}
+	}
+
+	config := `
+- name: iso-install.bios
+  medium: iso
+  installKargs: [console=ttyS0]
+  tags: [flaky]
+- name: pxe-static-ip.bios
+  medium: pxe
+  liveKargs: ["ip=10.0.2.15::10.0.2.2:255.255.255.0::ens2:none"]
+  arches: [x86_64]
+`
+	if err := os.MkdirAll(filepath.Join(Options.CosaWorkdir, "src/config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(Options.CosaWorkdir, "src/config/kola-testiso.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	extended, err := ParseTestIsoYaml()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extended) != len(scenarios)+1 {
+		t.Errorf("expected %d scenarios, got %d", len(scenarios)+1, len(extended))
+	}
+	for _, s := range extended {
+		if s.Name == "iso-install.bios" && (len(s.InstallKargs) != 1 || len(s.Arches) != 0 || len(s.Tags) != 1) {
+			t.Errorf("expected iso-install.bios to be replaced, got %+v", s)
+		}
+	}
+
+	for _, invalid := range []string{
+		"- name: x\n  medium: floppy\n",
+		"- name: x\n  medium: miniso\n  offline: true\n",
+		"- name: x\n  medium: live\n  multipath: true\n",
+		"- name: x\n  medium: pxe\n  network: {keyfiles: {a.nmconnection: ''}}\n",
+		"- name: x\n  medium: iso\n- name: x\n  medium: pxe\n",
+		"- name: x\n  medium: iso\n  unknown: true\n",
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseTestIsoYaml(); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
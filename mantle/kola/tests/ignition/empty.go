@@ -29,7 +29,7 @@ func init() {
 		Description:      "Verify that not inject SSH key into Ignition works on FCOS.",
 		Run:              noIgnitionSSHKey,
 		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu", "esx"},
+		ExcludePlatforms: []string{"qemu", "esx", "libvirt"},
 		Distros:          []string{"fcos"},
 		UserData:         conf.Empty(),
 		Tags:             []string{"ignition"},
@@ -39,7 +39,7 @@ func init() {
 		Description:      "Verify that not inject SSH key into Ignition with v3.0.0 works on FCOS.",
 		Run:              noIgnitionSSHKey,
 		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu", "esx"},
+		ExcludePlatforms: []string{"qemu", "esx", "libvirt"},
 		Distros:          []string{"fcos"},
 		Flags:            []register.Flag{register.NoSSHKeyInUserData},
 		UserData:         conf.Ignition(`{"ignition":{"version":"3.0.0"}}`),
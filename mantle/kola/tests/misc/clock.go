@@ -0,0 +1,94 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+func init() {
+	register.RegisterTest(&register.Test{
+		Run:         ClockTimeTravel,
+		ClusterSize: 1,
+		Name:        "coreos.clock.time-travel",
+		Description: "Verify the machines follow the time served by the NTP server of the cluster, including leap seconds.",
+		Platforms:   []string{"qemu"},
+		Flags:       []register.Flag{register.ControlledClock},
+		// the machines reach the NTP server over UDP, which restricted
+		// networking doesn't forward
+		Tags: []string{kola.NeedsInternetTag},
+	})
+}
+
+func ClockTimeTravel(c cluster.TestCluster) {
+	m := c.Machines()[0]
+	clock := c.Clock()
+
+	c.Run("sync", func(c cluster.TestCluster) {
+		if err := clock.Sync(m, 2*time.Second); err != nil {
+			c.Fatal(err)
+		}
+	})
+
+	c.Run("timer", func(c cluster.TestCluster) {
+		// a realtime timer an hour from now fires right after jumping
+		// two hours
+		at := clock.Now().Add(time.Hour).UTC().Format("2006-01-02 15:04:05 UTC")
+		c.RunCmdSyncf(m, "sudo systemd-run --quiet --unit kola-clock --on-calendar='%s' touch /var/tmp/kola-clock", at)
+		clock.Jump(2 * time.Hour)
+		if err := clock.Sync(m, 2*time.Second); err != nil {
+			c.Fatal(err)
+		}
+		err := util.Retry(10, 2*time.Second, func() error {
+			_, _, err := m.SSH("test -e /var/tmp/kola-clock")
+			return err
+		})
+		if err != nil {
+			c.Fatalf("timer didn't fire after jumping past %s", at)
+		}
+	})
+
+	c.Run("leap-second", func(c cluster.TestCluster) {
+		leap := time.Date(clock.Now().Year()+1, time.July, 1, 0, 0, 0, 0, time.UTC)
+		clock.Set(leap.Add(-10 * time.Minute))
+		if err := clock.InsertLeapSecond(leap); err != nil {
+			c.Fatal(err)
+		}
+		defer clock.Reset()
+		if err := clock.Sync(m, 2*time.Second); err != nil {
+			c.Fatal(err)
+		}
+		err := util.Retry(15, 2*time.Second, func() error {
+			out, _, err := m.SSH("chronyc tracking")
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(out), "Insert second") {
+				return fmt.Errorf("leap second not announced: %s", out)
+			}
+			return nil
+		})
+		if err != nil {
+			c.Fatal(err)
+		}
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
 	"github.com/coreos/coreos-assembler/mantle/kola/register"
 	"github.com/coreos/coreos-assembler/mantle/kola/tests/util"
+	"github.com/coreos/coreos-assembler/mantle/platform"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 )
 
@@ -86,102 +87,117 @@ func rpmOstreeUpgradeRollback(c cluster.TestCluster) {
 		c.Fatalf(`Unexpected results from "rpm-ostree status"; received: %v`, originalStatus)
 	}
 
-	c.Run("upgrade", func(c cluster.TestCluster) {
-		// create a local branch to act as our upgrade target
-		originalCsum := originalStatus.Deployments[0].Checksum
-		createBranch := "sudo ostree refs --create " + newBranch + " " + originalCsum
-		c.RunCmdSync(m, createBranch)
-
-		// make a commit to the new branch
-		createCommit := "sudo ostree commit -b " + newBranch + " --tree ref=" + originalCsum + " --add-metadata-string version=" + newVersion
-		newCommit := c.MustSSH(m, createCommit)
-
-		// And no zincati because we're intentionally overriding, also it fails
-		c.RunCmdSync(m, "sudo systemctl mask --now zincati")
-
-		// use "rpm-ostree rebase" to get to the "new" commit
-		c.RunCmdSync(m, "sudo rpm-ostree rebase :"+newBranch)
-
-		// get latest rpm-ostree status output to check validity
-		postUpgradeStatus, err := util.GetRpmOstreeStatus(c, m)
-		if err != nil {
-			c.Fatal(err)
-		}
-
-		// should have an additional deployment
-		if len(postUpgradeStatus.Deployments) != len(originalStatus.Deployments)+1 {
-			c.Fatalf("Expected %d deployments; found %d deployments", len(originalStatus.Deployments)+1, len(postUpgradeStatus.Deployments))
-		}
-
-		// reboot into new deployment
-		rebootErr := m.Reboot()
-		if rebootErr != nil {
-			c.Fatalf("Failed to reboot machine: %v", rebootErr)
-		}
-
-		// get latest rpm-ostree status output
-		postRebootStatus, err := util.GetRpmOstreeStatus(c, m)
-		if err != nil {
-			c.Fatal(err)
-		}
-
-		// should have 2 deployments, the previously booted deployment and the test deployment due to rpm-ostree pruning
-		if len(postRebootStatus.Deployments) != 2 {
-			c.Fatalf("Expected %d deployments; found %d deployment", 2, len(postRebootStatus.Deployments))
-		}
+	type upgradeRollbackState struct {
+		NewCommit string
+	}
+	// The subtests used to be "upgrade" and "rollback", which checked the
+	// machine after their reboot; the checks are now steps of their own.
+	cluster.RunScenario(c, m, upgradeRollbackState{},
+		cluster.Step[upgradeRollbackState]{
+			Name: "upgrade",
+			Run: func(c cluster.TestCluster, m platform.Machine, state *upgradeRollbackState) cluster.StepAction {
+				// create a local branch to act as our upgrade target
+				originalCsum := originalStatus.Deployments[0].Checksum
+				createBranch := "sudo ostree refs --create " + newBranch + " " + originalCsum
+				c.RunCmdSync(m, createBranch)
+
+				// make a commit to the new branch
+				createCommit := "sudo ostree commit -b " + newBranch + " --tree ref=" + originalCsum + " --add-metadata-string version=" + newVersion
+				state.NewCommit = string(c.MustSSH(m, createCommit))
+
+				// And no zincati because we're intentionally overriding, also it fails
+				c.RunCmdSync(m, "sudo systemctl mask --now zincati")
+
+				// use "rpm-ostree rebase" to get to the "new" commit
+				c.RunCmdSync(m, "sudo rpm-ostree rebase :"+newBranch)
+
+				// get latest rpm-ostree status output to check validity
+				postUpgradeStatus, err := util.GetRpmOstreeStatus(c, m)
+				if err != nil {
+					c.Fatal(err)
+				}
 
-		// origin should be new branch
-		if postRebootStatus.Deployments[0].Origin != newBranch {
-			c.Fatalf(`New deployment origin is incorrect; expected %q, got %q`, newBranch, postRebootStatus.Deployments[0].Origin)
-		}
+				// should have an additional deployment
+				if len(postUpgradeStatus.Deployments) != len(originalStatus.Deployments)+1 {
+					c.Fatalf("Expected %d deployments; found %d deployments", len(originalStatus.Deployments)+1, len(postUpgradeStatus.Deployments))
+				}
 
-		// new deployment should be booted
-		if !postRebootStatus.Deployments[0].Booted {
-			c.Fatalf("New deployment is not reporting as booted")
-		}
+				// reboot into new deployment
+				return cluster.StepReboot
+			},
+		},
+		cluster.Step[upgradeRollbackState]{
+			Name: "check-upgrade",
+			Run: func(c cluster.TestCluster, m platform.Machine, state *upgradeRollbackState) cluster.StepAction {
+				// get latest rpm-ostree status output
+				postRebootStatus, err := util.GetRpmOstreeStatus(c, m)
+				if err != nil {
+					c.Fatal(err)
+				}
 
-		// checksum should be new commit
-		if postRebootStatus.Deployments[0].Checksum != string(newCommit) {
-			c.Fatalf(`New deployment checksum is incorrect; expected %q, got %q`, newCommit, postRebootStatus.Deployments[0].Checksum)
-		}
+				// should have 2 deployments, the previously booted deployment and the test deployment due to rpm-ostree pruning
+				if len(postRebootStatus.Deployments) != 2 {
+					c.Fatalf("Expected %d deployments; found %d deployment", 2, len(postRebootStatus.Deployments))
+				}
 
-		// version should be new version string
-		if postRebootStatus.Deployments[0].Version != newVersion {
-			c.Fatalf(`New deployment version is incorrect; expected %q, got %q`, newVersion, postRebootStatus.Deployments[0].Checksum)
-		}
-	})
+				// origin should be new branch
+				if postRebootStatus.Deployments[0].Origin != newBranch {
+					c.Fatalf(`New deployment origin is incorrect; expected %q, got %q`, newBranch, postRebootStatus.Deployments[0].Origin)
+				}
 
-	c.Run("rollback", func(c cluster.TestCluster) {
-		// rollback to original deployment
-		c.RunCmdSync(m, "sudo rpm-ostree rollback")
+				// new deployment should be booted
+				if !postRebootStatus.Deployments[0].Booted {
+					c.Fatalf("New deployment is not reporting as booted")
+				}
 
-		newRebootErr := m.Reboot()
-		if newRebootErr != nil {
-			c.Fatalf("Failed to reboot machine: %v", err)
-		}
+				// checksum should be new commit
+				if postRebootStatus.Deployments[0].Checksum != state.NewCommit {
+					c.Fatalf(`New deployment checksum is incorrect; expected %q, got %q`, state.NewCommit, postRebootStatus.Deployments[0].Checksum)
+				}
 
-		rollbackStatus, err := util.GetRpmOstreeStatus(c, m)
-		if err != nil {
-			c.Fatal(err)
-		}
+				// version should be new version string
+				if postRebootStatus.Deployments[0].Version != newVersion {
+					c.Fatalf(`New deployment version is incorrect; expected %q, got %q`, newVersion, postRebootStatus.Deployments[0].Checksum)
+				}
+				return cluster.StepContinue
+			},
+		},
+		cluster.Step[upgradeRollbackState]{
+			Name: "rollback",
+			Run: func(c cluster.TestCluster, m platform.Machine, state *upgradeRollbackState) cluster.StepAction {
+				// rollback to original deployment
+				c.RunCmdSync(m, "sudo rpm-ostree rollback")
+				return cluster.StepReboot
+			},
+		},
+		cluster.Step[upgradeRollbackState]{
+			Name: "check-rollback",
+			Run: func(c cluster.TestCluster, m platform.Machine, state *upgradeRollbackState) cluster.StepAction {
+				rollbackStatus, err := util.GetRpmOstreeStatus(c, m)
+				if err != nil {
+					c.Fatal(err)
+				}
 
-		// still 2 deployments...
-		if len(rollbackStatus.Deployments) != 2 {
-			c.Fatalf("Expected %d deployments; found %d deployments", 2, len(rollbackStatus.Deployments))
-		}
+				// still 2 deployments...
+				if len(rollbackStatus.Deployments) != 2 {
+					c.Fatalf("Expected %d deployments; found %d deployments", 2, len(rollbackStatus.Deployments))
+				}
 
-		// validate we are back to the original deployment by comparing the
-		// the two RpmOstreeDeployment structs
-		if !reflect.DeepEqual(originalStatus.Deployments[0], rollbackStatus.Deployments[0]) {
-			c.Fatalf(`Differences found in "rpm-ostree status"; original %v, current: %v`, originalStatus.Deployments[0], rollbackStatus.Deployments[0])
-		}
+				// validate we are back to the original deployment by comparing the
+				// the two RpmOstreeDeployment structs
+				if !reflect.DeepEqual(originalStatus.Deployments[0], rollbackStatus.Deployments[0]) {
+					c.Fatalf(`Differences found in "rpm-ostree status"; original %v, current: %v`, originalStatus.Deployments[0], rollbackStatus.Deployments[0])
+				}
 
-		// cleanup our mess
-		cleanupErr := rpmOstreeCleanup(c, m)
-		if cleanupErr != nil {
-			c.Fatal(cleanupErr)
-		}
-	})
+				// cleanup our mess
+				cleanupErr := rpmOstreeCleanup(c, m)
+				if cleanupErr != nil {
+					c.Fatal(cleanupErr)
+				}
+				return cluster.StepContinue
+			},
+		},
+	)
 }
 
 // rpmOstreeInstallUninstall verifies that we can install a package
@@ -15,58 +15,62 @@
 package upgrade
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/coreos/pkg/capnslog"
 	rpmostreeclient "github.com/coreos/rpmostree-client-go/pkg/client"
 
 	"github.com/coreos/coreos-assembler/mantle/kola"
 	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
 	"github.com/coreos/coreos-assembler/mantle/kola/register"
 	"github.com/coreos/coreos-assembler/mantle/kola/tests/util"
+	"github.com/coreos/coreos-assembler/mantle/kola/tests/util/cincinnati"
 	"github.com/coreos/coreos-assembler/mantle/platform"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 )
 
 const workdir = "/var/srv/upgrade"
-const ostreeRepo = workdir + "/repo"
-
-var plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "kola/tests/upgrade")
+const ostreeRepo = cincinnati.RepoDir
 
 func init() {
 	register.RegisterUpgradeTest(&register.Test{
 		Run:         fcosUpgradeBasic,
 		ClusterSize: 1,
-		// if renaming this, also rename the command in kolet-httpd.service below
-		Name:     "fcos.upgrade.basic",
-		FailFast: true,
+		Name:        "fcos.upgrade.basic",
+		FailFast:    true,
 		NativeFuncs: map[string]register.NativeFuncWrap{
-			"httpd": register.CreateNativeFuncWrap(httpd),
+			"httpd": cincinnati.NativeFunc,
 		},
-		Tags:    []string{"upgrade"},
-		Distros: []string{"fcos"},
-		// This Ignition does a few things:
-		// 1. bumps Zincati verbosity
-		// 2. auto-runs httpd once kolet is scp'ed
-		// 3. changes the Zincati config to point to localhost:8080 so we'll be
-		//    able to feed the update graph we want
-		// 4. always start with Zincati updates disabled so we can finish
-		//    setting it up here before enabling it again without risking race
-		//    conditions
-		// 5. change the OSTree remote to localhost:8080
-		// We could use file:/// to simplify things though using a URL at least
-		// exercises the ostree/libcurl stack.
-		// We use strings.Replace here because fmt.Sprintf would try to
-		// interpret the percent signs and there's too many of them to be worth
-		// escaping.
-		UserData: conf.Ignition(strings.Replace(`{
+		Tags:     []string{"upgrade"},
+		Distros:  []string{"fcos"},
+		UserData: zincatiUserData("fcos.upgrade.basic", true),
+	})
+}
+
+// zincatiUserData returns the Ignition config of the upgrade tests. It does
+// a few things:
+//  1. bumps Zincati verbosity
+//  2. auto-runs the httpd native function of the test once kolet is scp'ed
+//  3. changes the Zincati config to point to localhost:8080 so we'll be
+//     able to feed the update graph we want
+//  4. always start with Zincati updates disabled so we can finish
+//     setting it up here before enabling it again without risking race
+//     conditions
+//  5. if localRemote, change the OSTree remote to localhost:8080
+//
+// We could use file:/// to simplify things though using a URL at least
+// exercises the ostree/libcurl stack.
+// We use a strings.Replacer here because fmt.Sprintf would try to
+// interpret the percent signs and there's too many of them to be worth
+// escaping.
+func zincatiUserData(test string, localRemote bool) *conf.UserData {
+	remote := ""
+	if localRemote {
+		remote = localRemoteFile + ","
+	}
+	return conf.Ignition(strings.NewReplacer("WORKDIR", workdir, "TEST", test, "REMOTE", remote).Replace(`{
   "ignition": { "version": "3.0.0" },
   "systemd": {
     "units": [
@@ -84,12 +88,13 @@ func init() {
       },
       {
         "name": "kolet-httpd.service",
-        "contents": "[Service]\nExecStart=/var/home/core/kolet run fcos.upgrade.basic httpd -v\n[Install]\nWantedBy=multi-user.target"
+        "contents": "[Service]\nExecStart=/var/home/core/kolet run TEST httpd -v\n[Install]\nWantedBy=multi-user.target"
       }
     ]
   },
   "storage": {
     "files": [
+      REMOTE
       {
         "path": "/etc/zincati/config.d/99-cincinnati-url.toml",
         "contents": { "source": "data:,cincinnati.base_url%3D%20%22http%3A%2F%2Flocalhost%3A8080%22%0A" },
@@ -104,12 +109,6 @@ func init() {
         "path": "/etc/zincati/config.d/99-agent-timing-speedup.toml",
         "contents": { "source": "data:,agent.timing.steady_interval_secs%20%3D%2020%0A" },
         "mode": 420
-      },
-      {
-        "path": "/etc/ostree/remotes.d/fedora.conf",
-        "contents": { "source": "data:,%5Bremote%20%22fedora%22%5D%0Aurl%3Dhttp%3A%2F%2Flocalhost%3A8080%0Agpg-verify%3Dfalse%0A" },
-        "overwrite": true,
-        "mode": 420
       }
     ],
     "directories": [
@@ -122,17 +121,23 @@ func init() {
       }
     ]
   }
-}`, "WORKDIR", workdir, -1)),
-	})
+}`))
 }
 
+// localRemoteFile points the fedora OSTree remote to the repo served by the
+// Cincinnati server.
+const localRemoteFile = `{
+        "path": "/etc/ostree/remotes.d/fedora.conf",
+        "contents": { "source": "data:,%5Bremote%20%22fedora%22%5D%0Aurl%3Dhttp%3A%2F%2Flocalhost%3A8080%0Agpg-verify%3Dfalse%0A" },
+        "overwrite": true,
+        "mode": 420
+      }`
+
 // upgradeFromPrevious verifies that the previous build is capable of upgrading
 // to the current build and to another build
 func fcosUpgradeBasic(c cluster.TestCluster) {
 	m := c.Machines()[0]
-	graph := new(Graph)
-
-	containerImageFilename := kola.CosaBuild.Meta.BuildArtifacts.Ostree.Path
+	graph := cincinnati.NewServer(m)
 
 	rpmostreeStatus, err := util.GetRpmOstreeStatus(c, m)
 	if err != nil {
@@ -143,71 +148,15 @@ func fcosUpgradeBasic(c cluster.TestCluster) {
 		c.Fatal(err)
 	}
 	usingContainer := booted.ContainerImageReference != ""
-	sourceContainerRef := fmt.Sprintf("ostree-unverified-image:oci-archive:%s:latest", containerImageFilename)
+	var sourceContainerRef string
 
 	c.Run("setup", func(c cluster.TestCluster) {
-		ostreeref := kola.CosaBuild.Meta.BuildRef
-		// this is the only heavy-weight part, though remember this test is
-		// optimized for qemu testing locally where this won't leave localhost at
-		// all. cloud testing should mostly be a pipeline thing, where the infra
-		// connection should be much faster
-		ostreeTarPath := filepath.Join(kola.CosaBuild.Dir, containerImageFilename)
-		if err := cluster.DropFile(c.Machines(), ostreeTarPath); err != nil {
-			c.Fatal(err)
-		}
-
-		// Keep any changes around here in sync with tests/rhcos/upgrade.go too!
-
-		// See https://github.com/coreos/fedora-coreos-tracker/issues/812
-		if usingContainer {
-			// In the container path we'll pass this file directly, so put it outside
-			// of the user's home directory so the systemd service can find it.
-			c.RunCmdSyncf(m, "sudo mv %s /var/tmp/%s", containerImageFilename, containerImageFilename)
-			sourceContainerRef = fmt.Sprintf("ostree-unverified-image:oci-archive:/var/tmp/%s:latest", containerImageFilename)
-		} else {
-			tmprepo := workdir + "/repo-bare"
-			// TODO: https://github.com/ostreedev/ostree-rs-ext/issues/34
-			c.RunCmdSyncf(m, "ostree --repo=%s init --mode=bare-user", tmprepo)
-			c.RunCmdSyncf(m, "ostree container import --repo=%s --write-ref %s %s", tmprepo, ostreeref, sourceContainerRef)
-			c.RunCmdSyncf(m, "ostree --repo=%s init --mode=archive", ostreeRepo)
-			c.RunCmdSyncf(m, "ostree --repo=%s pull-local %s %s", ostreeRepo, tmprepo, ostreeref)
-		}
-
-		// XXX: This is to work around sysroot
-		// remounting in libostree forcing a cache flush and blocking D-Bus.
-		// Should drop this once we fix it more properly in {rpm-,}ostree.
-		// https://github.com/coreos/coreos-assembler/issues/1301
-		c.RunCmdSync(m, "time sudo sync")
-
-		// disable zincati; from now on, we'll start it manually whenenever we
-		// want to upgrade via Zincati
-		c.RunCmdSync(m, "sudo systemctl disable --now --quiet zincati.service")
-		c.RunCmdSync(m, "sudo rm /etc/zincati/config.d/99-updates.toml")
-		// delete what mantle adds (XXX: should just opt out of this upfront)
-		c.RunCmdSync(m, "sudo rm /etc/zincati/config.d/90-disable-auto-updates.toml")
-
+		sourceContainerRef = importBuild(c, m, usingContainer)
+		disableZincati(c, m)
 	})
 
 	c.Run("upgrade-from-previous", func(c cluster.TestCluster) {
-		// We need to check now whether this is a within-stream update or a
-		// cross-stream rebase.
-		d, err := util.GetBootedDeployment(c, m)
-		if err != nil {
-			c.Fatal(err)
-		}
-		version := kola.CosaBuild.Meta.OstreeVersion
-		if usingContainer {
-			rpmostreeRebase(c, m, sourceContainerRef, version)
-		} else if strings.HasSuffix(d.Origin, ":"+kola.CosaBuild.Meta.BuildRef) {
-			// same stream; let's use Zincati
-			graph.seedFromMachine(c, m)
-			graph.addUpdate(c, m, version, kola.CosaBuild.Meta.OstreeCommit)
-			waitForUpgradeToVersion(c, m, version)
-		} else {
-			rpmostreeRebase(c, m, kola.CosaBuild.Meta.BuildRef, version)
-			// and from now on we can use Zincati, so seed the graph with the new node
-			graph.seedFromMachine(c, m)
-		}
+		upgradeToBuild(c, m, graph, usingContainer, sourceContainerRef)
 	})
 
 	// Now, synthesize an update and serve that -- this is similar to
@@ -229,63 +178,106 @@ func fcosUpgradeBasic(c cluster.TestCluster) {
 				ostree_command,
 				ostreeRepo, kola.CosaBuild.Meta.BuildRef, ostreeCommit, newVersion, ostreeCommit)
 
-			graph.addUpdate(c, m, newVersion, string(newCommit))
+			addUpdate(c, graph, newVersion, string(newCommit))
 
 			waitForUpgradeToVersion(c, m, newVersion)
 		}
 	})
 }
 
-// Should dedupe this with fedora-coreos-cincinnati -- we just handle the
-// bare minimum here. One question here is: why not use Cincinnati itself for
-// this? We could do this, though it'd somewhat muddle the focus of these tests
-// and make setup more complex.
-type Graph struct {
-	Nodes []Node   `json:"nodes"`
-	Edges [][2]int `json:"edges,omitempty"`
+// importBuild copies the OSTree content of the build under test to the
+// machine: as an OCI archive if the machine boots a container image,
+// otherwise into the repo served by the Cincinnati server. It returns the
+// container image reference to rebase to.
+func importBuild(c cluster.TestCluster, m platform.Machine, usingContainer bool) string {
+	containerImageFilename := kola.CosaBuild.Meta.BuildArtifacts.Ostree.Path
+	sourceContainerRef := fmt.Sprintf("ostree-unverified-image:oci-archive:%s:latest", containerImageFilename)
+	ostreeref := kola.CosaBuild.Meta.BuildRef
+	// this is the only heavy-weight part, though remember this test is
+	// optimized for qemu testing locally where this won't leave localhost at
+	// all. cloud testing should mostly be a pipeline thing, where the infra
+	// connection should be much faster
+	ostreeTarPath := filepath.Join(kola.CosaBuild.Dir, containerImageFilename)
+	if err := cluster.DropFile([]platform.Machine{m}, ostreeTarPath); err != nil {
+		c.Fatal(err)
+	}
+
+	// Keep any changes around here in sync with tests/rhcos/upgrade.go too!
+
+	// See https://github.com/coreos/fedora-coreos-tracker/issues/812
+	if usingContainer {
+		// In the container path we'll pass this file directly, so put it outside
+		// of the user's home directory so the systemd service can find it.
+		c.RunCmdSyncf(m, "sudo mv %s /var/tmp/%s", containerImageFilename, containerImageFilename)
+		sourceContainerRef = fmt.Sprintf("ostree-unverified-image:oci-archive:/var/tmp/%s:latest", containerImageFilename)
+	} else {
+		tmprepo := workdir + "/repo-bare"
+		// TODO: https://github.com/ostreedev/ostree-rs-ext/issues/34
+		c.RunCmdSyncf(m, "ostree --repo=%s init --mode=bare-user", tmprepo)
+		c.RunCmdSyncf(m, "ostree container import --repo=%s --write-ref %s %s", tmprepo, ostreeref, sourceContainerRef)
+		c.RunCmdSyncf(m, "ostree --repo=%s init --mode=archive", ostreeRepo)
+		c.RunCmdSyncf(m, "ostree --repo=%s pull-local %s %s", ostreeRepo, tmprepo, ostreeref)
+	}
+
+	// XXX: This is to work around sysroot
+	// remounting in libostree forcing a cache flush and blocking D-Bus.
+	// Should drop this once we fix it more properly in {rpm-,}ostree.
+	// https://github.com/coreos/coreos-assembler/issues/1301
+	c.RunCmdSync(m, "time sudo sync")
+	return sourceContainerRef
 }
 
-type Node struct {
-	Version  string            `json:"version"`
-	Metadata map[string]string `json:"metadata"`
-	Payload  string            `json:"payload"`
+// disableZincati disables Zincati; from then on, we start it manually
+// whenever we want to upgrade via Zincati.
+func disableZincati(c cluster.TestCluster, m platform.Machine) {
+	c.RunCmdSync(m, "sudo systemctl disable --now --quiet zincati.service")
+	c.RunCmdSync(m, "sudo rm /etc/zincati/config.d/99-updates.toml")
+	// delete what mantle adds (XXX: should just opt out of this upfront)
+	c.RunCmdSync(m, "sudo rm /etc/zincati/config.d/90-disable-auto-updates.toml")
 }
 
-func (g *Graph) seedFromMachine(c cluster.TestCluster, m platform.Machine) {
+// upgradeToBuild upgrades the machine to the build under test, imported
+// with importBuild.
+func upgradeToBuild(c cluster.TestCluster, m platform.Machine, graph *cincinnati.Server, usingContainer bool, sourceContainerRef string) {
+	// We need to check now whether this is a within-stream update or a
+	// cross-stream rebase.
 	d, err := util.GetBootedDeployment(c, m)
 	if err != nil {
 		c.Fatal(err)
 	}
-
-	g.Nodes = []Node{
-		{
-			Version: d.Version,
-			Payload: d.Checksum,
-			Metadata: map[string]string{
-				"org.fedoraproject.coreos.releases.age_index": "0",
-				"org.fedoraproject.coreos.scheme":             "checksum",
-			},
-		},
+	version := kola.CosaBuild.Meta.OstreeVersion
+	if usingContainer {
+		rpmostreeRebase(c, m, sourceContainerRef, version)
+	} else if strings.HasSuffix(d.Origin, ":"+kola.CosaBuild.Meta.BuildRef) {
+		// same stream; let's use Zincati
+		seedFromMachine(c, m, graph)
+		addUpdate(c, graph, version, kola.CosaBuild.Meta.OstreeCommit)
+		waitForUpgradeToVersion(c, m, version)
+	} else {
+		rpmostreeRebase(c, m, kola.CosaBuild.Meta.BuildRef, version)
+		// and from now on we can use Zincati, so seed the graph with the new node
+		seedFromMachine(c, m, graph)
 	}
-
-	g.sync(c, m)
 }
 
-func (g *Graph) addUpdate(c cluster.TestCluster, m platform.Machine, version, payload string) {
-	i := len(g.Nodes)
-
-	g.Nodes = append(g.Nodes, Node{
-		Version: version,
-		Payload: payload,
-		Metadata: map[string]string{
-			"org.fedoraproject.coreos.releases.age_index": strconv.Itoa(i),
-			"org.fedoraproject.coreos.scheme":             "checksum",
-		},
-	})
+// seedFromMachine resets the graph to the booted deployment of the machine.
+func seedFromMachine(c cluster.TestCluster, m platform.Machine, graph *cincinnati.Server) {
+	d, err := util.GetBootedDeployment(c, m)
+	if err != nil {
+		c.Fatal(err)
+	}
 
-	g.Edges = append(g.Edges, [2]int{i - 1, i})
+	err = graph.SetReleases([]cincinnati.Release{{Version: d.Version, Payload: d.Checksum}})
+	if err != nil {
+		c.Fatal(err)
+	}
+}
 
-	g.sync(c, m)
+// addUpdate adds a release to the graph, on top of the others.
+func addUpdate(c cluster.TestCluster, graph *cincinnati.Server, version, payload string) {
+	if err := graph.AddRelease(cincinnati.Release{Version: version, Payload: payload}); err != nil {
+		c.Fatal(err)
+	}
 }
 
 // XXX: consider making this distinction part of FCOS itself?
@@ -324,17 +316,6 @@ func undoZincatiDisablement(c cluster.TestCluster, m platform.Machine) {
 	}
 }
 
-func (g *Graph) sync(c cluster.TestCluster, m platform.Machine) {
-	b, err := json.Marshal(g)
-	if err != nil {
-		c.Fatalf("failed to marshal graph: %v", err)
-	}
-
-	if err := platform.InstallFile(bytes.NewReader(b), m, "graph.json"); err != nil {
-		c.Fatalf("failed to update graph.json: %v", err)
-	}
-}
-
 func runFnAndWaitForRebootIntoVersion(c cluster.TestCluster, m platform.Machine, version string, fn func()) {
 	oldBootId, err := platform.GetMachineBootId(m)
 	if err != nil {
@@ -378,12 +359,3 @@ func rpmostreeRebase(c cluster.TestCluster, m platform.Machine, ref, version str
 		c.RunCmdSyncf(m, "sudo systemd-run rpm-ostree rebase --reboot %s", ref)
 	})
 }
-
-func httpd() error {
-	http.Handle("/", http.FileServer(http.Dir(ostreeRepo)))
-	http.HandleFunc("/v1/graph", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "/var/home/core/graph.json")
-	})
-	plog.Info("Starting server")
-	return http.ListenAndServe("localhost:8080", nil)
-}
@@ -0,0 +1,247 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rpmostreeclient "github.com/coreos/rpmostree-client-go/pkg/client"
+
+	"github.com/coreos/coreos-assembler/mantle/kola"
+	"github.com/coreos/coreos-assembler/mantle/kola/cluster"
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/kola/tests/util"
+	"github.com/coreos/coreos-assembler/mantle/kola/tests/util/cincinnati"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+// chainKarg is appended before the first hop, and must survive all of them.
+const chainKarg = "kola.upgrade.chain=1"
+
+func init() {
+	register.RegisterUpgradeTest(&register.Test{
+		Run:         fcosUpgradeChain,
+		ClusterSize: 1,
+		Name:        "fcos.upgrade.chain",
+		Description: "Walks the machine through each release of the upgrade chain selected with `kola run-upgrade --upgrade-chain`, then to the build.",
+		FailFast:    true,
+		NativeFuncs: map[string]register.NativeFuncWrap{
+			"httpd": cincinnati.NativeFunc,
+		},
+		// the releases of the chain are pulled from the official remote
+		Tags:    []string{"upgrade", kola.NeedsInternetTag},
+		Distros: []string{"fcos"},
+		// the OSTree remote is only pointed to the Cincinnati server for
+		// the last hop
+		UserData: zincatiUserData("fcos.upgrade.chain", false),
+	})
+}
+
+// hopResult is the outcome of an upgrade hop, as reported in
+// upgrade-chain.json.
+type hopResult struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Method string `json:"method"`
+	// Duration is the time taken by the hop, in seconds.
+	Duration    float64  `json:"duration"`
+	Deployments []string `json:"deployments,omitempty"`
+	Kargs       string   `json:"kargs,omitempty"`
+	Passed      bool     `json:"passed"`
+}
+
+// fcosUpgradeChain verifies that the oldest release of the chain can
+// upgrade through each following release, then to the current build.
+func fcosUpgradeChain(c cluster.TestCluster) {
+	chain := kola.UpgradeChain
+	if len(chain) == 0 {
+		c.Skip("no upgrade chain; use kola run-upgrade --upgrade-chain")
+	}
+	m := c.Machines()[0]
+	graph := cincinnati.NewServer(m)
+
+	booted, err := util.GetBootedDeployment(c, m)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if booted.Version != chain[0].Version {
+		c.Fatalf("expected to boot version %s, but got version %s", chain[0].Version, booted.Version)
+	}
+	usingContainer := booted.ContainerImageReference != ""
+	var sourceContainerRef string
+
+	if !c.Run("setup", func(c cluster.TestCluster) {
+		sourceContainerRef = importBuild(c, m, usingContainer)
+		disableZincati(c, m)
+		runFnAndWaitForRebootIntoVersion(c, m, chain[0].Version, func() {
+			c.RunCmdSyncf(m, "sudo systemd-run rpm-ostree kargs --append=%s --reboot", chainKarg)
+		})
+	}) {
+		return
+	}
+
+	var results []hopResult
+	defer func() {
+		if err := writeHopResults(c, results); err != nil {
+			c.Error(err)
+		}
+	}()
+
+	for i := 1; i <= len(chain); i++ {
+		from := chain[i-1].Version
+		to, commit := kola.CosaBuild.Meta.OstreeVersion, kola.CosaBuild.Meta.OstreeCommit
+		if i < len(chain) {
+			to, commit = chain[i].Version, chain[i].Commit
+		}
+		if usingContainer {
+			// the commits of container images are merged ones
+			commit = ""
+		}
+		result := hopResult{From: from, To: to}
+		passed := c.Run(fmt.Sprintf("hop-%d-%s", i, to), func(c cluster.TestCluster) {
+			prev, err := util.GetBootedDeployment(c, m)
+			if err != nil {
+				c.Fatal(err)
+			}
+			start := time.Now()
+			defer func() {
+				result.Duration = time.Since(start).Seconds()
+			}()
+			switch {
+			case i == len(chain):
+				result.Method = "build"
+				setLocalRemote(c, m)
+				upgradeToBuild(c, m, graph, usingContainer, sourceContainerRef)
+			case usingContainer:
+				result.Method = "rebase"
+				rpmostreeRebase(c, m, "ostree-unverified-registry:quay.io/fedora/fedora-coreos:"+to, to)
+			default:
+				// serve the chain up to this hop only, so that Zincati
+				// can't skip any of it
+				result.Method = "zincati"
+				if err := graph.SetReleases(chainReleases(chain[:i+1])); err != nil {
+					c.Fatal(err)
+				}
+				waitForUpgradeToVersion(c, m, to)
+			}
+			result.Deployments, result.Kargs = checkHop(c, m, prev, to, commit)
+		})
+		result.Passed = passed
+		results = append(results, result)
+		if !passed {
+			break
+		}
+	}
+}
+
+// chainReleases returns the graph releases of the upgrade chain.
+func chainReleases(chain []kola.UpgradeRelease) []cincinnati.Release {
+	var releases []cincinnati.Release
+	for _, r := range chain {
+		releases = append(releases, cincinnati.Release{
+			Version: r.Version,
+			Payload: r.Commit,
+			Barrier: r.Barrier,
+		})
+	}
+	return releases
+}
+
+// setLocalRemote points the fedora OSTree remote to the repo served by the
+// Cincinnati server, like zincatiUserData does with localRemote.
+func setLocalRemote(c cluster.TestCluster, m platform.Machine) {
+	remote := fmt.Sprintf("[remote \"fedora\"]\nurl=%s\ngpg-verify=false\n", cincinnati.URL)
+	if err := platform.InstallFile(strings.NewReader(remote), m, workdir+"/fedora.conf"); err != nil {
+		c.Fatal(err)
+	}
+	// install rather than mv, so that the file gets the SELinux label of
+	// its new location
+	c.RunCmdSyncf(m, "sudo install -m 0644 %s/fedora.conf /etc/ostree/remotes.d/fedora.conf", workdir)
+}
+
+// checkHop checks the state of the machine after upgrading to version from
+// the prev deployment, and returns the deployments and kernel arguments.
+// The commit of the booted deployment is only checked if not empty.
+func checkHop(c cluster.TestCluster, m platform.Machine, prev *rpmostreeclient.Deployment, version, commit string) ([]string, string) {
+	out := c.MustSSH(m, "rpm-ostree status")
+	if err := os.WriteFile(filepath.Join(c.OutputDir(), "rpm-ostree-status.txt"), out, 0644); err != nil {
+		c.Fatal(err)
+	}
+	status, err := util.GetRpmOstreeStatus(c, m)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if status.Transaction != nil {
+		c.Fatalf("transaction still active after upgrade: %v", *status.Transaction)
+	}
+
+	var deployments []string
+	for _, d := range status.Deployments {
+		deployments = append(deployments, fmt.Sprintf("%s (%s)", d.Version, d.Checksum))
+	}
+	if len(status.Deployments) == 0 || !status.Deployments[0].Booted {
+		c.Fatalf("expected to boot the default deployment, got %v", deployments)
+	}
+	d := status.Deployments[0]
+	if d.Version != version {
+		c.Fatalf("expected to boot version %s, but got version %s", version, d.Version)
+	}
+	if commit != "" && d.Checksum != commit {
+		c.Fatalf("expected to boot commit %s, but got commit %s", commit, d.Checksum)
+	}
+	if d.Staged || (len(status.Deployments) > 1 && status.Deployments[1].Staged) {
+		c.Fatalf("unexpected staged deployment: %v", deployments)
+	}
+	// the previous deployment must be kept for rollback
+	rollback := false
+	for _, r := range status.Deployments[1:] {
+		rollback = rollback || r.Checksum == prev.Checksum
+	}
+	if !rollback {
+		c.Fatalf("previous deployment %s (%s) not kept: %v", prev.Version, prev.Checksum, deployments)
+	}
+
+	kargs := strings.TrimSpace(string(c.MustSSH(m, "cat /proc/cmdline")))
+	if !strings.Contains(" "+kargs+" ", " "+chainKarg+" ") {
+		c.Fatalf("karg %s lost: %s", chainKarg, kargs)
+	}
+	return deployments, kargs
+}
+
+// writeHopResults writes the results of the hops to upgrade-chain.json and
+// logs a summary.
+func writeHopResults(c cluster.TestCluster, results []hopResult) error {
+	var summary bytes.Buffer
+	for _, r := range results {
+		outcome := "PASS"
+		if !r.Passed {
+			outcome = "FAIL"
+		}
+		fmt.Fprintf(&summary, "\n  %s: %s -> %s via %s (%.0fs)", outcome, r.From, r.To, r.Method, r.Duration)
+	}
+	c.Logf("upgrade chain:%s", summary.String())
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.OutputDir(), "upgrade-chain.json"), b, 0644)
+}
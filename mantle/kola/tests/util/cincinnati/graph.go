@@ -0,0 +1,160 @@
+// Copyright 2023 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cincinnati serves a fake Fedora CoreOS Cincinnati update graph
+// along with OSTree repo content, so that upgrade tests can drive Zincati.
+//
+// The server runs on the machine under test, as a kolet native function;
+// the test drives it from the harness through a Server, whose state changes
+// are picked up by the next graph request. The graph is computed like the
+// Fedora CoreOS graph-builder and policy-engine do: releases are nodes
+// ordered by age, with edges from every release to every newer release up
+// to the next barrier, none out of dead-ends, and none to releases whose
+// rollout hasn't reached the client yet.
+package cincinnati
+
+import (
+	"strconv"
+	"time"
+)
+
+// Node metadata keys, as used by the Fedora CoreOS Cincinnati.
+const (
+	MetadataAgeIndex      = "org.fedoraproject.coreos.releases.age_index"
+	MetadataScheme        = "org.fedoraproject.coreos.scheme"
+	MetadataBarrier       = "org.fedoraproject.coreos.updates.barrier"
+	MetadataDeadEnd       = "org.fedoraproject.coreos.updates.deadend"
+	MetadataDeadEndReason = "org.fedoraproject.coreos.updates.deadend_reason"
+	MetadataStartEpoch    = "org.fedoraproject.coreos.updates.start_epoch"
+	MetadataStartValue    = "org.fedoraproject.coreos.updates.start_value"
+	MetadataDuration      = "org.fedoraproject.coreos.updates.duration_minutes"
+)
+
+// Release is a release of the update graph.
+type Release struct {
+	Version string `json:"version"`
+	// Payload is the OSTree commit of the release.
+	Payload string `json:"payload"`
+	// Barrier releases can't be skipped: older releases have no edges
+	// past them.
+	Barrier bool `json:"barrier,omitempty"`
+	// DeadEnd releases have no edges out of them. Zincati reports the
+	// reason.
+	DeadEnd       bool   `json:"deadend,omitempty"`
+	DeadEndReason string `json:"deadend_reason,omitempty"`
+	// Rollout, if set, throttles the edges to the release.
+	Rollout *Rollout `json:"rollout,omitempty"`
+	// Metadata is additional node metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Rollout is the progressive rollout of a release to clients, by their
+// rollout wariness.
+type Rollout struct {
+	Start time.Time `json:"start"`
+	// StartPercentage is the share of the clients which get the release
+	// at Start, from 0 to 1.
+	StartPercentage float64 `json:"start_percentage"`
+	// Duration is the time for the rollout to reach all the clients.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// reaches returns whether the release is rolled out at now to a client with
+// the rollout wariness. No client gets it before Start.
+func (r *Rollout) reaches(now time.Time, wariness float64) bool {
+	if now.Before(r.Start) {
+		return false
+	}
+	p := r.StartPercentage
+	if r.Duration > 0 {
+		p += (1 - r.StartPercentage) * float64(now.Sub(r.Start)) / float64(r.Duration)
+	}
+	return wariness <= p
+}
+
+// Node is a node of the update graph.
+type Node struct {
+	Version  string            `json:"version"`
+	Metadata map[string]string `json:"metadata"`
+	Payload  string            `json:"payload"`
+}
+
+// Graph is an update graph, as served to Zincati. Edges are pairs of
+// indexes of nodes.
+type Graph struct {
+	Nodes []Node   `json:"nodes"`
+	Edges [][2]int `json:"edges"`
+}
+
+func (r *Release) node(ageIndex int) Node {
+	metadata := map[string]string{
+		MetadataAgeIndex: strconv.Itoa(ageIndex),
+		MetadataScheme:   "checksum",
+	}
+	if r.Barrier {
+		metadata[MetadataBarrier] = "true"
+	}
+	if r.DeadEnd {
+		metadata[MetadataDeadEnd] = "true"
+		if r.DeadEndReason != "" {
+			metadata[MetadataDeadEndReason] = r.DeadEndReason
+		}
+	}
+	if r.Rollout != nil {
+		metadata[MetadataStartEpoch] = strconv.FormatInt(r.Rollout.Start.Unix(), 10)
+		metadata[MetadataStartValue] = strconv.FormatFloat(r.Rollout.StartPercentage, 'f', -1, 64)
+		metadata[MetadataDuration] = strconv.FormatInt(int64(r.Rollout.Duration/time.Minute), 10)
+	}
+	for k, v := range r.Metadata {
+		metadata[k] = v
+	}
+	return Node{
+		Version:  r.Version,
+		Payload:  r.Payload,
+		Metadata: metadata,
+	}
+}
+
+// BuildGraph returns the update graph of the releases, oldest first, as
+// served at now to a client with the rollout wariness, from 0 (eager) to 1
+// (wary).
+func BuildGraph(releases []Release, now time.Time, wariness float64) Graph {
+	g := Graph{
+		Nodes: make([]Node, 0, len(releases)),
+		Edges: [][2]int{},
+	}
+	for i := range releases {
+		g.Nodes = append(g.Nodes, releases[i].node(i))
+	}
+	for to := range releases {
+		if r := releases[to].Rollout; r != nil && !r.reaches(now, wariness) {
+			continue
+		}
+		// releases older than the last barrier before this one must
+		// go through it
+		from := 0
+		for i := to - 1; i >= 0; i-- {
+			if releases[i].Barrier {
+				from = i
+				break
+			}
+		}
+		for ; from < to; from++ {
+			if !releases[from].DeadEnd {
+				g.Edges = append(g.Edges, [2]int{from, to})
+			}
+		}
+	}
+	return g
+}
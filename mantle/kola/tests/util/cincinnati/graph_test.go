@@ -0,0 +1,179 @@
+// Copyright 2023 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cincinnati
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildGraph(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	releases := func(fn func([]Release)) []Release {
+		r := []Release{
+			{Version: "38.1", Payload: "a"},
+			{Version: "38.2", Payload: "b"},
+			{Version: "38.3", Payload: "c"},
+			{Version: "38.4", Payload: "d"},
+		}
+		if fn != nil {
+			fn(r)
+		}
+		return r
+	}
+
+	for _, tc := range []struct {
+		name     string
+		releases []Release
+		wariness float64
+		expected [][2]int
+	}{
+		{
+			name:     "every release to every newer one",
+			releases: releases(nil),
+			expected: [][2]int{{0, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}, {2, 3}},
+		},
+		{
+			name:     "barrier",
+			releases: releases(func(r []Release) { r[1].Barrier = true }),
+			expected: [][2]int{{0, 1}, {1, 2}, {1, 3}, {2, 3}},
+		},
+		{
+			name:     "dead-end",
+			releases: releases(func(r []Release) { r[2].DeadEnd = true }),
+			expected: [][2]int{{0, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}},
+		},
+		{
+			name: "rollout reached the client",
+			releases: releases(func(r []Release) {
+				r[3].Rollout = &Rollout{Start: now.Add(-time.Hour), StartPercentage: 0.2, Duration: 2 * time.Hour}
+			}),
+			wariness: 0.5,
+			expected: [][2]int{{0, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}, {2, 3}},
+		},
+		{
+			name: "rollout throttled",
+			releases: releases(func(r []Release) {
+				r[3].Rollout = &Rollout{Start: now.Add(-time.Hour), StartPercentage: 0.2, Duration: 2 * time.Hour}
+			}),
+			wariness: 0.7,
+			expected: [][2]int{{0, 1}, {0, 2}, {1, 2}},
+		},
+		{
+			name: "rollout not started",
+			releases: releases(func(r []Release) {
+				r[3].Rollout = &Rollout{Start: now.Add(time.Hour), StartPercentage: 1}
+			}),
+			expected: [][2]int{{0, 1}, {0, 2}, {1, 2}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := BuildGraph(tc.releases, now, tc.wariness)
+			if len(g.Nodes) != len(tc.releases) {
+				t.Fatalf("expected %d nodes, found %d", len(tc.releases), len(g.Nodes))
+			}
+			if !reflect.DeepEqual(g.Edges, tc.expected) {
+				t.Errorf("expected edges %v, found %v", tc.expected, g.Edges)
+			}
+		})
+	}
+
+	g := BuildGraph(releases(func(r []Release) {
+		r[0].DeadEnd = true
+		r[0].DeadEndReason = "https://github.com/coreos/fedora-coreos-tracker/issues/1"
+		r[1].Barrier = true
+	}), now, 0)
+	expected := map[string]string{
+		MetadataAgeIndex:      "0",
+		MetadataScheme:        "checksum",
+		MetadataDeadEnd:       "true",
+		MetadataDeadEndReason: "https://github.com/coreos/fedora-coreos-tracker/issues/1",
+	}
+	if !reflect.DeepEqual(g.Nodes[0].Metadata, expected) {
+		t.Errorf("unexpected dead-end metadata %v", g.Nodes[0].Metadata)
+	}
+	if g.Nodes[1].Metadata[MetadataBarrier] != "true" {
+		t.Errorf("unexpected barrier metadata %v", g.Nodes[1].Metadata)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	repoDir := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "config"), []byte("[core]\nmode=archive-z2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(Handler(stateFile, repoDir))
+	defer server.Close()
+
+	getGraph := func(query string) Graph {
+		res, err := http.Get(server.URL + "/v1/graph" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", res.StatusCode)
+		}
+		var g Graph
+		if err := json.NewDecoder(res.Body).Decode(&g); err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	// no state yet
+	if g := getGraph(""); len(g.Nodes) != 0 {
+		t.Errorf("expected an empty graph, found %+v", g)
+	}
+
+	// state changes are picked up by the next request
+	releases := []Release{
+		{Version: "38.1", Payload: "a"},
+		{Version: "38.2", Payload: "b", Rollout: &Rollout{Start: time.Now().Add(-time.Hour), StartPercentage: 0.5}},
+	}
+	b, err := json.Marshal(releases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stateFile, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if g := getGraph("?basearch=x86_64&stream=stable"); len(g.Nodes) != 2 || len(g.Edges) != 1 {
+		t.Errorf("unexpected graph %+v", g)
+	}
+	if g := getGraph("?rollout_wariness=0.9"); len(g.Edges) != 0 {
+		t.Errorf("expected a throttled graph, found %+v", g)
+	}
+
+	res, err := http.Get(server.URL + "/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %d serving the repo", res.StatusCode)
+	}
+}
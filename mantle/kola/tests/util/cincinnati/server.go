@@ -0,0 +1,210 @@
+// Copyright 2023 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cincinnati
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/coreos-assembler/mantle/kola/register"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+const (
+	// Addr is the address the server listens on, on the machine.
+	Addr = "localhost:8080"
+	// URL is the URL of the server, to be set as Zincati's
+	// cincinnati.base_url and as the URL of the OSTree remote.
+	URL = "http://" + Addr
+	// StateFile holds the releases served, as written by Server.
+	StateFile = "/var/home/core/cincinnati.json"
+	// RepoDir is the OSTree repo served.
+	RepoDir = "/var/srv/upgrade/repo"
+)
+
+var plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "kola/tests/util/cincinnati")
+
+// NativeFunc runs the server on the machine; tests using a Server register
+// it in their NativeFuncs and start it from their Ignition config once
+// kolet is copied to the machine.
+var NativeFunc = register.CreateNativeFuncWrap(Serve)
+
+// Serve serves the update graph of StateFile and the OSTree repo RepoDir on
+// Addr.
+func Serve() error {
+	plog.Infof("Starting Cincinnati server on %s", Addr)
+	return http.ListenAndServe(Addr, Handler(StateFile, RepoDir))
+}
+
+// Handler returns a handler serving the update graph of the releases in
+// stateFile on /v1/graph, and the content of repoDir on other paths. The
+// state file is read on every graph request, so that the harness can
+// change it at any time. The rollout wariness of the client is read from
+// the rollout_wariness parameter, and defaults to 0.
+func Handler(stateFile, repoDir string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(repoDir)))
+	mux.HandleFunc("/v1/graph", func(w http.ResponseWriter, r *http.Request) {
+		var wariness float64
+		if s := r.URL.Query().Get("rollout_wariness"); s != "" {
+			var err error
+			if wariness, err = strconv.ParseFloat(s, 64); err != nil {
+				http.Error(w, fmt.Sprintf("invalid rollout_wariness %q", s), http.StatusBadRequest)
+				return
+			}
+		}
+		releases, err := readState(stateFile)
+		if err != nil {
+			plog.Errorf("reading state: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		g := BuildGraph(releases, time.Now(), wariness)
+		plog.Infof("Serving graph of %d nodes and %d edges to %s", len(g.Nodes), len(g.Edges), r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(g); err != nil {
+			plog.Errorf("writing graph: %v", err)
+		}
+	})
+	return mux
+}
+
+func readState(stateFile string) ([]Release, error) {
+	b, err := os.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var releases []Release
+	if err := json.Unmarshal(b, &releases); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", stateFile, err)
+	}
+	return releases, nil
+}
+
+// Server drives the server running on a machine from the harness. Every
+// change to the releases is synced to the machine before returning.
+type Server struct {
+	m platform.Machine
+
+	mu       sync.Mutex
+	releases []Release
+}
+
+// NewServer returns a Server driving the server of the machine, initially
+// serving an empty graph.
+func NewServer(m platform.Machine) *Server {
+	return &Server{m: m}
+}
+
+// Releases returns a copy of the releases served, oldest first.
+func (s *Server) Releases() []Release {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Release(nil), s.releases...)
+}
+
+// SetReleases replaces the releases served, oldest first.
+func (s *Server) SetReleases(releases []Release) error {
+	s.mu.Lock()
+	s.releases = append([]Release(nil), releases...)
+	s.mu.Unlock()
+	return s.Sync()
+}
+
+// AddRelease adds a release, newer than all the others.
+func (s *Server) AddRelease(r Release) error {
+	s.mu.Lock()
+	s.releases = append(s.releases, r)
+	s.mu.Unlock()
+	return s.Sync()
+}
+
+// UpdateRelease changes the release of the version with fn, e.g. to make it
+// a dead-end or throttle its rollout.
+func (s *Server) UpdateRelease(version string, fn func(*Release)) error {
+	s.mu.Lock()
+	found := false
+	for i := range s.releases {
+		if s.releases[i].Version == version {
+			fn(&s.releases[i])
+			found = true
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		return fmt.Errorf("no release %s", version)
+	}
+	return s.Sync()
+}
+
+// RemoveRelease pulls the release of the version from the graph.
+func (s *Server) RemoveRelease(version string) error {
+	s.mu.Lock()
+	var releases []Release
+	for _, r := range s.releases {
+		if r.Version != version {
+			releases = append(releases, r)
+		}
+	}
+	found := len(releases) != len(s.releases)
+	s.releases = releases
+	s.mu.Unlock()
+	if !found {
+		return fmt.Errorf("no release %s", version)
+	}
+	return s.Sync()
+}
+
+// Graph returns the graph served at now to a client with the rollout
+// wariness.
+func (s *Server) Graph(now time.Time, wariness float64) Graph {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return BuildGraph(s.releases, now, wariness)
+}
+
+// Sync writes the releases to the state file of the machine. The file is
+// replaced atomically, so that the server never reads a partial state.
+func (s *Server) Sync() error {
+	s.mu.Lock()
+	releases := s.releases
+	if releases == nil {
+		releases = []Release{}
+	}
+	b, err := json.Marshal(releases)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal releases: %v", err)
+	}
+
+	tmp := StateFile + ".tmp"
+	if err := platform.InstallFile(bytes.NewReader(b), s.m, tmp); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmp, err)
+	}
+	if out, stderr, err := s.m.SSH(fmt.Sprintf("sudo mv %s %s", tmp, StateFile)); err != nil {
+		return fmt.Errorf("failed to update %s: %q: %s: %v", StateFile, out, stderr, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+
+	"github.com/coreos/stream-metadata-go/release"
+
+	"github.com/coreos/coreos-assembler/mantle/fcos"
+)
+
+// UpgradeRelease is a release of an upgrade chain.
+type UpgradeRelease struct {
+	Version string `json:"version"`
+	// Commit is the OSTree commit of the release for the architecture.
+	Commit  string `json:"commit"`
+	Barrier bool   `json:"barrier,omitempty"`
+}
+
+// UpgradeChain lists the releases `kola run-upgrade` walks the machine
+// through, oldest first, before upgrading it to the build under test. The
+// machine boots the oldest one. Empty unless requested.
+var UpgradeChain []UpgradeRelease
+
+// SelectUpgradeChain returns the upgrade chain ending with the release
+// parentVersion of the index, or the latest release for arch if empty.
+// The chain holds the last n releases for arch or, if barriers is set, the
+// barrier releases in updates (the last n of them if n > 0) followed by
+// parentVersion. Dead-end releases are never selected.
+func SelectUpgradeChain(index *release.Index, updates *fcos.Updates, arch, parentVersion string, n int, barriers bool) ([]UpgradeRelease, error) {
+	if !barriers && n <= 0 {
+		return nil, fmt.Errorf("invalid chain length %d", n)
+	}
+	if barriers && updates == nil {
+		return nil, fmt.Errorf("barriers requested without updates metadata")
+	}
+
+	hints := make(map[string]fcos.UpdatesReleaseMetadata)
+	if updates != nil {
+		for _, r := range updates.Releases {
+			hints[r.Version] = r.Metadata
+		}
+	}
+
+	// the releases for arch, oldest first, up to the parent
+	var candidates []UpgradeRelease
+	foundParent := false
+	for _, r := range index.Releases {
+		for _, commit := range r.Commits {
+			if commit.Architecture == arch {
+				candidates = append(candidates, UpgradeRelease{
+					Version: r.Version,
+					Commit:  commit.Checksum,
+					Barrier: hints[r.Version].Barrier != nil,
+				})
+				break
+			}
+		}
+		if parentVersion != "" && r.Version == parentVersion {
+			foundParent = true
+			break
+		}
+	}
+	if parentVersion != "" && !foundParent {
+		return nil, fmt.Errorf("release %s not found in release index of stream %s", parentVersion, index.Stream)
+	}
+	if len(candidates) == 0 || (parentVersion != "" && candidates[len(candidates)-1].Version != parentVersion) {
+		return nil, fmt.Errorf("no release for %s to end the upgrade chain with on stream %s", arch, index.Stream)
+	}
+	parent := candidates[len(candidates)-1]
+	if hints[parent.Version].Deadend != nil {
+		return nil, fmt.Errorf("release %s is a dead-end", parent.Version)
+	}
+
+	var chain []UpgradeRelease
+	for _, r := range candidates[:len(candidates)-1] {
+		if hints[r.Version].Deadend != nil || (barriers && !r.Barrier) {
+			continue
+		}
+		chain = append(chain, r)
+	}
+	if barriers {
+		if n > 0 && len(chain) > n {
+			chain = chain[len(chain)-n:]
+		}
+		return append(chain, parent), nil
+	}
+	chain = append(chain, parent)
+	if len(chain) > n {
+		chain = chain[len(chain)-n:]
+	}
+	return chain, nil
+}
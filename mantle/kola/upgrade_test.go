@@ -0,0 +1,117 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/stream-metadata-go/release"
+
+	"github.com/coreos/coreos-assembler/mantle/fcos"
+)
+
+func TestSelectUpgradeChain(t *testing.T) {
+	index := &release.Index{Stream: "stable"}
+	for _, r := range []struct {
+		version string
+		arches  []string
+	}{
+		{"36.1", []string{"x86_64", "aarch64"}},
+		{"36.2", []string{"x86_64"}},
+		{"37.1", []string{"x86_64", "aarch64"}},
+		{"37.2", []string{"x86_64", "aarch64"}},
+		{"38.1", []string{"x86_64", "aarch64"}},
+		{"38.2", []string{"x86_64"}},
+	} {
+		var commits []release.IndexReleaseCommit
+		for _, arch := range r.arches {
+			commits = append(commits, release.IndexReleaseCommit{Architecture: arch, Checksum: r.version + "-" + arch})
+		}
+		index.Releases = append(index.Releases, release.IndexRelease{Version: r.version, Commits: commits})
+	}
+	updates := &fcos.Updates{
+		Stream: "stable",
+		Releases: []fcos.UpdatesRelease{
+			{Version: "36.2", Metadata: fcos.UpdatesReleaseMetadata{Barrier: &fcos.UpdatesBarrier{}}},
+			{Version: "37.1", Metadata: fcos.UpdatesReleaseMetadata{Deadend: &fcos.UpdatesDeadend{}}},
+			{Version: "37.2", Metadata: fcos.UpdatesReleaseMetadata{Barrier: &fcos.UpdatesBarrier{}}},
+		},
+	}
+	versions := func(chain []UpgradeRelease) []string {
+		var ret []string
+		for _, r := range chain {
+			ret = append(ret, r.Version)
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		name     string
+		arch     string
+		parent   string
+		n        int
+		barriers bool
+		expected []string
+	}{
+		{"last releases", "x86_64", "", 3, false, []string{"37.2", "38.1", "38.2"}},
+		{"skips dead-ends", "x86_64", "38.1", 3, false, []string{"36.2", "37.2", "38.1"}},
+		{"other arch", "aarch64", "", 2, false, []string{"37.2", "38.1"}},
+		{"longer than the index", "aarch64", "", 10, false, []string{"36.1", "37.2", "38.1"}},
+		{"barriers", "x86_64", "", 0, true, []string{"36.2", "37.2", "38.2"}},
+		{"last barriers", "x86_64", "", 1, true, []string{"37.2", "38.2"}},
+		{"barriers of arch", "aarch64", "", 0, true, []string{"37.2", "38.1"}},
+		{"parent is a barrier", "x86_64", "37.2", 0, true, []string{"36.2", "37.2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chain, err := SelectUpgradeChain(index, updates, tc.arch, tc.parent, tc.n, tc.barriers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := versions(chain); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, found %v", tc.expected, actual)
+			}
+		})
+	}
+
+	chain, err := SelectUpgradeChain(index, updates, "x86_64", "37.2", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []UpgradeRelease{{Version: "37.2", Commit: "37.2-x86_64", Barrier: true}}
+	if !reflect.DeepEqual(chain, expected) {
+		t.Errorf("expected %+v, found %+v", expected, chain)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		arch   string
+		parent string
+	}{
+		{"unknown parent", "x86_64", "39.1"},
+		{"parent not built for arch", "aarch64", "38.2"},
+		{"dead-end parent", "x86_64", "37.1"},
+		{"unknown arch", "s390x", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := SelectUpgradeChain(index, updates, tc.arch, tc.parent, 2, false); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := SelectUpgradeChain(index, nil, "x86_64", "", 0, true); err == nil {
+		t.Error("expected an error without updates metadata")
+	}
+}
@@ -31,13 +31,16 @@ var plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle"
 // lead to incorrect results. Timekeeping sucks.
 
 // A simple NTP server intended for testing. It can serve time at some offset
-// from the real time and adjust for a single leap second.
+// from the real time, running at a skewed rate, and adjust for a single leap
+// second.
 type Server struct {
 	net.PacketConn
-	mu       sync.Mutex    // protects offset, leapTime, and leapType.
-	offset   time.Duration // see SetTime
-	leapTime time.Time     // see SetLeapSecond
-	leapType LeapIndicator
+	mu        sync.Mutex    // protects offset, skew, skewStart, leapTime, and leapType.
+	offset    time.Duration // see SetTime
+	skew      float64       // see SetSkew
+	skewStart time.Time
+	leapTime  time.Time // see SetLeapSecond
+	leapType  LeapIndicator
 }
 
 type ServerReq struct {
@@ -60,13 +63,43 @@ func NewServer(addr string) (*Server, error) {
 func (s *Server) SetTime(now time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.skewStart = time.Now()
 	if now.IsZero() {
 		s.offset = time.Duration(0)
 	} else {
-		s.offset = -time.Since(now)
+		s.offset = -s.skewStart.Sub(now)
 	}
 }
 
+// Make the served time run faster (positive skew) or slower (negative skew)
+// than the real time, by the given fraction, from now on. For example 1e-4
+// drifts by 100ppm, and 1 runs the clock twice as fast.
+func (s *Server) SetSkew(skew float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.offset = s.offsetAt(now)
+	s.skewStart = now
+	s.skew = skew
+}
+
+// Get the time currently served, ignoring a pending leap second.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	return now.Add(s.offsetAt(now))
+}
+
+// offsetAt returns the offset at real time now, including the skew. Must
+// be called with mu held.
+func (s *Server) offsetAt(now time.Time) time.Duration {
+	if s.skew == 0 {
+		return s.offset
+	}
+	return s.offset + time.Duration(s.skew*float64(now.Sub(s.skewStart)))
+}
+
 // Must be exactly midnight on the first day of the month. This is the first
 // time that is always valid after the leap has occurred for both adding and
 // removing a second. This is the same way leap seconds are officially listed.
@@ -90,18 +123,20 @@ func (s *Server) UpdateOffset(now time.Time) (time.Duration, LeapIndicator) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	offset := s.offsetAt(now)
 	if s.leapTime.IsZero() || s.leapType == LEAP_NONE {
-		return s.offset, LEAP_NONE
+		return offset, LEAP_NONE
 	}
 
-	now = now.Add(s.offset)
+	now = now.Add(offset)
 	if now.Add(24 * time.Hour).Before(s.leapTime) {
-		return s.offset, LEAP_NONE
+		return offset, LEAP_NONE
 	}
 
 	if s.leapType == LEAP_ADD && !now.Before(s.leapTime) {
 		plog.Infof("Inserting leap second at %s", s.leapTime)
 		s.offset -= time.Second
+		offset -= time.Second
 		s.leapTime = time.Time{}
 		s.leapType = LEAP_NONE
 
@@ -110,11 +145,12 @@ func (s *Server) UpdateOffset(now time.Time) (time.Duration, LeapIndicator) {
 
 		plog.Infof("Skipping leap second at %s", s.leapTime)
 		s.offset += time.Second
+		offset += time.Second
 		s.leapTime = time.Time{}
 		s.leapType = LEAP_NONE
 	}
 
-	return s.offset, s.leapType
+	return offset, s.leapType
 }
 
 // Serve NTP requests forever.
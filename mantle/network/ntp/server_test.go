@@ -42,6 +42,29 @@ func TestServerSetTime(t *testing.T) {
 	}
 }
 
+func TestServerSetSkew(t *testing.T) {
+	start := time.Date(2013, time.December, 10, 19, 22, 35, 0, time.UTC)
+	s := &Server{}
+	s.SetTime(start)
+	s.SetSkew(1)
+	// twice as fast: two hours are served after an hour
+	now := s.skewStart.Add(time.Hour)
+	offset, _ := s.UpdateOffset(now)
+	if d := now.Add(offset).Sub(start.Add(2 * time.Hour)); d < 0 || d > time.Second {
+		t.Errorf("Server time off by %s, internal offset %s", d, offset)
+	}
+
+	// changing the skew keeps the time served
+	served := s.Now()
+	s.SetSkew(0)
+	if d := s.Now().Sub(served); d < 0 || d > time.Second {
+		t.Errorf("Server time jumped by %s", d)
+	}
+	if s.Now().Before(start) || s.Now().After(start.Add(time.Minute)) {
+		t.Errorf("Server time %s not close to %s", s.Now(), start)
+	}
+}
+
 func TestServerSetLeap(t *testing.T) {
 	leap := time.Date(2012, time.July, 1, 0, 0, 0, 0, time.UTC)
 	s := &Server{}
@@ -47,6 +47,10 @@ type Options struct {
 	// SecretKey is the optional secret key to use. It will override all other sources
 	SecretKey string
 
+	// Endpoint overrides the endpoint of all services, e.g. to talk to
+	// an emulator such as the one in platform/api/fakecloud
+	Endpoint string
+
 	// AMI is the AWS AMI to launch EC2 instances with.
 	// If it is one of the special strings alpha|beta|stable, it will be resolved
 	// to an actual ID.
@@ -77,6 +81,10 @@ func New(opts *Options) (*API, error) {
 	} else if opts.CredentialsFile != "" {
 		awsCfg.Credentials = credentials.NewSharedCredentials(opts.CredentialsFile, opts.Profile)
 	}
+	if opts.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(opts.Endpoint)
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
 
 	sess, err := session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
@@ -0,0 +1,245 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/fakecloud"
+)
+
+func newTestAPI(t *testing.T, endpoint, region string) *API {
+	// a CA bundle makes the SDK modify http.DefaultClient when creating a
+	// session, which races with CopyImage's concurrent requests
+	t.Setenv("AWS_CA_BUNDLE", "")
+	api, err := New(&Options{
+		Options:       &platform.Options{},
+		Region:        region,
+		AccessKeyID:   "AKIAFAKECLOUD",
+		SecretKey:     "secret",
+		Endpoint:      endpoint,
+		InstanceType:  "t3.small",
+		SecurityGroup: "kola",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestUploadAndCreateImage(t *testing.T) {
+	fake := fakecloud.NewAWS()
+	defer fake.Close()
+	api := newTestAPI(t, fake.Endpoint(), "us-east-1")
+
+	if err := api.PreflightCheck(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := api.InitializeBucket("bucket"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// large enough to be uploaded in several parts
+	image := bytes.Repeat([]byte("coreos"), 2*1024*1024)
+	if err := api.UploadObjectExt(bytes.NewReader(image), "bucket", "ami-import/image.vmdk", false, "public-read", "application/octet-stream", 60); err != nil {
+		t.Fatal(err)
+	}
+	// not overwritten without force
+	if err := api.UploadObject(bytes.NewReader([]byte("other")), "bucket", "ami-import/image.vmdk", false); err != nil {
+		t.Fatal(err)
+	}
+	f, err := api.DownloadFile("bucket", "ami-import/image.vmdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, image) {
+		t.Fatalf("downloaded %d bytes, expected %d", len(downloaded), len(image))
+	}
+
+	if _, err := api.CreateSnapshot("missing", "s3://bucket/missing.vmdk", EC2ImageFormatVmdk); err == nil {
+		t.Error("expected an error importing a missing object")
+	}
+	snapshot, err := api.CreateSnapshot("image", "s3://bucket/ami-import/image.vmdk", EC2ImageFormatVmdk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := api.FindSnapshot("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.SnapshotID != snapshot.SnapshotID {
+		t.Fatalf("expected to find snapshot %s, got %v", snapshot.SnapshotID, found)
+	}
+	if err := api.DeleteObject("bucket", "ami-import/image.vmdk"); err != nil {
+		t.Fatal(err)
+	}
+
+	amiID, err := api.CreateHVMImage(snapshot.SnapshotID, 16, "image", "an image", "x86_64", "gp3", true, "uefi-preferred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// registering the same name again finds the existing image
+	again, err := api.CreateHVMImage(snapshot.SnapshotID, 16, "image", "an image", "x86_64", "gp3", true, "uefi-preferred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != amiID {
+		t.Errorf("expected existing image %s, got %s", amiID, again)
+	}
+	if err := api.GrantLaunchPermission(amiID, []string{"111111111111"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.CreateTags([]string{amiID, snapshot.SnapshotID}, map[string]string{"stream": "testing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	copies := make(map[string]ImageData)
+	err = api.CopyImage(amiID, []string{"us-east-2", "us-west-2"}, func(region string, data ImageData) {
+		copies[region] = data
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 2 {
+		t.Fatalf("expected 2 copies, got %v", copies)
+	}
+	west := newTestAPI(t, fake.Endpoint(), "us-west-2")
+	copied, err := west.describeImage(copies["us-west-2"].AMI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(copied.Name) != "image" || len(copied.Tags) != 2 {
+		t.Errorf("unexpected copied image %v", copied)
+	}
+	perms, err := west.ec2.DescribeImageAttribute(&ec2.DescribeImageAttributeInput{
+		Attribute: aws.String("launchPermission"),
+		ImageId:   copied.ImageId,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perms.LaunchPermissions) != 1 || aws.StringValue(perms.LaunchPermissions[0].UserId) != "111111111111" {
+		t.Errorf("launch permissions were not copied: %v", perms.LaunchPermissions)
+	}
+
+	if err := west.PublishImage(copies["us-west-2"].AMI); err != nil {
+		t.Fatal(err)
+	}
+	if copied, err = west.describeImage(copies["us-west-2"].AMI); err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(copied.Public) {
+		t.Error("expected the image to be public")
+	}
+
+	// RemoveImage deregisters the image before deleting its snapshot
+	if err := api.RemoveImage("image", "bucket", "ami-import/image.vmdk"); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := api.FindImage("image"); err != nil || id != "" {
+		t.Errorf("expected the image to be removed, got %q, %v", id, err)
+	}
+	if found, err := api.FindSnapshot("image"); err != nil || found != nil {
+		t.Errorf("expected the snapshot to be removed, got %v, %v", found, err)
+	}
+}
+
+func TestCreateInstancesAndGC(t *testing.T) {
+	fake := fakecloud.NewAWS()
+	defer fake.Close()
+	api := newTestAPI(t, fake.Endpoint(), "us-east-1")
+
+	if err := api.InitializeBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.UploadObject(bytes.NewReader([]byte("disk")), "bucket", "disk.raw", true); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := api.CreateSnapshot("disk", "s3://bucket/disk.raw", EC2ImageFormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.opts.AMI, err = api.CreateHVMImage(snapshot.SnapshotID, 16, "disk", "", "aarch64", "", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.AddKey("key", "ssh-ed25519 AAAA"); err != nil {
+		t.Fatal(err)
+	}
+	instances, err := api.CreateInstances("kola-test", "key", "{}", 2, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances, got %d", len(instances))
+	}
+	for _, instance := range instances {
+		if instance.PublicIpAddress == nil || aws.StringValue(instance.SubnetId) == "" {
+			t.Errorf("instance is not networked: %v", instance)
+		}
+	}
+	// the security group and its VPC are reused
+	if _, err := api.CreateInstances("kola-test", "", "", 1, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := api.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups.SecurityGroups) != 1 {
+		t.Errorf("expected 1 security group, got %d", len(groups.SecurityGroups))
+	}
+
+	states := func() map[string]int {
+		res, err := api.ec2.DescribeInstances(&ec2.DescribeInstancesInput{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		states := make(map[string]int)
+		for _, reservation := range res.Reservations {
+			for _, instance := range reservation.Instances {
+				states[*instance.State.Name]++
+			}
+		}
+		return states
+	}
+	if err := api.GC(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if s := states(); s[ec2.InstanceStateNameRunning] != 3 {
+		t.Errorf("expected recent instances to be kept, got %v", s)
+	}
+	if err := api.GC(0); err != nil {
+		t.Fatal(err)
+	}
+	if s := states(); s[ec2.InstanceStateNameTerminated] != 3 {
+		t.Errorf("expected instances to be terminated, got %v", s)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloud
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AccountID is the account owning all the resources of the AWS emulator.
+const AccountID = "123456789012"
+
+// credentialScope matches the service and region of a SigV4 Authorization
+// header.
+var credentialScope = regexp.MustCompile(`Credential=[^/]+/[0-9]+/([^/]+)/([^/]+)/aws4_request`)
+
+// AWS is an in-process emulator of the parts of the EC2, S3 and STS APIs
+// used by platform/api/aws. All services share a single endpoint, which
+// can be passed as aws.Options.Endpoint; the service and region of a
+// request are taken from its signature. EC2 state is kept per region,
+// while S3 buckets are global like in AWS.
+//
+// Long-running operations such as snapshot imports, image copies and
+// instance launches complete immediately.
+type AWS struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	regions map[string]*ec2Region
+	buckets map[string]*s3Bucket
+	uploads map[string]*s3Upload
+	nextID  int
+}
+
+// NewAWS starts an AWS emulator. It must be closed with Close.
+func NewAWS() *AWS {
+	a := &AWS{
+		regions: make(map[string]*ec2Region),
+		buckets: make(map[string]*s3Bucket),
+		uploads: make(map[string]*s3Upload),
+	}
+	a.server = httptest.NewServer(a)
+	return a
+}
+
+// Endpoint returns the URL of the emulator.
+func (a *AWS) Endpoint() string {
+	return a.server.URL
+}
+
+// Close shuts down the emulator.
+func (a *AWS) Close() {
+	a.server.Close()
+}
+
+// newID returns a new resource ID in the style of AWS, e.g. ami-0000000000000001.
+func (a *AWS) newID(prefix string) string {
+	a.nextID++
+	return fmt.Sprintf("%s-%017x", prefix, a.nextID)
+}
+
+// awsError is an error returned to the client with the given code.
+type awsError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func errorf(status int, code, format string, args ...interface{}) *awsError {
+	return &awsError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (a *AWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := credentialScope.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		writeQueryError(w, errorf(http.StatusForbidden, "MissingAuthenticationToken", "request is not signed"))
+		return
+	}
+	region, service := m[1], m[2]
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch service {
+	case "ec2":
+		a.serveEC2(w, r, region)
+	case "s3":
+		a.serveS3(w, r)
+	case "sts":
+		a.serveSTS(w, r)
+	default:
+		writeQueryError(w, errorf(http.StatusBadRequest, "UnsupportedOperation", "service %s is not emulated", service))
+	}
+}
+
+func (a *AWS) serveSTS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeQueryError(w, errorf(http.StatusBadRequest, "MalformedQueryString", "%v", err))
+		return
+	}
+	action := r.Form.Get("Action")
+	if action != "GetCallerIdentity" {
+		writeQueryError(w, errorf(http.StatusBadRequest, "InvalidAction", "action %s is not emulated", action))
+		return
+	}
+	out := &sts.GetCallerIdentityOutput{
+		Account: aws.String(AccountID),
+		Arn:     aws.String(fmt.Sprintf("arn:aws:iam::%s:user/fakecloud", AccountID)),
+		UserId:  aws.String("AIDAFAKECLOUD"),
+	}
+	writeXML(w, out, action+"Response", action+"Result")
+}
+
+// writeXML writes out, serialized like the SDK expects it, wrapped in the
+// elements names, which may contain attributes.
+func writeXML(w http.ResponseWriter, out interface{}, names ...string) {
+	var body bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&body, "<%s>", name)
+	}
+	if err := xmlutil.BuildXML(out, xml.NewEncoder(&body)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		fmt.Fprintf(&body, "</%s>", strings.Fields(names[i])[0])
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write(body.Bytes())
+}
+
+func writeQueryError(w http.ResponseWriter, err *awsError) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(err.Status)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>%d</RequestId></ErrorResponse>",
+		err.Code, xmlEscape(err.Message), time.Now().UnixNano())
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,1323 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloud
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const ec2Namespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+
+// ec2Region is the EC2 state of a region. Resources are stored as the SDK
+// types returned by the API, without their tags which are kept in tags.
+type ec2Region struct {
+	name string
+
+	reservations   []*ec2.Reservation
+	instances      map[string]*ec2.Instance
+	images         map[string]*ec2.Image
+	snapshots      map[string]*ec2.Snapshot
+	importTasks    map[string]*ec2.ImportSnapshotTask
+	securityGroups map[string]*ec2.SecurityGroup
+	vpcs           map[string]*ec2.Vpc
+	subnets        map[string]*ec2.Subnet
+	routeTables    map[string]*ec2.RouteTable
+	gateways       map[string]*ec2.InternetGateway
+	keyPairs       map[string]*ec2.KeyPairInfo
+
+	launchPermissions       map[string][]*ec2.LaunchPermission
+	createVolumePermissions map[string][]*ec2.CreateVolumePermission
+	tags                    map[string][]*ec2.Tag
+}
+
+func (a *AWS) region(name string) *ec2Region {
+	r, ok := a.regions[name]
+	if !ok {
+		r = &ec2Region{
+			name:                    name,
+			instances:               make(map[string]*ec2.Instance),
+			images:                  make(map[string]*ec2.Image),
+			snapshots:               make(map[string]*ec2.Snapshot),
+			importTasks:             make(map[string]*ec2.ImportSnapshotTask),
+			securityGroups:          make(map[string]*ec2.SecurityGroup),
+			vpcs:                    make(map[string]*ec2.Vpc),
+			subnets:                 make(map[string]*ec2.Subnet),
+			routeTables:             make(map[string]*ec2.RouteTable),
+			gateways:                make(map[string]*ec2.InternetGateway),
+			keyPairs:                make(map[string]*ec2.KeyPairInfo),
+			launchPermissions:       make(map[string][]*ec2.LaunchPermission),
+			createVolumePermissions: make(map[string][]*ec2.CreateVolumePermission),
+			tags:                    make(map[string][]*ec2.Tag),
+		}
+		a.regions[name] = r
+	}
+	return r
+}
+
+// Image returns a copy of image id in region, or nil if it doesn't exist.
+func (a *AWS) Image(region, id string) *ec2.Image {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	image, ok := a.region(region).images[id]
+	if !ok {
+		return nil
+	}
+	c := *image
+	return &c
+}
+
+// InstanceState returns the state of instance id in region, or "" if it
+// doesn't exist.
+func (a *AWS) InstanceState(region, id string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	instance, ok := a.region(region).instances[id]
+	if !ok {
+		return ""
+	}
+	return aws.StringValue(instance.State.Name)
+}
+
+// zones returns the availability zones of the region.
+func (r *ec2Region) zones() []string {
+	return []string{r.name + "a", r.name + "b", r.name + "c"}
+}
+
+// ec2Regions are the regions returned by DescribeRegions.
+var ec2Regions = []string{"ap-northeast-1", "eu-central-1", "eu-west-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2"}
+
+type ec2Handler func(a *AWS, r *ec2Region, form url.Values) (interface{}, error)
+
+// ec2Action adapts a handler taking and returning the SDK types of an
+// action to an ec2Handler.
+func ec2Action[I, O any](fn func(*AWS, *ec2Region, *I) (*O, error)) ec2Handler {
+	return func(a *AWS, r *ec2Region, form url.Values) (interface{}, error) {
+		in := new(I)
+		if err := decodeEC2Query(form, in); err != nil {
+			return nil, errorf(http.StatusBadRequest, "InvalidParameterValue", "%v", err)
+		}
+		return fn(a, r, in)
+	}
+}
+
+var ec2Actions = map[string]ec2Handler{
+	"AssociateRouteTable":           ec2Action((*AWS).associateRouteTable),
+	"AttachInternetGateway":         ec2Action((*AWS).attachInternetGateway),
+	"AuthorizeSecurityGroupIngress": ec2Action((*AWS).authorizeSecurityGroupIngress),
+	"CopyImage":                     ec2Action((*AWS).copyImage),
+	"CreateInternetGateway":         ec2Action((*AWS).createInternetGateway),
+	"CreateRoute":                   ec2Action((*AWS).createRoute),
+	"CreateRouteTable":              ec2Action((*AWS).createRouteTable),
+	"CreateSecurityGroup":           ec2Action((*AWS).createSecurityGroup),
+	"CreateSubnet":                  ec2Action((*AWS).createSubnet),
+	"CreateTags":                    ec2Action((*AWS).createTags),
+	"CreateVpc":                     ec2Action((*AWS).createVpc),
+	"DeleteKeyPair":                 ec2Action((*AWS).deleteKeyPair),
+	"DeleteSecurityGroup":           ec2Action((*AWS).deleteSecurityGroup),
+	"DeleteSnapshot":                ec2Action((*AWS).deleteSnapshot),
+	"DeregisterImage":               ec2Action((*AWS).deregisterImage),
+	"DescribeAvailabilityZones":     ec2Action((*AWS).describeAvailabilityZones),
+	"DescribeImageAttribute":        ec2Action((*AWS).describeImageAttribute),
+	"DescribeImages":                ec2Action((*AWS).describeImages),
+	"DescribeImportSnapshotTasks":   ec2Action((*AWS).describeImportSnapshotTasks),
+	"DescribeInstanceTypeOfferings": ec2Action((*AWS).describeInstanceTypeOfferings),
+	"DescribeInstances":             ec2Action((*AWS).describeInstances),
+	"DescribeRegions":               ec2Action((*AWS).describeRegions),
+	"DescribeSecurityGroups":        ec2Action((*AWS).describeSecurityGroups),
+	"DescribeSnapshotAttribute":     ec2Action((*AWS).describeSnapshotAttribute),
+	"DescribeSnapshots":             ec2Action((*AWS).describeSnapshots),
+	"DescribeSubnets":               ec2Action((*AWS).describeSubnets),
+	"DescribeVpcs":                  ec2Action((*AWS).describeVpcs),
+	"GetConsoleOutput":              ec2Action((*AWS).getConsoleOutput),
+	"ImportKeyPair":                 ec2Action((*AWS).importKeyPair),
+	"ImportSnapshot":                ec2Action((*AWS).importSnapshot),
+	"ModifyImageAttribute":          ec2Action((*AWS).modifyImageAttribute),
+	"ModifySnapshotAttribute":       ec2Action((*AWS).modifySnapshotAttribute),
+	"ModifySubnetAttribute":         ec2Action((*AWS).modifySubnetAttribute),
+	"ModifyVpcAttribute":            ec2Action((*AWS).modifyVpcAttribute),
+	"RegisterImage":                 ec2Action((*AWS).registerImage),
+	"RunInstances":                  ec2Action((*AWS).runInstances),
+	"TerminateInstances":            ec2Action((*AWS).terminateInstances),
+}
+
+func (a *AWS) serveEC2(w http.ResponseWriter, req *http.Request, region string) {
+	if err := req.ParseForm(); err != nil {
+		writeEC2Error(w, errorf(http.StatusBadRequest, "MalformedQueryString", "%v", err))
+		return
+	}
+	action := req.Form.Get("Action")
+	handler, ok := ec2Actions[action]
+	if !ok {
+		writeEC2Error(w, errorf(http.StatusBadRequest, "InvalidAction", "action %s is not emulated", action))
+		return
+	}
+	out, err := handler(a, a.region(region), req.Form)
+	if err != nil {
+		if awsErr, ok := err.(*awsError); ok {
+			writeEC2Error(w, awsErr)
+		} else {
+			writeEC2Error(w, errorf(http.StatusInternalServerError, "InternalError", "%v", err))
+		}
+		return
+	}
+	writeXML(w, out, fmt.Sprintf(`%sResponse xmlns="%s"`, action, ec2Namespace))
+}
+
+func writeEC2Error(w http.ResponseWriter, err *awsError) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(err.Status)
+	fmt.Fprintf(w, "<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>%d</RequestID></Response>",
+		err.Code, xmlEscape(err.Message), time.Now().UnixNano())
+}
+
+func notFound(code, id string) *awsError {
+	return errorf(http.StatusBadRequest, code, "The ID '%s' does not exist", id)
+}
+
+func invalidParameter(format string, args ...interface{}) *awsError {
+	return errorf(http.StatusBadRequest, "InvalidParameterValue", format, args...)
+}
+
+// sortedKeys returns the keys of m in creation order, since IDs are
+// allocated in increasing order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tagged returns the tags of a resource, or nil.
+func (r *ec2Region) tagged(id string) []*ec2.Tag {
+	return r.tags[id]
+}
+
+func (r *ec2Region) setTags(id string, tags []*ec2.Tag) {
+outer:
+	for _, tag := range tags {
+		for _, existing := range r.tags[id] {
+			if aws.StringValue(existing.Key) == aws.StringValue(tag.Key) {
+				existing.Value = tag.Value
+				continue outer
+			}
+		}
+		r.tags[id] = append(r.tags[id], &ec2.Tag{Key: tag.Key, Value: tag.Value})
+	}
+}
+
+// applyTagSpecifications tags id with the tags specified for resourceType.
+func (r *ec2Region) applyTagSpecifications(id, resourceType string, specs []*ec2.TagSpecification) {
+	for _, spec := range specs {
+		if aws.StringValue(spec.ResourceType) == resourceType {
+			r.setTags(id, spec.Tags)
+		}
+	}
+}
+
+// exists returns an error if the resource id does not exist.
+func (r *ec2Region) exists(id string) error {
+	var found bool
+	var code string
+	switch id[:strings.LastIndex(id, "-")+1] {
+	case "ami-":
+		_, found = r.images[id]
+		code = "InvalidAMIID.NotFound"
+	case "snap-":
+		_, found = r.snapshots[id]
+		code = "InvalidSnapshot.NotFound"
+	case "i-":
+		_, found = r.instances[id]
+		code = "InvalidInstanceID.NotFound"
+	case "sg-":
+		_, found = r.securityGroups[id]
+		code = "InvalidGroup.NotFound"
+	case "vpc-":
+		_, found = r.vpcs[id]
+		code = "InvalidVpcID.NotFound"
+	case "subnet-":
+		_, found = r.subnets[id]
+		code = "InvalidSubnetID.NotFound"
+	case "rtb-":
+		_, found = r.routeTables[id]
+		code = "InvalidRouteTableID.NotFound"
+	case "igw-":
+		_, found = r.gateways[id]
+		code = "InvalidInternetGatewayID.NotFound"
+	default:
+		code = "InvalidID"
+	}
+	if !found {
+		return notFound(code, id)
+	}
+	return nil
+}
+
+// matchFilters returns true if the resource matches all filters. values
+// returns the values of the resource for a filter name and false if the
+// filter is not supported; tag:<key> filters are handled here.
+func (r *ec2Region) matchFilters(id string, filters []*ec2.Filter, values func(name string) ([]string, bool)) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		var have []string
+		if strings.HasPrefix(name, "tag:") {
+			for _, tag := range r.tags[id] {
+				if aws.StringValue(tag.Key) == strings.TrimPrefix(name, "tag:") {
+					have = append(have, aws.StringValue(tag.Value))
+				}
+			}
+		} else {
+			var ok bool
+			if have, ok = values(name); !ok {
+				return false, invalidParameter("The filter '%s' is not emulated", name)
+			}
+		}
+		matched := false
+		for _, want := range filter.Values {
+			for _, v := range have {
+				if ok, _ := path.Match(aws.StringValue(want), v); ok {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ownedBySelf returns true if owners is empty or refers to our account.
+func ownedBySelf(owners []*string) bool {
+	for _, owner := range owners {
+		if s := aws.StringValue(owner); s == "self" || s == AccountID {
+			return true
+		}
+	}
+	return len(owners) == 0
+}
+
+// Regions and zones
+
+func (a *AWS) describeRegions(r *ec2Region, in *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error) {
+	out := &ec2.DescribeRegionsOutput{}
+	for _, name := range ec2Regions {
+		ok, err := r.matchFilters(name, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "region-name":
+				return []string{name}, true
+			case "opt-in-status":
+				return []string{"opt-in-not-required"}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Regions = append(out.Regions, &ec2.Region{
+				RegionName:  aws.String(name),
+				Endpoint:    aws.String(fmt.Sprintf("ec2.%s.amazonaws.com", name)),
+				OptInStatus: aws.String("opt-in-not-required"),
+			})
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) describeAvailabilityZones(r *ec2Region, in *ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	out := &ec2.DescribeAvailabilityZonesOutput{}
+	for _, zone := range r.zones() {
+		ok, err := r.matchFilters(zone, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "zone-name":
+				return []string{zone}, true
+			case "zone-type":
+				return []string{"availability-zone"}, true
+			case "region-name":
+				return []string{r.name}, true
+			case "state":
+				return []string{ec2.AvailabilityZoneStateAvailable}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.AvailabilityZones = append(out.AvailabilityZones, &ec2.AvailabilityZone{
+				ZoneName:   aws.String(zone),
+				ZoneType:   aws.String("availability-zone"),
+				RegionName: aws.String(r.name),
+				State:      aws.String(ec2.AvailabilityZoneStateAvailable),
+			})
+		}
+	}
+	return out, nil
+}
+
+// describeInstanceTypeOfferings offers the instance types filtered on, or
+// a few common ones, in all the zones of the region.
+func (a *AWS) describeInstanceTypeOfferings(r *ec2Region, in *ec2.DescribeInstanceTypeOfferingsInput) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	types := []string{"t3.small", "m5.large", "m6g.large"}
+	for _, filter := range in.Filters {
+		if aws.StringValue(filter.Name) == "instance-type" {
+			types = aws.StringValueSlice(filter.Values)
+		}
+	}
+	locations := r.zones()
+	if aws.StringValue(in.LocationType) == ec2.LocationTypeRegion {
+		locations = []string{r.name}
+	}
+	out := &ec2.DescribeInstanceTypeOfferingsOutput{}
+	for _, location := range locations {
+		for _, instanceType := range types {
+			ok, err := r.matchFilters("", in.Filters, func(filter string) ([]string, bool) {
+				switch filter {
+				case "instance-type":
+					return []string{instanceType}, true
+				case "location":
+					return []string{location}, true
+				}
+				return nil, false
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out.InstanceTypeOfferings = append(out.InstanceTypeOfferings, &ec2.InstanceTypeOffering{
+					InstanceType: aws.String(instanceType),
+					Location:     aws.String(location),
+					LocationType: in.LocationType,
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+// Key pairs
+
+func (a *AWS) importKeyPair(r *ec2Region, in *ec2.ImportKeyPairInput) (*ec2.ImportKeyPairOutput, error) {
+	name := aws.StringValue(in.KeyName)
+	if _, ok := r.keyPairs[name]; ok {
+		return nil, errorf(http.StatusBadRequest, "InvalidKeyPair.Duplicate", "The keypair '%s' already exists.", name)
+	}
+	key := &ec2.KeyPairInfo{
+		KeyName:        aws.String(name),
+		KeyPairId:      aws.String(a.newID("key")),
+		KeyFingerprint: aws.String("00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00"),
+	}
+	r.keyPairs[name] = key
+	r.applyTagSpecifications(*key.KeyPairId, ec2.ResourceTypeKeyPair, in.TagSpecifications)
+	return &ec2.ImportKeyPairOutput{
+		KeyName:        key.KeyName,
+		KeyPairId:      key.KeyPairId,
+		KeyFingerprint: key.KeyFingerprint,
+	}, nil
+}
+
+func (a *AWS) deleteKeyPair(r *ec2Region, in *ec2.DeleteKeyPairInput) (*ec2.DeleteKeyPairOutput, error) {
+	delete(r.keyPairs, aws.StringValue(in.KeyName))
+	return &ec2.DeleteKeyPairOutput{}, nil
+}
+
+// Tags
+
+func (a *AWS) createTags(r *ec2Region, in *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	for _, id := range in.Resources {
+		if err := r.exists(aws.StringValue(id)); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range in.Resources {
+		r.setTags(aws.StringValue(id), in.Tags)
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// Snapshots
+
+// importSnapshot creates a snapshot from an S3 object. The import task
+// completes immediately, or is deleted if the object doesn't exist.
+func (a *AWS) importSnapshot(r *ec2Region, in *ec2.ImportSnapshotInput) (*ec2.ImportSnapshotOutput, error) {
+	if in.DiskContainer == nil || in.DiskContainer.UserBucket == nil {
+		return nil, invalidParameter("only imports from S3 are emulated")
+	}
+	bucket := aws.StringValue(in.DiskContainer.UserBucket.S3Bucket)
+	key := aws.StringValue(in.DiskContainer.UserBucket.S3Key)
+	task := &ec2.ImportSnapshotTask{
+		ImportTaskId: aws.String(a.newID("import-snap")),
+		Description:  in.Description,
+		SnapshotTaskDetail: &ec2.SnapshotTaskDetail{
+			Description: in.Description,
+			Format:      in.DiskContainer.Format,
+			UserBucket: &ec2.UserBucketDetails{
+				S3Bucket: aws.String(bucket),
+				S3Key:    aws.String(key),
+			},
+		},
+	}
+	detail := task.SnapshotTaskDetail
+	if obj := a.object(bucket, key); obj == nil {
+		detail.Status = aws.String("deleted")
+		detail.StatusMessage = aws.String(fmt.Sprintf("ClientError: Unable to read s3://%s/%s", bucket, key))
+	} else {
+		const GiB = 1024 * 1024 * 1024
+		snapshot := &ec2.Snapshot{
+			SnapshotId:  aws.String(a.newID("snap")),
+			Description: aws.String(fmt.Sprintf("Created by AWS-VMImport service for %s", *task.ImportTaskId)),
+			OwnerId:     aws.String(AccountID),
+			State:       aws.String(ec2.SnapshotStateCompleted),
+			Progress:    aws.String("100%"),
+			StartTime:   aws.Time(time.Now()),
+			VolumeId:    aws.String("vol-ffffffff"),
+			VolumeSize:  aws.Int64((int64(len(obj.data)) + GiB - 1) / GiB),
+			Encrypted:   aws.Bool(false),
+		}
+		if *snapshot.VolumeSize == 0 {
+			snapshot.VolumeSize = aws.Int64(1)
+		}
+		r.snapshots[*snapshot.SnapshotId] = snapshot
+		detail.Status = aws.String("completed")
+		detail.Progress = aws.String("100")
+		detail.StatusMessage = aws.String("")
+		detail.SnapshotId = snapshot.SnapshotId
+		detail.DiskImageSize = aws.Float64(float64(len(obj.data)))
+	}
+	r.importTasks[*task.ImportTaskId] = task
+	r.applyTagSpecifications(*task.ImportTaskId, ec2.ResourceTypeImportSnapshotTask, in.TagSpecifications)
+	return &ec2.ImportSnapshotOutput{
+		ImportTaskId:       task.ImportTaskId,
+		Description:        task.Description,
+		SnapshotTaskDetail: task.SnapshotTaskDetail,
+	}, nil
+}
+
+func (a *AWS) describeImportSnapshotTasks(r *ec2Region, in *ec2.DescribeImportSnapshotTasksInput) (*ec2.DescribeImportSnapshotTasksOutput, error) {
+	ids := aws.StringValueSlice(in.ImportTaskIds)
+	if len(ids) == 0 {
+		ids = sortedKeys(r.importTasks)
+	}
+	out := &ec2.DescribeImportSnapshotTasksOutput{}
+	for _, id := range ids {
+		task, ok := r.importTasks[id]
+		if !ok {
+			return nil, notFound("InvalidConversionTaskId.Malformed", id)
+		}
+		t := *task
+		t.Tags = r.tagged(id)
+		out.ImportSnapshotTasks = append(out.ImportSnapshotTasks, &t)
+	}
+	return out, nil
+}
+
+func (a *AWS) describeSnapshots(r *ec2Region, in *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	ids := aws.StringValueSlice(in.SnapshotIds)
+	for _, id := range ids {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		ids = sortedKeys(r.snapshots)
+	}
+	out := &ec2.DescribeSnapshotsOutput{}
+	if !ownedBySelf(in.OwnerIds) {
+		return out, nil
+	}
+	for _, id := range ids {
+		snapshot := r.snapshots[id]
+		ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "snapshot-id":
+				return []string{id}, true
+			case "status":
+				return []string{aws.StringValue(snapshot.State)}, true
+			case "owner-id":
+				return []string{AccountID}, true
+			case "description":
+				return []string{aws.StringValue(snapshot.Description)}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s := *snapshot
+			s.Tags = r.tagged(id)
+			out.Snapshots = append(out.Snapshots, &s)
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) describeSnapshotAttribute(r *ec2Region, in *ec2.DescribeSnapshotAttributeInput) (*ec2.DescribeSnapshotAttributeOutput, error) {
+	id := aws.StringValue(in.SnapshotId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	if aws.StringValue(in.Attribute) != ec2.SnapshotAttributeNameCreateVolumePermission {
+		return nil, invalidParameter("attribute %s is not emulated", aws.StringValue(in.Attribute))
+	}
+	return &ec2.DescribeSnapshotAttributeOutput{
+		SnapshotId:              in.SnapshotId,
+		CreateVolumePermissions: r.createVolumePermissions[id],
+	}, nil
+}
+
+func (a *AWS) modifySnapshotAttribute(r *ec2Region, in *ec2.ModifySnapshotAttributeInput) (*ec2.ModifySnapshotAttributeOutput, error) {
+	id := aws.StringValue(in.SnapshotId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	if in.CreateVolumePermission == nil {
+		return nil, invalidParameter("only createVolumePermission modifications are emulated")
+	}
+	perms := r.createVolumePermissions[id]
+	for _, add := range in.CreateVolumePermission.Add {
+		if !hasVolumePermission(perms, add) {
+			perms = append(perms, add)
+		}
+	}
+	var kept []*ec2.CreateVolumePermission
+	for _, perm := range perms {
+		if !hasVolumePermission(in.CreateVolumePermission.Remove, perm) {
+			kept = append(kept, perm)
+		}
+	}
+	r.createVolumePermissions[id] = kept
+	return &ec2.ModifySnapshotAttributeOutput{}, nil
+}
+
+func hasVolumePermission(perms []*ec2.CreateVolumePermission, perm *ec2.CreateVolumePermission) bool {
+	for _, p := range perms {
+		if aws.StringValue(p.Group) == aws.StringValue(perm.Group) && aws.StringValue(p.UserId) == aws.StringValue(perm.UserId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AWS) deleteSnapshot(r *ec2Region, in *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	id := aws.StringValue(in.SnapshotId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	for _, imageID := range sortedKeys(r.images) {
+		if imageSnapshotID(r.images[imageID]) == id {
+			return nil, errorf(http.StatusBadRequest, "InvalidSnapshot.InUse", "The snapshot %s is currently in use by %s", id, imageID)
+		}
+	}
+	delete(r.snapshots, id)
+	delete(r.createVolumePermissions, id)
+	delete(r.tags, id)
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+// Images
+
+func imageSnapshotID(image *ec2.Image) string {
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs != nil {
+			return aws.StringValue(mapping.Ebs.SnapshotId)
+		}
+	}
+	return ""
+}
+
+func (a *AWS) registerImage(r *ec2Region, in *ec2.RegisterImageInput) (*ec2.RegisterImageOutput, error) {
+	name := aws.StringValue(in.Name)
+	if name == "" {
+		return nil, errorf(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter name")
+	}
+	for _, image := range r.images {
+		if aws.StringValue(image.Name) == name {
+			return nil, errorf(http.StatusBadRequest, "InvalidAMIName.Duplicate", "AMI name %s is already in use by AMI %s", name, *image.ImageId)
+		}
+	}
+	for _, mapping := range in.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+			if err := r.exists(*mapping.Ebs.SnapshotId); err != nil {
+				return nil, err
+			}
+		}
+	}
+	image := &ec2.Image{
+		ImageId:             aws.String(a.newID("ami")),
+		Name:                in.Name,
+		Description:         in.Description,
+		Architecture:        in.Architecture,
+		VirtualizationType:  in.VirtualizationType,
+		RootDeviceName:      in.RootDeviceName,
+		RootDeviceType:      aws.String(ec2.DeviceTypeEbs),
+		BlockDeviceMappings: in.BlockDeviceMappings,
+		EnaSupport:          in.EnaSupport,
+		SriovNetSupport:     in.SriovNetSupport,
+		BootMode:            in.BootMode,
+		ImdsSupport:         in.ImdsSupport,
+		ImageType:           aws.String(ec2.ImageTypeValuesMachine),
+		State:               aws.String(ec2.ImageStateAvailable),
+		OwnerId:             aws.String(AccountID),
+		CreationDate:        aws.String(time.Now().UTC().Format(time.RFC3339)),
+		Public:              aws.Bool(false),
+	}
+	r.images[*image.ImageId] = image
+	return &ec2.RegisterImageOutput{ImageId: image.ImageId}, nil
+}
+
+// copyImage copies an image and its snapshot from another region. Like
+// AWS, it doesn't copy tags or permissions and doesn't enforce unique
+// image names.
+func (a *AWS) copyImage(r *ec2Region, in *ec2.CopyImageInput) (*ec2.CopyImageOutput, error) {
+	sourceRegion := a.region(aws.StringValue(in.SourceRegion))
+	sourceID := aws.StringValue(in.SourceImageId)
+	source, ok := sourceRegion.images[sourceID]
+	if !ok {
+		return nil, notFound("InvalidAMIID.NotFound", sourceID)
+	}
+	image := *source
+	image.ImageId = aws.String(a.newID("ami"))
+	image.Name = in.Name
+	image.Description = in.Description
+	image.CreationDate = aws.String(time.Now().UTC().Format(time.RFC3339))
+	image.Public = aws.Bool(false)
+	image.BlockDeviceMappings = nil
+	for _, mapping := range source.BlockDeviceMappings {
+		m := *mapping
+		if mapping.Ebs != nil {
+			ebs := *mapping.Ebs
+			if sourceSnapshot, ok := sourceRegion.snapshots[aws.StringValue(ebs.SnapshotId)]; ok {
+				snapshot := *sourceSnapshot
+				snapshot.SnapshotId = aws.String(a.newID("snap"))
+				snapshot.Description = aws.String(fmt.Sprintf("Copied for DestinationAmi %s from SourceAmi %s for SourceSnapshot %s", *image.ImageId, sourceID, *sourceSnapshot.SnapshotId))
+				snapshot.StartTime = aws.Time(time.Now())
+				r.snapshots[*snapshot.SnapshotId] = &snapshot
+				ebs.SnapshotId = snapshot.SnapshotId
+			}
+			m.Ebs = &ebs
+		}
+		image.BlockDeviceMappings = append(image.BlockDeviceMappings, &m)
+	}
+	r.images[*image.ImageId] = &image
+	return &ec2.CopyImageOutput{ImageId: image.ImageId}, nil
+}
+
+func (a *AWS) describeImages(r *ec2Region, in *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	ids := aws.StringValueSlice(in.ImageIds)
+	for _, id := range ids {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		ids = sortedKeys(r.images)
+	}
+	out := &ec2.DescribeImagesOutput{}
+	if !ownedBySelf(in.Owners) {
+		return out, nil
+	}
+	for _, id := range ids {
+		image := r.images[id]
+		ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "image-id":
+				return []string{id}, true
+			case "name":
+				return []string{aws.StringValue(image.Name)}, true
+			case "state":
+				return []string{aws.StringValue(image.State)}, true
+			case "architecture":
+				return []string{aws.StringValue(image.Architecture)}, true
+			case "owner-id":
+				return []string{AccountID}, true
+			case "is-public":
+				return []string{fmt.Sprint(aws.BoolValue(image.Public))}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			i := *image
+			i.Tags = r.tagged(id)
+			out.Images = append(out.Images, &i)
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) describeImageAttribute(r *ec2Region, in *ec2.DescribeImageAttributeInput) (*ec2.DescribeImageAttributeOutput, error) {
+	id := aws.StringValue(in.ImageId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	out := &ec2.DescribeImageAttributeOutput{ImageId: in.ImageId}
+	switch aws.StringValue(in.Attribute) {
+	case ec2.ImageAttributeNameLaunchPermission:
+		out.LaunchPermissions = r.launchPermissions[id]
+	case ec2.ImageAttributeNameDescription:
+		out.Description = &ec2.AttributeValue{Value: r.images[id].Description}
+	default:
+		return nil, invalidParameter("attribute %s is not emulated", aws.StringValue(in.Attribute))
+	}
+	return out, nil
+}
+
+func (a *AWS) modifyImageAttribute(r *ec2Region, in *ec2.ModifyImageAttributeInput) (*ec2.ModifyImageAttributeOutput, error) {
+	id := aws.StringValue(in.ImageId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	image := r.images[id]
+	if in.Description != nil {
+		image.Description = in.Description.Value
+	}
+	if in.LaunchPermission != nil {
+		perms := r.launchPermissions[id]
+		for _, add := range in.LaunchPermission.Add {
+			if !hasLaunchPermission(perms, add) {
+				perms = append(perms, add)
+			}
+		}
+		var kept []*ec2.LaunchPermission
+		for _, perm := range perms {
+			if !hasLaunchPermission(in.LaunchPermission.Remove, perm) {
+				kept = append(kept, perm)
+			}
+		}
+		r.launchPermissions[id] = kept
+		image.Public = aws.Bool(hasLaunchPermission(kept, &ec2.LaunchPermission{Group: aws.String(ec2.PermissionGroupAll)}))
+	}
+	return &ec2.ModifyImageAttributeOutput{}, nil
+}
+
+func hasLaunchPermission(perms []*ec2.LaunchPermission, perm *ec2.LaunchPermission) bool {
+	for _, p := range perms {
+		if aws.StringValue(p.Group) == aws.StringValue(perm.Group) && aws.StringValue(p.UserId) == aws.StringValue(perm.UserId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AWS) deregisterImage(r *ec2Region, in *ec2.DeregisterImageInput) (*ec2.DeregisterImageOutput, error) {
+	id := aws.StringValue(in.ImageId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	delete(r.images, id)
+	delete(r.launchPermissions, id)
+	delete(r.tags, id)
+	return &ec2.DeregisterImageOutput{}, nil
+}
+
+// Instances
+
+// runInstances starts MaxCount instances, which are running with a public
+// IP address right away.
+func (a *AWS) runInstances(r *ec2Region, in *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	imageID := aws.StringValue(in.ImageId)
+	if err := r.exists(imageID); err != nil {
+		return nil, err
+	}
+	if key := aws.StringValue(in.KeyName); key != "" {
+		if _, ok := r.keyPairs[key]; !ok {
+			return nil, errorf(http.StatusBadRequest, "InvalidKeyPair.NotFound", "The key pair '%s' does not exist", key)
+		}
+	}
+	zone := r.zones()[0]
+	var vpcID *string
+	if in.SubnetId != nil {
+		if err := r.exists(*in.SubnetId); err != nil {
+			return nil, err
+		}
+		subnet := r.subnets[*in.SubnetId]
+		zone = aws.StringValue(subnet.AvailabilityZone)
+		vpcID = subnet.VpcId
+	}
+	var groups []*ec2.GroupIdentifier
+	for _, id := range in.SecurityGroupIds {
+		if err := r.exists(aws.StringValue(id)); err != nil {
+			return nil, err
+		}
+		groups = append(groups, &ec2.GroupIdentifier{GroupId: id, GroupName: r.securityGroups[*id].GroupName})
+	}
+	count := aws.Int64Value(in.MaxCount)
+	if count < aws.Int64Value(in.MinCount) || count < 1 {
+		return nil, invalidParameter("invalid instance count %d", count)
+	}
+	instanceType := aws.StringValue(in.InstanceType)
+	if instanceType == "" {
+		instanceType = "m1.small"
+	}
+
+	reservation := &ec2.Reservation{
+		ReservationId: aws.String(a.newID("r")),
+		OwnerId:       aws.String(AccountID),
+	}
+	for i := int64(0); i < count; i++ {
+		id := a.newID("i")
+		instance := &ec2.Instance{
+			InstanceId:       aws.String(id),
+			ImageId:          in.ImageId,
+			InstanceType:     aws.String(instanceType),
+			KeyName:          in.KeyName,
+			LaunchTime:       aws.Time(time.Now()),
+			Placement:        &ec2.Placement{AvailabilityZone: aws.String(zone)},
+			PrivateIpAddress: aws.String(fmt.Sprintf("172.31.%d.%d", (a.nextID/250)%250, a.nextID%250+2)),
+			PublicIpAddress:  aws.String(fmt.Sprintf("198.51.100.%d", a.nextID%250+2)),
+			State:            &ec2.InstanceState{Code: aws.Int64(16), Name: aws.String(ec2.InstanceStateNameRunning)},
+			SubnetId:         in.SubnetId,
+			VpcId:            vpcID,
+			SecurityGroups:   groups,
+			Architecture:     r.images[imageID].Architecture,
+			RootDeviceName:   r.images[imageID].RootDeviceName,
+			RootDeviceType:   aws.String(ec2.DeviceTypeEbs),
+		}
+		if in.IamInstanceProfile != nil {
+			instance.IamInstanceProfile = &ec2.IamInstanceProfile{
+				Arn: aws.String(fmt.Sprintf("arn:aws:iam::%s:instance-profile/%s", AccountID, aws.StringValue(in.IamInstanceProfile.Name))),
+			}
+		}
+		r.instances[id] = instance
+		r.applyTagSpecifications(id, ec2.ResourceTypeInstance, in.TagSpecifications)
+		reservation.Instances = append(reservation.Instances, instance)
+	}
+	r.reservations = append(r.reservations, reservation)
+
+	out := *reservation
+	out.Instances = nil
+	for _, instance := range reservation.Instances {
+		i := *instance
+		i.Tags = r.tagged(*i.InstanceId)
+		out.Instances = append(out.Instances, &i)
+	}
+	return &out, nil
+}
+
+func (a *AWS) describeInstances(r *ec2Region, in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	ids := make(map[string]bool)
+	for _, id := range aws.StringValueSlice(in.InstanceIds) {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	out := &ec2.DescribeInstancesOutput{}
+	for _, reservation := range r.reservations {
+		res := *reservation
+		res.Instances = nil
+		for _, instance := range reservation.Instances {
+			id := *instance.InstanceId
+			if len(ids) > 0 && !ids[id] {
+				continue
+			}
+			ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+				switch filter {
+				case "instance-id":
+					return []string{id}, true
+				case "image-id":
+					return []string{aws.StringValue(instance.ImageId)}, true
+				case "instance-state-name":
+					return []string{aws.StringValue(instance.State.Name)}, true
+				case "instance-type":
+					return []string{aws.StringValue(instance.InstanceType)}, true
+				}
+				return nil, false
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				i := *instance
+				i.Tags = r.tagged(id)
+				res.Instances = append(res.Instances, &i)
+			}
+		}
+		if len(res.Instances) > 0 {
+			out.Reservations = append(out.Reservations, &res)
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) terminateInstances(r *ec2Region, in *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	for _, id := range in.InstanceIds {
+		if err := r.exists(aws.StringValue(id)); err != nil {
+			return nil, err
+		}
+	}
+	out := &ec2.TerminateInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		instance := r.instances[*id]
+		previous := instance.State
+		instance.State = &ec2.InstanceState{Code: aws.Int64(48), Name: aws.String(ec2.InstanceStateNameTerminated)}
+		instance.PublicIpAddress = nil
+		out.TerminatingInstances = append(out.TerminatingInstances, &ec2.InstanceStateChange{
+			InstanceId:    id,
+			PreviousState: previous,
+			CurrentState:  instance.State,
+		})
+	}
+	return out, nil
+}
+
+// getConsoleOutput always returns an empty console.
+func (a *AWS) getConsoleOutput(r *ec2Region, in *ec2.GetConsoleOutputInput) (*ec2.GetConsoleOutputOutput, error) {
+	if err := r.exists(aws.StringValue(in.InstanceId)); err != nil {
+		return nil, err
+	}
+	return &ec2.GetConsoleOutputOutput{
+		InstanceId: in.InstanceId,
+		Timestamp:  aws.Time(time.Now()),
+	}, nil
+}
+
+// Networking
+
+func (a *AWS) createVpc(r *ec2Region, in *ec2.CreateVpcInput) (*ec2.CreateVpcOutput, error) {
+	vpc := &ec2.Vpc{
+		VpcId:     aws.String(a.newID("vpc")),
+		CidrBlock: in.CidrBlock,
+		State:     aws.String(ec2.VpcStateAvailable),
+		OwnerId:   aws.String(AccountID),
+		IsDefault: aws.Bool(false),
+	}
+	if aws.BoolValue(in.AmazonProvidedIpv6CidrBlock) {
+		vpc.Ipv6CidrBlockAssociationSet = []*ec2.VpcIpv6CidrBlockAssociation{
+			{
+				AssociationId: aws.String(a.newID("vpc-cidr-assoc")),
+				Ipv6CidrBlock: aws.String(fmt.Sprintf("2600:1f14:%x:ff00::/56", a.nextID)),
+				Ipv6CidrBlockState: &ec2.VpcCidrBlockState{
+					State: aws.String(ec2.VpcCidrBlockStateCodeAssociated),
+				},
+			},
+		}
+	}
+	r.vpcs[*vpc.VpcId] = vpc
+	r.applyTagSpecifications(*vpc.VpcId, ec2.ResourceTypeVpc, in.TagSpecifications)
+	return &ec2.CreateVpcOutput{Vpc: vpc}, nil
+}
+
+func (a *AWS) modifyVpcAttribute(r *ec2Region, in *ec2.ModifyVpcAttributeInput) (*ec2.ModifyVpcAttributeOutput, error) {
+	if err := r.exists(aws.StringValue(in.VpcId)); err != nil {
+		return nil, err
+	}
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+func (a *AWS) describeVpcs(r *ec2Region, in *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	ids := aws.StringValueSlice(in.VpcIds)
+	for _, id := range ids {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		ids = sortedKeys(r.vpcs)
+	}
+	out := &ec2.DescribeVpcsOutput{}
+	for _, id := range ids {
+		ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+			if filter == "vpc-id" {
+				return []string{id}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			vpc := *r.vpcs[id]
+			vpc.Tags = r.tagged(id)
+			out.Vpcs = append(out.Vpcs, &vpc)
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) createRouteTable(r *ec2Region, in *ec2.CreateRouteTableInput) (*ec2.CreateRouteTableOutput, error) {
+	vpcID := aws.StringValue(in.VpcId)
+	if err := r.exists(vpcID); err != nil {
+		return nil, err
+	}
+	table := &ec2.RouteTable{
+		RouteTableId: aws.String(a.newID("rtb")),
+		VpcId:        in.VpcId,
+		OwnerId:      aws.String(AccountID),
+		Routes: []*ec2.Route{
+			{
+				DestinationCidrBlock: r.vpcs[vpcID].CidrBlock,
+				GatewayId:            aws.String("local"),
+				State:                aws.String(ec2.RouteStateActive),
+			},
+		},
+	}
+	r.routeTables[*table.RouteTableId] = table
+	r.applyTagSpecifications(*table.RouteTableId, ec2.ResourceTypeRouteTable, in.TagSpecifications)
+	return &ec2.CreateRouteTableOutput{RouteTable: table}, nil
+}
+
+func (a *AWS) createRoute(r *ec2Region, in *ec2.CreateRouteInput) (*ec2.CreateRouteOutput, error) {
+	if err := r.exists(aws.StringValue(in.RouteTableId)); err != nil {
+		return nil, err
+	}
+	if in.GatewayId != nil {
+		if err := r.exists(*in.GatewayId); err != nil {
+			return nil, err
+		}
+	}
+	table := r.routeTables[*in.RouteTableId]
+	table.Routes = append(table.Routes, &ec2.Route{
+		DestinationCidrBlock:     in.DestinationCidrBlock,
+		DestinationIpv6CidrBlock: in.DestinationIpv6CidrBlock,
+		GatewayId:                in.GatewayId,
+		State:                    aws.String(ec2.RouteStateActive),
+	})
+	return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+}
+
+func (a *AWS) associateRouteTable(r *ec2Region, in *ec2.AssociateRouteTableInput) (*ec2.AssociateRouteTableOutput, error) {
+	if err := r.exists(aws.StringValue(in.RouteTableId)); err != nil {
+		return nil, err
+	}
+	if err := r.exists(aws.StringValue(in.SubnetId)); err != nil {
+		return nil, err
+	}
+	association := &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(a.newID("rtbassoc")),
+		RouteTableId:            in.RouteTableId,
+		SubnetId:                in.SubnetId,
+	}
+	table := r.routeTables[*in.RouteTableId]
+	table.Associations = append(table.Associations, association)
+	return &ec2.AssociateRouteTableOutput{AssociationId: association.RouteTableAssociationId}, nil
+}
+
+func (a *AWS) createInternetGateway(r *ec2Region, in *ec2.CreateInternetGatewayInput) (*ec2.CreateInternetGatewayOutput, error) {
+	gateway := &ec2.InternetGateway{
+		InternetGatewayId: aws.String(a.newID("igw")),
+		OwnerId:           aws.String(AccountID),
+	}
+	r.gateways[*gateway.InternetGatewayId] = gateway
+	r.applyTagSpecifications(*gateway.InternetGatewayId, ec2.ResourceTypeInternetGateway, in.TagSpecifications)
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: gateway}, nil
+}
+
+func (a *AWS) attachInternetGateway(r *ec2Region, in *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	if err := r.exists(aws.StringValue(in.InternetGatewayId)); err != nil {
+		return nil, err
+	}
+	if err := r.exists(aws.StringValue(in.VpcId)); err != nil {
+		return nil, err
+	}
+	gateway := r.gateways[*in.InternetGatewayId]
+	gateway.Attachments = append(gateway.Attachments, &ec2.InternetGatewayAttachment{
+		VpcId: in.VpcId,
+		State: aws.String(ec2.AttachmentStatusAttached),
+	})
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (a *AWS) createSubnet(r *ec2Region, in *ec2.CreateSubnetInput) (*ec2.CreateSubnetOutput, error) {
+	if err := r.exists(aws.StringValue(in.VpcId)); err != nil {
+		return nil, err
+	}
+	zone := aws.StringValue(in.AvailabilityZone)
+	if zone == "" {
+		zone = r.zones()[0]
+	}
+	valid := false
+	for _, z := range r.zones() {
+		valid = valid || z == zone
+	}
+	if !valid {
+		return nil, invalidParameter("Value (%s) for parameter availabilityZone is invalid", zone)
+	}
+	subnet := &ec2.Subnet{
+		SubnetId:         aws.String(a.newID("subnet")),
+		VpcId:            in.VpcId,
+		AvailabilityZone: aws.String(zone),
+		CidrBlock:        in.CidrBlock,
+		State:            aws.String(ec2.SubnetStateAvailable),
+		OwnerId:          aws.String(AccountID),
+	}
+	if in.Ipv6CidrBlock != nil {
+		subnet.Ipv6CidrBlockAssociationSet = []*ec2.SubnetIpv6CidrBlockAssociation{
+			{
+				AssociationId: aws.String(a.newID("subnet-cidr-assoc")),
+				Ipv6CidrBlock: in.Ipv6CidrBlock,
+			},
+		}
+	}
+	r.subnets[*subnet.SubnetId] = subnet
+	r.applyTagSpecifications(*subnet.SubnetId, ec2.ResourceTypeSubnet, in.TagSpecifications)
+	return &ec2.CreateSubnetOutput{Subnet: subnet}, nil
+}
+
+func (a *AWS) modifySubnetAttribute(r *ec2Region, in *ec2.ModifySubnetAttributeInput) (*ec2.ModifySubnetAttributeOutput, error) {
+	if err := r.exists(aws.StringValue(in.SubnetId)); err != nil {
+		return nil, err
+	}
+	subnet := r.subnets[*in.SubnetId]
+	if in.MapPublicIpOnLaunch != nil {
+		subnet.MapPublicIpOnLaunch = in.MapPublicIpOnLaunch.Value
+	}
+	if in.AssignIpv6AddressOnCreation != nil {
+		subnet.AssignIpv6AddressOnCreation = in.AssignIpv6AddressOnCreation.Value
+	}
+	return &ec2.ModifySubnetAttributeOutput{}, nil
+}
+
+func (a *AWS) describeSubnets(r *ec2Region, in *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	ids := aws.StringValueSlice(in.SubnetIds)
+	for _, id := range ids {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		ids = sortedKeys(r.subnets)
+	}
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, id := range ids {
+		subnet := r.subnets[id]
+		ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "subnet-id":
+				return []string{id}, true
+			case "vpc-id":
+				return []string{aws.StringValue(subnet.VpcId)}, true
+			case "availability-zone":
+				return []string{aws.StringValue(subnet.AvailabilityZone)}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s := *subnet
+			s.Tags = r.tagged(id)
+			out.Subnets = append(out.Subnets, &s)
+		}
+	}
+	return out, nil
+}
+
+func (a *AWS) createSecurityGroup(r *ec2Region, in *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	if in.VpcId != nil {
+		if err := r.exists(*in.VpcId); err != nil {
+			return nil, err
+		}
+	}
+	name := aws.StringValue(in.GroupName)
+	for _, group := range r.securityGroups {
+		if aws.StringValue(group.GroupName) == name && aws.StringValue(group.VpcId) == aws.StringValue(in.VpcId) {
+			return nil, errorf(http.StatusBadRequest, "InvalidGroup.Duplicate", "The security group '%s' already exists", name)
+		}
+	}
+	group := &ec2.SecurityGroup{
+		GroupId:     aws.String(a.newID("sg")),
+		GroupName:   in.GroupName,
+		Description: in.Description,
+		VpcId:       in.VpcId,
+		OwnerId:     aws.String(AccountID),
+	}
+	r.securityGroups[*group.GroupId] = group
+	r.applyTagSpecifications(*group.GroupId, ec2.ResourceTypeSecurityGroup, in.TagSpecifications)
+	return &ec2.CreateSecurityGroupOutput{GroupId: group.GroupId}, nil
+}
+
+func (a *AWS) authorizeSecurityGroupIngress(r *ec2Region, in *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	if err := r.exists(aws.StringValue(in.GroupId)); err != nil {
+		return nil, err
+	}
+	group := r.securityGroups[*in.GroupId]
+	group.IpPermissions = append(group.IpPermissions, in.IpPermissions...)
+	return &ec2.AuthorizeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (a *AWS) deleteSecurityGroup(r *ec2Region, in *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	id := aws.StringValue(in.GroupId)
+	if err := r.exists(id); err != nil {
+		return nil, err
+	}
+	delete(r.securityGroups, id)
+	delete(r.tags, id)
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (a *AWS) describeSecurityGroups(r *ec2Region, in *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	ids := aws.StringValueSlice(in.GroupIds)
+	for _, id := range ids {
+		if err := r.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		ids = sortedKeys(r.securityGroups)
+	}
+	names := aws.StringValueSlice(in.GroupNames)
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range ids {
+		group := r.securityGroups[id]
+		if len(names) > 0 && !contains(names, aws.StringValue(group.GroupName)) {
+			continue
+		}
+		ok, err := r.matchFilters(id, in.Filters, func(filter string) ([]string, bool) {
+			switch filter {
+			case "group-id":
+				return []string{id}, true
+			case "group-name":
+				return []string{aws.StringValue(group.GroupName)}, true
+			case "vpc-id":
+				return []string{aws.StringValue(group.VpcId)}, true
+			}
+			return nil, false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			g := *group
+			g.Tags = r.tagged(id)
+			out.SecurityGroups = append(out.SecurityGroups, &g)
+		}
+	}
+	return out, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
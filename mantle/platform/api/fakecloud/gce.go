@@ -0,0 +1,481 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// gceLinkPrefix is the prefix of the self links of GCE resources, which
+// are the same as in GCE rather than pointing to the emulator.
+const gceLinkPrefix = "https://www.googleapis.com/compute/v1/"
+
+// GCE is an in-process emulator of the parts of the Compute Engine API used
+// by platform/api/gcloud: projects, licenses, images and their IAM
+// policies, instances and operations. Endpoint can be passed as
+// gcloud.Options.Endpoint. Requests are not authenticated and all
+// operations are done by the time they are returned.
+type GCE struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	projects map[string]*gceProject
+	nextID   uint64
+}
+
+type gceProject struct {
+	images     map[string]*compute.Image
+	policies   map[string]*compute.Policy
+	instances  map[string]*compute.Instance // by zone/name
+	operations map[string]*compute.Operation
+}
+
+// NewGCE starts a GCE emulator. It must be closed with Close.
+func NewGCE() *GCE {
+	g := &GCE{projects: make(map[string]*gceProject)}
+	g.server = httptest.NewServer(g)
+	return g
+}
+
+// Endpoint returns the base URL of the Compute Engine API of the emulator.
+func (g *GCE) Endpoint() string {
+	return g.server.URL + "/compute/v1/"
+}
+
+// Close shuts down the emulator.
+func (g *GCE) Close() {
+	g.server.Close()
+}
+
+// ServiceAccount returns the default service account of project.
+func ServiceAccount(project string) string {
+	return fmt.Sprintf("%s-compute@developer.gserviceaccount.com", project)
+}
+
+func (g *GCE) project(name string) *gceProject {
+	p, ok := g.projects[name]
+	if !ok {
+		p = &gceProject{
+			images:     make(map[string]*compute.Image),
+			policies:   make(map[string]*compute.Policy),
+			instances:  make(map[string]*compute.Instance),
+			operations: make(map[string]*compute.Operation),
+		}
+		g.projects[name] = p
+	}
+	return p
+}
+
+// gceError is an error returned to the client, with the reason GCE uses
+// for it such as notFound.
+type gceError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *gceError) Error() string {
+	return e.Message
+}
+
+func gceErrorf(code int, reason, format string, args ...interface{}) *gceError {
+	return &gceError{Code: code, Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+func gceNotFound(kind, link string) *gceError {
+	return gceErrorf(http.StatusNotFound, "notFound", "The resource '%s' of type %s was not found", strings.TrimPrefix(link, gceLinkPrefix), kind)
+}
+
+func (g *GCE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	out, err := g.route(r)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(err.Code)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    err.Code,
+				"message": err.Message,
+				"errors": []map[string]string{
+					{"reason": err.Reason, "message": err.Message},
+				},
+			},
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (g *GCE) route(r *http.Request) (interface{}, *gceError) {
+	path := strings.TrimPrefix(r.URL.Path, "/compute/v1/")
+	seg := strings.Split(path, "/")
+	if len(seg) < 2 || seg[0] != "projects" {
+		return nil, gceErrorf(http.StatusNotFound, "notFound", "unknown path %s", r.URL.Path)
+	}
+	name := seg[1]
+	p := g.project(name)
+	link := gceLinkPrefix + path
+	method := r.Method
+	// requests are identified by their path without the project
+	switch route := strings.Join(seg[2:], "/"); {
+	case route == "" && method == http.MethodGet:
+		return &compute.Project{
+			Name:                  name,
+			Kind:                  "compute#project",
+			DefaultServiceAccount: ServiceAccount(name),
+			SelfLink:              link,
+		}, nil
+	case len(seg) == 5 && seg[2] == "global" && seg[3] == "licenses" && method == http.MethodGet:
+		return &compute.License{Name: seg[4], Kind: "compute#license", SelfLink: link}, nil
+	case route == "global/images" && method == http.MethodGet:
+		return g.listImages(p, r)
+	case route == "global/images" && method == http.MethodPost:
+		return g.insertImage(p, name, r)
+	case len(seg) >= 5 && seg[2] == "global" && seg[3] == "images":
+		image, ok := p.images[seg[4]]
+		if !ok {
+			return nil, gceNotFound("image", gceLinkPrefix+strings.Join(seg[:5], "/"))
+		}
+		switch {
+		case len(seg) == 5 && method == http.MethodGet:
+			return image, nil
+		case len(seg) == 5 && method == http.MethodDelete:
+			delete(p.images, image.Name)
+			delete(p.policies, image.Name)
+			return g.operation(p, "", "delete", image.Id, image.SelfLink), nil
+		case len(seg) == 5 && method == http.MethodPatch:
+			if err := patch(image, r); err != nil {
+				return nil, err
+			}
+			return g.operation(p, "", "patch", image.Id, image.SelfLink), nil
+		case len(seg) == 6 && seg[5] == "deprecate" && method == http.MethodPost:
+			var status compute.DeprecationStatus
+			if err := decodeBody(r, &status); err != nil {
+				return nil, err
+			}
+			image.Deprecated = &status
+			return g.operation(p, "", "deprecate", image.Id, image.SelfLink), nil
+		case len(seg) == 6 && seg[5] == "getIamPolicy" && method == http.MethodGet:
+			if policy, ok := p.policies[image.Name]; ok {
+				return policy, nil
+			}
+			return &compute.Policy{Etag: "ACAB", Version: 1}, nil
+		case len(seg) == 6 && seg[5] == "setIamPolicy" && method == http.MethodPost:
+			var req compute.GlobalSetPolicyRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			if req.Policy == nil {
+				return nil, gceErrorf(http.StatusBadRequest, "invalid", "policy is required")
+			}
+			p.policies[image.Name] = req.Policy
+			return req.Policy, nil
+		}
+	case route == "global/operations" && method == http.MethodGet:
+		return g.listOperations(p, r)
+	case len(seg) == 5 && seg[2] == "global" && seg[3] == "operations" && method == http.MethodGet:
+		if op, ok := p.operations[seg[4]]; ok && op.Zone == "" {
+			return op, nil
+		}
+		return nil, gceNotFound("operation", link)
+	case len(seg) == 6 && seg[2] == "zones" && seg[4] == "operations" && method == http.MethodGet:
+		if op, ok := p.operations[seg[5]]; ok && strings.HasSuffix(op.Zone, "/zones/"+seg[3]) {
+			return op, nil
+		}
+		return nil, gceNotFound("operation", link)
+	case len(seg) == 5 && seg[2] == "zones" && seg[4] == "instances" && method == http.MethodGet:
+		return g.listInstances(p, seg[3], r)
+	case len(seg) == 5 && seg[2] == "zones" && seg[4] == "instances" && method == http.MethodPost:
+		return g.insertInstance(p, name, seg[3], r)
+	case len(seg) >= 6 && seg[2] == "zones" && seg[4] == "instances":
+		key := seg[3] + "/" + seg[5]
+		instance, ok := p.instances[key]
+		if !ok {
+			return nil, gceNotFound("instance", gceLinkPrefix+strings.Join(seg[:6], "/"))
+		}
+		switch {
+		case len(seg) == 6 && method == http.MethodGet:
+			return instance, nil
+		case len(seg) == 6 && method == http.MethodDelete:
+			delete(p.instances, key)
+			return g.operation(p, instance.Zone, "delete", instance.Id, instance.SelfLink), nil
+		case len(seg) == 7 && seg[6] == "serialPort" && method == http.MethodGet:
+			return &compute.SerialPortOutput{Kind: "compute#serialPortOutput", SelfLink: link}, nil
+		}
+	}
+	return nil, gceErrorf(http.StatusBadRequest, "invalid", "%s %s is not emulated", method, r.URL.Path)
+}
+
+func decodeBody(r *http.Request, v interface{}) *gceError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return gceErrorf(http.StatusBadRequest, "parseError", "%v", err)
+	}
+	return nil
+}
+
+// patch merges the JSON body of r into v.
+func patch(v interface{}, r *http.Request) *gceError {
+	var fields map[string]json.RawMessage
+	if err := decodeBody(r, &fields); err != nil {
+		return err
+	}
+	current, err := json.Marshal(v)
+	if err != nil {
+		return gceErrorf(http.StatusInternalServerError, "backendError", "%v", err)
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return gceErrorf(http.StatusInternalServerError, "backendError", "%v", err)
+	}
+	for k, field := range fields {
+		merged[k] = field
+	}
+	buf, err := json.Marshal(merged)
+	if err != nil {
+		return gceErrorf(http.StatusInternalServerError, "backendError", "%v", err)
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return gceErrorf(http.StatusBadRequest, "invalid", "%v", err)
+	}
+	return nil
+}
+
+// operation records a completed operation on a resource. zone is the link
+// of the zone of zonal operations.
+func (g *GCE) operation(p *gceProject, zone, operationType string, targetID uint64, targetLink string) *compute.Operation {
+	g.nextID++
+	name := fmt.Sprintf("operation-%d", g.nextID)
+	project := strings.Split(strings.TrimPrefix(targetLink, gceLinkPrefix), "/")[1]
+	link := gceLinkPrefix + "projects/" + project + "/global/operations/" + name
+	if zone != "" {
+		link = zone + "/operations/" + name
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	op := &compute.Operation{
+		Kind:          "compute#operation",
+		Id:            g.nextID,
+		Name:          name,
+		OperationType: operationType,
+		TargetId:      targetID,
+		TargetLink:    targetLink,
+		Zone:          zone,
+		Status:        "DONE",
+		Progress:      100,
+		InsertTime:    now,
+		StartTime:     now,
+		EndTime:       now,
+		SelfLink:      link,
+	}
+	p.operations[name] = op
+	return op
+}
+
+// filterClause matches the clauses of a list filter in the "field eq regexp"
+// syntax used by platform/api/gcloud, e.g. "(targetId eq 1) (operationType eq insert)".
+var filterClause = regexp.MustCompile(`(\w+)\s+(eq|ne)\s+([^()\s]+)`)
+
+// matchesFilter returns true if resource matches the filter of r.
+func matchesFilter(resource interface{}, r *http.Request) (bool, *gceError) {
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		return true, nil
+	}
+	clauses := filterClause.FindAllStringSubmatch(filter, -1)
+	if len(clauses) == 0 {
+		return false, gceErrorf(http.StatusBadRequest, "invalid", "filter %q is not emulated", filter)
+	}
+	buf, err := json.Marshal(resource)
+	if err != nil {
+		return false, gceErrorf(http.StatusInternalServerError, "backendError", "%v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return false, gceErrorf(http.StatusInternalServerError, "backendError", "%v", err)
+	}
+	for _, clause := range clauses {
+		re, err := regexp.Compile("^(?:" + clause[3] + ")$")
+		if err != nil {
+			return false, gceErrorf(http.StatusBadRequest, "invalid", "%v", err)
+		}
+		value := ""
+		if v, ok := fields[clause[1]]; ok {
+			value = fmt.Sprint(v)
+		}
+		if re.MatchString(value) != (clause[2] == "eq") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (g *GCE) listImages(p *gceProject, r *http.Request) (*compute.ImageList, *gceError) {
+	list := &compute.ImageList{Kind: "compute#imageList"}
+	for _, name := range sortedKeys(p.images) {
+		ok, err := matchesFilter(p.images[name], r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list.Items = append(list.Items, p.images[name])
+		}
+	}
+	return list, nil
+}
+
+func (g *GCE) insertImage(p *gceProject, project string, r *http.Request) (*compute.Operation, *gceError) {
+	var image compute.Image
+	if err := decodeBody(r, &image); err != nil {
+		return nil, err
+	}
+	if image.Name == "" {
+		return nil, gceErrorf(http.StatusBadRequest, "required", "Required field 'resource.name' not specified")
+	}
+	link := gceLinkPrefix + "projects/" + project + "/global/images/" + image.Name
+	if _, ok := p.images[image.Name]; ok {
+		return nil, gceErrorf(http.StatusConflict, "alreadyExists", "The resource '%s' already exists", strings.TrimPrefix(link, gceLinkPrefix))
+	}
+	if image.RawDisk == nil && image.SourceImage == "" && image.SourceDisk == "" {
+		return nil, gceErrorf(http.StatusBadRequest, "invalid", "Invalid value for field 'resource': the image has no source")
+	}
+	g.nextID++
+	image.Kind = "compute#image"
+	image.Id = g.nextID
+	image.Status = "READY"
+	image.CreationTimestamp = time.Now().UTC().Format(time.RFC3339)
+	image.SelfLink = link
+	p.images[image.Name] = &image
+	return g.operation(p, "", "insert", image.Id, link), nil
+}
+
+func (g *GCE) listOperations(p *gceProject, r *http.Request) (*compute.OperationList, *gceError) {
+	list := &compute.OperationList{Kind: "compute#operationList"}
+	for _, name := range sortedKeys(p.operations) {
+		op := p.operations[name]
+		if op.Zone != "" {
+			continue
+		}
+		ok, err := matchesFilter(op, r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list.Items = append(list.Items, op)
+		}
+	}
+	return list, nil
+}
+
+func (g *GCE) listInstances(p *gceProject, zone string, r *http.Request) (*compute.InstanceList, *gceError) {
+	list := &compute.InstanceList{Kind: "compute#instanceList"}
+	for _, key := range sortedKeys(p.instances) {
+		if !strings.HasPrefix(key, zone+"/") {
+			continue
+		}
+		ok, err := matchesFilter(p.instances[key], r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list.Items = append(list.Items, p.instances[key])
+		}
+	}
+	return list, nil
+}
+
+// sourceImage returns the image referred to by a link like
+// [https://www.googleapis.com/compute/v1/]projects/p/global/images/name
+// or .../global/images/family/name.
+func (g *GCE) sourceImage(link string) (*compute.Image, *gceError) {
+	path := link[strings.Index(link, "projects/")+1:]
+	seg := strings.Split("p"+path, "/")
+	if len(seg) < 5 || seg[2] != "global" || seg[3] != "images" {
+		return nil, gceErrorf(http.StatusBadRequest, "invalid", "Invalid value for field 'sourceImage': '%s'", link)
+	}
+	p, ok := g.projects[seg[1]]
+	if !ok {
+		return nil, gceNotFound("image", link)
+	}
+	if len(seg) == 6 && seg[4] == "family" {
+		var latest *compute.Image
+		for _, name := range sortedKeys(p.images) {
+			image := p.images[name]
+			if image.Family == seg[5] && image.Deprecated == nil &&
+				(latest == nil || image.CreationTimestamp >= latest.CreationTimestamp) {
+				latest = image
+			}
+		}
+		if latest == nil {
+			return nil, gceNotFound("image", link)
+		}
+		return latest, nil
+	}
+	image, ok := p.images[seg[4]]
+	if !ok {
+		return nil, gceNotFound("image", link)
+	}
+	return image, nil
+}
+
+func (g *GCE) insertInstance(p *gceProject, project, zone string, r *http.Request) (*compute.Operation, *gceError) {
+	var instance compute.Instance
+	if err := decodeBody(r, &instance); err != nil {
+		return nil, err
+	}
+	if instance.Name == "" {
+		return nil, gceErrorf(http.StatusBadRequest, "required", "Required field 'resource.name' not specified")
+	}
+	zoneLink := gceLinkPrefix + "projects/" + project + "/zones/" + zone
+	link := zoneLink + "/instances/" + instance.Name
+	key := zone + "/" + instance.Name
+	if _, ok := p.instances[key]; ok {
+		return nil, gceErrorf(http.StatusConflict, "alreadyExists", "The resource '%s' already exists", strings.TrimPrefix(link, gceLinkPrefix))
+	}
+	for _, disk := range instance.Disks {
+		if disk.InitializeParams != nil && disk.InitializeParams.SourceImage != "" {
+			if _, err := g.sourceImage(disk.InitializeParams.SourceImage); err != nil {
+				return nil, err
+			}
+		}
+	}
+	g.nextID++
+	instance.Kind = "compute#instance"
+	instance.Id = g.nextID
+	instance.Status = "RUNNING"
+	instance.Zone = zoneLink
+	instance.CreationTimestamp = time.Now().UTC().Format(time.RFC3339)
+	instance.SelfLink = link
+	for i, iface := range instance.NetworkInterfaces {
+		iface.Name = fmt.Sprintf("nic%d", i)
+		iface.NetworkIP = fmt.Sprintf("10.128.%d.%d", (g.nextID/250)%250, g.nextID%250+2)
+		for _, config := range iface.AccessConfigs {
+			if config.Type == "ONE_TO_ONE_NAT" {
+				config.NatIP = fmt.Sprintf("203.0.113.%d", g.nextID%250+2)
+			}
+		}
+	}
+	p.instances[key] = &instance
+	return g.operation(p, zoneLink, "insert", instance.Id, link), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloud
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeEC2Query fills the SDK input struct v from the form of an EC2 query
+// request. It is the inverse of the SDK's queryutil.Parse for EC2.
+func decodeEC2Query(form url.Values, v interface{}) error {
+	return decodeQueryValue(form, reflect.ValueOf(v).Elem(), "", "")
+}
+
+// hasQueryPrefix returns true if any key of form is prefix or starts with
+// prefix followed by a dot.
+func hasQueryPrefix(form url.Values, prefix string) bool {
+	for k := range form {
+		if k == prefix || strings.HasPrefix(k, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeQueryValue(form url.Values, v reflect.Value, prefix string, tag reflect.StructTag) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		if !hasQueryPrefix(form, prefix) {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return decodeQueryValue(form, v.Elem(), prefix, tag)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("ignore") != "" || field.Tag.Get("location") != "" {
+				continue
+			}
+			name := field.Tag.Get("queryName")
+			if name == "" {
+				if locName := field.Tag.Get("locationName"); locName != "" {
+					name = strings.ToUpper(locName[0:1]) + locName[1:]
+				} else {
+					name = field.Name
+				}
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if err := decodeQueryValue(form, v.Field(i), name, field.Tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		// EC2 lists are always flattened: Name.1, Name.2, ...
+		for n := 1; hasQueryPrefix(form, prefix+"."+strconv.Itoa(n)); n++ {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeQueryValue(form, elem, prefix+"."+strconv.Itoa(n), ""); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+		return nil
+	}
+
+	s := form.Get(prefix)
+	switch value := v.Addr().Interface().(type) {
+	case *string:
+		*value = s
+	case *[]byte:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		*value = b
+	case *bool:
+		*value = s == "true"
+	case *int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		*value = i
+	case *float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		*value = f
+	case *time.Time:
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		*value = ts
+	default:
+		return fmt.Errorf("%s: unsupported type %s", prefix, t)
+	}
+	return nil
+}
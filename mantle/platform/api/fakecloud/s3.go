@@ -0,0 +1,309 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloud
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type s3Object struct {
+	data         []byte
+	etag         string
+	contentType  string
+	cacheControl string
+	acl          string
+	modified     time.Time
+}
+
+type s3Bucket struct {
+	created time.Time
+	objects map[string]*s3Object
+}
+
+type s3Upload struct {
+	bucket, key string
+	template    s3Object
+	parts       map[int64][]byte
+}
+
+// object returns the object key of bucket, or nil.
+func (a *AWS) object(bucket, key string) *s3Object {
+	if b, ok := a.buckets[bucket]; ok {
+		return b.objects[key]
+	}
+	return nil
+}
+
+func newS3Object(data []byte, header http.Header) *s3Object {
+	sum := md5.Sum(data)
+	return &s3Object{
+		data:         data,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		contentType:  header.Get("Content-Type"),
+		cacheControl: header.Get("Cache-Control"),
+		acl:          header.Get("X-Amz-Acl"),
+		modified:     time.Now().UTC(),
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, err *awsError) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(err.Status)
+	// HEAD responses have no body; the SDK derives the code from the status
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message><Resource>%s</Resource></Error>",
+			err.Code, xmlEscape(err.Message), xmlEscape(r.URL.Path))
+	}
+}
+
+// serveS3 handles path-style S3 requests.
+func (a *AWS) serveS3(w http.ResponseWriter, r *http.Request) {
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	if bucketName == "" {
+		writeS3Error(w, r, errorf(http.StatusNotImplemented, "NotImplemented", "listing buckets is not emulated"))
+		return
+	}
+	bucket, ok := a.buckets[bucketName]
+	if !ok && !(key == "" && r.Method == http.MethodPut) {
+		writeS3Error(w, r, errorf(http.StatusNotFound, "NoSuchBucket", "The specified bucket %s does not exist", bucketName))
+		return
+	}
+
+	var err *awsError
+	if key == "" {
+		switch r.Method {
+		case http.MethodPut:
+			err = a.createBucket(bucketName)
+		case http.MethodHead:
+		case http.MethodGet:
+			err = listObjects(w, bucketName, bucket, query)
+		case http.MethodDelete:
+			err = a.deleteBucket(w, bucketName, bucket)
+		default:
+			err = errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "method %s is not allowed", r.Method)
+		}
+	} else {
+		switch {
+		case r.Method == http.MethodPut && query.Has("uploadId"):
+			err = a.uploadPart(w, r, query)
+		case r.Method == http.MethodPut && query.Has("acl"):
+			err = putObjectAcl(r, bucket, key)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			err = a.copyObject(w, r, bucket, key)
+		case r.Method == http.MethodPut:
+			err = putObject(w, r, bucket, key)
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			err = a.createMultipartUpload(w, r, bucketName, key)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			err = a.completeMultipartUpload(w, r, bucket, query)
+		case r.Method == http.MethodDelete && query.Has("uploadId"):
+			delete(a.uploads, query.Get("uploadId"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			delete(bucket.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			err = getObject(w, r, bucket, key)
+		default:
+			err = errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "method %s is not allowed", r.Method)
+		}
+	}
+	if err != nil {
+		writeS3Error(w, r, err)
+	}
+}
+
+func (a *AWS) createBucket(name string) *awsError {
+	if _, ok := a.buckets[name]; ok {
+		return errorf(http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+	}
+	a.buckets[name] = &s3Bucket{created: time.Now().UTC(), objects: make(map[string]*s3Object)}
+	return nil
+}
+
+func (a *AWS) deleteBucket(w http.ResponseWriter, name string, bucket *s3Bucket) *awsError {
+	if len(bucket.objects) > 0 {
+		return errorf(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+	}
+	delete(a.buckets, name)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func listObjects(w http.ResponseWriter, name string, bucket *s3Bucket, query url.Values) *awsError {
+	out := &s3.ListObjectsOutput{
+		Name:        aws.String(name),
+		Prefix:      aws.String(query.Get("prefix")),
+		IsTruncated: aws.Bool(false),
+	}
+	for _, key := range sortedKeys(bucket.objects) {
+		if !strings.HasPrefix(key, query.Get("prefix")) {
+			continue
+		}
+		obj := bucket.objects[key]
+		out.Contents = append(out.Contents, &s3.Object{
+			Key:          aws.String(key),
+			ETag:         aws.String(obj.etag),
+			Size:         aws.Int64(int64(len(obj.data))),
+			LastModified: aws.Time(obj.modified),
+			StorageClass: aws.String(s3.ObjectStorageClassStandard),
+		})
+	}
+	writeXML(w, out, "ListBucketResult")
+	return nil
+}
+
+func putObject(w http.ResponseWriter, r *http.Request, bucket *s3Bucket, key string) *awsError {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "IncompleteBody", "%v", err)
+	}
+	obj := newS3Object(data, r.Header)
+	bucket.objects[key] = obj
+	w.Header().Set("ETag", obj.etag)
+	return nil
+}
+
+func putObjectAcl(r *http.Request, bucket *s3Bucket, key string) *awsError {
+	obj, ok := bucket.objects[key]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	}
+	obj.acl = r.Header.Get("X-Amz-Acl")
+	return nil
+}
+
+func (a *AWS) copyObject(w http.ResponseWriter, r *http.Request, bucket *s3Bucket, key string) *awsError {
+	source, err := url.QueryUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "%v", err)
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	src := a.object(sourceBucket, sourceKey)
+	if src == nil {
+		return errorf(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	}
+	obj := *src
+	obj.modified = time.Now().UTC()
+	obj.acl = r.Header.Get("X-Amz-Acl")
+	bucket.objects[key] = &obj
+	writeXML(w, &s3.CopyObjectResult{
+		ETag:         aws.String(obj.etag),
+		LastModified: aws.Time(obj.modified),
+	}, "CopyObjectResult")
+	return nil
+}
+
+func getObject(w http.ResponseWriter, r *http.Request, bucket *s3Bucket, key string) *awsError {
+	obj, ok := bucket.objects[key]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	}
+	w.Header().Set("ETag", obj.etag)
+	if obj.contentType != "" {
+		w.Header().Set("Content-Type", obj.contentType)
+	}
+	if obj.cacheControl != "" {
+		w.Header().Set("Cache-Control", obj.cacheControl)
+	}
+	http.ServeContent(w, r, "", obj.modified, bytes.NewReader(obj.data))
+	return nil
+}
+
+func (a *AWS) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) *awsError {
+	id := a.newID("upload")
+	a.uploads[id] = &s3Upload{
+		bucket:   bucketName,
+		key:      key,
+		template: *newS3Object(nil, r.Header),
+		parts:    make(map[int64][]byte),
+	}
+	writeXML(w, &s3.CreateMultipartUploadOutput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(id),
+	}, "InitiateMultipartUploadResult")
+	return nil
+}
+
+func (a *AWS) uploadPart(w http.ResponseWriter, r *http.Request, query url.Values) *awsError {
+	upload, ok := a.uploads[query.Get("uploadId")]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+	}
+	part, err := strconv.ParseInt(query.Get("partNumber"), 10, 64)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "InvalidArgument", "invalid part number: %v", err)
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "IncompleteBody", "%v", err)
+	}
+	upload.parts[part] = data
+	w.Header().Set("ETag", newS3Object(data, r.Header).etag)
+	return nil
+}
+
+func (a *AWS) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket *s3Bucket, query url.Values) *awsError {
+	id := query.Get("uploadId")
+	upload, ok := a.uploads[id]
+	if !ok {
+		return errorf(http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+	}
+	var body struct {
+		Parts []struct {
+			PartNumber int64
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&body); err != nil {
+		return errorf(http.StatusBadRequest, "MalformedXML", "%v", err)
+	}
+	sort.Slice(body.Parts, func(i, j int) bool { return body.Parts[i].PartNumber < body.Parts[j].PartNumber })
+	var data []byte
+	for _, part := range body.Parts {
+		partData, ok := upload.parts[part.PartNumber]
+		if !ok {
+			return errorf(http.StatusBadRequest, "InvalidPart", "part %d was not uploaded", part.PartNumber)
+		}
+		data = append(data, partData...)
+	}
+	obj := newS3Object(data, nil)
+	obj.contentType = upload.template.contentType
+	obj.cacheControl = upload.template.cacheControl
+	obj.acl = upload.template.acl
+	bucket.objects[upload.key] = obj
+	delete(a.uploads, id)
+	writeXML(w, &s3.CompleteMultipartUploadOutput{
+		Bucket: aws.String(upload.bucket),
+		Key:    aws.String(upload.key),
+		ETag:   aws.String(obj.etag),
+	}, "CompleteMultipartUploadResult")
+	return nil
+}
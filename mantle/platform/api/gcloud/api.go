@@ -43,6 +43,10 @@ type Options struct {
 	JSONKeyFile  string
 	ServiceAuth  bool
 	Confidential bool
+	// Endpoint overrides the Compute Engine API endpoint, e.g. to talk to
+	// an emulator such as the one in platform/api/fakecloud. Requests are
+	// not authenticated unless JSONKeyFile or ServiceAuth is set.
+	Endpoint string
 	*platform.Options
 }
 
@@ -68,6 +72,8 @@ func New(opts *Options) (*API, error) {
 
 	if opts.ServiceAuth {
 		client = auth.GoogleServiceClient()
+	} else if opts.Endpoint != "" && opts.JSONKeyFile == "" {
+		client = http.DefaultClient
 	} else {
 		client, err = auth.GoogleClientFromKeyFile(opts.JSONKeyFile)
 		if err != nil {
@@ -78,7 +84,11 @@ func New(opts *Options) (*API, error) {
 
 	ctx := context.Background()
 
-	computeService, err := compute.NewService(ctx, option.WithHTTPClient(client))
+	clientOpts := []option.ClientOption{option.WithHTTPClient(client)}
+	if opts.Endpoint != "" {
+		clientOpts = append(clientOpts, option.WithEndpoint(opts.Endpoint))
+	}
+	computeService, err := compute.NewService(ctx, clientOpts...)
 	if err != nil {
 		return nil, err
 	}
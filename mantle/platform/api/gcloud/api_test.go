@@ -0,0 +1,193 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcloud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/fakecloud"
+)
+
+func newTestAPI(t *testing.T, endpoint, image string) *API {
+	api, err := New(&Options{
+		Options:     &platform.Options{BaseName: "kola"},
+		Image:       image,
+		Project:     "project",
+		Zone:        "us-central1-a",
+		MachineType: "n1-standard-1",
+		DiskType:    "pd-ssd",
+		Network:     "default",
+		Endpoint:    endpoint,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestImages(t *testing.T) {
+	fake := fakecloud.NewGCE()
+	defer fake.Close()
+	api := newTestAPI(t, fake.Endpoint(), "")
+	if api.options.ServiceAcct != fakecloud.ServiceAccount("project") {
+		t.Errorf("unexpected service account %q", api.options.ServiceAcct)
+	}
+
+	spec := &ImageSpec{
+		Architecture: "x86_64",
+		Family:       "fedora-coreos-testing",
+		Name:         "fedora-coreos-1-gcp-x86-64",
+		SourceImage:  "https://storage.googleapis.com/bucket/image.tar.gz",
+		Licenses:     []string{"fedora-coreos-testing"},
+	}
+	for _, overwrite := range []bool{false, true} {
+		// CreateImage normalizes the architecture in place
+		spec.Architecture = "x86_64"
+		_, pending, err := api.CreateImage(spec, overwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pending.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spec.Architecture = "x86_64"
+	if _, _, err := api.CreateImage(spec, false); err == nil {
+		t.Error("expected an error creating an existing image")
+	}
+
+	images, err := api.ListImages(context.Background(), "fedora-coreos-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || len(images[0].Licenses) != 1 || images[0].Architecture != "X86_64" {
+		t.Fatalf("unexpected images %v", images)
+	}
+	if images, err := api.ListImages(context.Background(), "", "fedora-coreos"); err != nil || len(images) != 0 {
+		t.Errorf("expected no image in family fedora-coreos, got %v, %v", images, err)
+	}
+	pending, err := api.GetPendingForImage(images[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pending.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, do := range []func() (*Pending, error){
+		func() (*Pending, error) {
+			return api.UpdateImage(spec.Name, "fedora-coreos-stable", "an image")
+		},
+		func() (*Pending, error) {
+			return api.DeprecateImage(spec.Name, DeprecationStateDeprecated, "fedora-coreos-2-gcp-x86-64")
+		},
+	} {
+		pending, err := do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pending.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image, err := api.compute.Images.Get("project", spec.Name).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Family != "fedora-coreos-stable" || image.Description != "an image" {
+		t.Errorf("image was not updated: %v", image)
+	}
+	if image.Deprecated == nil || image.Deprecated.State != string(DeprecationStateDeprecated) {
+		t.Errorf("image was not deprecated: %v", image.Deprecated)
+	}
+
+	if err := api.SetImagePublic(spec.Name); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := api.compute.Images.GetIamPolicy("project", spec.Name).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Bindings) != 1 || policy.Bindings[0].Members[0] != "allAuthenticatedUsers" {
+		t.Errorf("image is not public: %v", policy.Bindings)
+	}
+
+	if pending, err = api.DeleteImage(spec.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := pending.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.DeleteImage(spec.Name); err == nil {
+		t.Error("expected an error deleting a missing image")
+	}
+}
+
+func TestInstancesAndGC(t *testing.T) {
+	fake := fakecloud.NewGCE()
+	defer fake.Close()
+	api := newTestAPI(t, fake.Endpoint(), "")
+	_, pending, err := api.CreateImage(&ImageSpec{
+		Architecture: "aarch64",
+		Name:         "image",
+		SourceImage:  "https://storage.googleapis.com/bucket/image.tar.gz",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pending.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestAPI(t, fake.Endpoint(), "missing").CreateInstance("", nil, platform.MachineOptions{}, false); err == nil {
+		t.Error("expected an error creating an instance of a missing image")
+	}
+	api = newTestAPI(t, fake.Endpoint(), "image")
+	for i := 0; i < 2; i++ {
+		inst, err := api.CreateInstance("{}", nil, platform.MachineOptions{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intIP, extIP := InstanceIPs(inst); intIP == "" || extIP == "" {
+			t.Errorf("instance %s is not networked", inst.Name)
+		}
+		if _, err := api.GetConsoleOutput(inst.Name); err != nil {
+			t.Error(err)
+		}
+	}
+
+	instances, err := api.ListInstances("kola-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances, got %d", len(instances))
+	}
+	if err := api.GC(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if instances, err = api.ListInstances(""); err != nil || len(instances) != 2 {
+		t.Errorf("expected recent instances to be kept, got %d, %v", len(instances), err)
+	}
+	if err := api.GC(0); err != nil {
+		t.Fatal(err)
+	}
+	if instances, err = api.ListInstances(""); err != nil || len(instances) != 0 {
+		t.Errorf("expected instances to be deleted, got %d, %v", len(instances), err)
+	}
+}
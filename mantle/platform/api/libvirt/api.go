@@ -0,0 +1,133 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/digitalocean/go-libvirt"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+var plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "platform/api/libvirt")
+
+type Options struct {
+	*platform.Options
+
+	// URI is the libvirt connection URI, e.g. qemu:///system or
+	// qemu:///session. Only local connections over the libvirtd Unix
+	// socket are supported; the socket can be given with ?socket=.
+	URI string
+	// Pool is the storage pool the disk images are uploaded to.
+	Pool string
+	// DiskImage is the path to the qcow2 image to boot.
+	DiskImage string
+	// Firmware is bios or uefi.
+	Firmware string
+	// Arch is the architecture of the guests, as in coreos-assembler.
+	Arch string
+	// Memory is the default memory size in MiB.
+	Memory int
+	// CPUs is the number of virtual CPUs.
+	CPUs int
+	// KeepFailed leaves the domains of failed tests running.
+	KeepFailed bool
+}
+
+type API struct {
+	options *Options
+	conn    *libvirt.Libvirt
+	pool    libvirt.StoragePool
+}
+
+// socketPath returns the path of the libvirtd socket of a connection URI.
+func socketPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("parsing libvirt URI %q: %v", uri, err)
+	}
+	if u.Scheme != "qemu" && u.Scheme != "qemu+unix" {
+		return "", fmt.Errorf("unsupported libvirt URI %q: only local qemu connections are supported", uri)
+	}
+	if u.Host != "" {
+		return "", fmt.Errorf("unsupported libvirt URI %q: remote hosts are not supported", uri)
+	}
+	if socket := u.Query().Get("socket"); socket != "" {
+		return socket, nil
+	}
+	switch u.Path {
+	case "/system":
+		return "/var/run/libvirt/libvirt-sock", nil
+	case "/session":
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			return "", fmt.Errorf("XDG_RUNTIME_DIR is not set; pass the socket of %q with ?socket=", uri)
+		}
+		return filepath.Join(runtimeDir, "libvirt", "libvirt-sock"), nil
+	}
+	return "", fmt.Errorf("unsupported libvirt URI %q: expected qemu:///system or qemu:///session", uri)
+}
+
+// New connects to libvirtd and looks up the storage pool.
+func New(opts *Options) (*API, error) {
+	socket, err := socketPath(opts.URI)
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.DialTimeout("unix", socket, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to libvirtd: %v", err)
+	}
+	conn := libvirt.New(c)
+	// libvirtd requires listing the authentication methods before opening
+	// the connection, even if none are used
+	if _, err := conn.AuthList(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("listing libvirt authentication methods: %v", err)
+	}
+	// the URI sent to the daemon doesn't include the client-side socket
+	u, _ := url.Parse(opts.URI)
+	u.Scheme = "qemu"
+	u.RawQuery = ""
+	if err := conn.ConnectOpen(libvirt.OptString{u.String()}, 0); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("opening libvirt connection to %s: %v", u, err)
+	}
+
+	api := &API{
+		options: opts,
+		conn:    conn,
+	}
+	api.pool, err = conn.StoragePoolLookupByName(opts.Pool)
+	if err != nil {
+		api.Close()
+		return nil, fmt.Errorf("looking up storage pool %q: %v", opts.Pool, err)
+	}
+	return api, nil
+}
+
+// Close closes the connection to libvirtd.
+func (a *API) Close() {
+	if err := a.conn.Disconnect(); err != nil {
+		plog.Warningf("closing libvirt connection: %v", err)
+	}
+}
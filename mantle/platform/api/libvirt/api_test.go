@@ -0,0 +1,120 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	for _, tc := range []struct {
+		uri    string
+		socket string
+	}{
+		{"qemu:///system", "/var/run/libvirt/libvirt-sock"},
+		{"qemu+unix:///system", "/var/run/libvirt/libvirt-sock"},
+		{"qemu:///session", "/run/user/1000/libvirt/libvirt-sock"},
+		{"qemu:///system?socket=/tmp/sock", "/tmp/sock"},
+	} {
+		socket, err := socketPath(tc.uri)
+		if err != nil {
+			t.Errorf("%s: %v", tc.uri, err)
+		} else if socket != tc.socket {
+			t.Errorf("%s: got socket %q, expected %q", tc.uri, socket, tc.socket)
+		}
+	}
+
+	for _, uri := range []string{"qemu+ssh://host/system", "qemu://host/system", "xen:///system", "qemu:///embed"} {
+		if _, err := socketPath(uri); err == nil {
+			t.Errorf("%s: expected an error", uri)
+		}
+	}
+}
+
+func TestDomainXML(t *testing.T) {
+	spec := &DomainSpec{
+		Name:         "kola-1",
+		MemoryMiB:    2048,
+		CPUs:         2,
+		Arch:         "x86_64",
+		Firmware:     "uefi-secure",
+		Disks:        []string{"kola-1-disk.qcow2", "kola-1-disk1.qcow2"},
+		Network:      "kola-net",
+		MAC:          "52:54:00:12:34:56",
+		IgnitionPath: "/var/lib/libvirt/images/kola-1-ignition.json",
+	}
+	d, err := newDomainXML(spec, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(buf)
+	for _, s := range []string{
+		`<memory unit="MiB">2048</memory>`,
+		`<os firmware="efi"><type arch="x86_64">hvm</type><firmware><feature enabled="yes" name="secure-boot"></feature></firmware></os>`,
+		`<smm></smm>`,
+		`<sysinfo type="fwcfg"><entry name="opt/com.coreos/config" file="/var/lib/libvirt/images/kola-1-ignition.json"></entry></sysinfo>`,
+		`<source pool="default" volume="kola-1-disk.qcow2"></source><target dev="vda" bus="virtio"></target><serial>primary-disk</serial>`,
+		`<source pool="default" volume="kola-1-disk1.qcow2"></source><target dev="vdb" bus="virtio"></target></disk>`,
+		`<mac address="52:54:00:12:34:56"></mac><source network="kola-net"></source>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("domain XML doesn't contain %s:\n%s", s, out)
+		}
+	}
+
+	spec.Firmware = ""
+	spec.IgnitionPath = ""
+	if d, err = newDomainXML(spec, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if d.OS.Firmware != "" || d.Features.SMM != nil || d.SysInfo != nil {
+		t.Errorf("unexpected firmware or fw_cfg for a BIOS domain without Ignition config: %+v", d)
+	}
+
+	spec.Arch = "aarch64"
+	if d, err = newDomainXML(spec, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if d.OS.Firmware != "efi" {
+		t.Errorf("aarch64 domain doesn't use UEFI")
+	}
+
+	spec.Arch = "s390x"
+	if _, err := newDomainXML(spec, "default"); err == nil {
+		t.Errorf("expected an error for s390x")
+	}
+}
+
+func TestNetworkXML(t *testing.T) {
+	buf, err := xml.Marshal(newNetworkXML("kola-net", 123, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<network><name>kola-net</name><forward mode="nat"></forward><ip address="192.168.123.1" netmask="255.255.255.0"><dhcp><range start="192.168.123.2" end="192.168.123.254"></range></dhcp></ip></network>`
+	if string(buf) != expected {
+		t.Errorf("got network XML\n%s\nexpected\n%s", buf, expected)
+	}
+
+	if n := newNetworkXML("kola-net", 123, false); n.Forward != nil {
+		t.Errorf("isolated network is forwarded")
+	}
+}
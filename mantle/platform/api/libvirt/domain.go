@@ -0,0 +1,269 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/digitalocean/go-libvirt"
+)
+
+// ignitionFwCfgName is the fw_cfg entry Ignition reads its config from on
+// the qemu platform.
+const ignitionFwCfgName = "opt/com.coreos/config"
+
+// DomainSpec describes a domain to create.
+type DomainSpec struct {
+	Name      string
+	UUID      string
+	MemoryMiB int
+	CPUs      int
+	// Arch is the architecture of the guest, as in coreos-assembler.
+	Arch string
+	// Firmware is bios, uefi or uefi-secure.
+	Firmware string
+	// Disks are the names of volumes of the storage pool; the first one
+	// is booted.
+	Disks []string
+	// Network is the name of the network of the only NIC, with the MAC
+	// address MAC.
+	Network string
+	MAC     string
+	// IgnitionPath, if set, is the path of the Ignition config on the
+	// libvirt host.
+	IgnitionPath string
+}
+
+type domainXML struct {
+	XMLName xml.Name `xml:"domain"`
+	Type    string   `xml:"type,attr"`
+	Name    string   `xml:"name"`
+	UUID    string   `xml:"uuid,omitempty"`
+	Memory  struct {
+		Unit  string `xml:"unit,attr"`
+		Value int    `xml:",chardata"`
+	} `xml:"memory"`
+	VCPU    int         `xml:"vcpu"`
+	SysInfo *sysInfoXML `xml:"sysinfo,omitempty"`
+	OS      struct {
+		Firmware string `xml:"firmware,attr,omitempty"`
+		Type     struct {
+			Arch  string `xml:"arch,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"type"`
+		FirmwareInfo *firmwareInfoXML `xml:"firmware,omitempty"`
+	} `xml:"os"`
+	Features struct {
+		ACPI *struct{} `xml:"acpi"`
+		SMM  *struct{} `xml:"smm"`
+	} `xml:"features"`
+	CPU struct {
+		Mode string `xml:"mode,attr"`
+	} `xml:"cpu"`
+	OnPoweroff string `xml:"on_poweroff"`
+	OnReboot   string `xml:"on_reboot"`
+	OnCrash    string `xml:"on_crash"`
+	Devices    struct {
+		Disks      []diskXML      `xml:"disk"`
+		Interfaces []interfaceXML `xml:"interface"`
+		Serial     struct {
+			Type   string `xml:"type,attr"`
+			Target struct {
+				Port int `xml:"port,attr"`
+			} `xml:"target"`
+		} `xml:"serial"`
+		RNG struct {
+			Model   string `xml:"model,attr"`
+			Backend struct {
+				Model string `xml:"model,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"backend"`
+		} `xml:"rng"`
+	} `xml:"devices"`
+}
+
+type sysInfoXML struct {
+	Type    string            `xml:"type,attr"`
+	Entries []sysInfoEntryXML `xml:"entry"`
+}
+
+type sysInfoEntryXML struct {
+	Name string `xml:"name,attr"`
+	File string `xml:"file,attr"`
+}
+
+type firmwareInfoXML struct {
+	Features []featureXML `xml:"feature"`
+}
+
+type featureXML struct {
+	Enabled string `xml:"enabled,attr"`
+	Name    string `xml:"name,attr"`
+}
+
+type diskXML struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Driver struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"driver"`
+	Source struct {
+		Pool   string `xml:"pool,attr"`
+		Volume string `xml:"volume,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+	Serial string `xml:"serial,omitempty"`
+}
+
+type interfaceXML struct {
+	Type string `xml:"type,attr"`
+	MAC  struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Network string `xml:"network,attr"`
+	} `xml:"source"`
+	Model struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+}
+
+// newDomainXML returns the definition of the domain spec, whose disks are
+// volumes of pool.
+func newDomainXML(spec *DomainSpec, pool string) (*domainXML, error) {
+	d := &domainXML{
+		Type: "kvm",
+		Name: spec.Name,
+		UUID: spec.UUID,
+		VCPU: spec.CPUs,
+		// kola reboots machines, but they shouldn't come back once
+		// they're powered off
+		OnPoweroff: "destroy",
+		OnReboot:   "restart",
+		OnCrash:    "destroy",
+	}
+	d.Memory.Unit = "MiB"
+	d.Memory.Value = spec.MemoryMiB
+	d.CPU.Mode = "host-passthrough"
+	d.Features.ACPI = &struct{}{}
+
+	// Ignition is passed through fw_cfg, which is only available on
+	// these architectures
+	switch spec.Arch {
+	case "x86_64", "aarch64":
+		d.OS.Type.Arch = spec.Arch
+	default:
+		return nil, fmt.Errorf("unsupported architecture %q: the libvirt platform supports x86_64 and aarch64", spec.Arch)
+	}
+	d.OS.Type.Value = "hvm"
+	switch spec.Firmware {
+	case "", "bios":
+		if spec.Arch == "aarch64" {
+			d.OS.Firmware = "efi"
+		}
+	case "uefi":
+		d.OS.Firmware = "efi"
+	case "uefi-secure":
+		d.OS.Firmware = "efi"
+		d.OS.FirmwareInfo = &firmwareInfoXML{
+			Features: []featureXML{{Enabled: "yes", Name: "secure-boot"}},
+		}
+		d.Features.SMM = &struct{}{}
+	default:
+		return nil, fmt.Errorf("unsupported firmware %q", spec.Firmware)
+	}
+
+	if spec.IgnitionPath != "" {
+		d.SysInfo = &sysInfoXML{
+			Type:    "fwcfg",
+			Entries: []sysInfoEntryXML{{Name: ignitionFwCfgName, File: spec.IgnitionPath}},
+		}
+	}
+
+	for i, volume := range spec.Disks {
+		disk := diskXML{Type: "volume", Device: "disk"}
+		disk.Driver.Name = "qemu"
+		disk.Driver.Type = "qcow2"
+		disk.Source.Pool = pool
+		disk.Source.Volume = volume
+		disk.Target.Dev = fmt.Sprintf("vd%c", 'a'+i)
+		disk.Target.Bus = "virtio"
+		if i == 0 {
+			// like the qemu platform, for tests looking up the boot disk
+			disk.Serial = "primary-disk"
+		}
+		d.Devices.Disks = append(d.Devices.Disks, disk)
+	}
+
+	iface := interfaceXML{Type: "network"}
+	iface.MAC.Address = spec.MAC
+	iface.Source.Network = spec.Network
+	iface.Model.Type = "virtio"
+	d.Devices.Interfaces = append(d.Devices.Interfaces, iface)
+
+	d.Devices.Serial.Type = "pty"
+	d.Devices.RNG.Model = "virtio"
+	d.Devices.RNG.Backend.Model = "random"
+	d.Devices.RNG.Backend.Value = "/dev/urandom"
+	return d, nil
+}
+
+// CreateDomain creates and starts a transient domain; it disappears once
+// destroyed.
+func (a *API) CreateDomain(spec *DomainSpec) error {
+	d, err := newDomainXML(spec, a.options.Pool)
+	if err != nil {
+		return err
+	}
+	buf, err := xml.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if _, err := a.conn.DomainCreateXML(string(buf), 0); err != nil {
+		return fmt.Errorf("creating domain %q: %v", spec.Name, err)
+	}
+	return nil
+}
+
+// DestroyDomain forcibly stops the transient domain name, which removes it.
+func (a *API) DestroyDomain(name string) error {
+	dom, err := a.conn.DomainLookupByName(name)
+	if err != nil {
+		return fmt.Errorf("looking up domain %q: %v", name, err)
+	}
+	if err := a.conn.DomainDestroy(dom); err != nil {
+		return fmt.Errorf("destroying domain %q: %v", name, err)
+	}
+	return nil
+}
+
+// StreamConsole copies the serial console of the domain name to w until
+// the domain stops.
+func (a *API) StreamConsole(name string, w io.Writer) error {
+	dom, err := a.conn.DomainLookupByName(name)
+	if err != nil {
+		return fmt.Errorf("looking up domain %q: %v", name, err)
+	}
+	if err := a.conn.DomainOpenConsole(dom, nil, w, uint32(libvirt.DomainConsoleForce)); err != nil {
+		return fmt.Errorf("streaming console of domain %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/digitalocean/go-libvirt"
+)
+
+type networkXML struct {
+	XMLName xml.Name    `xml:"network"`
+	Name    string      `xml:"name"`
+	Forward *forwardXML `xml:"forward,omitempty"`
+	IP      struct {
+		Address string `xml:"address,attr"`
+		Netmask string `xml:"netmask,attr"`
+		DHCP    struct {
+			Range struct {
+				Start string `xml:"start,attr"`
+				End   string `xml:"end,attr"`
+			} `xml:"range"`
+		} `xml:"dhcp"`
+	} `xml:"ip"`
+}
+
+type forwardXML struct {
+	Mode string `xml:"mode,attr"`
+}
+
+// newNetworkXML returns the definition of a network on the subnet
+// 192.168.<subnet>.0/24 with DHCP. The network is NATed if nat is true and
+// isolated from the outside otherwise; the host can reach the guests in both
+// cases.
+func newNetworkXML(name string, subnet int, nat bool) *networkXML {
+	n := &networkXML{Name: name}
+	if nat {
+		n.Forward = &forwardXML{Mode: "nat"}
+	}
+	prefix := fmt.Sprintf("192.168.%d.", subnet)
+	n.IP.Address = prefix + "1"
+	n.IP.Netmask = "255.255.255.0"
+	n.IP.DHCP.Range.Start = prefix + "2"
+	n.IP.DHCP.Range.End = prefix + "254"
+	return n
+}
+
+// CreateNetwork creates the transient network name on a free /24 subnet,
+// with Internet access through NAT if nat is true.
+func (a *API) CreateNetwork(name string, nat bool) error {
+	var err error
+	// pick subnets at random to not clash with concurrent runs; creating
+	// a network fails if its subnet is in use
+	for i := 0; i < 10; i++ {
+		var buf []byte
+		buf, err = xml.Marshal(newNetworkXML(name, 100+rand.Intn(150), nat))
+		if err != nil {
+			return err
+		}
+		if _, err = a.conn.NetworkCreateXML(string(buf)); err == nil {
+			return nil
+		}
+		if !strings.Contains(err.Error(), "in use") {
+			break
+		}
+	}
+	return fmt.Errorf("creating network %q: %v", name, err)
+}
+
+// DestroyNetwork destroys the transient network name.
+func (a *API) DestroyNetwork(name string) error {
+	net, err := a.conn.NetworkLookupByName(name)
+	if err != nil {
+		return fmt.Errorf("looking up network %q: %v", name, err)
+	}
+	if err := a.conn.NetworkDestroy(net); err != nil {
+		return fmt.Errorf("destroying network %q: %v", name, err)
+	}
+	return nil
+}
+
+// LeasedIP returns the IPv4 address leased to mac on network, or "" if
+// there is none yet.
+func (a *API) LeasedIP(network, mac string) (string, error) {
+	net, err := a.conn.NetworkLookupByName(network)
+	if err != nil {
+		return "", fmt.Errorf("looking up network %q: %v", network, err)
+	}
+	leases, _, err := a.conn.NetworkGetDhcpLeases(net, libvirt.OptString{mac}, 1, 0)
+	if err != nil {
+		return "", fmt.Errorf("getting DHCP leases of network %q: %v", network, err)
+	}
+	for _, lease := range leases {
+		if lease.Type == int32(libvirt.IPAddrTypeIpv4) {
+			return lease.Ipaddr, nil
+		}
+	}
+	return "", nil
+}
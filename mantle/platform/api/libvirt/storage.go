@@ -0,0 +1,143 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/digitalocean/go-libvirt"
+)
+
+type volumeFormat struct {
+	Type string `xml:"type,attr"`
+}
+
+type volumeXML struct {
+	XMLName  xml.Name `xml:"volume"`
+	Name     string   `xml:"name"`
+	Capacity struct {
+		Unit  string `xml:"unit,attr"`
+		Value uint64 `xml:",chardata"`
+	} `xml:"capacity"`
+	Target struct {
+		Format volumeFormat `xml:"format"`
+	} `xml:"target"`
+	BackingStore *backingStoreXML `xml:"backingStore,omitempty"`
+}
+
+type backingStoreXML struct {
+	Path   string       `xml:"path"`
+	Format volumeFormat `xml:"format"`
+}
+
+func newVolumeXML(name, format string, size uint64) *volumeXML {
+	v := &volumeXML{Name: name}
+	v.Capacity.Unit = "bytes"
+	v.Capacity.Value = size
+	v.Target.Format.Type = format
+	return v
+}
+
+func (a *API) createVolume(v *volumeXML) (libvirt.StorageVol, error) {
+	buf, err := xml.Marshal(v)
+	if err != nil {
+		return libvirt.StorageVol{}, err
+	}
+	vol, err := a.conn.StorageVolCreateXML(a.pool, string(buf), 0)
+	if err != nil {
+		return libvirt.StorageVol{}, fmt.Errorf("creating volume %q: %v", v.Name, err)
+	}
+	return vol, nil
+}
+
+// upload creates the volume v with the contents of r, of size bytes, and
+// returns its path.
+func (a *API) upload(v *volumeXML, r io.Reader, size uint64) (string, error) {
+	vol, err := a.createVolume(v)
+	if err != nil {
+		return "", err
+	}
+	if err := a.conn.StorageVolUpload(vol, r, 0, size, 0); err != nil {
+		a.deleteVolume(vol)
+		return "", fmt.Errorf("uploading volume %q: %v", v.Name, err)
+	}
+	path, err := a.conn.StorageVolGetPath(vol)
+	if err != nil {
+		a.deleteVolume(vol)
+		return "", fmt.Errorf("getting path of volume %q: %v", v.Name, err)
+	}
+	return path, nil
+}
+
+// UploadImage uploads the qcow2 image at path to the volume name and
+// returns the path of the volume.
+func (a *API) UploadImage(name, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := uint64(fi.Size())
+	plog.Debugf("Uploading %s to volume %s", path, name)
+	return a.upload(newVolumeXML(name, "qcow2", size), f, size)
+}
+
+// UploadData uploads data to the raw volume name and returns the path of
+// the volume.
+func (a *API) UploadData(name string, data []byte) (string, error) {
+	size := uint64(len(data))
+	return a.upload(newVolumeXML(name, "raw", size), bytes.NewReader(data), size)
+}
+
+// CreateOverlay creates the qcow2 volume name of size bytes, backed by the
+// qcow2 image at backingPath.
+func (a *API) CreateOverlay(name, backingPath string, size uint64) error {
+	v := newVolumeXML(name, "qcow2", size)
+	v.BackingStore = &backingStoreXML{Path: backingPath, Format: volumeFormat{Type: "qcow2"}}
+	_, err := a.createVolume(v)
+	return err
+}
+
+// CreateDisk creates the empty qcow2 volume name of size bytes.
+func (a *API) CreateDisk(name string, size uint64) error {
+	_, err := a.createVolume(newVolumeXML(name, "qcow2", size))
+	return err
+}
+
+func (a *API) deleteVolume(vol libvirt.StorageVol) {
+	if err := a.conn.StorageVolDelete(vol, 0); err != nil {
+		plog.Warningf("deleting volume %q: %v", vol.Name, err)
+	}
+}
+
+// DeleteVolume deletes the volume name.
+func (a *API) DeleteVolume(name string) error {
+	vol, err := a.conn.StorageVolLookupByName(a.pool, name)
+	if err != nil {
+		return fmt.Errorf("looking up volume %q: %v", name, err)
+	}
+	if err := a.conn.StorageVolDelete(vol, 0); err != nil {
+		return fmt.Errorf("deleting volume %q: %v", name, err)
+	}
+	return nil
+}
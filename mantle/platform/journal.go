@@ -56,6 +56,10 @@ func (g gzWriteCloser) Close() error {
 	return err.AsError()
 }
 
+// journalRawFile is the name of the gzipped journal export, relative to
+// the output directory of a machine.
+const journalRawFile = "journal-raw.txt.gz"
+
 // NewJournal creates a Journal recorder that will log to "journal.txt"
 // and "journal-raw.txt.gz" inside the given output directory.
 func NewJournal(dir string) (*Journal, error) {
@@ -65,7 +69,7 @@ func NewJournal(dir string) (*Journal, error) {
 		return nil, err
 	}
 
-	pr := filepath.Join(dir, "journal-raw.txt.gz")
+	pr := filepath.Join(dir, journalRawFile)
 	jr, err := os.OpenFile(pr, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, err
@@ -150,3 +154,68 @@ func (j *Journal) Destroy() {
 		plog.Errorf("Failed to close raw journal: %v", err)
 	}
 }
+
+// RecordedJournals returns the names of the subdirectories of dir in which
+// a journal was recorded, i.e. the IDs of the machines of a cluster whose
+// output directory is dir.
+func RecordedJournals(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), journalRawFile)); err == nil {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+// ReadJournalEntries parses the journal export recorded in the given
+// machine output directory. The journal is streamed again from the start
+// after each reboot, so entries are deduplicated by cursor. There is no
+// guarantee that anything is returned if called before Destroy.
+func ReadJournalEntries(dir string) ([]journal.Entry, error) {
+	f, err := os.Open(filepath.Join(dir, journalRawFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading raw journal")
+	}
+	defer f.Close()
+	// an empty file is a valid, empty journal
+	if fi, err := f.Stat(); err != nil {
+		return nil, errors.Wrapf(err, "reading raw journal")
+	} else if fi.Size() == 0 {
+		return nil, nil
+	}
+	// each journal recording appends a gzip member, which gzip.Reader
+	// reads as one stream
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading raw journal")
+	}
+	defer z.Close()
+
+	var entries []journal.Entry
+	seen := make(map[string]bool)
+	src := journal.NewExportReader(z)
+	for {
+		entry, err := src.ReadEntry()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// the recording may have been cut off mid-entry
+			return entries, nil
+		} else if err != nil {
+			return entries, errors.Wrapf(err, "parsing raw journal")
+		}
+		if cursor, ok := entry[journal.FIELD_CURSOR]; ok {
+			if seen[string(cursor)] {
+				continue
+			}
+			seen[string(cursor)] = true
+		}
+		entries = append(entries, entry)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pborman/uuid"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+// Cluster is a cluster of fake machines.
+type Cluster struct {
+	*platform.BaseCluster
+	flight *flight
+}
+
+func (fc *Cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return fc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (fc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	config, err := fc.RenderUserData(userdata, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	serial := fc.AllocateMachineSerial()
+	mach := newMachine(fc, uuid.New(), fmt.Sprintf("192.0.2.%d", serial%254+1))
+	mach.config = config
+	mach.options = options
+
+	dir := filepath.Join(fc.RuntimeConf().OutputDir, mach.ID())
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	if err := config.WriteFile(filepath.Join(dir, "user-data")); err != nil {
+		return nil, err
+	}
+	if mach.journal, err = platform.NewJournal(dir); err != nil {
+		return nil, err
+	}
+
+	if fc.flight.opts.Setup != nil {
+		if err := fc.flight.opts.Setup(mach); err != nil {
+			mach.Destroy()
+			return nil, err
+		}
+	}
+
+	if !options.SkipStartMachine {
+		if err := mach.Start(); err != nil {
+			mach.Destroy()
+			return nil, err
+		}
+	}
+
+	fc.AddMach(mach)
+
+	return mach, nil
+}
+
+func (fc *Cluster) Destroy() {
+	fc.BaseCluster.Destroy()
+	fc.flight.DelCluster(fc)
+}
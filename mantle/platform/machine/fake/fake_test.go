@@ -0,0 +1,162 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+func newCluster(t *testing.T, opts *Options) *Cluster {
+	flight, dir := NewTestFlight(t, opts)
+	c, err := flight.NewCluster(&platform.RuntimeConfig{OutputDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Cluster)
+}
+
+func startMachine(t *testing.T, c *Cluster) *Machine {
+	m, err := c.NewMachine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*Machine)
+}
+
+func TestScript(t *testing.T) {
+	opts := &Options{Script: &Script{}}
+	opts.Script.Respond(`uname -r`, "6.0.0-fake\n")
+	opts.Script.Respond(`echo (\w+)`, "flight")
+	c := newCluster(t, opts)
+	m := startMachine(t, c)
+	m.Script().Handle(`echo (\w+)`, func(c *Command) Response {
+		return Response{Stdout: c.Match[1]}
+	})
+	m.Script().Fail(`false`, 1, "failed")
+
+	if out, _, err := m.SSH("uname -r"); err != nil || string(out) != "6.0.0-fake" {
+		t.Errorf("expected the flight response, got %q %v", out, err)
+	}
+	if out, _, err := m.SSH("echo machine"); err != nil || string(out) != "machine" {
+		t.Errorf("expected the machine response, got %q %v", out, err)
+	}
+	if _, stderr, err := m.SSH("false"); err == nil || string(stderr) != "failed" {
+		t.Errorf("expected a failure, got %q %v", stderr, err)
+	}
+	if _, _, err := m.SSH("ls"); err == nil || !strings.Contains(err.Error(), "127") {
+		t.Errorf("expected exit status 127 without response, got %v", err)
+	}
+	if len(c.Machines()) != 1 {
+		t.Errorf("expected 1 machine, got %d", len(c.Machines()))
+	}
+}
+
+func TestReboot(t *testing.T) {
+	c := newCluster(t, &Options{})
+	m := startMachine(t, c)
+	m.Log("kola", "before reboot")
+
+	bootID := m.BootID()
+	if err := m.Reboot(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Boots() != 2 || m.BootID() == bootID {
+		t.Errorf("expected a new boot, got boot %d %s", m.Boots(), m.BootID())
+	}
+
+	// a reboot happening while waiting for it
+	bootID = m.BootID()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		m.TriggerReboot()
+	}()
+	if err := m.WaitForReboot(10*time.Second, bootID); err != nil {
+		t.Fatal(err)
+	}
+	if m.Boots() != 3 {
+		t.Errorf("expected 3 boots, got %d", m.Boots())
+	}
+
+	m.Log("kola", "after reboot")
+	m.WriteConsole("console message\n")
+	m.Destroy()
+	journal := m.JournalOutput()
+	if !strings.Contains(journal, "kola: before reboot") || !strings.Contains(journal, "kola: after reboot") ||
+		strings.Count(journal, "-- Reboot --") != 2 {
+		t.Errorf("unexpected journal:\n%s", journal)
+	}
+	console, err := os.ReadFile(filepath.Join(m.RuntimeConf().OutputDir, m.ID(), "console.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(console), "Booting fake machine") != 3 || !strings.Contains(string(console), "console message") {
+		t.Errorf("unexpected console:\n%s", console)
+	}
+	if c.ConsoleOutput()[m.ID()] != string(console) {
+		t.Errorf("console of the destroyed machine not kept by the cluster")
+	}
+}
+
+func TestFailures(t *testing.T) {
+	var setup func(m *Machine) error
+	c := newCluster(t, &Options{
+		Setup: func(m *Machine) error { return setup(m) },
+	})
+
+	setup = func(m *Machine) error {
+		return errors.New("no capacity")
+	}
+	if _, err := c.NewMachine(nil); err == nil || err.Error() != "no capacity" {
+		t.Errorf("expected the setup error, got %v", err)
+	}
+
+	setup = func(m *Machine) error {
+		m.SetFailedUnits("foo.service")
+		return nil
+	}
+	if _, err := c.NewMachine(nil); err == nil || !strings.Contains(err.Error(), "failed or stuck systemd units") {
+		t.Errorf("expected failed units, got %v", err)
+	}
+
+	setup = func(m *Machine) error {
+		m.SetIgnitionError(errors.New("ignition failed"))
+		return nil
+	}
+	if _, err := c.NewMachine(nil); err == nil || !strings.Contains(err.Error(), "emergency.target") {
+		t.Errorf("expected an Ignition failure, got %v", err)
+	}
+
+	setup = func(m *Machine) error { return nil }
+	m := startMachine(t, c)
+	m.SetSSHError(errors.New("unreachable"))
+	if _, _, err := m.SSH("true"); err == nil || err.Error() != "unreachable" {
+		t.Errorf("expected the SSH error, got %v", err)
+	}
+	m.SetSSHError(nil)
+	m.Script().Handle(`true`, func(*Command) Response { return Response{Disconnect: true} })
+	if _, _, err := m.SSH("true"); err == nil {
+		t.Errorf("expected a disconnection")
+	}
+	if len(c.Machines()) != 1 {
+		t.Errorf("expected only the started machine in the cluster, got %d", len(c.Machines()))
+	}
+}
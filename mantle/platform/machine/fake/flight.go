@@ -0,0 +1,115 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake is a platform of machines which only exist in memory, for
+// unit testing code which drives machines, e.g. kola tests and the
+// harness, without booting anything.
+//
+// Machines are reached over a mock SSH connection (see network/mockssh) on
+// which commands get the response of a Script. Machines answer the commands
+// used by platform.CheckMachine and journal recording by default, so they
+// start, reboot and record their journal like machines of real platforms.
+package fake
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+)
+
+const (
+	Platform platform.Name = "fake"
+)
+
+var (
+	plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "platform/machine/fake")
+)
+
+// Options contains the options of fake flights.
+type Options struct {
+	// Script is run by the machines of every cluster, after their own
+	// script and before the default responses.
+	Script *Script
+	// Setup, if set, is called with each new machine before it starts,
+	// e.g. to script it or inject failures. An error fails the creation
+	// of the machine.
+	Setup func(m *Machine) error
+
+	*platform.Options
+}
+
+type flight struct {
+	*platform.BaseFlight
+	opts *Options
+}
+
+func NewFlight(opts *Options) (platform.Flight, error) {
+	bf, err := platform.NewBaseFlight(opts.Options, Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	ff := &flight{
+		BaseFlight: bf,
+		opts:       opts,
+	}
+
+	return ff, nil
+}
+
+// NewTestFlight returns a flight for the unit test t, which is destroyed
+// when the test ends, and a temporary directory for the output of its
+// clusters, which is removed after the flight is destroyed. The flight gets
+// the base name "kola" unless opts.Options is set.
+func NewTestFlight(t testing.TB, opts *Options) (platform.Flight, string) {
+	t.Helper()
+	if opts.Options == nil {
+		opts.Options = &platform.Options{BaseName: "kola"}
+	}
+	// created first to be removed after the flight is destroyed
+	dir := t.TempDir()
+	flight, err := NewFlight(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(flight.Destroy)
+	return flight, dir
+}
+
+func (ff *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
+	if rconf.OutputDir == "" {
+		return nil, errors.New("platform fake requires an output directory")
+	}
+	bc, err := platform.NewBaseCluster(ff.BaseFlight, rconf)
+	if err != nil {
+		return nil, err
+	}
+
+	fc := &Cluster{
+		BaseCluster: bc,
+		flight:      ff,
+	}
+
+	ff.AddCluster(fc)
+
+	return fc, nil
+}
+
+func (ff *flight) ConfigTooLarge(ud conf.UserData) bool {
+	return false
+}
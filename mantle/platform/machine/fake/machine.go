@@ -0,0 +1,535 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/coreos-assembler/mantle/network/journal"
+	"github.com/coreos/coreos-assembler/mantle/network/mockssh"
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+// journalEntry is an entry of the journal of a fake machine; its cursor is
+// its index in the journal.
+type journalEntry struct {
+	boot       string
+	realtime   time.Time
+	monotonic  time.Duration
+	identifier string
+	message    string
+}
+
+// Machine is a fake machine. Its commands get the response of its own
+// script, then of the script of the flight, then the default responses;
+// commands without response fail with exit status 127.
+type Machine struct {
+	cluster *Cluster
+	id      string
+	ip      string
+	config  *conf.Conf
+	options platform.MachineOptions
+	journal *platform.Journal
+	script  Script
+
+	mu sync.Mutex
+	// changed is closed and replaced on any change of the boot, journal
+	// or destruction of the machine
+	changed     chan struct{}
+	bootID      string
+	boots       int
+	bootTime    time.Time
+	entries     []journalEntry
+	console     bytes.Buffer
+	sshErr      error
+	ignitionErr error
+	failedUnits []string
+	destroyed   bool
+	// exported is the number of journal entries sent to the recorder by
+	// the followers of the journal
+	exported  int
+	followers sync.WaitGroup
+}
+
+func newMachine(fc *Cluster, id, ip string) *Machine {
+	m := &Machine{
+		cluster: fc,
+		id:      id,
+		ip:      ip,
+		changed: make(chan struct{}),
+	}
+	m.boot()
+	return m
+}
+
+func (m *Machine) ID() string {
+	return m.id
+}
+
+func (m *Machine) IP() string {
+	return m.ip
+}
+
+func (m *Machine) PrivateIP() string {
+	return m.ip
+}
+
+func (m *Machine) RuntimeConf() platform.RuntimeConfig {
+	return m.cluster.RuntimeConf()
+}
+
+func (m *Machine) IgnitionError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ignitionErr
+}
+
+// SSHClient returns a client connected to the mock SSH server of the
+// machine, unless it is unreachable.
+func (m *Machine) SSHClient() (*ssh.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case m.destroyed:
+		return nil, fmt.Errorf("machine %s is destroyed", m.id)
+	case m.ignitionErr != nil:
+		return nil, fmt.Errorf("dial tcp %s:22: connect: connection refused", m.ip)
+	case m.sshErr != nil:
+		return nil, m.sshErr
+	}
+	return mockssh.NewMockClient(m.handle), nil
+}
+
+func (m *Machine) PasswordSSHClient(user string, password string) (*ssh.Client, error) {
+	return m.SSHClient()
+}
+
+// SSH runs cmd over a new SSH connection like the machines of other
+// platforms, trimming whitespace around its output.
+func (m *Machine) SSH(cmd string) ([]byte, []byte, error) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	client, err := m.SSHClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.Close()
+
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(cmd)
+	outBytes := bytes.TrimSpace(stdout.Bytes())
+	errBytes := bytes.TrimSpace(stderr.Bytes())
+	return outBytes, errBytes, err
+}
+
+func (m *Machine) Start() error {
+	return platform.StartMachine(m, m.journal)
+}
+
+func (m *Machine) Reboot() error {
+	return platform.RebootMachine(m, m.journal)
+}
+
+func (m *Machine) WaitForReboot(timeout time.Duration, oldBootId string) error {
+	return platform.WaitForMachineReboot(m, m.journal, timeout, oldBootId)
+}
+
+func (m *Machine) Destroy() {
+	m.mu.Lock()
+	if m.destroyed {
+		m.mu.Unlock()
+		return
+	}
+	m.destroyed = true
+	m.broadcast()
+	m.mu.Unlock()
+
+	// followers send the remaining entries before disconnecting
+	m.followers.Wait()
+	if m.journal != nil {
+		m.flushJournal()
+		m.journal.Destroy()
+	}
+
+	path := filepath.Join(m.RuntimeConf().OutputDir, m.id, "console.txt")
+	if err := os.WriteFile(path, []byte(m.ConsoleOutput()), 0644); err != nil {
+		plog.Errorf("Error writing console for machine %v: %v", m.id, err)
+	}
+
+	m.cluster.DelMach(m)
+}
+
+// flushJournal waits for the journal recorder to catch up with the entries
+// sent to it, which would otherwise be cut off by stopping it.
+func (m *Machine) flushJournal() {
+	m.mu.Lock()
+	exported := m.exported
+	m.mu.Unlock()
+	if exported == 0 {
+		return
+	}
+	err := util.Retry(100, 10*time.Millisecond, func() error {
+		data, err := m.journal.Read()
+		if err != nil {
+			return err
+		}
+		// journal.txt has a line per entry, plus reboot markers and
+		// indented continuation lines
+		recorded := 0
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-- ") {
+				recorded++
+			}
+		}
+		if recorded < exported {
+			return fmt.Errorf("%d of %d journal entries recorded", recorded, exported)
+		}
+		return nil
+	})
+	if err != nil {
+		plog.Warningf("Journal of machine %v incomplete: %v", m.id, err)
+	}
+}
+
+func (m *Machine) ConsoleOutput() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.console.String()
+}
+
+func (m *Machine) JournalOutput() string {
+	if m.journal == nil {
+		return ""
+	}
+
+	data, err := m.journal.Read()
+	if err != nil {
+		plog.Errorf("Reading journal for machine %v: %v", m.id, err)
+	}
+	return string(data)
+}
+
+// Script returns the script of the machine.
+func (m *Machine) Script() *Script {
+	return &m.script
+}
+
+// Config returns the rendered config the machine was created with.
+func (m *Machine) Config() *conf.Conf {
+	return m.config
+}
+
+// Options returns the options the machine was created with.
+func (m *Machine) Options() platform.MachineOptions {
+	return m.options
+}
+
+// BootID returns the boot ID of the current boot.
+func (m *Machine) BootID() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bootID
+}
+
+// Boots returns the number of times the machine booted.
+func (m *Machine) Boots() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.boots
+}
+
+// TriggerReboot reboots the machine at once, as if it rebooted on its own,
+// e.g. to apply an update. Sessions waiting for the reboot or following the
+// journal are disconnected.
+func (m *Machine) TriggerReboot() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logLocked("systemd-shutdown", "Rebooting.")
+	m.boot()
+}
+
+// Log adds a message to the journal of the current boot.
+func (m *Machine) Log(identifier, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logLocked(identifier, message)
+}
+
+// WriteConsole appends s to the console output.
+func (m *Machine) WriteConsole(s string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.console.WriteString(s)
+}
+
+// SetSSHError makes new SSH connections to the machine fail with err, or
+// succeed again if err is nil. As on other platforms, starting or rebooting
+// an unreachable machine retries for several minutes.
+func (m *Machine) SetSSHError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sshErr = err
+}
+
+// SetIgnitionError makes the machine fail in Ignition with err: it is
+// unreachable and fails to start.
+func (m *Machine) SetIgnitionError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ignitionErr = err
+}
+
+// SetFailedUnits sets the systemd units which failed, which makes the
+// system degraded and platform.CheckMachine fail unless the cluster allows
+// failed units.
+func (m *Machine) SetFailedUnits(units ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedUnits = units
+}
+
+// boot starts a new boot; m.mu must be held, except on creation.
+func (m *Machine) boot() {
+	m.bootID = uuid.New()
+	m.boots++
+	m.bootTime = time.Now()
+	fmt.Fprintf(&m.console, "[    0.000000] Booting fake machine %s (boot %d)\n", m.id, m.boots)
+	m.logLocked("kernel", fmt.Sprintf("Booting fake machine %s (boot %d)", m.id, m.boots))
+}
+
+func (m *Machine) logLocked(identifier, message string) {
+	now := time.Now()
+	m.entries = append(m.entries, journalEntry{
+		boot:       m.bootID,
+		realtime:   now,
+		monotonic:  now.Sub(m.bootTime),
+		identifier: identifier,
+		message:    message,
+	})
+	m.broadcast()
+}
+
+// broadcast wakes up the sessions waiting for a change; m.mu must be held.
+func (m *Machine) broadcast() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// handle runs a command of a mock SSH session.
+func (m *Machine) handle(s *mockssh.Session) {
+	c := &Command{
+		Machine: m,
+		Cmd:     s.Exec,
+		Env:     s.Env,
+		Stdin:   s.Stdin,
+		Stdout:  s.Stdout,
+		Stderr:  s.Stderr,
+	}
+	resp := m.respond(c)
+	_, _ = io.WriteString(s.Stdout, resp.Stdout)
+	_, _ = io.WriteString(s.Stderr, resp.Stderr)
+	if resp.Reboot {
+		m.TriggerReboot()
+	}
+	if resp.Disconnect {
+		_ = s.Close()
+	} else {
+		_ = s.Exit(resp.ExitStatus)
+	}
+}
+
+func (m *Machine) respond(c *Command) Response {
+	for _, s := range []*Script{&m.script, m.cluster.flight.opts.Script, &defaultScript} {
+		if handler, match := s.lookup(c.Cmd); handler != nil {
+			c.Match = match
+			return handler(c)
+		}
+	}
+	plog.Debugf("No response to %q on machine %s", c.Cmd, m.id)
+	return Response{
+		Stderr:     fmt.Sprintf("fake: no response to %q", c.Cmd),
+		ExitStatus: 127,
+	}
+}
+
+// defaultScript answers the commands used to start and reboot machines, to
+// record their journal and to log to it.
+var defaultScript Script
+
+func init() {
+	defaultScript.Handle(`cat /proc/sys/kernel/random/boot_id`, func(c *Command) Response {
+		return Response{Stdout: c.Machine.BootID() + "\n"}
+	})
+	defaultScript.Handle(`systemctl is-system-running(?: --wait)?( \|\| :)?`, func(c *Command) Response {
+		c.Machine.mu.Lock()
+		defer c.Machine.mu.Unlock()
+		if len(c.Machine.failedUnits) == 0 {
+			return Response{Stdout: "running\n"}
+		}
+		resp := Response{Stdout: "degraded\n", ExitStatus: 1}
+		if c.Match[1] != "" {
+			resp.ExitStatus = 0
+		}
+		return resp
+	})
+	defaultScript.Respond(`\. /etc/os-release && echo "\$ID"`, "fedora\n")
+	defaultScript.Respond(`\. /etc/os-release && echo "\$VARIANT_ID"`, "coreos\n")
+	defaultScript.Respond(`rpm -q --queryformat='%\{VERSION\}\n' systemd`, "256\n")
+	defaultScript.Handle(`busctl .* ListUnitsFiltered as 2 state (\S+) .*`, func(c *Command) Response {
+		if c.Match[1] != "failed" {
+			return Response{}
+		}
+		c.Machine.mu.Lock()
+		defer c.Machine.mu.Unlock()
+		var out strings.Builder
+		for _, unit := range c.Machine.failedUnits {
+			out.WriteString(unit + "\n")
+		}
+		return Response{Stdout: out.String()}
+	})
+	defaultScript.Handle(`(?:sudo )?(?:systemctl )?reboot`, func(c *Command) Response {
+		return Response{Disconnect: true, Reboot: true}
+	})
+	// the command of platform.WaitForMachineReboot
+	defaultScript.Handle(`if \[ \$\(cat /proc/sys/kernel/random/boot_id\) == '([^']*)' \]; then .*sleep infinity; fi`, func(c *Command) Response {
+		return c.Machine.waitForReboot(c.Match[1])
+	})
+	// the command of TestCluster.JournalLog
+	defaultScript.Handle(`logger --tag (\S+) '([^']*)'`, func(c *Command) Response {
+		c.Machine.Log(c.Match[1], c.Match[2])
+		return Response{}
+	})
+	// the command of journal.Recorder
+	defaultScript.Handle(`journalctl --output=export --follow --lines=all (?:--boot|--after-cursor (\S+))`, func(c *Command) Response {
+		return c.Machine.followJournal(c, c.Match[1])
+	})
+}
+
+// waitForReboot blocks until the machine reboots if it is still in the
+// given boot, like `sleep infinity` killed by the reboot.
+func (m *Machine) waitForReboot(bootID string) Response {
+	for waited := false; ; waited = true {
+		m.mu.Lock()
+		current, destroyed, changed := m.bootID, m.destroyed, m.changed
+		m.mu.Unlock()
+		if current != bootID && !waited {
+			return Response{}
+		}
+		if current != bootID || destroyed {
+			return Response{Disconnect: true}
+		}
+		<-changed
+	}
+}
+
+// followJournal streams the journal after the given cursor, or from the
+// start of the current boot, in export format until the machine reboots.
+func (m *Machine) followJournal(c *Command, cursor string) Response {
+	m.mu.Lock()
+	if m.destroyed {
+		m.mu.Unlock()
+		return Response{Disconnect: true}
+	}
+	m.followers.Add(1)
+	defer m.followers.Done()
+	bootID := m.bootID
+	next := 0
+	if cursor == "" {
+		for next < len(m.entries) && m.entries[next].boot != bootID {
+			next++
+		}
+	} else if i, err := strconv.Atoi(strings.TrimPrefix(cursor, "fake-")); err == nil {
+		next = i + 1
+	} else {
+		m.mu.Unlock()
+		return Response{Stderr: fmt.Sprintf("Failed to seek to cursor: %s", cursor), ExitStatus: 1}
+	}
+	m.mu.Unlock()
+
+	for {
+		m.mu.Lock()
+		var entries []journalEntry
+		if next < len(m.entries) {
+			entries = m.entries[next:]
+		}
+		rebooted := m.bootID != bootID
+		destroyed, changed := m.destroyed, m.changed
+		m.mu.Unlock()
+
+		for _, e := range entries {
+			if err := writeExport(c.Stdout, next, e); err != nil {
+				return Response{Disconnect: true}
+			}
+			next++
+			m.mu.Lock()
+			m.exported++
+			m.mu.Unlock()
+		}
+		if rebooted || destroyed {
+			return Response{Disconnect: true}
+		}
+		<-changed
+	}
+}
+
+// writeExport writes an entry in the journal export format.
+func writeExport(w io.Writer, cursor int, e journalEntry) error {
+	var buf bytes.Buffer
+	field := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", name, value)
+			return
+		}
+		buf.WriteString(name + "\n")
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+	field(journal.FIELD_CURSOR, fmt.Sprintf("fake-%d", cursor))
+	field(journal.FIELD_REALTIME_TIMESTAMP, strconv.FormatInt(e.realtime.UnixMicro(), 10))
+	field(journal.FIELD_MONOTONIC_TIMESTAMP, strconv.FormatInt(e.monotonic.Microseconds(), 10))
+	field(journal.FIELD_BOOT_ID, e.boot)
+	// kernel messages come from the kmsg transport, like on real machines
+	if e.identifier == "kernel" {
+		field(journal.FIELD_TRANSPORT, "kernel")
+	} else {
+		field(journal.FIELD_TRANSPORT, "journal")
+	}
+	field(journal.FIELD_SYSLOG_IDENTIFIER, e.identifier)
+	field(journal.FIELD_MESSAGE, e.message)
+	buf.WriteString("\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"io"
+	"regexp"
+	"sync"
+)
+
+// Command is a command run over SSH on a fake machine.
+type Command struct {
+	Machine *Machine
+	// Cmd is the command line, as given to the SSH session.
+	Cmd string
+	// Match holds the submatches of the pattern of the handler.
+	Match []string
+	Env   []string
+
+	// Stdin, Stdout and Stderr are the streams of the SSH session, for
+	// handlers which stream their output; Response.Stdout and
+	// Response.Stderr are written after the handler returns.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Response is the result of a command.
+type Response struct {
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+	// Disconnect ends the session without an exit status, as when sshd
+	// is killed, e.g. by a reboot.
+	Disconnect bool
+	// Reboot reboots the machine before the session ends.
+	Reboot bool
+}
+
+// Handler responds to a command.
+type Handler func(c *Command) Response
+
+type rule struct {
+	re      *regexp.Regexp
+	handler Handler
+}
+
+// Script maps commands to their responses. The zero value is an empty
+// script, and it is safe to modify while machines run it.
+type Script struct {
+	mu    sync.Mutex
+	rules []rule
+}
+
+// Handle makes handler respond to the commands which entirely match the
+// regular expression pattern; use regexp.QuoteMeta to match a command
+// literally. Patterns added later take precedence.
+func (s *Script) Handle(pattern string, handler Handler) {
+	re := regexp.MustCompile(`^(?:` + pattern + `)$`)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{re: re, handler: handler})
+}
+
+// Respond makes the commands matching pattern succeed with the given
+// output.
+func (s *Script) Respond(pattern, stdout string) {
+	s.Handle(pattern, func(*Command) Response {
+		return Response{Stdout: stdout}
+	})
+}
+
+// Fail makes the commands matching pattern fail with the given exit status
+// and error output.
+func (s *Script) Fail(pattern string, status int, stderr string) {
+	s.Handle(pattern, func(*Command) Response {
+		return Response{Stderr: stderr, ExitStatus: status}
+	})
+}
+
+// lookup returns the handler of cmd and the submatches of its pattern.
+func (s *Script) lookup(cmd string) (Handler, []string) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		if m := s.rules[i].re.FindStringSubmatch(cmd); m != nil {
+			return s.rules[i].handler, m
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/libvirt"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+const GiB = 1024 * 1024 * 1024
+
+type cluster struct {
+	*platform.BaseCluster
+	flight  *flight
+	network string
+
+	mu sync.Mutex
+	// kept is true if machines were left running
+	kept bool
+}
+
+func (lc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return lc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (lc *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if options.MultiPathDisk {
+		return nil, errors.New("platform libvirt does not support multipathed disks")
+	}
+	if options.AdditionalNics > 0 {
+		return nil, errors.New("platform libvirt does not support additional nics")
+	}
+	if options.AppendKernelArgs != "" {
+		return nil, errors.New("platform libvirt does not support appending kernel arguments")
+	}
+	if options.AppendFirstbootKernelArgs != "" {
+		return nil, errors.New("platform libvirt does not support appending firstboot kernel arguments")
+	}
+
+	conf, err := lc.RenderUserData(userdata, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if !conf.IsIgnition() && !conf.IsEmpty() {
+		return nil, fmt.Errorf("libvirt only supports Ignition or empty configs")
+	}
+
+	id := uuid.New()
+	lm := &machine{
+		cluster: lc,
+		id:      id,
+		name:    fmt.Sprintf("%s-%s", lc.flight.opts.BaseName, id),
+	}
+	dir := filepath.Join(lc.RuntimeConf().OutputDir, lm.name)
+	if err := os.Mkdir(dir, 0777); err != nil {
+		return nil, err
+	}
+	lm.consolePath = filepath.Join(dir, "console.txt")
+
+	spec, err := lc.domainSpec(lm, conf, options)
+	if err != nil {
+		lm.Destroy()
+		return nil, err
+	}
+	if conf.IsIgnition() {
+		if err := conf.WriteFile(filepath.Join(dir, "ignition.json")); err != nil {
+			lm.Destroy()
+			return nil, err
+		}
+	}
+
+	if err := lc.flight.api.CreateDomain(spec); err != nil {
+		lm.Destroy()
+		return nil, err
+	}
+	lm.running = true
+	if err := lm.startConsole(); err != nil {
+		lm.Destroy()
+		return nil, err
+	}
+
+	err = util.WaitUntilReady(5*time.Minute, 2*time.Second, func() (bool, error) {
+		ip, err := lc.flight.api.LeasedIP(lc.network, spec.MAC)
+		lm.ip = ip
+		return ip != "", err
+	})
+	if err != nil {
+		lm.Destroy()
+		return nil, fmt.Errorf("waiting for the IP address of %s: %v", lm.name, err)
+	}
+
+	if lm.journal, err = platform.NewJournal(dir); err != nil {
+		lm.Destroy()
+		return nil, err
+	}
+
+	// Run StartMachine, which blocks on the machine being booted up enough
+	// for SSH access, but only if the caller didn't tell us not to.
+	if !options.SkipStartMachine {
+		if err := platform.StartMachine(lm, lm.journal); err != nil {
+			lm.Destroy()
+			return nil, err
+		}
+	}
+
+	lc.AddMach(lm)
+
+	return lm, nil
+}
+
+// domainSpec creates the volumes of a machine and returns the description
+// of its domain.
+func (lc *cluster) domainSpec(lm *machine, conf *conf.Conf, options platform.MachineOptions) (*libvirt.DomainSpec, error) {
+	opts := lc.flight.opts
+	api := lc.flight.api
+
+	spec := &libvirt.DomainSpec{
+		Name:      lm.name,
+		UUID:      lm.id,
+		MemoryMiB: opts.Memory,
+		CPUs:      opts.CPUs,
+		Arch:      opts.Arch,
+		Firmware:  opts.Firmware,
+		Network:   lc.network,
+		MAC:       randomMAC(),
+	}
+	if options.MinMemory > spec.MemoryMiB {
+		spec.MemoryMiB = options.MinMemory
+	}
+
+	size := lc.flight.baseSize
+	if minSize := uint64(options.MinDiskSize) * GiB; minSize > size {
+		size = minSize
+	}
+	if err := lm.createVolume("disk.qcow2", func(name string) error {
+		return api.CreateOverlay(name, lc.flight.basePath, size)
+	}); err != nil {
+		return nil, err
+	}
+	for i, diskSpec := range options.AdditionalDisks {
+		diskSize, diskOpts, err := util.ParseDiskSpec(diskSpec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse spec %q: %w", diskSpec, err)
+		}
+		if len(diskOpts) > 0 {
+			return nil, fmt.Errorf("platform libvirt does not support disk options: %q", diskSpec)
+		}
+		if err := lm.createVolume(fmt.Sprintf("disk%d.qcow2", i+1), func(name string) error {
+			return api.CreateDisk(name, uint64(diskSize)*GiB)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	spec.Disks = append([]string(nil), lm.volumes...)
+
+	if conf.IsIgnition() {
+		// the config is uploaded to the pool, which unlike the output
+		// directory is accessible to the libvirt-managed QEMU
+		if err := lm.createVolume("ignition.json", func(name string) error {
+			var err error
+			spec.IgnitionPath, err = api.UploadData(name, []byte(conf.String()))
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// randomMAC returns a random MAC address in the range used by QEMU.
+func randomMAC() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		plog.Errorf("failed to generate a random MAC address: %v", err)
+	}
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", b[0], b[1], b[2])
+}
+
+// keep records that a machine of the cluster was left running.
+func (lc *cluster) keep() {
+	lc.mu.Lock()
+	lc.kept = true
+	lc.mu.Unlock()
+	lc.flight.keep()
+}
+
+func (lc *cluster) Destroy() {
+	lc.BaseCluster.Destroy()
+
+	if lc.kept {
+		plog.Noticef("Keeping network %s of the machines left running", lc.network)
+	} else if err := lc.flight.api.DestroyNetwork(lc.network); err != nil {
+		plog.Errorf("Error destroying network %s: %v", lc.network, err)
+	}
+	lc.flight.DelCluster(lc)
+}
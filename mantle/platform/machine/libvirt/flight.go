@@ -0,0 +1,139 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+	"github.com/coreos/coreos-assembler/mantle/platform/api/libvirt"
+	"github.com/coreos/coreos-assembler/mantle/platform/conf"
+	"github.com/coreos/coreos-assembler/mantle/util"
+)
+
+const (
+	Platform platform.Name = "libvirt"
+)
+
+var (
+	plog = capnslog.NewPackageLogger("github.com/coreos/coreos-assembler/mantle", "platform/machine/libvirt")
+)
+
+type flight struct {
+	*platform.BaseFlight
+	opts *libvirt.Options
+	api  *libvirt.API
+
+	// the disk image, uploaded once to back the disks of all machines
+	baseVolume string
+	basePath   string
+	baseSize   uint64
+
+	mu sync.Mutex
+	// kept is true if machines of failed tests were left running, in
+	// which case the resources they use are kept too
+	kept bool
+}
+
+func NewFlight(opts *libvirt.Options) (platform.Flight, error) {
+	if opts.DiskImage == "" {
+		return nil, fmt.Errorf("no disk image specified")
+	}
+	info, err := util.GetImageInfo(opts.DiskImage)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting %s: %v", opts.DiskImage, err)
+	}
+	if info.Format != "qcow2" {
+		return nil, fmt.Errorf("%s is in format %s; the libvirt platform requires a qcow2 image", opts.DiskImage, info.Format)
+	}
+
+	bf, err := platform.NewBaseFlight(opts.Options, Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := libvirt.New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	lf := &flight{
+		BaseFlight: bf,
+		opts:       opts,
+		api:        api,
+		baseVolume: bf.Name() + ".qcow2",
+		baseSize:   info.VirtualSize,
+	}
+	lf.basePath, err = api.UploadImage(lf.baseVolume, opts.DiskImage)
+	if err != nil {
+		lf.baseVolume = ""
+		lf.Destroy()
+		return nil, err
+	}
+
+	return lf, nil
+}
+
+func (lf *flight) ConfigTooLarge(ud conf.UserData) bool {
+
+	// not implemented
+	return false
+}
+
+// NewCluster creates a Cluster instance, whose machines share a libvirt
+// network.
+func (lf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
+	bc, err := platform.NewBaseCluster(lf.BaseFlight, rconf)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &cluster{
+		BaseCluster: bc,
+		flight:      lf,
+		network:     bc.Name(),
+	}
+	if err := lf.api.CreateNetwork(lc.network, rconf.InternetAccess); err != nil {
+		return nil, err
+	}
+
+	lf.AddCluster(lc)
+
+	return lc, nil
+}
+
+// keep records that resources of the flight are used by a machine left
+// running.
+func (lf *flight) keep() {
+	lf.mu.Lock()
+	lf.kept = true
+	lf.mu.Unlock()
+}
+
+func (lf *flight) Destroy() {
+	lf.BaseFlight.Destroy()
+
+	if lf.baseVolume != "" {
+		if lf.kept {
+			plog.Noticef("Keeping volume %s backing the machines left running", lf.baseVolume)
+		} else if err := lf.api.DeleteVolume(lf.baseVolume); err != nil {
+			plog.Errorf("Error deleting volume %s: %v", lf.baseVolume, err)
+		}
+	}
+	lf.api.Close()
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/coreos-assembler/mantle/platform"
+)
+
+type machine struct {
+	cluster *cluster
+	id      string
+	// name is the name of the domain, and the ID of the machine
+	name    string
+	volumes []string
+	running bool
+	ip      string
+	journal *platform.Journal
+
+	consolePath string
+	consoleDone chan struct{}
+	console     string
+}
+
+func (lm *machine) ID() string {
+	return lm.name
+}
+
+func (lm *machine) IP() string {
+	return lm.ip
+}
+
+func (lm *machine) PrivateIP() string {
+	return lm.ip
+}
+
+func (lm *machine) RuntimeConf() platform.RuntimeConfig {
+	return lm.cluster.RuntimeConf()
+}
+
+func (lm *machine) SSHClient() (*ssh.Client, error) {
+	return lm.cluster.SSHClient(lm.IP())
+}
+
+func (lm *machine) PasswordSSHClient(user string, password string) (*ssh.Client, error) {
+	return lm.cluster.PasswordSSHClient(lm.IP(), user, password)
+}
+
+func (lm *machine) SSH(cmd string) ([]byte, []byte, error) {
+	return lm.cluster.SSH(lm, cmd)
+}
+
+func (lm *machine) IgnitionError() error {
+	return nil
+}
+
+func (lm *machine) Start() error {
+	return platform.StartMachine(lm, lm.journal)
+}
+
+func (lm *machine) Reboot() error {
+	return platform.RebootMachine(lm, lm.journal)
+}
+
+func (lm *machine) WaitForReboot(timeout time.Duration, oldBootId string) error {
+	return platform.WaitForMachineReboot(lm, lm.journal, timeout, oldBootId)
+}
+
+// createVolume creates the volume of the machine with the given suffix
+// using create, and records it for deletion.
+func (lm *machine) createVolume(suffix string, create func(name string) error) error {
+	name := lm.name + "-" + suffix
+	if err := create(name); err != nil {
+		return err
+	}
+	lm.volumes = append(lm.volumes, name)
+	return nil
+}
+
+// startConsole records the serial console of the domain to consolePath
+// until it stops.
+func (lm *machine) startConsole() error {
+	f, err := os.OpenFile(lm.consolePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	lm.consoleDone = make(chan struct{})
+	go func() {
+		defer close(lm.consoleDone)
+		defer f.Close()
+		// the stream is usually cut when the domain is destroyed
+		if err := lm.cluster.flight.api.StreamConsole(lm.name, f); err != nil {
+			plog.Debugf("Recording console of %s: %v", lm.name, err)
+		}
+	}()
+	return nil
+}
+
+// keep returns true if the machine should be left running for inspection.
+func (lm *machine) keep() bool {
+	failed := lm.RuntimeConf().TestFailed
+	return lm.running && lm.cluster.flight.opts.KeepFailed && failed != nil && failed()
+}
+
+func (lm *machine) Destroy() {
+	api := lm.cluster.flight.api
+	kept := lm.keep()
+	if kept {
+		lm.cluster.keep()
+		plog.Noticef("Leaving machine %s running for inspection; remove it with `virsh destroy %s` and `virsh vol-delete --pool %s` on its volumes %s",
+			lm.name, lm.name, lm.cluster.flight.opts.Pool, strings.Join(lm.volumes, ", "))
+	} else if lm.running {
+		lm.running = false
+		if err := api.DestroyDomain(lm.name); err != nil {
+			plog.Errorf("Error destroying domain %s: %v", lm.name, err)
+		}
+	}
+
+	if lm.journal != nil {
+		lm.journal.Destroy()
+	}
+
+	if lm.consoleDone != nil && !kept {
+		// the console stream ends with the domain
+		select {
+		case <-lm.consoleDone:
+		case <-time.After(10 * time.Second):
+			plog.Warningf("Timed out waiting for the console of %s to close", lm.name)
+		}
+	}
+	if buf, err := os.ReadFile(lm.consolePath); err == nil {
+		lm.console = string(buf)
+	} else if lm.consoleDone != nil {
+		plog.Errorf("Error reading console for instance %v: %v", lm.ID(), err)
+	}
+
+	if !kept {
+		for _, volume := range lm.volumes {
+			if err := api.DeleteVolume(volume); err != nil {
+				plog.Errorf("Error deleting volume %s: %v", volume, err)
+			}
+		}
+	}
+
+	lm.cluster.DelMach(lm)
+}
+
+func (lm *machine) ConsoleOutput() string {
+	return lm.console
+}
+
+func (lm *machine) JournalOutput() string {
+	if lm.journal == nil {
+		return ""
+	}
+
+	data, err := lm.journal.Read()
+	if err != nil {
+		plog.Errorf("Reading journal for instance %v: %v", lm.ID(), err)
+	}
+	return string(data)
+}
+
+// String is used in log messages.
+func (lm *machine) String() string {
+	return fmt.Sprintf("%s (%s)", lm.name, lm.ip)
+}
@@ -16,6 +16,7 @@ package qemu
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -27,6 +28,7 @@ import (
 	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 
+	"github.com/coreos/coreos-assembler/mantle/network/ntp"
 	"github.com/coreos/coreos-assembler/mantle/platform"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 	"github.com/coreos/coreos-assembler/mantle/util"
@@ -41,6 +43,31 @@ type Cluster struct {
 	flight *flight
 
 	mu sync.Mutex
+
+	// see platform.RuntimeConfig.NTPServer
+	ntpServer *ntp.Server
+}
+
+// usermodeHostAddr is the address of the host in the user mode network of
+// the guests.
+const usermodeHostAddr = "10.0.2.2"
+
+// chronyConf points chrony to the NTP server of the cluster. Polling every
+// few seconds and stepping the clock on any large offset makes the guests
+// follow the time changes of the server promptly.
+const chronyConf = `# Written by kola: the time is served by the NTP server of the cluster
+server %s port %d iburst minpoll 0 maxpoll 2
+makestep 1 -1
+rtcsync
+`
+
+// NTPServer implements platform.ClockCluster.
+func (qc *Cluster) NTPServer() (*ntp.Server, string) {
+	if qc.ntpServer == nil {
+		return nil, ""
+	}
+	port := qc.ntpServer.LocalAddr().(*net.UDPAddr).Port
+	return qc.ntpServer, net.JoinHostPort(usermodeHostAddr, strconv.Itoa(port))
 }
 
 func (qc *Cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
@@ -95,6 +122,17 @@ func (qc *Cluster) NewMachineWithQemuOptions(userdata *conf.UserData, options pl
 		}
 	}
 
+	if qc.ntpServer != nil {
+		if !conf.IsIgnition() {
+			return nil, fmt.Errorf("an NTP server requires an Ignition config")
+		}
+		// chronyd.service reads its options from the last environment file
+		port := qc.ntpServer.LocalAddr().(*net.UDPAddr).Port
+		conf.AddFile("/etc/kola/chrony.conf", fmt.Sprintf(chronyConf, usermodeHostAddr, port), 0644)
+		conf.AddFile("/etc/kola/chronyd.env", "OPTIONS=\"-f /etc/kola/chrony.conf\"\n", 0644)
+		conf.AddSystemdUnitDropin("chronyd.service", "kola-ntp.conf", "[Service]\nEnvironmentFile=/etc/kola/chronyd.env\n")
+	}
+
 	var confPath string
 	if conf.IsIgnition() {
 		confPath = filepath.Join(dir, "ignition.json")
@@ -229,5 +267,8 @@ func (qc *Cluster) NewMachineWithQemuOptions(userdata *conf.UserData, options pl
 
 func (qc *Cluster) Destroy() {
 	qc.BaseCluster.Destroy()
+	if qc.ntpServer != nil {
+		qc.ntpServer.Close()
+	}
 	qc.flight.DelCluster(qc)
 }
@@ -15,8 +15,11 @@
 package qemu
 
 import (
+	"errors"
+
 	"github.com/coreos/pkg/capnslog"
 
+	"github.com/coreos/coreos-assembler/mantle/network/ntp"
 	"github.com/coreos/coreos-assembler/mantle/platform"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 )
@@ -89,6 +92,12 @@ func (af *flight) ConfigTooLarge(ud conf.UserData) bool {
 // NewCluster creates a Cluster instance, suitable for running virtual
 // machines in QEMU.
 func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
+	// guestfwd only supports TCP, so the machines can only reach the NTP
+	// server with unrestricted networking
+	if rconf.NTPServer && !rconf.InternetAccess {
+		return nil, errors.New("an NTP server requires Internet access; tag the test with needs-internet")
+	}
+
 	bc, err := platform.NewBaseCluster(qf.BaseFlight, rconf)
 	if err != nil {
 		return nil, err
@@ -99,6 +108,16 @@ func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, e
 		flight:      qf,
 	}
 
+	if rconf.NTPServer {
+		// guests reach the loopback of the host on usermodeHostAddr
+		qc.ntpServer, err = ntp.NewServer("127.0.0.1:0")
+		if err != nil {
+			bc.Destroy()
+			return nil, err
+		}
+		go qc.ntpServer.Serve()
+	}
+
 	qf.AddCluster(qc)
 
 	return qc, nil
@@ -17,6 +17,7 @@ package qemu
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -120,3 +121,71 @@ func (m *machine) JournalOutput() string {
 func (m *machine) RemovePrimaryBlockDevice() error {
 	return m.inst.RemovePrimaryBlockDevice()
 }
+
+func (m *machine) SaveSnapshot(name string) error {
+	return m.inst.SaveSnapshot(name)
+}
+
+func (m *machine) RestoreSnapshot(name string) error {
+	if err := m.inst.LoadSnapshot(name); err != nil {
+		return err
+	}
+	// The guest clock is that of the snapshot; catch up with the RTC,
+	// which follows the host.
+	if out, stderr, err := m.SSH("sudo hwclock --hctosys"); err != nil {
+		plog.Warningf("Failed to set the clock of %s after restoring snapshot %s: %s: %v: %s", m.ID(), name, out, err, stderr)
+	}
+	// The journal connection didn't survive; resume recording
+	if err := m.journal.Start(context.TODO(), m, ""); err != nil {
+		return fmt.Errorf("machine %q failed to resume journal: %v", m.ID(), err)
+	}
+	return nil
+}
+
+func (m *machine) DeleteSnapshot(name string) error {
+	return m.inst.DeleteSnapshot(name)
+}
+
+func (m *machine) SetNICLink(nic string, up bool) error {
+	return m.inst.SetNICLink(nic, up)
+}
+
+func (m *machine) ThrottleDisk(disk string, limits platform.IOThrottle) error {
+	return m.inst.ThrottleDisk(disk, limits)
+}
+
+func (m *machine) SetDiskIOErrors(disk string, reads, writes bool) error {
+	return m.inst.SetDiskIOErrors(disk, reads, writes)
+}
+
+func (m *machine) UnplugDisk(disk string) error {
+	return m.inst.UnplugDisk(disk)
+}
+
+func (m *machine) ReplugDisk(disk string) error {
+	return m.inst.ReplugDisk(disk)
+}
+
+func (m *machine) UnplugNIC(nic string) error {
+	return m.inst.UnplugNIC(nic)
+}
+
+func (m *machine) ReplugNIC(nic string) error {
+	return m.inst.ReplugNIC(nic)
+}
+
+func (m *machine) Pause() error {
+	return m.inst.Pause()
+}
+
+func (m *machine) Resume() error {
+	return m.inst.Resume()
+}
+
+func (m *machine) PauseVCPU(index int) error {
+	return m.inst.PauseVCPU(index)
+}
+
+func (m *machine) ResumeVCPU(index int) error {
+	return m.inst.ResumeVCPU(index)
+}
@@ -32,6 +32,7 @@ import (
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/net/context"
 
+	"github.com/coreos/coreos-assembler/mantle/network/ntp"
 	"github.com/coreos/coreos-assembler/mantle/platform/conf"
 	"github.com/coreos/coreos-assembler/mantle/util"
 )
@@ -129,6 +130,17 @@ type Cluster interface {
 	SSHOnTestFailure() bool
 }
 
+// ClockCluster is a cluster whose machines can get their time from an NTP
+// server run by kola, see RuntimeConfig.NTPServer.
+type ClockCluster interface {
+	Cluster
+
+	// NTPServer returns the NTP server of the cluster and its address as
+	// reachable from the machines, or nil if RuntimeConfig.NTPServer isn't
+	// set.
+	NTPServer() (*ntp.Server, string)
+}
+
 // Flight represents a group of Clusters within a single platform.
 type Flight interface {
 	// NewCluster creates a new Cluster.
@@ -208,12 +220,21 @@ type RuntimeConfig struct {
 	AllowFailedUnits   bool                // don't fail CheckMachine if a systemd unit has failed
 	WarningsAction     conf.WarningsAction // what to do on Ignition or Butane validation warnings
 
+	// NTPServer is true if the machines should get their time from an NTP
+	// server controlled through ClockCluster; on QEMU, it requires
+	// InternetAccess
+	NTPServer bool
+
 	// InternetAccess is true if the cluster should be Internet connected
 	InternetAccess bool
 	EarlyRelease   func()
 
 	// whether a Manhole into a machine should be created on detected failure
 	SSHOnTestFailure bool
+
+	// TestFailed, if set, reports whether the test using the cluster has
+	// failed, for platforms that can keep machines for inspection
+	TestFailed func() bool
 }
 
 // Wrap a StdoutPipe as a io.ReadCloser
@@ -39,6 +39,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -82,6 +83,38 @@ type QEMUMachine interface {
 	// RemovePrimaryBlockDevice removes the primary device from a given qemu
 	// instance and sets the secondary device as primary.
 	RemovePrimaryBlockDevice() error
+
+	// SaveSnapshot saves the memory and disk state of the machine under
+	// name, e.g. to run several subtests from the same provisioned state.
+	SaveSnapshot(name string) error
+
+	// RestoreSnapshot brings the machine back to the state saved under
+	// name and resumes recording its journal.
+	RestoreSnapshot(name string) error
+
+	// DeleteSnapshot deletes the state saved under name.
+	DeleteSnapshot(name string) error
+
+	// Fault injection, see qemu_faults.go for the naming of disks and NICs.
+
+	// SetNICLink brings the link of a NIC up or down.
+	SetNICLink(nic string, up bool) error
+	// ThrottleDisk limits the I/O of a disk.
+	ThrottleDisk(disk string, limits IOThrottle) error
+	// SetDiskIOErrors makes reads and/or writes of a disk fail.
+	SetDiskIOErrors(disk string, reads, writes bool) error
+	// UnplugDisk and ReplugDisk hot-unplug and plug back a disk.
+	UnplugDisk(disk string) error
+	ReplugDisk(disk string) error
+	// UnplugNIC and ReplugNIC hot-unplug and plug back a NIC.
+	UnplugNIC(nic string) error
+	ReplugNIC(nic string) error
+	// Pause and Resume stop and restart the whole machine.
+	Pause() error
+	Resume() error
+	// PauseVCPU and ResumeVCPU stop and restart a single vCPU.
+	PauseVCPU(index int) error
+	ResumeVCPU(index int) error
 }
 
 // Disk holds the details of a virtual disk.
@@ -155,8 +188,17 @@ type QemuInstance struct {
 
 	journalPipe *os.File
 
-	qmpSocket     *qmp.SocketMonitor
+	qmpSocket     qmp.Monitor
 	qmpSocketPath string
+
+	// state of the faults injected into the instance, see qemu_faults.go
+	devices     map[string]string
+	pcieRootBus bool
+	faultsMu    sync.Mutex
+	unplugged   map[string]bool
+	heldDisks   map[string]string // format node of the disk
+	faultyDisks map[string]diskFault
+	pausedVCPUs map[int]*pausedVCPU
 }
 
 // Signaled returns whether QEMU process was signaled.
@@ -271,6 +313,8 @@ func (inst *QemuInstance) WaitAll(ctx context.Context) error {
 
 // Destroy kills the instance and associated sidecar processes.
 func (inst *QemuInstance) Destroy() {
+	// detach from stopped vCPU threads so the process can exit
+	inst.resumeVCPUs()
 	if inst.qmpSocket != nil {
 		inst.qmpSocket.Disconnect() //nolint // Ignore Errors
 		inst.qmpSocket = nil
@@ -422,6 +466,34 @@ func (inst *QemuInstance) RemovePrimaryBlockDevice() (err2 error) {
 	return nil
 }
 
+// SaveSnapshot saves the state of the VM, i.e. its memory, devices and
+// qcow2 disks, as an internal snapshot named tag. The VM is paused while
+// the snapshot is taken.
+func (inst *QemuInstance) SaveSnapshot(tag string) error {
+	if err := inst.runSnapshotJob("snapshot-save", tag); err != nil {
+		return errors.Wrapf(err, "Saving snapshot %s", tag)
+	}
+	return nil
+}
+
+// LoadSnapshot restores the state of the VM saved by SaveSnapshot. Disk
+// changes made since are discarded and the VM resumes from the snapshot;
+// connections from the host to the VM don't survive this.
+func (inst *QemuInstance) LoadSnapshot(tag string) error {
+	if err := inst.runSnapshotJob("snapshot-load", tag); err != nil {
+		return errors.Wrapf(err, "Loading snapshot %s", tag)
+	}
+	return nil
+}
+
+// DeleteSnapshot deletes a snapshot saved by SaveSnapshot.
+func (inst *QemuInstance) DeleteSnapshot(tag string) error {
+	if err := inst.runSnapshotJob("snapshot-delete", tag); err != nil {
+		return errors.Wrapf(err, "Deleting snapshot %s", tag)
+	}
+	return nil
+}
+
 // A directory mounted from the host into the guest, via 9p or virtiofs
 type HostMount struct {
 	src      string
@@ -494,6 +566,11 @@ type QemuBuilder struct {
 	hostMounts []HostMount
 	// fds is file descriptors we own to pass to qemu
 	fds []*os.File
+	// devices holds the -device arguments of the disks and NICs by drive
+	// or netdev id, to plug them back after hot-unplugging them
+	devices map[string]string
+	// q35 is set if the machine type is q35, for UEFI on x86_64
+	q35 bool
 
 	// IBM Secure Execution
 	secureExecution bool
@@ -598,6 +675,16 @@ func virtio(arch, device, args string) string {
 	return fmt.Sprintf("virtio-%s-%s,%s", device, suffix, args)
 }
 
+// appendDevice adds a -device argument for the disk or NIC whose drive or
+// netdev is backend, remembering it for hotplug.
+func (builder *QemuBuilder) appendDevice(backend, device string) {
+	if builder.devices == nil {
+		builder.devices = make(map[string]string)
+	}
+	builder.devices[backend] = device
+	builder.Append("-device", device)
+}
+
 // EnableUsermodeNetworking configure forwarding for all requested ports,
 // via usermode network helpers.
 func (builder *QemuBuilder) EnableUsermodeNetworking(h []HostForwardPort, usernetAddr string) {
@@ -668,7 +755,8 @@ func (builder *QemuBuilder) setupNetworking() error {
 		builder.Append("-boot", "order=n")
 	}
 
-	builder.Append("-netdev", netdev, "-device", virtio(builder.architecture, "net", "netdev=eth0"))
+	builder.Append("-netdev", netdev)
+	builder.appendDevice("eth0", virtio(builder.architecture, "net", "netdev=eth0"))
 	return nil
 }
 
@@ -682,7 +770,8 @@ func (builder *QemuBuilder) setupAdditionalNetworking() error {
 
 		netdev := fmt.Sprintf("user,id=eth%s,dhcpstart=10.0.2.%s", idSuffix, netSuffix)
 		device := virtio(builder.architecture, "net", fmt.Sprintf("netdev=eth%s,mac=52:55:00:d1:56:%s", idSuffix, macSuffix))
-		builder.Append("-netdev", netdev, "-device", device)
+		builder.Append("-netdev", netdev)
+		builder.appendDevice("eth"+idSuffix, device)
 		macCounter++
 	}
 
@@ -1203,7 +1292,7 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 			pID := fmt.Sprintf("mpath%d%d", builder.diskID, i)
 			scsiID := fmt.Sprintf("scsi_%s", pID)
 			builder.Append("-device", fmt.Sprintf("virtio-scsi-%s,id=%s", bus, scsiID))
-			builder.Append("-device",
+			builder.appendDevice(pID,
 				fmt.Sprintf("scsi-hd,bus=%s.0,drive=%s,vendor=NVME,product=VirtualMultipath,wwn=%d%s",
 					scsiID, pID, wwn, opts))
 			builder.Append("-drive", fmt.Sprintf("if=none,id=%s,format=raw,file=%s,media=disk,%s",
@@ -1219,9 +1308,9 @@ func (builder *QemuBuilder) addDiskImpl(disk *Disk, primary bool) error {
 		disk.dstFileName = ""
 		switch channel {
 		case "virtio":
-			builder.Append("-device", virtio(builder.architecture, "blk", fmt.Sprintf("drive=%s%s", id, opts)))
+			builder.appendDevice(id, virtio(builder.architecture, "blk", fmt.Sprintf("drive=%s%s", id, opts)))
 		case "nvme":
-			builder.Append("-device", fmt.Sprintf("nvme,drive=%s%s", id, opts))
+			builder.appendDevice(id, fmt.Sprintf("nvme,drive=%s%s", id, opts))
 		default:
 			panic(fmt.Sprintf("Unhandled channel: %s", channel))
 		}
@@ -1403,6 +1492,7 @@ func (builder *QemuBuilder) setupUefi(secureBoot bool) error {
 		builder.Append("-drive", fmt.Sprintf("file=/usr/share/edk2/ovmf/OVMF_CODE%s.fd,if=pflash,format=raw,unit=0,readonly=on,auto-read-only=off", varsVariant))
 		builder.Append("-drive", fmt.Sprintf("file=%s,if=pflash,format=raw,unit=1,readonly=off,auto-read-only=off", fdset))
 		builder.Append("-machine", "q35")
+		builder.q35 = true
 	case "aarch64":
 		if secureBoot {
 			return fmt.Errorf("architecture %s doesn't have support for secure boot in kola", coreosarch.CurrentRpmArch())
@@ -1993,6 +2083,10 @@ func (builder *QemuBuilder) Exec() (*QemuInstance, error) {
 		}()
 	}
 
+	inst.devices = builder.devices
+	// the devices of the q35 and virt machines are plugged into their PCI
+	// Express root bus
+	inst.pcieRootBus = builder.q35 || builder.architecture == "aarch64"
 	return &inst, nil
 }
 
@@ -0,0 +1,582 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Injection of infrastructure faults into a running qemu instance, mostly
+// through QMP. Disks are named after their drive id: disk-1 for the primary
+// disk, disk-2 etc. for the additional ones, and mpath<N>0 and mpath<N>1 for
+// the two paths of a multipath disk. NICs are named after their netdev id:
+// eth0 for the primary NIC, eth1 etc. for the additional ones.
+//
+// Hot-unplugging needs a bus which supports hotplug. The PCI Express root bus
+// of the q35 machine used for UEFI on x86_64 and of the virt machine of
+// aarch64 doesn't, so there only the SCSI disks of multipath can be
+// unplugged.
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// IOThrottle holds I/O limits for a disk; zero means unlimited.
+type IOThrottle struct {
+	BPS       int64 // bytes per second
+	BPSRead   int64
+	BPSWrite  int64
+	IOPS      int64 // I/O operations per second
+	IOPSRead  int64
+	IOPSWrite int64
+}
+
+// diskFault is an I/O error injection in progress on a disk.
+type diskFault struct {
+	format     string // node whose file child was replaced
+	formatDrv  string
+	protocol   string // original file child
+	cache      QMPBlockCache
+	blkdebug   string // replacement file child
+	readsFail  bool
+	writesFail bool
+}
+
+// pausedVCPU is a vCPU thread stopped with ptrace. All ptrace requests
+// must come from the same OS thread, hence the dedicated goroutine.
+type pausedVCPU struct {
+	resume chan struct{}
+	done   chan error
+}
+
+// QMPBlockCache holds the cache options of a block node.
+type QMPBlockCache struct {
+	Direct  bool `json:"direct"`
+	NoFlush bool `json:"no-flush"`
+}
+
+// QMPBlockNodes is the result of a query-named-block-nodes command.
+type QMPBlockNodes struct {
+	Return []struct {
+		NodeName string        `json:"node-name"`
+		Driver   string        `json:"drv"`
+		File     string        `json:"file"`
+		ReadOnly bool          `json:"ro"`
+		Cache    QMPBlockCache `json:"cache"`
+	} `json:"return"`
+}
+
+// QMPCPUs is the result of a query-cpus-fast command.
+type QMPCPUs struct {
+	Return []struct {
+		CPUIndex int `json:"cpu-index"`
+		ThreadID int `json:"thread-id"`
+	} `json:"return"`
+}
+
+// deviceRemovalTimeout is how long the guest has to release a device
+// being hot-unplugged.
+const deviceRemovalTimeout = 30 * time.Second
+
+// runQmp executes a qemu command with arguments over the QMP socket.
+func (inst *QemuInstance) runQmp(command string, args interface{}) ([]byte, error) {
+	cmd := map[string]interface{}{"execute": command}
+	if args != nil {
+		cmd["arguments"] = args
+	}
+	buf, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return inst.runQmpCommand(string(buf))
+}
+
+// runHmp executes a human monitor command, returning an error if it
+// printed one.
+func (inst *QemuInstance) runHmp(cmd string) error {
+	out, err := inst.runQmp("human-monitor-command", map[string]interface{}{"command-line": cmd})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Return string `json:"return"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return errors.Wrapf(err, "De-serializing human-monitor-command output")
+	}
+	if msg := strings.TrimSpace(res.Return); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// SetNICLink brings the link of a NIC up or down, as if its cable was
+// plugged or unplugged.
+func (inst *QemuInstance) SetNICLink(nic string, up bool) error {
+	if _, err := inst.runQmp("set_link", map[string]interface{}{"name": nic, "up": up}); err != nil {
+		return errors.Wrapf(err, "Setting link of %s up=%v", nic, up)
+	}
+	return nil
+}
+
+// ThrottleDisk limits the I/O of a disk; use a zero IOThrottle to remove
+// the limits.
+func (inst *QemuInstance) ThrottleDisk(disk string, limits IOThrottle) error {
+	qdev, err := inst.diskQdev(disk)
+	if err != nil {
+		return err
+	}
+	args := map[string]interface{}{
+		"id":      qdev,
+		"bps":     limits.BPS,
+		"bps_rd":  limits.BPSRead,
+		"bps_wr":  limits.BPSWrite,
+		"iops":    limits.IOPS,
+		"iops_rd": limits.IOPSRead,
+		"iops_wr": limits.IOPSWrite,
+	}
+	if _, err := inst.runQmp("block_set_io_throttle", args); err != nil {
+		return errors.Wrapf(err, "Throttling disk %s", disk)
+	}
+	return nil
+}
+
+// SetDiskIOErrors makes the data reads and/or writes of a disk fail with
+// EIO, by inserting a blkdebug node between the disk image and its file.
+// Passing false for both removes the node.
+func (inst *QemuInstance) SetDiskIOErrors(disk string, reads, writes bool) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if inst.faultyDisks == nil {
+		inst.faultyDisks = make(map[string]diskFault)
+	}
+
+	fault, faulty := inst.faultyDisks[disk]
+	if faulty {
+		if fault.readsFail == reads && fault.writesFail == writes {
+			return nil
+		}
+		// restore the disk before injecting other errors
+		if err := inst.reopenFile(fault, fault.protocol); err != nil {
+			return errors.Wrapf(err, "Removing I/O errors from disk %s", disk)
+		}
+		if _, err := inst.runQmp("blockdev-del", map[string]interface{}{"node-name": fault.blkdebug}); err != nil {
+			return errors.Wrapf(err, "Removing I/O errors from disk %s", disk)
+		}
+		delete(inst.faultyDisks, disk)
+	}
+	if !reads && !writes {
+		return nil
+	}
+	if !faulty {
+		var err error
+		if fault, err = inst.diskNodes(disk); err != nil {
+			return err
+		}
+	}
+
+	var rules []map[string]interface{}
+	if reads {
+		rules = append(rules, map[string]interface{}{"event": "read_aio", "errno": unix.EIO})
+	}
+	if writes {
+		rules = append(rules, map[string]interface{}{"event": "write_aio", "errno": unix.EIO})
+	}
+	fault.blkdebug = "faulty-" + disk
+	fault.readsFail, fault.writesFail = reads, writes
+	args := map[string]interface{}{
+		"driver":       "blkdebug",
+		"node-name":    fault.blkdebug,
+		"image":        fault.protocol,
+		"inject-error": rules,
+	}
+	if _, err := inst.runQmp("blockdev-add", args); err != nil {
+		return errors.Wrapf(err, "Adding blkdebug node for disk %s", disk)
+	}
+	if err := inst.reopenFile(fault, fault.blkdebug); err != nil {
+		_, _ = inst.runQmp("blockdev-del", map[string]interface{}{"node-name": fault.blkdebug})
+		return errors.Wrapf(err, "Injecting I/O errors into disk %s", disk)
+	}
+	inst.faultyDisks[disk] = fault
+	return nil
+}
+
+// reopenFile replaces the file child of the format node of a disk, keeping
+// its cache options.
+func (inst *QemuInstance) reopenFile(fault diskFault, file string) error {
+	args := map[string]interface{}{
+		"options": []map[string]interface{}{{
+			"driver":    fault.formatDrv,
+			"node-name": fault.format,
+			"file":      file,
+			"cache":     fault.cache,
+		}},
+	}
+	_, err := inst.runQmp("blockdev-reopen", args)
+	return err
+}
+
+// diskNodes finds the format node of a disk and its file child.
+func (inst *QemuInstance) diskNodes(disk string) (diskFault, error) {
+	var fault diskFault
+	blkdevs, err := inst.listBlkDevices()
+	if err != nil {
+		return fault, errors.Wrapf(err, "Could not list block devices through qmp")
+	}
+	for _, dev := range blkdevs.Return {
+		if dev.Device == disk || (dev.Device == "" && dev.Inserted.NodeName == holdNode(disk)) {
+			fault.format = dev.Inserted.NodeName
+		}
+	}
+	if fault.format == "" {
+		return fault, fmt.Errorf("disk %s not found", disk)
+	}
+
+	out, err := inst.runQmp("query-named-block-nodes", map[string]interface{}{"flat": true})
+	if err != nil {
+		return fault, errors.Wrapf(err, "Running QMP query-named-block-nodes command")
+	}
+	var nodes QMPBlockNodes
+	if err := json.Unmarshal(out, &nodes); err != nil {
+		return fault, errors.Wrapf(err, "De-serializing QMP query-named-block-nodes output")
+	}
+	// a replugged disk is attached through its hold node
+	if fault.format == holdNode(disk) {
+		fault.format = inst.heldDisks[disk]
+	}
+	var filename string
+	for _, node := range nodes.Return {
+		if node.NodeName == fault.format {
+			fault.formatDrv = node.Driver
+			fault.cache = node.Cache
+			filename = node.File
+		}
+	}
+	// the file child has the same file name as the image
+	for _, node := range nodes.Return {
+		if node.NodeName == fault.format || node.File != filename {
+			continue
+		}
+		switch node.Driver {
+		case "file", "host_device", "nbd":
+			fault.protocol = node.NodeName
+		}
+	}
+	if fault.formatDrv == "" || fault.protocol == "" {
+		return fault, fmt.Errorf("could not find the block nodes of disk %s", disk)
+	}
+	return fault, nil
+}
+
+// diskQdev returns the QOM path of the device of a disk.
+func (inst *QemuInstance) diskQdev(disk string) (string, error) {
+	blkdevs, err := inst.listBlkDevices()
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not list block devices through qmp")
+	}
+	for _, dev := range blkdevs.Return {
+		if dev.Device == disk || (dev.Device == "" && dev.Inserted.NodeName == holdNode(disk)) {
+			if dev.DevicePath == "" {
+				return "", fmt.Errorf("disk %s is unplugged", disk)
+			}
+			return dev.DevicePath, nil
+		}
+	}
+	return "", fmt.Errorf("disk %s not found", disk)
+}
+
+// holdNode is the name of the node keeping the image of an unplugged disk
+// open; the disk is plugged back through it.
+func holdNode(disk string) string {
+	return "hold-" + disk
+}
+
+// UnplugDisk hot-unplugs a disk, keeping its image for ReplugDisk. The
+// bus of the disk must support hotplug.
+func (inst *QemuInstance) UnplugDisk(disk string) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if inst.unplugged[disk] {
+		return fmt.Errorf("disk %s is already unplugged", disk)
+	}
+	if _, ok := inst.devices[disk]; !ok {
+		return fmt.Errorf("disk %s not found", disk)
+	}
+	if err := inst.checkHotplug(disk); err != nil {
+		return err
+	}
+	qdev, err := inst.diskQdev(disk)
+	if err != nil {
+		return err
+	}
+	qdev = strings.TrimSuffix(qdev, "/virtio-backend")
+
+	// Removing the device deletes its drive and closes the image unless
+	// another node references it, which is the job of the hold node.
+	if _, held := inst.heldDisks[disk]; !held {
+		blkdevs, err := inst.listBlkDevices()
+		if err != nil {
+			return errors.Wrapf(err, "Could not list block devices through qmp")
+		}
+		var format string
+		for _, dev := range blkdevs.Return {
+			if dev.Device == disk {
+				format = dev.Inserted.NodeName
+			}
+		}
+		args := map[string]interface{}{
+			"driver":    "raw",
+			"node-name": holdNode(disk),
+			"file":      format,
+		}
+		if _, err := inst.runQmp("blockdev-add", args); err != nil {
+			return errors.Wrapf(err, "Holding image of disk %s", disk)
+		}
+		if inst.heldDisks == nil {
+			inst.heldDisks = make(map[string]string)
+		}
+		inst.heldDisks[disk] = format
+	}
+	if err := inst.deleteDevice(qdev); err != nil {
+		return errors.Wrapf(err, "Unplugging disk %s", disk)
+	}
+	inst.setUnplugged(disk, true)
+	return nil
+}
+
+// ReplugDisk plugs back a disk removed with UnplugDisk.
+func (inst *QemuInstance) ReplugDisk(disk string) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if !inst.unplugged[disk] {
+		return fmt.Errorf("disk %s is not unplugged", disk)
+	}
+	device := strings.Replace(inst.devices[disk], "drive="+disk, "drive="+holdNode(disk), 1)
+	if err := inst.runHmp("device_add " + device); err != nil {
+		return errors.Wrapf(err, "Replugging disk %s", disk)
+	}
+	inst.setUnplugged(disk, false)
+	return nil
+}
+
+// UnplugNIC hot-unplugs a NIC; its network backend is kept for ReplugNIC.
+// The bus of the NIC must support hotplug.
+func (inst *QemuInstance) UnplugNIC(nic string) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if inst.unplugged[nic] {
+		return fmt.Errorf("NIC %s is already unplugged", nic)
+	}
+	if _, ok := inst.devices[nic]; !ok {
+		return fmt.Errorf("NIC %s not found", nic)
+	}
+	if err := inst.checkHotplug(nic); err != nil {
+		return err
+	}
+	qdev, err := inst.nicQdev(nic)
+	if err != nil {
+		return err
+	}
+	if err := inst.deleteDevice(qdev); err != nil {
+		return errors.Wrapf(err, "Unplugging NIC %s", nic)
+	}
+	inst.setUnplugged(nic, true)
+	return nil
+}
+
+// ReplugNIC plugs back a NIC removed with UnplugNIC.
+func (inst *QemuInstance) ReplugNIC(nic string) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if !inst.unplugged[nic] {
+		return fmt.Errorf("NIC %s is not unplugged", nic)
+	}
+	if err := inst.runHmp("device_add " + inst.devices[nic]); err != nil {
+		return errors.Wrapf(err, "Replugging NIC %s", nic)
+	}
+	inst.setUnplugged(nic, false)
+	return nil
+}
+
+// checkHotplug returns an error if the device of a disk or NIC can't be
+// hot-unplugged, rather than letting the guest or qemu fail to release it.
+func (inst *QemuInstance) checkHotplug(name string) error {
+	if inst.pcieRootBus && !strings.HasPrefix(inst.devices[name], "scsi-hd,") {
+		return fmt.Errorf("%s can't be hot-unplugged: its device is on the PCI Express root bus (e.g. of the q35 machine used for UEFI), which doesn't support hotplug", name)
+	}
+	return nil
+}
+
+func (inst *QemuInstance) setUnplugged(name string, unplugged bool) {
+	if inst.unplugged == nil {
+		inst.unplugged = make(map[string]bool)
+	}
+	inst.unplugged[name] = unplugged
+}
+
+// nicQdev returns the QOM path of the device of a NIC.
+func (inst *QemuInstance) nicQdev(nic string) (string, error) {
+	devs, err := inst.listDevices()
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not list devices through qmp")
+	}
+	for _, dev := range devs.Return {
+		if !strings.HasPrefix(dev.Type, "child<virtio-net-") {
+			continue
+		}
+		path := "/machine/peripheral-anon/" + dev.Name
+		out, err := inst.runQmp("qom-get", map[string]interface{}{"path": path, "property": "netdev"})
+		if err != nil {
+			return "", errors.Wrapf(err, "Getting netdev of %s", path)
+		}
+		var res struct {
+			Return string `json:"return"`
+		}
+		if err := json.Unmarshal(out, &res); err != nil {
+			return "", errors.Wrapf(err, "De-serializing QMP qom-get output")
+		}
+		if res.Return == nic {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("NIC %s not found", nic)
+}
+
+// deleteDevice hot-unplugs a device and waits for the guest to release it.
+func (inst *QemuInstance) deleteDevice(qdev string) error {
+	if _, err := inst.runQmp("device_del", map[string]interface{}{"id": qdev}); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(deviceRemovalTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := inst.runQmp("qom-get", map[string]interface{}{"path": qdev, "property": "type"}); err != nil {
+			// the device is gone
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out after %v waiting for the guest to release %s", deviceRemovalTimeout, qdev)
+}
+
+// Pause stops all the vCPUs of the VM.
+func (inst *QemuInstance) Pause() error {
+	if _, err := inst.runQmp("stop", nil); err != nil {
+		return errors.Wrapf(err, "Pausing VM")
+	}
+	return nil
+}
+
+// Resume restarts the vCPUs of the VM stopped by Pause.
+func (inst *QemuInstance) Resume() error {
+	if _, err := inst.runQmp("cont", nil); err != nil {
+		return errors.Wrapf(err, "Resuming VM")
+	}
+	return nil
+}
+
+// PauseVCPU stops a single vCPU, which QMP can't do, by stopping its host
+// thread with ptrace; the guest sees the vCPU hang.
+func (inst *QemuInstance) PauseVCPU(index int) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	if _, ok := inst.pausedVCPUs[index]; ok {
+		return fmt.Errorf("vCPU %d is already paused", index)
+	}
+	out, err := inst.runQmp("query-cpus-fast", nil)
+	if err != nil {
+		return errors.Wrapf(err, "Running QMP query-cpus-fast command")
+	}
+	var cpus QMPCPUs
+	if err := json.Unmarshal(out, &cpus); err != nil {
+		return errors.Wrapf(err, "De-serializing QMP query-cpus-fast output")
+	}
+	tid := -1
+	for _, cpu := range cpus.Return {
+		if cpu.CPUIndex == index {
+			tid = cpu.ThreadID
+		}
+	}
+	if tid < 0 {
+		return fmt.Errorf("vCPU %d not found", index)
+	}
+
+	p := &pausedVCPU{
+		resume: make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		// Unlike PTRACE_ATTACH, seizing and interrupting a thread
+		// doesn't stop the other threads of the process.
+		if err := unix.PtraceSeize(tid); err != nil {
+			p.done <- errors.Wrapf(err, "seizing thread %d of vCPU %d", tid, index)
+			return
+		}
+		if err := unix.PtraceInterrupt(tid); err != nil {
+			_ = unix.PtraceDetach(tid)
+			p.done <- errors.Wrapf(err, "interrupting thread %d of vCPU %d", tid, index)
+			return
+		}
+		var ws unix.WaitStatus
+		if _, err := unix.Wait4(tid, &ws, unix.WALL, nil); err != nil {
+			_ = unix.PtraceDetach(tid)
+			p.done <- errors.Wrapf(err, "waiting for thread %d of vCPU %d", tid, index)
+			return
+		}
+		p.done <- nil
+		<-p.resume
+		p.done <- unix.PtraceDetach(tid)
+	}()
+	if err := <-p.done; err != nil {
+		return err
+	}
+	if inst.pausedVCPUs == nil {
+		inst.pausedVCPUs = make(map[int]*pausedVCPU)
+	}
+	inst.pausedVCPUs[index] = p
+	return nil
+}
+
+// resumeVCPUs restarts the vCPUs stopped by PauseVCPU, e.g. before
+// destroying the instance.
+func (inst *QemuInstance) resumeVCPUs() {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	for index, p := range inst.pausedVCPUs {
+		close(p.resume)
+		<-p.done
+		delete(inst.pausedVCPUs, index)
+	}
+}
+
+// ResumeVCPU restarts a vCPU stopped by PauseVCPU.
+func (inst *QemuInstance) ResumeVCPU(index int) error {
+	inst.faultsMu.Lock()
+	defer inst.faultsMu.Unlock()
+	p, ok := inst.pausedVCPUs[index]
+	if !ok {
+		return fmt.Errorf("vCPU %d is not paused", index)
+	}
+	delete(inst.pausedVCPUs, index)
+	close(p.resume)
+	if err := <-p.done; err != nil {
+		return errors.Wrapf(err, "Resuming vCPU %d", index)
+	}
+	return nil
+}
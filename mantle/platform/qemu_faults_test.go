@@ -0,0 +1,379 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// disk2 is the answer of query-block for an instance with an additional
+// disk-2 plugged into the given QOM path, or unplugged if it is empty.
+func disk2(qdev string) qmpHandler {
+	return returns([]interface{}{
+		map[string]interface{}{
+			"device": "disk-2",
+			"qdev":   qdev,
+			"inserted": map[string]interface{}{
+				"node-name": "fmt-2",
+				"drv":       "qcow2",
+			},
+		},
+	})
+}
+
+func TestSetNICLink(t *testing.T) {
+	inst, m := newFakeInstance(nil)
+	if err := inst.SetNICLink("eth1", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.SetNICLink("eth1", true); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "set_link", "arguments": {"name": "eth1", "up": false}}`,
+		`{"execute": "set_link", "arguments": {"name": "eth1", "up": true}}`)
+}
+
+func TestThrottleDisk(t *testing.T) {
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"query-block": disk2("/machine/peripheral-anon/device[1]/virtio-backend"),
+	})
+	if err := inst.ThrottleDisk("disk-2", IOThrottle{BPSWrite: 1 << 20, IOPS: 100}); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "block_set_io_throttle", "arguments": {
+			"id": "/machine/peripheral-anon/device[1]/virtio-backend",
+			"bps": 0, "bps_rd": 0, "bps_wr": 1048576,
+			"iops": 100, "iops_rd": 0, "iops_wr": 0}}`)
+
+	if err := inst.ThrottleDisk("disk-3", IOThrottle{}); err == nil {
+		t.Error("expected an error throttling a missing disk")
+	}
+}
+
+func TestSetDiskIOErrors(t *testing.T) {
+	var reopenErr error
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"query-block": disk2("/machine/peripheral-anon/device[1]/virtio-backend"),
+		"query-named-block-nodes": returns([]interface{}{
+			map[string]interface{}{
+				"node-name": "fmt-2",
+				"drv":       "qcow2",
+				"file":      "/var/tmp/disk-2.qcow2",
+				"cache":     map[string]interface{}{"writeback": true, "direct": true, "no-flush": false},
+			},
+			map[string]interface{}{
+				"node-name": "proto-2",
+				"drv":       "file",
+				"file":      "/var/tmp/disk-2.qcow2",
+			},
+			// the backing file isn't the file child
+			map[string]interface{}{
+				"node-name": "backing-2",
+				"drv":       "file",
+				"file":      "/var/tmp/base.qcow2",
+			},
+		}),
+		"blockdev-reopen": func(map[string]interface{}) (interface{}, error) {
+			return struct{}{}, reopenErr
+		},
+	})
+
+	if err := inst.SetDiskIOErrors("disk-2", true, false); err != nil {
+		t.Fatal(err)
+	}
+	// the cache options of the disk are kept
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "query-named-block-nodes", "arguments": {"flat": true}}`,
+		`{"execute": "blockdev-add", "arguments": {
+			"driver": "blkdebug", "node-name": "faulty-disk-2", "image": "proto-2",
+			"inject-error": [{"event": "read_aio", "errno": 5}]}}`,
+		`{"execute": "blockdev-reopen", "arguments": {"options": [{
+			"driver": "qcow2", "node-name": "fmt-2", "file": "faulty-disk-2",
+			"cache": {"direct": true, "no-flush": false}}]}}`)
+
+	// the same errors again are a no-op
+	if err := inst.SetDiskIOErrors("disk-2", true, false); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t)
+
+	// other errors replace the blkdebug node
+	if err := inst.SetDiskIOErrors("disk-2", true, true); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "blockdev-reopen", "arguments": {"options": [{
+			"driver": "qcow2", "node-name": "fmt-2", "file": "proto-2",
+			"cache": {"direct": true, "no-flush": false}}]}}`,
+		`{"execute": "blockdev-del", "arguments": {"node-name": "faulty-disk-2"}}`,
+		`{"execute": "blockdev-add", "arguments": {
+			"driver": "blkdebug", "node-name": "faulty-disk-2", "image": "proto-2",
+			"inject-error": [{"event": "read_aio", "errno": 5}, {"event": "write_aio", "errno": 5}]}}`,
+		`{"execute": "blockdev-reopen", "arguments": {"options": [{
+			"driver": "qcow2", "node-name": "fmt-2", "file": "faulty-disk-2",
+			"cache": {"direct": true, "no-flush": false}}]}}`)
+
+	if err := inst.SetDiskIOErrors("disk-2", false, false); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "blockdev-reopen", "arguments": {"options": [{
+			"driver": "qcow2", "node-name": "fmt-2", "file": "proto-2",
+			"cache": {"direct": true, "no-flush": false}}]}}`,
+		`{"execute": "blockdev-del", "arguments": {"node-name": "faulty-disk-2"}}`)
+	if len(inst.faultyDisks) != 0 {
+		t.Errorf("unexpected faulty disks %v", inst.faultyDisks)
+	}
+
+	// a failed reopen removes the blkdebug node again
+	reopenErr = errors.New("reopen failed")
+	if err := inst.SetDiskIOErrors("disk-2", false, true); err == nil || !strings.Contains(err.Error(), "reopen failed") {
+		t.Fatalf("expected the reopen error, got %v", err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "query-named-block-nodes", "arguments": {"flat": true}}`,
+		`{"execute": "blockdev-add", "arguments": {
+			"driver": "blkdebug", "node-name": "faulty-disk-2", "image": "proto-2",
+			"inject-error": [{"event": "write_aio", "errno": 5}]}}`,
+		`{"execute": "blockdev-reopen", "arguments": {"options": [{
+			"driver": "qcow2", "node-name": "fmt-2", "file": "faulty-disk-2",
+			"cache": {"direct": true, "no-flush": false}}]}}`,
+		`{"execute": "blockdev-del", "arguments": {"node-name": "faulty-disk-2"}}`)
+	if len(inst.faultyDisks) != 0 {
+		t.Errorf("unexpected faulty disks %v", inst.faultyDisks)
+	}
+}
+
+// gone makes qom-get fail, as it does once a device was removed.
+func gone(map[string]interface{}) (interface{}, error) {
+	return nil, errors.New("Device not found")
+}
+
+func TestUnplugDisk(t *testing.T) {
+	qdev := "/machine/peripheral-anon/device[1]"
+	var hmpOutput string
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"query-block": disk2(qdev + "/virtio-backend"),
+		"qom-get":     gone,
+		"human-monitor-command": func(map[string]interface{}) (interface{}, error) {
+			return hmpOutput, nil
+		},
+	})
+	inst.devices = map[string]string{"disk-2": "virtio-blk-pci,drive=disk-2,serial=disk-2"}
+
+	if err := inst.UnplugDisk("disk-2"); err != nil {
+		t.Fatal(err)
+	}
+	// the image is held open by a node the disk is plugged back through
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "query-block"}`,
+		`{"execute": "blockdev-add", "arguments": {"driver": "raw", "node-name": "hold-disk-2", "file": "fmt-2"}}`,
+		`{"execute": "device_del", "arguments": {"id": "/machine/peripheral-anon/device[1]"}}`,
+		`{"execute": "qom-get", "arguments": {"path": "/machine/peripheral-anon/device[1]", "property": "type"}}`)
+	if err := inst.UnplugDisk("disk-2"); err == nil {
+		t.Error("expected an error unplugging an unplugged disk")
+	}
+	m.check(t)
+
+	if err := inst.ReplugDisk("disk-2"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "human-monitor-command", "arguments": {"command-line": "device_add virtio-blk-pci,drive=hold-disk-2,serial=disk-2"}}`)
+	if err := inst.ReplugDisk("disk-2"); err == nil {
+		t.Error("expected an error replugging a plugged disk")
+	}
+
+	// the hold node is reused by later unplugs
+	m.reset()
+	if err := inst.UnplugDisk("disk-2"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "device_del", "arguments": {"id": "/machine/peripheral-anon/device[1]"}}`,
+		`{"execute": "qom-get", "arguments": {"path": "/machine/peripheral-anon/device[1]", "property": "type"}}`)
+
+	// errors of device_add are only printed by the human monitor
+	hmpOutput = "Error: Bus 'pci.0' not found\r\n"
+	if err := inst.ReplugDisk("disk-2"); err == nil || err.Error() != "Replugging disk disk-2: Error: Bus 'pci.0' not found" {
+		t.Errorf("expected the device_add error, got %v", err)
+	}
+	if !inst.unplugged["disk-2"] {
+		t.Error("disk marked as plugged after a failed replug")
+	}
+
+	if err := inst.UnplugDisk("disk-3"); err == nil {
+		t.Error("expected an error unplugging a missing disk")
+	}
+}
+
+func TestUnplugNIC(t *testing.T) {
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"qom-list": returns([]interface{}{
+			map[string]interface{}{"name": "device[0]", "type": "child<virtio-blk-pci>"},
+			map[string]interface{}{"name": "device[2]", "type": "child<virtio-net-pci>"},
+			map[string]interface{}{"name": "device[3]", "type": "child<virtio-net-pci>"},
+		}),
+		"qom-get": func(args map[string]interface{}) (interface{}, error) {
+			switch fmt.Sprintf("%s %s", args["path"], args["property"]) {
+			case "/machine/peripheral-anon/device[2] netdev":
+				return "eth0", nil
+			case "/machine/peripheral-anon/device[3] netdev":
+				return "eth1", nil
+			}
+			return gone(args)
+		},
+		"human-monitor-command": returns(""),
+	})
+	inst.devices = map[string]string{
+		"eth0": "virtio-net-pci,netdev=eth0",
+		"eth1": "virtio-net-pci,netdev=eth1,mac=52:55:00:d1:56:00",
+	}
+
+	if err := inst.UnplugNIC("eth1"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "qom-list", "arguments": {"path": "/machine/peripheral-anon"}}`,
+		`{"execute": "qom-get", "arguments": {"path": "/machine/peripheral-anon/device[2]", "property": "netdev"}}`,
+		`{"execute": "qom-get", "arguments": {"path": "/machine/peripheral-anon/device[3]", "property": "netdev"}}`,
+		`{"execute": "device_del", "arguments": {"id": "/machine/peripheral-anon/device[3]"}}`,
+		`{"execute": "qom-get", "arguments": {"path": "/machine/peripheral-anon/device[3]", "property": "type"}}`)
+
+	if err := inst.ReplugNIC("eth1"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "human-monitor-command", "arguments": {"command-line": "device_add virtio-net-pci,netdev=eth1,mac=52:55:00:d1:56:00"}}`)
+}
+
+func TestUnplugPCIeRootBus(t *testing.T) {
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"query-block": returns([]interface{}{
+			map[string]interface{}{
+				"device":   "mpath10",
+				"qdev":     "/machine/peripheral-anon/device[4]",
+				"inserted": map[string]interface{}{"node-name": "fmt-mpath10", "drv": "raw"},
+			},
+		}),
+		"qom-get": gone,
+	})
+	inst.pcieRootBus = true
+	inst.devices = map[string]string{
+		"eth0":    "virtio-net-pci,netdev=eth0",
+		"disk-1":  "virtio-blk-pci,drive=disk-1",
+		"mpath10": "scsi-hd,bus=scsi_mpath10.0,drive=mpath10",
+	}
+
+	// the devices on the root bus are rejected before trying
+	if err := inst.UnplugNIC("eth0"); err == nil || !strings.Contains(err.Error(), "doesn't support hotplug") {
+		t.Errorf("expected unplugging a NIC to be rejected, got %v", err)
+	}
+	if err := inst.UnplugDisk("disk-1"); err == nil || !strings.Contains(err.Error(), "doesn't support hotplug") {
+		t.Errorf("expected unplugging a virtio disk to be rejected, got %v", err)
+	}
+	m.check(t)
+
+	// unlike the SCSI disks of multipath
+	if err := inst.UnplugDisk("mpath10"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	inst, m := newFakeInstance(nil)
+	if err := inst.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t, `{"execute": "stop"}`, `{"execute": "cont"}`)
+}
+
+// processState returns the state of a process, e.g. S for sleeping and t
+// for stopped by a tracer.
+func processState(t *testing.T, pid int) string {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the state follows the command name, which is in parentheses
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return fields[0]
+}
+
+func TestPauseVCPU(t *testing.T) {
+	// a child process stands in for the thread of the vCPU
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	inst, m := newFakeInstance(map[string]qmpHandler{
+		"query-cpus-fast": returns([]interface{}{
+			map[string]interface{}{"cpu-index": 0, "thread-id": cmd.Process.Pid},
+		}),
+	})
+
+	if err := inst.PauseVCPU(1); err == nil || err.Error() != "vCPU 1 not found" {
+		t.Errorf("expected a missing vCPU error, got %v", err)
+	}
+	if err := inst.ResumeVCPU(0); err == nil {
+		t.Error("expected an error resuming a running vCPU")
+	}
+	if err := inst.PauseVCPU(0); errors.Is(err, unix.EPERM) {
+		t.Skipf("ptrace isn't permitted: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	m.check(t, `{"execute": "query-cpus-fast"}`, `{"execute": "query-cpus-fast"}`)
+	if state := processState(t, cmd.Process.Pid); state != "t" {
+		t.Errorf("expected the paused thread to be stopped, got state %s", state)
+	}
+	if err := inst.PauseVCPU(0); err == nil {
+		t.Error("expected an error pausing a paused vCPU")
+	}
+
+	if err := inst.ResumeVCPU(0); err != nil {
+		t.Fatal(err)
+	}
+	// the thread runs again once the tracer detached
+	deadline := time.Now().Add(5 * time.Second)
+	for processState(t, cmd.Process.Pid) == "t" {
+		if time.Now().After(deadline) {
+			t.Fatal("the resumed thread is still stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// snapshotDisks is the answer of query-block for an instance with two
+// qcow2 disks, UEFI variables, an ISO and an empty CD-ROM drive; only the
+// qcow2 disks can store snapshots.
+var snapshotDisks = returns([]interface{}{
+	map[string]interface{}{
+		"device":   "",
+		"qdev":     "/machine/peripheral-anon/device[0]/virtio-backend",
+		"inserted": map[string]interface{}{"node-name": "fmt-1", "drv": "qcow2"},
+	},
+	map[string]interface{}{
+		"device":   "pflash1",
+		"inserted": map[string]interface{}{"node-name": "pflash1", "drv": "raw"},
+	},
+	map[string]interface{}{
+		"device":   "",
+		"qdev":     "/machine/peripheral-anon/device[1]",
+		"inserted": map[string]interface{}{"node-name": "iso", "drv": "raw", "ro": true},
+	},
+	map[string]interface{}{
+		"device": "ide1-cd0",
+	},
+	map[string]interface{}{
+		"device":   "disk-2",
+		"qdev":     "/machine/peripheral-anon/device[2]/virtio-backend",
+		"inserted": map[string]interface{}{"node-name": "fmt-2", "drv": "qcow2"},
+	},
+})
+
+// fakeJob is a job as listed by query-jobs.
+type fakeJob struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// fakeJobs runs the jobs started by the snapshot commands: they are running
+// when first queried and concluded afterwards, with the error of their id
+// if any.
+type fakeJobs struct {
+	mu     sync.Mutex
+	jobs   []*fakeJob
+	errors map[string]string
+}
+
+func (j *fakeJobs) start(command string) qmpHandler {
+	return func(args map[string]interface{}) (interface{}, error) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.jobs = append(j.jobs, &fakeJob{
+			ID:     args["job-id"].(string),
+			Type:   command,
+			Status: "running",
+		})
+		return struct{}{}, nil
+	}
+}
+
+func (j *fakeJobs) query(map[string]interface{}) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ret := []fakeJob{}
+	for _, job := range j.jobs {
+		ret = append(ret, *job)
+		if job.Status == "running" {
+			job.Status = "concluded"
+			job.Error = j.errors[job.ID]
+		}
+	}
+	return ret, nil
+}
+
+func (j *fakeJobs) dismiss(args map[string]interface{}) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, job := range j.jobs {
+		if job.ID == args["id"] {
+			j.jobs = append(j.jobs[:i], j.jobs[i+1:]...)
+			return struct{}{}, nil
+		}
+	}
+	return nil, errors.New("job not found")
+}
+
+// handlers returns the handlers of the snapshot commands and of the
+// commands managing their jobs.
+func (j *fakeJobs) handlers() map[string]qmpHandler {
+	return map[string]qmpHandler{
+		"query-block":     snapshotDisks,
+		"snapshot-save":   j.start("snapshot-save"),
+		"snapshot-load":   j.start("snapshot-load"),
+		"snapshot-delete": j.start("snapshot-delete"),
+		"query-jobs":      j.query,
+		"job-dismiss":     j.dismiss,
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	jobs := &fakeJobs{}
+	inst, m := newFakeInstance(jobs.handlers())
+
+	if err := inst.SaveSnapshot("provisioned"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "snapshot-save", "arguments": {"job-id": "snapshot-save-provisioned",
+			"tag": "provisioned", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "job-dismiss", "arguments": {"id": "snapshot-save-provisioned"}}`)
+
+	if err := inst.LoadSnapshot("provisioned"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "snapshot-load", "arguments": {"job-id": "snapshot-load-provisioned",
+			"tag": "provisioned", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "job-dismiss", "arguments": {"id": "snapshot-load-provisioned"}}`)
+
+	if err := inst.DeleteSnapshot("provisioned"); err != nil {
+		t.Fatal(err)
+	}
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "snapshot-delete", "arguments": {"job-id": "snapshot-delete-provisioned",
+			"tag": "provisioned", "devices": ["fmt-1", "fmt-2"]}}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "job-dismiss", "arguments": {"id": "snapshot-delete-provisioned"}}`)
+
+	if len(jobs.jobs) != 0 {
+		t.Errorf("expected the jobs to be dismissed, got %+v", jobs.jobs)
+	}
+}
+
+func TestSnapshotJobError(t *testing.T) {
+	jobs := &fakeJobs{errors: map[string]string{
+		"snapshot-load-missing": "Snapshot 'missing' does not exist in one or more devices",
+	}}
+	inst, m := newFakeInstance(jobs.handlers())
+
+	err := inst.LoadSnapshot("missing")
+	if err == nil || !strings.Contains(err.Error(), "Snapshot 'missing' does not exist") {
+		t.Fatalf("expected the error of the job, got %v", err)
+	}
+	// the failed job is dismissed all the same
+	m.check(t,
+		`{"execute": "query-block"}`,
+		`{"execute": "snapshot-load", "arguments": {"job-id": "snapshot-load-missing",
+			"tag": "missing", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "query-jobs"}`,
+		`{"execute": "job-dismiss", "arguments": {"id": "snapshot-load-missing"}}`)
+	if len(jobs.jobs) != 0 {
+		t.Errorf("expected the job to be dismissed, got %+v", jobs.jobs)
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		handlers map[string]qmpHandler
+		err      string
+		// commands run before failing
+		commands []string
+	}{
+		{
+			name: "no qcow2 disk",
+			handlers: map[string]qmpHandler{
+				"query-block": returns([]interface{}{
+					map[string]interface{}{
+						"device":   "pflash1",
+						"inserted": map[string]interface{}{"node-name": "pflash1", "drv": "raw"},
+					},
+				}),
+			},
+			err:      "no qcow2 disk",
+			commands: []string{`{"execute": "query-block"}`},
+		},
+		{
+			name: "command rejected",
+			handlers: map[string]qmpHandler{
+				"query-block": snapshotDisks,
+				"snapshot-save": func(map[string]interface{}) (interface{}, error) {
+					return nil, errors.New("The command snapshot-save has not been found")
+				},
+			},
+			err: "snapshot-save has not been found",
+			commands: []string{
+				`{"execute": "query-block"}`,
+				`{"execute": "snapshot-save", "arguments": {"job-id": "snapshot-save-provisioned",
+					"tag": "provisioned", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+			},
+		},
+		{
+			name: "job not found",
+			handlers: map[string]qmpHandler{
+				"query-block": snapshotDisks,
+				"query-jobs":  returns([]interface{}{}),
+			},
+			err: "job snapshot-save-provisioned not found",
+			commands: []string{
+				`{"execute": "query-block"}`,
+				`{"execute": "snapshot-save", "arguments": {"job-id": "snapshot-save-provisioned",
+					"tag": "provisioned", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+				`{"execute": "query-jobs"}`,
+			},
+		},
+		{
+			name: "query-jobs failed",
+			handlers: map[string]qmpHandler{
+				"query-block": snapshotDisks,
+				"query-jobs": func(map[string]interface{}) (interface{}, error) {
+					return nil, errors.New("monitor disconnected")
+				},
+			},
+			err: "query-jobs command: monitor disconnected",
+			commands: []string{
+				`{"execute": "query-block"}`,
+				`{"execute": "snapshot-save", "arguments": {"job-id": "snapshot-save-provisioned",
+					"tag": "provisioned", "vmstate": "fmt-1", "devices": ["fmt-1", "fmt-2"]}}`,
+				`{"execute": "query-jobs"}`,
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			inst, m := newFakeInstance(c.handlers)
+			err := inst.SaveSnapshot("provisioned")
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected an error containing %q, got %v", c.err, err)
+			}
+			if !strings.HasPrefix(err.Error(), "Saving snapshot provisioned: ") {
+				t.Errorf("expected the error to name the snapshot, got %v", err)
+			}
+			m.check(t, c.commands...)
+		})
+	}
+}
@@ -19,6 +19,7 @@ package platform
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -39,6 +40,8 @@ type QOMBlkDev struct {
 		Inserted   struct {
 			BackingFileDepth int    `json:"backing_file_depth"`
 			NodeName         string `json:"node-name"`
+			Driver           string `json:"drv"`
+			ReadOnly         bool   `json:"ro"`
 		} `json:"inserted"`
 	} `json:"return"`
 }
@@ -99,3 +102,113 @@ func (inst *QemuInstance) deleteBlockDevice(device string) error {
 	}
 	return nil
 }
+
+// QMPJobs is the result of a query-jobs command.
+type QMPJobs struct {
+	Return []struct {
+		ID     string `json:"id"`
+		Type   string `json:"type"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"return"`
+}
+
+// runQmpJob executes a qemu command starting a job with the given id over
+// the QMP socket, and waits for the job to conclude.
+func (inst *QemuInstance) runQmpJob(id string, cmd string, timeout time.Duration) error {
+	if _, err := inst.runQmpCommand(cmd); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := inst.runQmpCommand(`{ "execute": "query-jobs" }`)
+		if err != nil {
+			return errors.Wrapf(err, "Running QMP query-jobs command")
+		}
+		var jobs QMPJobs
+		if err = json.Unmarshal(out, &jobs); err != nil {
+			return errors.Wrapf(err, "De-serializing QMP query-jobs output")
+		}
+		found := false
+		for _, job := range jobs.Return {
+			if job.ID != id {
+				continue
+			}
+			found = true
+			if job.Status != "concluded" {
+				break
+			}
+			dismiss := fmt.Sprintf(`{ "execute": "job-dismiss", "arguments": { "id": "%s" } }`, id)
+			if _, err := inst.runQmpCommand(dismiss); err != nil {
+				return errors.Wrapf(err, "Dismissing job %s", id)
+			}
+			if job.Error != "" {
+				return errors.New(job.Error)
+			}
+			return nil
+		}
+		if !found {
+			return fmt.Errorf("job %s not found", id)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for job %s", timeout, id)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// snapshotNodes returns the block nodes of the writable qcow2 disks, which
+// are included in internal snapshots.
+func (inst *QemuInstance) snapshotNodes() ([]string, error) {
+	blkdevs, err := inst.listBlkDevices()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not list block devices through qmp")
+	}
+	var nodes []string
+	for _, dev := range blkdevs.Return {
+		if dev.Inserted.NodeName == "" || dev.Inserted.ReadOnly {
+			continue
+		}
+		if dev.Inserted.Driver != "qcow2" {
+			// e.g. the UEFI variables and multipath disks
+			plog.Debugf("Not snapshotting %s block device %s", dev.Inserted.Driver, dev.Device)
+			continue
+		}
+		nodes = append(nodes, dev.Inserted.NodeName)
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no qcow2 disk to store snapshots in")
+	}
+	return nodes, nil
+}
+
+// snapshotTimeout bounds saving or loading the memory and disk state of a
+// VM.
+const snapshotTimeout = 5 * time.Minute
+
+// runSnapshotJob runs one of the snapshot-save, snapshot-load and
+// snapshot-delete commands for the internal snapshot tag of the qcow2
+// disks, the VM state being stored in the first one.
+func (inst *QemuInstance) runSnapshotJob(command, tag string) error {
+	nodes, err := inst.snapshotNodes()
+	if err != nil {
+		return err
+	}
+	id := command + "-" + tag
+	args := map[string]interface{}{
+		"job-id":  id,
+		"tag":     tag,
+		"devices": nodes,
+	}
+	if command != "snapshot-delete" {
+		args["vmstate"] = nodes[0]
+	}
+	buf, err := json.Marshal(map[string]interface{}{
+		"execute":   command,
+		"arguments": args,
+	})
+	if err != nil {
+		return err
+	}
+	return inst.runQmpJob(id, string(buf), snapshotTimeout)
+}
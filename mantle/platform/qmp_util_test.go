@@ -0,0 +1,114 @@
+// Copyright 2026 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/digitalocean/go-qemu/qmp"
+)
+
+// qmpCommand is a command run on a fakeMonitor.
+type qmpCommand struct {
+	Execute   string                 `json:"execute"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// qmpHandler answers a QMP command with the value of its "return" member,
+// or fails it.
+type qmpHandler func(args map[string]interface{}) (interface{}, error)
+
+// fakeMonitor is a QMP monitor which records the commands run on it and
+// answers them with the handler of their name, or an empty return value.
+type fakeMonitor struct {
+	mu       sync.Mutex
+	commands []qmpCommand
+	handlers map[string]qmpHandler
+}
+
+// newFakeInstance returns an instance whose QMP socket is a new
+// fakeMonitor, with the handlers.
+func newFakeInstance(handlers map[string]qmpHandler) (*QemuInstance, *fakeMonitor) {
+	m := &fakeMonitor{handlers: handlers}
+	return &QemuInstance{qmpSocket: m}, m
+}
+
+func (m *fakeMonitor) Connect() error    { return nil }
+func (m *fakeMonitor) Disconnect() error { return nil }
+
+func (m *fakeMonitor) Events() (<-chan qmp.Event, error) {
+	return nil, nil
+}
+
+func (m *fakeMonitor) Run(command []byte) ([]byte, error) {
+	var cmd qmpCommand
+	if err := json.Unmarshal(command, &cmd); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.commands = append(m.commands, cmd)
+	handler := m.handlers[cmd.Execute]
+	m.mu.Unlock()
+
+	var ret interface{} = struct{}{}
+	if handler != nil {
+		var err error
+		if ret, err = handler(cmd.Arguments); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(map[string]interface{}{"return": ret})
+}
+
+// reset forgets the commands run so far.
+func (m *fakeMonitor) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands = nil
+}
+
+// check fails the test unless the commands run so far are the expected
+// ones, given as JSON, and forgets them.
+func (m *fakeMonitor) check(t *testing.T, expected ...string) {
+	t.Helper()
+	m.mu.Lock()
+	got := m.commands
+	m.commands = nil
+	m.mu.Unlock()
+
+	var want []qmpCommand
+	for _, e := range expected {
+		var cmd qmpCommand
+		if err := json.Unmarshal([]byte(e), &cmd); err != nil {
+			t.Fatalf("bad expected command %s: %v", e, err)
+		}
+		want = append(want, cmd)
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("unexpected QMP commands\ngot:  %s\nwant: %s", gotJSON, wantJSON)
+	}
+}
+
+// returns answers a command with a constant value.
+func returns(ret interface{}) qmpHandler {
+	return func(map[string]interface{}) (interface{}, error) {
+		return ret, nil
+	}
+}
@@ -96,11 +96,11 @@ func ReadBuild(dir, buildID, arch string) (*Build, string, error) {
 	}
 
 	if buildID == "" {
-		b, err := getBuilds(dir)
+		b, err := ReadBuildsJSON(dir)
 		if err != nil {
 			return nil, "", err
 		}
-		latest, ok := b.getLatest(arch)
+		latest, ok := b.Latest(arch)
 		if !ok {
 			return nil, "", ErrNoBuildsFound
 		}
@@ -282,6 +282,12 @@ func (build *Build) artifacts() map[string]*Artifact {
 			continue
 		}
 
+		// Artifacts with extra metadata convert themselves.
+		if a, ok := field.Interface().(interface{ toArtifact() *Artifact }); ok {
+			ret[strings.ToLower(tag)] = a.toArtifact()
+			continue
+		}
+
 		// Optional structs (i.e. "omitempty") are pointers a struct.
 		if field.Addr().Elem().CanInterface() {
 			r, ok := reflect.ValueOf(&ba).Elem().Field(i).Elem().Interface().(Artifact)
@@ -318,6 +324,18 @@ func (e *Extensions) toArtifact() *Artifact {
 	}
 }
 
+// toArtifact converts an ExtensionsContainerArtifact to an Artifact
+func (e *ExtensionsContainerArtifact) toArtifact() *Artifact {
+	return &Artifact{
+		Path:               e.Path,
+		Sha256:             e.Sha256,
+		SizeInBytes:        e.SizeInBytes,
+		SkipCompression:    e.SkipCompression,
+		UncompressedSha256: e.UncompressedSha256,
+		UncompressedSize:   e.UncompressedSize,
+	}
+}
+
 func FetchAndParseBuild(url string) (*Build, error) {
 	res, err := http.Get(url)
 	if err != nil {
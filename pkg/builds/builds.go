@@ -1,18 +1,26 @@
 package builds
 
 import (
-	"bytes"
 	"encoding/json"
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
+
+	schema "github.com/xeipuuv/gojsonschema"
 )
 
 const (
 	// CosaBuildsJSON is the COSA build.json file name
 	CosaBuildsJSON = "builds.json"
+
+	// BuildsSchemaVersion is the version of builds.json written for new
+	// workdirs; only version 1 is supported.
+	BuildsSchemaVersion = "1.0.0"
 )
 
 var (
@@ -20,40 +28,206 @@ var (
 	ErrNoBuildsFound = errors.New("no COSA builds found")
 )
 
-// build represents the build struct in a buildJSON
-type build struct {
+// buildsSchemaJSON is the JSON Schema of builds.json.
+const buildsSchemaJSON = `{
+  "type": "object",
+  "required": ["schema-version", "builds"],
+  "properties": {
+    "schema-version": {
+      "type": "string",
+      "pattern": "^1\\."
+    },
+    "builds": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["id", "arches"],
+        "properties": {
+          "id": {"type": "string", "minLength": 1},
+          "arches": {
+            "type": "array",
+            "minItems": 1,
+            "uniqueItems": true,
+            "items": {"type": "string", "minLength": 1}
+          }
+        }
+      }
+    },
+    "timestamp": {"type": "string"},
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["name", "created", "target"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1},
+          "created": {"type": "string"},
+          "target": {"type": "string", "minLength": 1},
+          "description": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+// BuildEntry is a build recorded in builds.json.
+type BuildEntry struct {
 	ID     string   `json:"id"`
 	Arches []string `json:"arches"`
 }
 
-// buildsJSON represents the JSON that records the builds
-// TODO: this should be generated by a schema
-type buildsJSON struct {
-	SchemaVersion string  `json:"schema-version"`
-	Builds        []build `json:"builds"`
-	TimeStamp     string  `json:"timestamp"`
+// BuildTag names a build, see `cosa tag`.
+type BuildTag struct {
+	Name        string `json:"name"`
+	Created     string `json:"created"`
+	Target      string `json:"target"`
+	Description string `json:"description,omitempty"`
+}
+
+// BuildsJSON represents the JSON that records the builds, newest first.
+type BuildsJSON struct {
+	SchemaVersion string       `json:"schema-version"`
+	Builds        []BuildEntry `json:"builds"`
+	TimeStamp     string       `json:"timestamp,omitempty"`
+	Tags          []BuildTag   `json:"tags,omitempty"`
+}
+
+// NewBuildsJSON returns the builds.json of a new workdir.
+func NewBuildsJSON() *BuildsJSON {
+	return &BuildsJSON{
+		SchemaVersion: BuildsSchemaVersion,
+		Builds:        []BuildEntry{},
+	}
 }
 
-func getBuilds(dir string) (*buildsJSON, error) {
+// ReadBuildsJSON reads and validates the builds.json in dir.
+func ReadBuildsJSON(dir string) (*BuildsJSON, error) {
 	path := filepath.Join(dir, CosaBuildsJSON)
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, ErrNoBuildsFound
 	}
-	d := []byte{}
-	bufD := bytes.NewBuffer(d)
-	if _, err := io.Copy(bufD, f); err != nil {
-		return nil, err
+	if err := validateBuildsJSON(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-	b := &buildsJSON{}
-	if err := json.Unmarshal(bufD.Bytes(), b); err != nil {
-		return nil, err
+	b := &BuildsJSON{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
 	return b, nil
 }
 
-// getLatest returns the latest build for the arch.
-func (b *buildsJSON) getLatest(arch string) (string, bool) {
+// WriteBuildsJSON validates b and atomically replaces the builds.json in
+// dir with it. Concurrent writers should use UpdateBuildsJSON instead.
+func WriteBuildsJSON(dir string, b *BuildsJSON) error {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := validateBuildsJSON(data); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, "."+CosaBuildsJSON+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, CosaBuildsJSON))
+}
+
+// UpdateBuildsJSON calls update on the builds.json in dir, or on a new one
+// if there is none, and writes it back if update succeeds. The update holds
+// an exclusive flock(2) on .builds.json.flock in dir, which the Python
+// tooling also takes around its reads and writes of builds.json (see
+// lock_builds_json in src/cosalib/builds.py), so concurrent updates are
+// serialized.
+func UpdateBuildsJSON(dir string, update func(*BuildsJSON) error) error {
+	unlock, err := lockBuildsJSON(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := ReadBuildsJSON(dir)
+	if err == ErrNoBuildsFound {
+		b = NewBuildsJSON()
+	} else if err != nil {
+		return err
+	}
+	if err := update(b); err != nil {
+		return err
+	}
+	return WriteBuildsJSON(dir, b)
+}
+
+func lockBuildsJSON(dir string) (func(), error) {
+	// a lock file distinct from the flufl.lock one of write_json in the
+	// Python tooling, which unlinks its lock file
+	path := filepath.Join(dir, "."+CosaBuildsJSON+".flock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint
+		f.Close()
+	}, nil
+}
+
+// Validate checks the builds against the schema.
+func (b *BuildsJSON) Validate() []error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return []error{err}
+	}
+	return schemaErrors(data)
+}
+
+func validateBuildsJSON(data []byte) error {
+	errs := schemaErrors(data)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
+
+func schemaErrors(data []byte) []error {
+	result, err := schema.Validate(
+		schema.NewStringLoader(buildsSchemaJSON),
+		schema.NewBytesLoader(data),
+	)
+	if err != nil {
+		return []error{fmt.Errorf("invalid: %w", err)}
+	}
+	var e []error
+	for _, desc := range result.Errors() {
+		e = append(e, fmt.Errorf("invalid: %s", desc))
+	}
+	return e
+}
+
+// Latest returns the latest build for the arch.
+func (b *BuildsJSON) Latest(arch string) (string, bool) {
 	for _, b := range b.Builds {
 		for _, a := range b.Arches {
 			if a == arch {
@@ -63,3 +237,45 @@ func (b *buildsJSON) getLatest(arch string) (string, bool) {
 	}
 	return "", false
 }
+
+// Get returns the build id, or nil.
+func (b *BuildsJSON) Get(id string) *BuildEntry {
+	for i := range b.Builds {
+		if b.Builds[i].ID == id {
+			return &b.Builds[i]
+		}
+	}
+	return nil
+}
+
+// Insert records the build id for arch as the newest build, or adds arch to
+// the build if it exists.
+func (b *BuildsJSON) Insert(id, arch string) error {
+	if e := b.Get(id); e != nil {
+		for _, a := range e.Arches {
+			if a == arch {
+				return fmt.Errorf("build %s for %s already exists", id, arch)
+			}
+		}
+		e.Arches = append(e.Arches, arch)
+		return nil
+	}
+	b.Builds = append([]BuildEntry{{ID: id, Arches: []string{arch}}}, b.Builds...)
+	return nil
+}
+
+// TagsOf returns the names of the tags targeting the build id.
+func (b *BuildsJSON) TagsOf(id string) []string {
+	var names []string
+	for _, t := range b.Tags {
+		if t.Target == id {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// BumpTimestamp sets the modification time of the builds to now.
+func (b *BuildsJSON) BumpTimestamp() {
+	b.TimeStamp = time.Now().UTC().Format(time.RFC3339)
+}
@@ -30,7 +30,7 @@ func TestBuildsMeta(t *testing.T) {
 		t.Fatalf("failed to write the test data %v", err)
 	}
 
-	b, err := getBuilds(tmpd)
+	b, err := ReadBuildsJSON(tmpd)
 	if err != nil {
 		t.Fatalf("failed to find the builds")
 	}
@@ -38,7 +38,7 @@ func TestBuildsMeta(t *testing.T) {
 		t.Fatalf("builds should not be nil")
 	}
 
-	latest, ok := b.getLatest("x86_64")
+	latest, ok := b.Latest("x86_64")
 	if !ok {
 		t.Fatalf("x86_64 build should be available")
 	}
@@ -55,3 +55,52 @@ func TestCanArtifact(t *testing.T) {
 		t.Errorf("darkCloud is not a valid cloud")
 	}
 }
+
+func TestBuildsJSONUpdate(t *testing.T) {
+	tmpd := t.TempDir()
+
+	if _, err := ReadBuildsJSON(tmpd); err != ErrNoBuildsFound {
+		t.Fatalf("expected ErrNoBuildsFound, got %v", err)
+	}
+
+	// a new builds.json is created
+	if err := UpdateBuildsJSON(tmpd, func(b *BuildsJSON) error {
+		if err := b.Insert("1", "x86_64"); err != nil {
+			return err
+		}
+		if err := b.Insert("2", "x86_64"); err != nil {
+			return err
+		}
+		return b.Insert("2", "aarch64")
+	}); err != nil {
+		t.Fatalf("failed to update builds: %v", err)
+	}
+	b, err := ReadBuildsJSON(tmpd)
+	if err != nil {
+		t.Fatalf("failed to read builds: %v", err)
+	}
+	if latest, _ := b.Latest("aarch64"); latest != "2" {
+		t.Errorf("expected latest aarch64 build 2, got %q", latest)
+	}
+	if err := b.Insert("2", "x86_64"); err == nil {
+		t.Errorf("inserting an existing build should fail")
+	}
+
+	// invalid builds are never written
+	b.Builds[0].Arches = nil
+	if errs := b.Validate(); len(errs) == 0 {
+		t.Errorf("build without arches should be invalid")
+	}
+	if err := WriteBuildsJSON(tmpd, b); err == nil {
+		t.Errorf("writing invalid builds should fail")
+	}
+	if err := UpdateBuildsJSON(tmpd, func(b *BuildsJSON) error {
+		b.SchemaVersion = "2.0.0"
+		return nil
+	}); err == nil {
+		t.Errorf("writing an unsupported schema version should fail")
+	}
+	if b, err = ReadBuildsJSON(tmpd); err != nil || len(b.Builds) != 2 {
+		t.Errorf("builds.json was modified by failed writes: %v, %+v", err, b)
+	}
+}
@@ -1,12 +1,10 @@
 package builds
 
 // generated by 'make schema'
-// source hash: 73eba405214212d41c92204c48cde7bede443c9815395676b5e9c52531d08a61
+// source hash: 215cc5aa881db3d3f5f3e25b9584a04e36f0a6ea4e81d96b769ea34c32682518
 
 type AdvisoryDiff []AdvisoryDiffItems
 
-type AdvisoryDiffItems interface{}
-
 type AliyunImage struct {
 	ImageID string `json:"id"`
 	Region  string `json:"name"`
@@ -83,40 +81,40 @@ type Build struct {
 }
 
 type BuildArtifacts struct {
-	Aliyun                        *Artifact `json:"aliyun,omitempty"`
-	AppleHv                       *Artifact `json:"applehv,omitempty"`
-	Aws                           *Artifact `json:"aws,omitempty"`
-	Azure                         *Artifact `json:"azure,omitempty"`
-	AzureStack                    *Artifact `json:"azurestack,omitempty"`
-	Dasd                          *Artifact `json:"dasd,omitempty"`
-	DigitalOcean                  *Artifact `json:"digitalocean,omitempty"`
-	Exoscale                      *Artifact `json:"exoscale,omitempty"`
-	ExtensionsContainer           *Artifact `json:"extensions-container,omitempty"`
-	Gcp                           *Artifact `json:"gcp,omitempty"`
-	HyperV                        *Artifact `json:"hyperv,omitempty"`
-	IbmCloud                      *Artifact `json:"ibmcloud,omitempty"`
-	Initramfs                     *Artifact `json:"initramfs,omitempty"`
-	Iso                           *Artifact `json:"iso,omitempty"`
-	Kernel                        *Artifact `json:"kernel,omitempty"`
-	KubeVirt                      *Artifact `json:"kubevirt,omitempty"`
-	LegacyOscontainer             *Artifact `json:"legacy-oscontainer,omitempty"`
-	LiveInitramfs                 *Artifact `json:"live-initramfs,omitempty"`
-	LiveIso                       *Artifact `json:"live-iso,omitempty"`
-	LiveKernel                    *Artifact `json:"live-kernel,omitempty"`
-	LiveRootfs                    *Artifact `json:"live-rootfs,omitempty"`
-	Metal                         *Artifact `json:"metal,omitempty"`
-	Metal4KNative                 *Artifact `json:"metal4k,omitempty"`
-	Nutanix                       *Artifact `json:"nutanix,omitempty"`
-	OciManifest                   *Artifact `json:"oci-manifest,omitempty"`
-	OpenStack                     *Artifact `json:"openstack,omitempty"`
-	Ostree                        Artifact  `json:"ostree"`
-	PowerVirtualServer            *Artifact `json:"powervs,omitempty"`
-	Qemu                          *Artifact `json:"qemu,omitempty"`
-	SecureExecutionIgnitionPubKey *Artifact `json:"ignition-gpg-key,omitempty"`
-	SecureExecutionQemu           *Artifact `json:"qemu-secex,omitempty"`
-	VirtualBox                    *Artifact `json:"virtualbox,omitempty"`
-	Vmware                        *Artifact `json:"vmware,omitempty"`
-	Vultr                         *Artifact `json:"vultr,omitempty"`
+	Aliyun                        *Artifact                    `json:"aliyun,omitempty"`
+	AppleHv                       *Artifact                    `json:"applehv,omitempty"`
+	Aws                           *Artifact                    `json:"aws,omitempty"`
+	Azure                         *Artifact                    `json:"azure,omitempty"`
+	AzureStack                    *Artifact                    `json:"azurestack,omitempty"`
+	Dasd                          *Artifact                    `json:"dasd,omitempty"`
+	DigitalOcean                  *Artifact                    `json:"digitalocean,omitempty"`
+	Exoscale                      *Artifact                    `json:"exoscale,omitempty"`
+	ExtensionsContainer           *ExtensionsContainerArtifact `json:"extensions-container,omitempty"`
+	Gcp                           *Artifact                    `json:"gcp,omitempty"`
+	HyperV                        *Artifact                    `json:"hyperv,omitempty"`
+	IbmCloud                      *Artifact                    `json:"ibmcloud,omitempty"`
+	Initramfs                     *Artifact                    `json:"initramfs,omitempty"`
+	Iso                           *Artifact                    `json:"iso,omitempty"`
+	Kernel                        *Artifact                    `json:"kernel,omitempty"`
+	KubeVirt                      *Artifact                    `json:"kubevirt,omitempty"`
+	LegacyOscontainer             *Artifact                    `json:"legacy-oscontainer,omitempty"`
+	LiveInitramfs                 *Artifact                    `json:"live-initramfs,omitempty"`
+	LiveIso                       *Artifact                    `json:"live-iso,omitempty"`
+	LiveKernel                    *Artifact                    `json:"live-kernel,omitempty"`
+	LiveRootfs                    *Artifact                    `json:"live-rootfs,omitempty"`
+	Metal                         *Artifact                    `json:"metal,omitempty"`
+	Metal4KNative                 *Artifact                    `json:"metal4k,omitempty"`
+	Nutanix                       *Artifact                    `json:"nutanix,omitempty"`
+	OciManifest                   *Artifact                    `json:"oci-manifest,omitempty"`
+	OpenStack                     *Artifact                    `json:"openstack,omitempty"`
+	Ostree                        Artifact                     `json:"ostree"`
+	PowerVirtualServer            *Artifact                    `json:"powervs,omitempty"`
+	Qemu                          *Artifact                    `json:"qemu,omitempty"`
+	SecureExecutionIgnitionPubKey *Artifact                    `json:"ignition-gpg-key,omitempty"`
+	SecureExecutionQemu           *Artifact                    `json:"qemu-secex,omitempty"`
+	VirtualBox                    *Artifact                    `json:"virtualbox,omitempty"`
+	Vmware                        *Artifact                    `json:"vmware,omitempty"`
+	Vultr                         *Artifact                    `json:"vultr,omitempty"`
 }
 
 type Cloudartifact struct {
@@ -134,6 +132,16 @@ type Extensions struct {
 	Sha256         string                 `json:"sha256"`
 }
 
+type ExtensionsContainerArtifact struct {
+	Hotfixes           []Hotfix `json:"hotfixes,omitempty"`
+	Path               string   `json:"path"`
+	Sha256             string   `json:"sha256"`
+	SizeInBytes        float64  `json:"size,omitempty"`
+	SkipCompression    bool     `json:"skip-compression,omitempty"`
+	UncompressedSha256 string   `json:"uncompressed-sha256,omitempty"`
+	UncompressedSize   int      `json:"uncompressed-size,omitempty"`
+}
+
 type Gcp struct {
 	ImageFamily  string `json:"family,omitempty"`
 	ImageName    string `json:"image"`
@@ -148,6 +156,18 @@ type Git struct {
 	Origin string `json:"origin"`
 }
 
+type Hotfix struct {
+	Link     string          `json:"link"`
+	OsMajor  string          `json:"osmajor,omitempty"`
+	Packages []HotfixPackage `json:"packages"`
+	Repo     string          `json:"repo"`
+}
+
+type HotfixPackage struct {
+	Nevra  string `json:"nevra"`
+	Sha256 string `json:"sha256"`
+}
+
 type Image struct {
 	Comment string `json:"comment,omitempty"`
 	Digest  string `json:"digest,omitempty"`
@@ -163,8 +183,6 @@ type Koji struct {
 
 type PackageSetDifferences []PackageSetDifferencesItems
 
-type PackageSetDifferencesItems interface{}
-
 type S3 struct {
 	Bucket    string `json:"bucket,omitempty"`
 	Key       string `json:"key,omitempty"`
@@ -0,0 +1,409 @@
+package builds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CosaCommitMetaJSON is the file recording the metadata of the OSTree
+	// commit of a build
+	CosaCommitMetaJSON = "commitmeta.json"
+)
+
+// Package is an RPM, as recorded in the rpmdb package list of a commit.
+type Package struct {
+	Name    string `json:"name"`
+	Epoch   string `json:"epoch"`
+	Version string `json:"version"`
+	Release string `json:"release"`
+	Arch    string `json:"arch"`
+}
+
+// EVR returns the epoch, version and release of the package, omitting the
+// epoch if it's 0.
+func (p Package) EVR() string {
+	evr := p.Version + "-" + p.Release
+	if p.Epoch != "" && p.Epoch != "0" {
+		evr = p.Epoch + ":" + evr
+	}
+	return evr
+}
+
+// NEVRA returns the name, EVR and arch of the package.
+func (p Package) NEVRA() string {
+	return fmt.Sprintf("%s-%s.%s", p.Name, p.EVR(), p.Arch)
+}
+
+// CommitMeta is the part of commitmeta.json used to compare builds.
+type CommitMeta struct {
+	Packages []Package
+	// Advisories are the IDs of the advisories of the packages.
+	Advisories []string
+}
+
+// ReadCommitMeta reads the commitmeta.json of the build in dir.
+func ReadCommitMeta(dir string) (*CommitMeta, error) {
+	path := filepath.Join(dir, CosaCommitMetaJSON)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		PkgList    [][]string          `json:"rpmostree.rpmdb.pkglist"`
+		Advisories []AdvisoryDiffItems `json:"rpmostree.advisories"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	m := &CommitMeta{}
+	for _, nevra := range raw.PkgList {
+		if len(nevra) != 5 {
+			return nil, fmt.Errorf("invalid package %v in %s", nevra, path)
+		}
+		m.Packages = append(m.Packages, Package{
+			Name:    nevra[0],
+			Epoch:   nevra[1],
+			Version: nevra[2],
+			Release: nevra[3],
+			Arch:    nevra[4],
+		})
+	}
+	for _, advisory := range raw.Advisories {
+		if advisory.raw != nil {
+			return nil, fmt.Errorf("invalid advisory %s in %s", advisory.raw, path)
+		}
+		m.Advisories = append(m.Advisories, advisory.ID)
+	}
+	return m, nil
+}
+
+// PackageChange is a package whose version differs between two builds.
+type PackageChange struct {
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Downgrade is true if To is older than From.
+	Downgrade bool `json:"downgrade,omitempty"`
+}
+
+// PackageDiff is the difference between the packages of two builds.
+type PackageDiff struct {
+	Added   []Package       `json:"added,omitempty"`
+	Removed []Package       `json:"removed,omitempty"`
+	Changed []PackageChange `json:"changed,omitempty"`
+}
+
+// AdvisoryChanges is the difference between the advisories of two builds.
+type AdvisoryChanges struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ArtifactChange compares an artifact of two builds. A zero size means that
+// the build doesn't have the artifact.
+type ArtifactChange struct {
+	Name     string `json:"name"`
+	FromSize int64  `json:"from-size"`
+	ToSize   int64  `json:"to-size"`
+	// the uncompressed sizes, if the artifacts are compressed
+	FromUncompressedSize int64 `json:"from-uncompressed-size,omitempty"`
+	ToUncompressedSize   int64 `json:"to-uncompressed-size,omitempty"`
+}
+
+// Delta returns the change in size of the artifact.
+func (c *ArtifactChange) Delta() int64 {
+	return c.ToSize - c.FromSize
+}
+
+// ValueChange is a value that differs between two builds.
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BuildDiff is the difference between two builds of an arch.
+type BuildDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Arch string `json:"arch"`
+
+	OstreeCommit *ValueChange `json:"ostree-commit,omitempty"`
+	ConfigGitRev *ValueChange `json:"config-gitrev,omitempty"`
+	// Kernel is the version of the kernel package.
+	Kernel *ValueChange `json:"kernel,omitempty"`
+
+	Packages   PackageDiff      `json:"packages"`
+	Advisories AdvisoryChanges  `json:"advisories"`
+	Artifacts  []ArtifactChange `json:"artifacts,omitempty"`
+}
+
+// kernelPackage is the package providing the kernel.
+const kernelPackage = "kernel"
+
+func valueChange(from, to string) *ValueChange {
+	if from == to {
+		return nil
+	}
+	return &ValueChange{From: from, To: to}
+}
+
+// DiffBuilds compares the build from to the build to, with the metadata of
+// their commits.
+func DiffBuilds(from *Build, fromCommit *CommitMeta, to *Build, toCommit *CommitMeta) *BuildDiff {
+	d := &BuildDiff{
+		From:         from.BuildID,
+		To:           to.BuildID,
+		Arch:         to.Architecture,
+		OstreeCommit: valueChange(from.OstreeCommit, to.OstreeCommit),
+		ConfigGitRev: valueChange(from.ConfigGitRev, to.ConfigGitRev),
+		Packages:     diffPackages(fromCommit.Packages, toCommit.Packages),
+		Advisories:   diffAdvisories(fromCommit.Advisories, toCommit.Advisories),
+	}
+
+	kernel := func(pkgs []Package) string {
+		for _, p := range pkgs {
+			if p.Name == kernelPackage {
+				return p.EVR()
+			}
+		}
+		return ""
+	}
+	d.Kernel = valueChange(kernel(fromCommit.Packages), kernel(toCommit.Packages))
+
+	fromArtifacts := artifactsOf(from)
+	toArtifacts := artifactsOf(to)
+	var names []string
+	for name := range fromArtifacts {
+		names = append(names, name)
+	}
+	for name := range toArtifacts {
+		if _, ok := fromArtifacts[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := ArtifactChange{Name: name}
+		if a, ok := fromArtifacts[name]; ok {
+			c.FromSize = int64(a.SizeInBytes)
+			c.FromUncompressedSize = int64(a.UncompressedSize)
+		}
+		if a, ok := toArtifacts[name]; ok {
+			c.ToSize = int64(a.SizeInBytes)
+			c.ToUncompressedSize = int64(a.UncompressedSize)
+		}
+		d.Artifacts = append(d.Artifacts, c)
+	}
+	return d
+}
+
+// artifactsOf returns the artifacts present in the build.
+func artifactsOf(b *Build) map[string]*Artifact {
+	ret := map[string]*Artifact{}
+	if b.BuildArtifacts == nil {
+		return ret
+	}
+	for name, a := range b.artifacts() {
+		if a.Path != "" {
+			ret[name] = a
+		}
+	}
+	return ret
+}
+
+func diffPackages(from, to []Package) PackageDiff {
+	key := func(p Package) string {
+		return p.Name + "." + p.Arch
+	}
+	fromPkgs := map[string]Package{}
+	for _, p := range from {
+		fromPkgs[key(p)] = p
+	}
+	toPkgs := map[string]Package{}
+	for _, p := range to {
+		toPkgs[key(p)] = p
+	}
+
+	var d PackageDiff
+	for k, p := range toPkgs {
+		old, ok := fromPkgs[k]
+		if !ok {
+			d.Added = append(d.Added, p)
+		} else if cmp := compareEVR(old, p); cmp != 0 {
+			d.Changed = append(d.Changed, PackageChange{
+				Name:      p.Name,
+				Arch:      p.Arch,
+				From:      old.EVR(),
+				To:        p.EVR(),
+				Downgrade: cmp > 0,
+			})
+		}
+	}
+	for k, p := range fromPkgs {
+		if _, ok := toPkgs[k]; !ok {
+			d.Removed = append(d.Removed, p)
+		}
+	}
+
+	less := func(a, b Package) bool {
+		return a.Name < b.Name || a.Name == b.Name && a.Arch < b.Arch
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return less(d.Added[i], d.Added[j]) })
+	sort.Slice(d.Removed, func(i, j int) bool { return less(d.Removed[i], d.Removed[j]) })
+	sort.Slice(d.Changed, func(i, j int) bool {
+		a, b := d.Changed[i], d.Changed[j]
+		return a.Name < b.Name || a.Name == b.Name && a.Arch < b.Arch
+	})
+	return d
+}
+
+func diffAdvisories(from, to []string) AdvisoryChanges {
+	inFrom := map[string]bool{}
+	for _, id := range from {
+		inFrom[id] = true
+	}
+	inTo := map[string]bool{}
+	for _, id := range to {
+		inTo[id] = true
+	}
+
+	var d AdvisoryChanges
+	for id := range inTo {
+		if !inFrom[id] {
+			d.Added = append(d.Added, id)
+		}
+	}
+	for id := range inFrom {
+		if !inTo[id] {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// compareEVR compares the versions of two packages like RPM, returning -1,
+// 0 or 1 if a is older, the same or newer than b.
+func compareEVR(a, b Package) int {
+	epoch := func(p Package) int {
+		e, _ := strconv.Atoi(p.Epoch)
+		return e
+	}
+	if ea, eb := epoch(a), epoch(b); ea != eb {
+		if ea < eb {
+			return -1
+		}
+		return 1
+	}
+	if c := rpmvercmp(a.Version, b.Version); c != 0 {
+		return c
+	}
+	return rpmvercmp(a.Release, b.Release)
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// rpmvercmp compares two version or release strings like rpmvercmp() in
+// RPM, including the handling of ~ and ^.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	for len(a) > 0 || len(b) > 0 {
+		for len(a) > 0 && !isAlnum(a[0]) && a[0] != '~' && a[0] != '^' {
+			a = a[1:]
+		}
+		for len(b) > 0 && !isAlnum(b[0]) && b[0] != '~' && b[0] != '^' {
+			b = b[1:]
+		}
+
+		// a tilde sorts before everything else
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		// a caret sorts after the end of the string, but before anything
+		// else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if a == "" || b == "" {
+			break
+		}
+
+		// compare the next numeric or alphabetic segments
+		isNum := isDigit(a[0])
+		segment := func(s string) (string, string) {
+			i := 0
+			for i < len(s) && isAlnum(s[i]) && isDigit(s[i]) == isNum {
+				i++
+			}
+			return s[:i], s[i:]
+		}
+		var sa, sb string
+		sa, a = segment(a)
+		sb, b = segment(b)
+		if sb == "" {
+			// numeric segments are newer than alphabetic ones
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			sa = strings.TrimLeft(sa, "0")
+			sb = strings.TrimLeft(sb, "0")
+			if len(sa) != len(sb) {
+				if len(sa) > len(sb) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+	if a == "" && b == "" {
+		return 0
+	}
+	// whichever version still has characters left over wins
+	if a != "" {
+		return 1
+	}
+	return -1
+}
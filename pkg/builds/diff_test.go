@@ -0,0 +1,133 @@
+package builds
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRpmvercmp(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0.1", "2.0", 1},
+		{"1.10", "1.9", 1},
+		{"1.010", "1.10", 0},
+		{"1.0a", "1.0", 1},
+		{"1.a", "1.1", -1},
+		{"fc38", "fc39", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1_0", "1.0", 0},
+	} {
+		if actual := rpmvercmp(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("rpmvercmp(%q, %q) = %d, expected %d", tc.a, tc.b, actual, tc.expected)
+		}
+		if actual := rpmvercmp(tc.b, tc.a); actual != -tc.expected {
+			t.Errorf("rpmvercmp(%q, %q) = %d, expected %d", tc.b, tc.a, actual, -tc.expected)
+		}
+	}
+}
+
+func TestDiffBuilds(t *testing.T) {
+	tmpd := t.TempDir()
+	commitmeta := func(id, data string) *CommitMeta {
+		dir := filepath.Join(tmpd, id)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, CosaCommitMetaJSON), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := ReadCommitMeta(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	fromCommit := commitmeta("1", `{
+		"rpmostree.rpmdb.pkglist": [
+			["bash", "0", "5.2.15", "3.fc38", "x86_64"],
+			["kernel", "0", "6.3.8", "200.fc38", "x86_64"],
+			["vim-minimal", "2", "9.0.1627", "1.fc38", "x86_64"],
+			["zincati", "0", "0.0.25", "4.fc38", "x86_64"]
+		],
+		"rpmostree.advisories": [
+			["FEDORA-2023-1", 1, 4, [], {}]
+		]
+	}`)
+	toCommit := commitmeta("2", `{
+		"rpmostree.rpmdb.pkglist": [
+			["bash", "0", "5.2.15", "3.fc38", "x86_64"],
+			["kernel", "0", "6.3.12", "200.fc38", "x86_64"],
+			["vim-minimal", "2", "9.0.1572", "1.fc38", "x86_64"],
+			["toolbox", "0", "0.0.99.4", "1.fc38", "x86_64"]
+		],
+		"rpmostree.advisories": [
+			["FEDORA-2023-2", 1, 4, [], {}]
+		]
+	}`)
+
+	from := &Build{
+		BuildID:      "38.1",
+		OstreeCommit: "a",
+		ConfigGitRev: "c1",
+		BuildArtifacts: &BuildArtifacts{
+			Ostree: Artifact{Path: "ostree.tar", SizeInBytes: 1000},
+			Qemu:   &Artifact{Path: "disk.qcow2.xz", SizeInBytes: 500, UncompressedSize: 2000},
+		},
+	}
+	to := &Build{
+		BuildID:      "38.2",
+		Architecture: "x86_64",
+		OstreeCommit: "b",
+		ConfigGitRev: "c1",
+		BuildArtifacts: &BuildArtifacts{
+			Ostree: Artifact{Path: "ostree.tar", SizeInBytes: 1100},
+			Metal:  &Artifact{Path: "metal.raw.xz", SizeInBytes: 700},
+		},
+	}
+
+	d := DiffBuilds(from, fromCommit, to, toCommit)
+	if d.From != "38.1" || d.To != "38.2" || d.Arch != "x86_64" {
+		t.Errorf("unexpected builds %s, %s, %s", d.From, d.To, d.Arch)
+	}
+	if !reflect.DeepEqual(d.OstreeCommit, &ValueChange{From: "a", To: "b"}) || d.ConfigGitRev != nil {
+		t.Errorf("unexpected commit or config changes: %v, %v", d.OstreeCommit, d.ConfigGitRev)
+	}
+	if !reflect.DeepEqual(d.Kernel, &ValueChange{From: "6.3.8-200.fc38", To: "6.3.12-200.fc38"}) {
+		t.Errorf("unexpected kernel change %v", d.Kernel)
+	}
+
+	expectedPackages := PackageDiff{
+		Added:   []Package{{Name: "toolbox", Epoch: "0", Version: "0.0.99.4", Release: "1.fc38", Arch: "x86_64"}},
+		Removed: []Package{{Name: "zincati", Epoch: "0", Version: "0.0.25", Release: "4.fc38", Arch: "x86_64"}},
+		Changed: []PackageChange{
+			{Name: "kernel", Arch: "x86_64", From: "6.3.8-200.fc38", To: "6.3.12-200.fc38"},
+			{Name: "vim-minimal", Arch: "x86_64", From: "2:9.0.1627-1.fc38", To: "2:9.0.1572-1.fc38", Downgrade: true},
+		},
+	}
+	if !reflect.DeepEqual(d.Packages, expectedPackages) {
+		t.Errorf("unexpected package diff:\n%+v\nexpected:\n%+v", d.Packages, expectedPackages)
+	}
+
+	expectedAdvisories := AdvisoryChanges{Added: []string{"FEDORA-2023-2"}, Removed: []string{"FEDORA-2023-1"}}
+	if !reflect.DeepEqual(d.Advisories, expectedAdvisories) {
+		t.Errorf("unexpected advisories %+v", d.Advisories)
+	}
+
+	expectedArtifacts := []ArtifactChange{
+		{Name: "metal", ToSize: 700},
+		{Name: "ostree", FromSize: 1000, ToSize: 1100},
+		{Name: "qemu", FromSize: 500, FromUncompressedSize: 2000},
+	}
+	if !reflect.DeepEqual(d.Artifacts, expectedArtifacts) {
+		t.Errorf("unexpected artifacts %+v", d.Artifacts)
+	}
+}
@@ -0,0 +1,220 @@
+package builds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// The package and advisory diffs of meta.json are written by
+// `rpm-ostree db diff --advisories --format=json` as arrays of tuples. The
+// types below decode these tuples into structs, and encode them back the
+// same way. Entries which can't be decoded are kept as is, so that reading
+// and writing meta.json doesn't lose them; Validate() rejects them.
+
+// PackageDiffType is the type of change of a package between two builds.
+type PackageDiffType int
+
+const (
+	PackageAdded PackageDiffType = iota
+	PackageRemoved
+	PackageUpgraded
+	PackageDowngraded
+)
+
+func (t PackageDiffType) String() string {
+	switch t {
+	case PackageAdded:
+		return "added"
+	case PackageRemoved:
+		return "removed"
+	case PackageUpgraded:
+		return "upgraded"
+	case PackageDowngraded:
+		return "downgraded"
+	}
+	return fmt.Sprintf("PackageDiffType(%d)", int(t))
+}
+
+// DiffPackage is a package of a package diff entry.
+type DiffPackage struct {
+	Name string
+	// EVR is the epoch, version and release of the package; the epoch is
+	// omitted if it's 0.
+	EVR  string
+	Arch string
+}
+
+// NEVRA returns the name, EVR and arch of the package.
+func (p DiffPackage) NEVRA() string {
+	return fmt.Sprintf("%s-%s.%s", p.Name, p.EVR, p.Arch)
+}
+
+func (p DiffPackage) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{p.Name, p.EVR, p.Arch})
+}
+
+func (p *DiffPackage) UnmarshalJSON(data []byte) error {
+	var t []string
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	if len(t) != 3 {
+		return fmt.Errorf("expected name, EVR and arch, found %d fields", len(t))
+	}
+	p.Name, p.EVR, p.Arch = t[0], t[1], t[2]
+	return nil
+}
+
+// PackageSetDifferencesItems is an entry of a package diff, a package
+// which changed between two builds.
+type PackageSetDifferencesItems struct {
+	Name string
+	Type PackageDiffType
+	// Previous is the package in the older build, unless it was added.
+	Previous *DiffPackage
+	// New is the package in the newer build, unless it was removed.
+	New *DiffPackage
+
+	// raw is the entry if it couldn't be decoded.
+	raw json.RawMessage
+}
+
+type packageDiffDetails struct {
+	Previous *DiffPackage `json:"PreviousPackage,omitempty"`
+	New      *DiffPackage `json:"NewPackage,omitempty"`
+}
+
+func (d PackageSetDifferencesItems) MarshalJSON() ([]byte, error) {
+	if d.raw != nil {
+		return d.raw, nil
+	}
+	return json.Marshal([]interface{}{d.Name, d.Type, packageDiffDetails{d.Previous, d.New}})
+}
+
+func (d *PackageSetDifferencesItems) UnmarshalJSON(data []byte) error {
+	*d = PackageSetDifferencesItems{}
+	var name string
+	var details packageDiffDetails
+	t := []interface{}{&name, &d.Type, &details}
+	if err := unmarshalTuple(data, t, len(t)); err != nil {
+		*d = PackageSetDifferencesItems{raw: append(json.RawMessage(nil), data...)}
+		return nil
+	}
+	d.Name, d.Previous, d.New = name, details.Previous, details.New
+	return nil
+}
+
+// AdvisoryKind is the kind of an advisory, as in libdnf.
+type AdvisoryKind int
+
+const (
+	AdvisoryUnknown AdvisoryKind = iota
+	AdvisorySecurity
+	AdvisoryBugfix
+	AdvisoryEnhancement
+	AdvisoryNewPackage
+)
+
+func (k AdvisoryKind) String() string {
+	switch k {
+	case AdvisoryUnknown:
+		return "unknown"
+	case AdvisorySecurity:
+		return "security"
+	case AdvisoryBugfix:
+		return "bugfix"
+	case AdvisoryEnhancement:
+		return "enhancement"
+	case AdvisoryNewPackage:
+		return "newpackage"
+	}
+	return fmt.Sprintf("AdvisoryKind(%d)", int(k))
+}
+
+// AdvisorySeverity is the severity of an advisory, as in rpm-ostree.
+type AdvisorySeverity int
+
+const (
+	AdvisorySeverityNone AdvisorySeverity = iota
+	AdvisorySeverityLow
+	AdvisorySeverityModerate
+	AdvisorySeverityImportant
+	AdvisorySeverityCritical
+)
+
+func (s AdvisorySeverity) String() string {
+	switch s {
+	case AdvisorySeverityNone:
+		return "none"
+	case AdvisorySeverityLow:
+		return "low"
+	case AdvisorySeverityModerate:
+		return "moderate"
+	case AdvisorySeverityImportant:
+		return "important"
+	case AdvisorySeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("AdvisorySeverity(%d)", int(s))
+}
+
+// AdvisoryDiffItems is an entry of an advisory diff, an advisory of the
+// packages which changed between two builds.
+type AdvisoryDiffItems struct {
+	ID       string
+	Kind     AdvisoryKind
+	Severity AdvisorySeverity
+	// Packages are the NEVRAs of the packages fixed by the advisory.
+	Packages []string
+	// References are the references of the advisory (e.g. CVEs), by URL.
+	References map[string]interface{}
+
+	// raw is the entry if it couldn't be decoded.
+	raw json.RawMessage
+}
+
+func (a AdvisoryDiffItems) MarshalJSON() ([]byte, error) {
+	if a.raw != nil {
+		return a.raw, nil
+	}
+	packages, references := a.Packages, a.References
+	if packages == nil {
+		packages = []string{}
+	}
+	if references == nil {
+		references = map[string]interface{}{}
+	}
+	return json.Marshal([]interface{}{a.ID, a.Kind, a.Severity, packages, references})
+}
+
+func (a *AdvisoryDiffItems) UnmarshalJSON(data []byte) error {
+	*a = AdvisoryDiffItems{}
+	// the references are optional
+	t := []interface{}{&a.ID, &a.Kind, &a.Severity, &a.Packages, &a.References}
+	if err := unmarshalTuple(data, t, len(t)-1); err != nil {
+		*a = AdvisoryDiffItems{raw: append(json.RawMessage(nil), data...)}
+	}
+	return nil
+}
+
+// unmarshalTuple decodes the JSON array in data into the elements of
+// tuple, requiring at least min elements.
+func unmarshalTuple(data []byte, tuple []interface{}, min int) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	if len(elems) < min || len(elems) > len(tuple) {
+		return fmt.Errorf("expected %d to %d elements, found %d", min, len(tuple), len(elems))
+	}
+	for i, elem := range elems {
+		if bytes.Equal(bytes.TrimSpace(elem), []byte("null")) {
+			return fmt.Errorf("element %d is null", i)
+		}
+		if err := json.Unmarshal(elem, tuple[i]); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
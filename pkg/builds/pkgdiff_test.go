@@ -0,0 +1,112 @@
+package builds
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPackageDiffJSON(t *testing.T) {
+	data := `[
+		["podman", 2, {"PreviousPackage": ["podman", "2:1.8.1-0.7.rc4.fc31", "x86_64"], "NewPackage": ["podman", "2:1.8.1-2.fc31", "x86_64"]}],
+		["zram-generator", 0, {"NewPackage": ["zram-generator", "1.1.2-4.fc38", "x86_64"]}],
+		["bogus", 1]
+	]`
+	var diff PackageSetDifferences
+	if err := json.Unmarshal([]byte(data), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 3 {
+		t.Fatalf("expected 3 entries, found %d", len(diff))
+	}
+	expected := PackageSetDifferencesItems{
+		Name:     "podman",
+		Type:     PackageUpgraded,
+		Previous: &DiffPackage{Name: "podman", EVR: "2:1.8.1-0.7.rc4.fc31", Arch: "x86_64"},
+		New:      &DiffPackage{Name: "podman", EVR: "2:1.8.1-2.fc31", Arch: "x86_64"},
+	}
+	if !reflect.DeepEqual(diff[0], expected) {
+		t.Errorf("expected %+v, found %+v", expected, diff[0])
+	}
+	if diff[1].Type != PackageAdded || diff[1].Previous != nil || diff[1].New.NEVRA() != "zram-generator-1.1.2-4.fc38.x86_64" {
+		t.Errorf("unexpected added package %+v", diff[1])
+	}
+	if diff[2].raw == nil {
+		t.Errorf("expected malformed entry to be kept as is, found %+v", diff[2])
+	}
+
+	// round-trip
+	out, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual, orig interface{}
+	if err := json.Unmarshal(out, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(data), &orig); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, orig) {
+		t.Errorf("round-trip mismatch: %s", out)
+	}
+}
+
+func TestAdvisoryDiffJSON(t *testing.T) {
+	data := `[
+		["FEDORA-2023-abcdef", 1, 3, ["openssl-1:3.0.9-2.fc38.x86_64"], {"https://bugzilla.redhat.com/1": [0, "CVE-2023-1"]}],
+		["FEDORA-2023-012345", 2, 0, []],
+		[1, 2, 3, 4]
+	]`
+	var diff AdvisoryDiff
+	if err := json.Unmarshal([]byte(data), &diff); err != nil {
+		t.Fatal(err)
+	}
+	a := diff[0]
+	if a.ID != "FEDORA-2023-abcdef" || a.Kind != AdvisorySecurity || a.Severity != AdvisorySeverityImportant ||
+		!reflect.DeepEqual(a.Packages, []string{"openssl-1:3.0.9-2.fc38.x86_64"}) || len(a.References) != 1 {
+		t.Errorf("unexpected advisory %+v", a)
+	}
+	if diff[1].Kind.String() != "bugfix" || diff[1].References != nil {
+		t.Errorf("unexpected advisory %+v", diff[1])
+	}
+	if diff[2].raw == nil {
+		t.Errorf("expected malformed entry to be kept as is, found %+v", diff[2])
+	}
+}
+
+func TestValidateDiffs(t *testing.T) {
+	build := func(pkgdiff, advisories string) *Build {
+		b := &Build{}
+		if err := json.Unmarshal([]byte(`{"pkgdiff": `+pkgdiff+`, "advisories-diff": `+advisories+`}`), b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// the errors of the diffs, ignoring the other required fields
+	diffErrors := func(b *Build) int {
+		ref := build(`[]`, `[]`).Validate()
+		return len(b.Validate()) - len(ref)
+	}
+
+	valid := build(
+		`[["kernel", 3, {"PreviousPackage": ["kernel", "6.4.0-1.fc38", "x86_64"], "NewPackage": ["kernel", "6.3.0-1.fc38", "x86_64"]}]]`,
+		`[["FEDORA-2023-1", 1, 4, ["kernel-6.3.0-1.fc38.x86_64"], {}]]`)
+	if n := diffErrors(valid); n != 0 {
+		t.Errorf("expected valid diffs, found %v", valid.Validate())
+	}
+
+	for _, tc := range []struct {
+		pkgdiff, advisories string
+	}{
+		{`[["kernel", 7, {}]]`, `[]`},
+		{`[["kernel", 2, {"NewPackage": ["kernel", "6.4.0-1.fc38", "x86_64"]}]]`, `[]`},
+		{`[["kernel", 1, {"PreviousPackage": ["kernel", "6.4.0-1.fc38"]}]]`, `[]`},
+		{`[]`, `[["", 1, 4, [], {}]]`},
+		{`[]`, `[["FEDORA-2023-1", "security"]]`},
+	} {
+		if n := diffErrors(build(tc.pkgdiff, tc.advisories)); n <= 0 {
+			t.Errorf("expected %s %s to be invalid", tc.pkgdiff, tc.advisories)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package builds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// LocalBuild is a build found in a builds directory.
+type LocalBuild struct {
+	ID string
+	// Timestamps maps the arches of the build to their build time.
+	Timestamps map[string]time.Time
+}
+
+// Arches returns the sorted arches of the build.
+func (b *LocalBuild) Arches() []string {
+	arches := make([]string, 0, len(b.Timestamps))
+	for arch := range b.Timestamps {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// Timestamp returns the build time of the newest arch of the build.
+func (b *LocalBuild) Timestamp() time.Time {
+	var ts time.Time
+	for _, t := range b.Timestamps {
+		if t.After(ts) {
+			ts = t
+		}
+	}
+	return ts
+}
+
+// ScanLocalBuilds returns the builds in dir, newest first. Only the arches
+// with a meta.json are considered.
+func ScanLocalBuilds(dir string) ([]LocalBuild, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var builds []LocalBuild
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		archEntries, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		b := LocalBuild{ID: entry.Name(), Timestamps: map[string]time.Time{}}
+		for _, archEntry := range archEntries {
+			if !archEntry.IsDir() {
+				continue
+			}
+			ts, err := readBuildTimestamp(filepath.Join(dir, entry.Name(), archEntry.Name(), CosaMetaJSON))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			b.Timestamps[archEntry.Name()] = ts
+		}
+		if len(b.Timestamps) > 0 {
+			builds = append(builds, b)
+		}
+	}
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].Timestamp().After(builds[j].Timestamp())
+	})
+	return builds, nil
+}
+
+// readBuildTimestamp returns the build time recorded in a meta.json.
+func readBuildTimestamp(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var meta struct {
+		BuildTimeStamp  string `json:"coreos-assembler.build-timestamp"`
+		OstreeTimestamp string `json:"ostree-timestamp"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	// older builds only have ostree-timestamp
+	ts := meta.BuildTimeStamp
+	if ts == "" {
+		ts = meta.OstreeTimestamp
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid build timestamp in %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Retention decides which builds of an arch to keep. A build is kept if
+// either rule keeps it; with both rules unset, all builds are kept.
+type Retention struct {
+	// KeepLastN keeps the N newest untagged builds.
+	KeepLastN int
+	// KeepNewerThan keeps the builds younger than it.
+	KeepNewerThan time.Duration
+}
+
+func (r Retention) keepsAll() bool {
+	return r.KeepLastN == 0 && r.KeepNewerThan == 0
+}
+
+// PrunePolicy decides which builds to prune.
+type PrunePolicy struct {
+	Retention
+	// ArchRetention overrides the retention for some arches.
+	ArchRetention map[string]Retention
+	// Arches, if set, restricts pruning to these arches.
+	Arches []string
+	// PruneTagged allows pruning tagged builds, which are kept otherwise.
+	PruneTagged bool
+	// Builds, if set, are pruned regardless of the retention.
+	Builds []string
+}
+
+// PruneDecision is the outcome of a prune for a build.
+type PruneDecision struct {
+	ID string
+	// Keep and Prune are the arches of the build kept and pruned.
+	Keep  []string
+	Prune []string
+	// Tags are the tags targeting the build.
+	Tags []string
+}
+
+// Removed returns true if the whole build is pruned.
+func (d *PruneDecision) Removed() bool {
+	return len(d.Keep) == 0
+}
+
+// PlanPrune applies the policy to the local builds, newest first, and
+// returns the decision for each of them, in the same order.
+func PlanPrune(local []LocalBuild, b *BuildsJSON, p PrunePolicy, now time.Time) ([]PruneDecision, error) {
+	inScope := func(arch string) bool {
+		if len(p.Arches) == 0 {
+			return true
+		}
+		for _, a := range p.Arches {
+			if a == arch {
+				return true
+			}
+		}
+		return false
+	}
+
+	// pruned[id][arch] is set for the arches to prune
+	pruned := map[string]map[string]bool{}
+	prune := func(id, arch string) {
+		if pruned[id] == nil {
+			pruned[id] = map[string]bool{}
+		}
+		pruned[id][arch] = true
+	}
+
+	if len(p.Builds) > 0 {
+		for _, id := range p.Builds {
+			var build *LocalBuild
+			for i := range local {
+				if local[i].ID == id {
+					build = &local[i]
+				}
+			}
+			if build == nil {
+				return nil, fmt.Errorf("failed to find build ID: %s", id)
+			}
+			if tags := b.TagsOf(id); len(tags) > 0 && !p.PruneTagged {
+				return nil, fmt.Errorf("build %s is tagged (%v)", id, tags)
+			}
+			for _, arch := range build.Arches() {
+				if inScope(arch) {
+					prune(id, arch)
+				}
+			}
+		}
+	} else {
+		for _, arch := range localArches(local) {
+			if !inScope(arch) {
+				continue
+			}
+			r, ok := p.ArchRetention[arch]
+			if !ok {
+				r = p.Retention
+			}
+			if r.keepsAll() {
+				continue
+			}
+			var builds []LocalBuild
+			for _, build := range local {
+				if _, ok := build.Timestamps[arch]; ok {
+					builds = append(builds, build)
+				}
+			}
+			sort.SliceStable(builds, func(i, j int) bool {
+				return builds[i].Timestamps[arch].After(builds[j].Timestamps[arch])
+			})
+			n := r.KeepLastN
+			for _, build := range builds {
+				switch {
+				case len(b.TagsOf(build.ID)) > 0 && !p.PruneTagged:
+				case n > 0:
+					n--
+				case r.KeepNewerThan > 0 && now.Sub(build.Timestamps[arch]) < r.KeepNewerThan:
+				default:
+					prune(build.ID, arch)
+				}
+			}
+		}
+	}
+
+	decisions := make([]PruneDecision, 0, len(local))
+	for _, build := range local {
+		d := PruneDecision{ID: build.ID, Tags: b.TagsOf(build.ID)}
+		for _, arch := range build.Arches() {
+			if pruned[build.ID][arch] {
+				d.Prune = append(d.Prune, arch)
+			} else {
+				d.Keep = append(d.Keep, arch)
+			}
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, nil
+}
+
+// localArches returns the sorted arches of the builds.
+func localArches(local []LocalBuild) []string {
+	seen := map[string]bool{}
+	var arches []string
+	for _, build := range local {
+		for arch := range build.Timestamps {
+			if !seen[arch] {
+				seen[arch] = true
+				arches = append(arches, arch)
+			}
+		}
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// ApplyPrune sets the builds to the ones kept by the decisions, newest
+// first, which also records the local builds missing from the builds and
+// drops the ones not found locally. Tags targeting pruned builds are
+// removed.
+func (b *BuildsJSON) ApplyPrune(decisions []PruneDecision) {
+	removed := map[string]bool{}
+	builds := []BuildEntry{}
+	for _, d := range decisions {
+		if d.Removed() {
+			removed[d.ID] = true
+			continue
+		}
+		kept := map[string]bool{}
+		for _, arch := range d.Keep {
+			kept[arch] = true
+		}
+		// preserve the order of the recorded arches
+		var arches []string
+		if e := b.Get(d.ID); e != nil {
+			for _, arch := range e.Arches {
+				if kept[arch] {
+					arches = append(arches, arch)
+					delete(kept, arch)
+				}
+			}
+		}
+		for _, arch := range d.Keep {
+			if kept[arch] {
+				arches = append(arches, arch)
+			}
+		}
+		builds = append(builds, BuildEntry{ID: d.ID, Arches: arches})
+	}
+	b.Builds = builds
+
+	var tags []BuildTag
+	for _, t := range b.Tags {
+		if !removed[t.Target] {
+			tags = append(tags, t)
+		}
+	}
+	b.Tags = tags
+}
@@ -0,0 +1,181 @@
+package builds
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanPrune(t *testing.T) {
+	now := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return now.Add(-time.Duration(d) * 24 * time.Hour)
+	}
+	// newest first
+	local := []LocalBuild{
+		{ID: "5", Timestamps: map[string]time.Time{"x86_64": day(1), "aarch64": day(1)}},
+		{ID: "4", Timestamps: map[string]time.Time{"x86_64": day(2)}},
+		{ID: "3", Timestamps: map[string]time.Time{"x86_64": day(10), "aarch64": day(10)}},
+		{ID: "2", Timestamps: map[string]time.Time{"x86_64": day(20), "aarch64": day(20)}},
+		{ID: "1", Timestamps: map[string]time.Time{"x86_64": day(30), "aarch64": day(30)}},
+	}
+	b := &BuildsJSON{
+		SchemaVersion: BuildsSchemaVersion,
+		Builds: []BuildEntry{
+			{ID: "4", Arches: []string{"x86_64"}},
+			{ID: "3", Arches: []string{"aarch64", "x86_64"}},
+			{ID: "2", Arches: []string{"x86_64", "aarch64"}},
+			{ID: "1", Arches: []string{"x86_64", "aarch64"}},
+		},
+		Tags: []BuildTag{{Name: "stable", Created: "2023-06-01T00:00:00Z", Target: "1"}},
+	}
+
+	// pruned arches of each build, and whether they're removed
+	type outcome map[string][]string
+	for _, tc := range []struct {
+		name     string
+		policy   PrunePolicy
+		expected outcome
+	}{
+		{
+			name:     "keep all",
+			policy:   PrunePolicy{},
+			expected: outcome{},
+		},
+		{
+			name:   "keep last 2",
+			policy: PrunePolicy{Retention: Retention{KeepLastN: 2}},
+			expected: outcome{
+				"3": {"x86_64"},
+				"2": {"aarch64", "x86_64"},
+			},
+		},
+		{
+			name:   "keep last 1 or newer than 15 days",
+			policy: PrunePolicy{Retention: Retention{KeepLastN: 1, KeepNewerThan: 15 * 24 * time.Hour}},
+			expected: outcome{
+				"2": {"aarch64", "x86_64"},
+			},
+		},
+		{
+			name:   "prune tagged",
+			policy: PrunePolicy{Retention: Retention{KeepLastN: 3}, PruneTagged: true},
+			expected: outcome{
+				"2": {"x86_64"},
+				"1": {"aarch64", "x86_64"},
+			},
+		},
+		{
+			name: "per arch",
+			policy: PrunePolicy{
+				Retention:     Retention{KeepLastN: 4},
+				ArchRetention: map[string]Retention{"aarch64": {KeepLastN: 1}},
+			},
+			expected: outcome{
+				"3": {"aarch64"},
+				"2": {"aarch64"},
+			},
+		},
+		{
+			name:   "only aarch64",
+			policy: PrunePolicy{Retention: Retention{KeepLastN: 1}, Arches: []string{"aarch64"}},
+			expected: outcome{
+				"3": {"aarch64"},
+				"2": {"aarch64"},
+			},
+		},
+		{
+			name:   "explicit builds",
+			policy: PrunePolicy{Builds: []string{"5", "3"}},
+			expected: outcome{
+				"5": {"aarch64", "x86_64"},
+				"3": {"aarch64", "x86_64"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decisions, err := PlanPrune(local, b, tc.policy, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decisions) != len(local) {
+				t.Fatalf("expected %d decisions, got %d", len(local), len(decisions))
+			}
+			actual := outcome{}
+			for i, d := range decisions {
+				if d.ID != local[i].ID {
+					t.Errorf("decision %d is for build %s, expected %s", i, d.ID, local[i].ID)
+				}
+				if len(d.Prune) > 0 {
+					actual[d.ID] = d.Prune
+				}
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected pruning %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+
+	if _, err := PlanPrune(local, b, PrunePolicy{Builds: []string{"1"}}, now); err == nil {
+		t.Errorf("pruning a tagged build should fail")
+	}
+	if _, err := PlanPrune(local, b, PrunePolicy{Builds: []string{"6"}}, now); err == nil {
+		t.Errorf("pruning a missing build should fail")
+	}
+
+	decisions, err := PlanPrune(local, b, PrunePolicy{Retention: Retention{KeepLastN: 1}, PruneTagged: true}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ApplyPrune(decisions)
+	// build 5 wasn't recorded yet; the tag of the pruned build 1 is gone
+	expected := []BuildEntry{
+		{ID: "5", Arches: []string{"aarch64", "x86_64"}},
+	}
+	if !reflect.DeepEqual(b.Builds, expected) || len(b.Tags) != 0 {
+		t.Errorf("unexpected builds after pruning: %+v, tags %+v", b.Builds, b.Tags)
+	}
+	if errs := b.Validate(); len(errs) > 0 {
+		t.Errorf("builds are invalid after pruning: %v", errs)
+	}
+}
+
+func TestScanLocalBuilds(t *testing.T) {
+	tmpd := t.TempDir()
+	for path, meta := range map[string]string{
+		"1/x86_64":  `{"coreos-assembler.build-timestamp": "2023-06-01T00:00:00Z"}`,
+		"2/x86_64":  `{"coreos-assembler.build-timestamp": "2023-06-02T00:00:00Z"}`,
+		"2/aarch64": `{"ostree-timestamp": "2023-06-03T00:00:00Z"}`,
+		// no meta.json
+		"3/x86_64": "",
+	} {
+		dir := filepath.Join(tmpd, path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if meta != "" {
+			if err := os.WriteFile(filepath.Join(dir, CosaMetaJSON), []byte(meta), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.Symlink("2", filepath.Join(tmpd, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := ScanLocalBuilds(tmpd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(local) != 2 || local[0].ID != "2" || local[1].ID != "1" {
+		t.Fatalf("unexpected builds %+v", local)
+	}
+	if arches := local[0].Arches(); !reflect.DeepEqual(arches, []string{"aarch64", "x86_64"}) {
+		t.Errorf("unexpected arches %v", arches)
+	}
+	if ts := local[0].Timestamp(); !ts.Equal(time.Date(2023, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", ts)
+	}
+}
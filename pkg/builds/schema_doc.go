@@ -1,5 +1,5 @@
 // Generated by ./generate-schema.sh
-// Source hash: 73eba405214212d41c92204c48cde7bede443c9815395676b5e9c52531d08a61
+// Source hash: 215cc5aa881db3d3f5f3e25b9584a04e36f0a6ea4e81d96b769ea34c32682518
 // DO NOT EDIT
 
 package builds
@@ -53,6 +53,118 @@ var generatedSchemaJSON = `{
         "sha256"
       ]
     },
+    "extensions-container-artifact": {
+      "type": "object",
+      "properties": {
+        "path": {
+          "$id": "#/extensions-container-artifact/Path",
+          "type": "string",
+          "title": "Path"
+        },
+        "sha256": {
+          "$id": "#/extensions-container-artifact/sha256",
+          "type": "string",
+          "title": "SHA256"
+        },
+        "size": {
+          "$id": "#/extensions-container-artifact/size",
+          "type": "number",
+          "title": "Size in bytes"
+        },
+        "skip-compression": {
+          "$id": "#/extensions-container-artifact/skip-compression",
+          "type": "boolean",
+          "title": "Skip compression",
+          "description": "Artifact should not be compressed or decompressed before use",
+          "default": false
+        },
+        "uncompressed-sha256": {
+          "$id": "#/extensions-container-artifact/uncompressed-sha256",
+          "type": "string",
+          "title": "Uncompressed SHA256"
+        },
+        "uncompressed-size": {
+          "$id": "#/extensions-container-artifact/uncompressed-size",
+          "type": "integer",
+          "title": "Uncompressed-size"
+        },
+        "hotfixes": {
+          "$id": "#/extensions-container-artifact/hotfixes",
+          "type": "array",
+          "title": "Hotfixes",
+          "description": "Hotfix RPMs included in the extensions container",
+          "items": {
+            "$id": "#/extensions-container-artifact/hotfixes/item",
+            "$ref": "#/definitions/hotfix"
+          }
+        }
+      },
+      "optional": [
+        "size",
+        "uncompressed-sha256",
+        "uncompressed-size",
+        "skip-compression",
+        "hotfixes"
+      ],
+      "required": [
+        "path",
+        "sha256"
+      ]
+    },
+    "hotfix": {
+      "type": "object",
+      "required": [
+        "link",
+        "repo",
+        "packages"
+      ],
+      "optional": [
+        "osmajor"
+      ],
+      "properties": {
+        "link": {
+          "$id": "#/hotfix/link",
+          "type": "string",
+          "title": "Link"
+        },
+        "osmajor": {
+          "$id": "#/hotfix/osmajor",
+          "type": "string",
+          "title": "OS major"
+        },
+        "repo": {
+          "$id": "#/hotfix/repo",
+          "type": "string",
+          "title": "Repo"
+        },
+        "packages": {
+          "$id": "#/hotfix/packages",
+          "type": "array",
+          "title": "Packages",
+          "items": {
+            "$id": "#/hotfix/packages/item",
+            "type": "object",
+            "title": "Hotfix package",
+            "required": [
+              "nevra",
+              "sha256"
+            ],
+            "properties": {
+              "nevra": {
+                "$id": "#/hotfix/packages/item/nevra",
+                "type": "string",
+                "title": "NEVRA"
+              },
+              "sha256": {
+                "$id": "#/hotfix/packages/item/sha256",
+                "type": "string",
+                "title": "SHA256"
+              }
+            }
+          }
+        }
+      }
+    },
     "image": {
       "type": "object",
       "required": [
@@ -189,7 +301,58 @@ var generatedSchemaJSON = `{
       "items": {
         "$id": "#/pkgdiff/items/item",
         "title": "Items",
-        "default": "",
+        "description": "Package name, type of change (0 added, 1 removed, 2 upgraded, 3 downgraded) and packages",
+        "type": "array",
+        "minItems": 3,
+        "maxItems": 3,
+        "items": [
+          {
+            "$id": "#/pkgdiff/items/item/name",
+            "type": "string",
+            "minLength": 1
+          },
+          {
+            "$id": "#/pkgdiff/items/item/type",
+            "type": "integer",
+            "enum": [0, 1, 2, 3]
+          },
+          {
+            "$id": "#/pkgdiff/items/item/packages",
+            "type": "object",
+            "properties": {
+              "PreviousPackage": {
+                "$ref": "#/definitions/pkg-diff-package"
+              },
+              "NewPackage": {
+                "$ref": "#/definitions/pkg-diff-package"
+              }
+            }
+          }
+        ],
+        "allOf": [
+          {
+            "if": {"items": [{}, {"const": 0}]},
+            "then": {"items": [{}, {}, {"required": ["NewPackage"]}]}
+          },
+          {
+            "if": {"items": [{}, {"const": 1}]},
+            "then": {"items": [{}, {}, {"required": ["PreviousPackage"]}]}
+          },
+          {
+            "if": {"items": [{}, {"enum": [2, 3]}]},
+            "then": {"items": [{}, {}, {"required": ["PreviousPackage", "NewPackage"]}]}
+          }
+        ]
+      }
+    },
+    "pkg-diff-package": {
+      "$id": "#/pkgdiff/package",
+      "description": "Package name, EVR and arch",
+      "type": "array",
+      "minItems": 3,
+      "maxItems": 3,
+      "items": {
+        "type": "string",
         "minLength": 1
       }
     },
@@ -199,7 +362,39 @@ var generatedSchemaJSON = `{
       "items": {
         "$id": "#/advisory-diff/items/item",
         "title": "Items",
-        "default": ""
+        "description": "Advisory ID, kind, severity, package NEVRAs and references",
+        "type": "array",
+        "minItems": 4,
+        "maxItems": 5,
+        "items": [
+          {
+            "$id": "#/advisory-diff/items/item/id",
+            "type": "string",
+            "minLength": 1
+          },
+          {
+            "$id": "#/advisory-diff/items/item/kind",
+            "type": "integer",
+            "minimum": 0
+          },
+          {
+            "$id": "#/advisory-diff/items/item/severity",
+            "type": "integer",
+            "minimum": 0
+          },
+          {
+            "$id": "#/advisory-diff/items/item/packages",
+            "type": "array",
+            "items": {
+              "type": "string",
+              "minLength": 1
+            }
+          },
+          {
+            "$id": "#/advisory-diff/items/item/references",
+            "type": "object"
+          }
+        ]
       }
     }
   },
@@ -512,7 +707,7 @@ var generatedSchemaJSON = `{
           "$id": "#/properties/images/properties/extensions-container",
           "type": "object",
           "title": "extensions-container",
-          "$ref": "#/definitions/artifact"
+          "$ref": "#/definitions/extensions-container-artifact"
         },
         "legacy-oscontainer": {
           "$id": "#/properties/images/properties/legacy-oscontainer",
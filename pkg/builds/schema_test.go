@@ -151,9 +151,9 @@ func TestMergeMeta(t *testing.T) {
 
 	// Create a fake build dir
 	fakeBuildID := "999.1"
-	bjson, _ := json.Marshal(buildsJSON{
-		SchemaVersion: "0.1.0",
-		Builds: []build{
+	bjson, _ := json.Marshal(BuildsJSON{
+		SchemaVersion: BuildsSchemaVersion,
+		Builds: []BuildEntry{
 			{
 				ID:     fakeBuildID,
 				Arches: []string{BuilderArch()},
@@ -248,3 +248,28 @@ func TestMetaRegEx(t *testing.T) {
 		})
 	}
 }
+
+func TestExtensionsContainerArtifact(t *testing.T) {
+	var b Build
+	meta := `{"images": {"extensions-container": {"path": "ext.ociarchive", "sha256": "abc", "hotfixes": [{"link": "https://example.com/1", "repo": "fix", "packages": [{"nevra": "foo-0:1.0-1.x86_64", "sha256": "def"}]}]}}}`
+	if err := b.mergeMeta(bytes.NewReader([]byte(meta))); err != nil {
+		t.Fatalf("failed to read extensions container hotfixes: %v", err)
+	}
+	if got := b.BuildArtifacts.ExtensionsContainer.Hotfixes[0].Packages[0].Nevra; got != "foo-0:1.0-1.x86_64" {
+		t.Errorf("hotfix package is %q", got)
+	}
+	a, err := b.GetArtifact("extensions-container")
+	if err != nil {
+		t.Fatalf("failed to get artifact: %v", err)
+	}
+	if a.Path != "ext.ociarchive" || a.Sha256 != "abc" {
+		t.Errorf("artifact is %+v", a)
+	}
+
+	// hotfixes are only recorded for the extensions container
+	var c Build
+	meta = `{"images": {"qemu": {"path": "qemu.qcow2", "sha256": "abc", "hotfixes": []}}}`
+	if err := c.mergeMeta(bytes.NewReader([]byte(meta))); err == nil {
+		t.Error("hotfixes of qemu artifact should have been rejected")
+	}
+}
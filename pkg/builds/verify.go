@@ -0,0 +1,242 @@
+package builds
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ArtifactOpener opens a file of a build directory, by its path relative to
+// the build directory.
+type ArtifactOpener func(path string) (io.ReadCloser, error)
+
+// VerifyOptions restricts the verification of a build.
+type VerifyOptions struct {
+	// Artifacts, if set, are the only artifacts verified.
+	Artifacts []string
+	// SkipUncompressed skips decompressing the artifacts to verify their
+	// uncompressed checksums.
+	SkipUncompressed bool
+}
+
+// ArtifactCheck is the outcome of the verification of an artifact.
+type ArtifactCheck struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Errors are the problems found; the artifact is intact if it's empty.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// OK returns true if the artifact matches its metadata.
+func (c *ArtifactCheck) OK() bool {
+	return len(c.Errors) == 0
+}
+
+func (c *ArtifactCheck) errorf(format string, args ...interface{}) {
+	c.Errors = append(c.Errors, fmt.Sprintf(format, args...))
+}
+
+// VerifyArtifacts reads the artifacts of the build with open and checks
+// them against their recorded sizes and checksums, returning the checks
+// sorted by artifact name. Errors reading an artifact are reported in its
+// check.
+func (build *Build) VerifyArtifacts(open ArtifactOpener, opts VerifyOptions) ([]ArtifactCheck, error) {
+	artifacts := map[string]*Artifact{}
+	if build.BuildArtifacts != nil {
+		artifacts = build.artifacts()
+	} else if build.Extensions != nil {
+		artifacts["extensions"] = build.Extensions.toArtifact()
+	}
+
+	var names []string
+	if len(opts.Artifacts) > 0 {
+		for _, name := range opts.Artifacts {
+			if a, ok := artifacts[name]; !ok || a.Path == "" {
+				return nil, fmt.Errorf("artifact %s not defined", name)
+			}
+			names = append(names, name)
+		}
+	} else {
+		for name, a := range artifacts {
+			if a.Path != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	checks := make([]ArtifactCheck, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, verifyArtifact(name, artifacts[name], open, !opts.SkipUncompressed))
+	}
+	return checks, nil
+}
+
+// countingHash is a SHA-256 hash counting the bytes written to it.
+type countingHash struct {
+	hash.Hash
+	n int64
+}
+
+func newCountingHash() *countingHash {
+	return &countingHash{Hash: sha256.New()}
+}
+
+func (h *countingHash) Write(p []byte) (int, error) {
+	n, err := h.Hash.Write(p)
+	h.n += int64(n)
+	return n, err
+}
+
+func (h *countingHash) sum() string {
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func verifyArtifact(name string, a *Artifact, open ArtifactOpener, uncompressed bool) ArtifactCheck {
+	c := ArtifactCheck{Name: name, Path: a.Path}
+	if a.Sha256 == "" {
+		c.errorf("no sha256 recorded")
+	}
+	uncompressed = uncompressed && (a.UncompressedSha256 != "" || a.UncompressedSize != 0)
+
+	f, err := open(a.Path)
+	if err != nil {
+		c.errorf("%v", err)
+		return c
+	}
+	defer f.Close()
+
+	// Hash the compressed and uncompressed content in a single read of
+	// the artifact, which may well be remote.
+	compressed := newCountingHash()
+	r := io.TeeReader(f, compressed)
+	if uncompressed {
+		h := newCountingHash()
+		if err := decompress(a.Path, r, h); err != nil {
+			c.errorf("failed to decompress: %v", err)
+		} else {
+			if a.UncompressedSize != 0 && h.n != int64(a.UncompressedSize) {
+				c.errorf("uncompressed size mismatch: expected %d, found %d", a.UncompressedSize, h.n)
+			}
+			if a.UncompressedSha256 != "" && h.sum() != a.UncompressedSha256 {
+				c.errorf("uncompressed sha256 mismatch: expected %s, found %s", a.UncompressedSha256, h.sum())
+			}
+		}
+	}
+	// the decompressor may not have consumed trailing data
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		c.errorf("failed to read: %v", err)
+		return c
+	}
+
+	if a.SizeInBytes != 0 && compressed.n != int64(a.SizeInBytes) {
+		c.errorf("size mismatch: expected %d, found %d", int64(a.SizeInBytes), compressed.n)
+	}
+	if a.Sha256 != "" && compressed.sum() != a.Sha256 {
+		c.errorf("sha256 mismatch: expected %s, found %s", a.Sha256, compressed.sum())
+	}
+	return c
+}
+
+// decompress writes the decompressed content of r to w, using the
+// compression of the file extension of path.
+func decompress(path string, r io.Reader, w io.Writer) error {
+	var prog string
+	switch filepath.Ext(path) {
+	case ".gz":
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, z); err != nil {
+			return err
+		}
+		return z.Close()
+	case ".xz":
+		prog = "xz"
+	case ".zst":
+		prog = "zstd"
+	default:
+		return fmt.Errorf("unknown compression of %s", path)
+	}
+
+	var stderr strings.Builder
+	cmd := exec.Command(prog, "-dc")
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", prog, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// buildMetadataFiles are the files which cmd-build writes to every build
+// directory besides meta.json and commitmeta.json: the flattened manifest
+// (see cmdlib.sh), the archive of the config and the git metadata of the
+// config and yum repos.
+var buildMetadataFiles = map[string]bool{
+	"ostree-commit-object":               true,
+	"manifest.json":                      true,
+	"coreos-assembler-config.tar.gz":     true,
+	"coreos-assembler-config-git.json":   true,
+	"coreos-assembler-yumrepos-git.json": true,
+}
+
+// buildMetadataFile returns true if the file is metadata written to build
+// directories by cosa, rather than an artifact.
+func buildMetadataFile(name string) bool {
+	switch {
+	case IsMetaJSON(name), name == CosaCommitMetaJSON, buildMetadataFiles[name]:
+		return true
+	case strings.HasPrefix(name, "manifest-lock.generated.") && strings.HasSuffix(name, ".json"):
+		return true
+	}
+	return false
+}
+
+// UnreferencedFiles returns the files of the build directory dir which are
+// neither artifacts of the build nor cosa metadata, sorted by their path
+// relative to dir.
+func (build *Build) UnreferencedFiles(dir string) ([]string, error) {
+	referenced := map[string]bool{}
+	if build.BuildArtifacts != nil {
+		for _, a := range build.artifacts() {
+			if a.Path != "" {
+				referenced[a.Path] = true
+			}
+		}
+	} else if build.Extensions != nil {
+		referenced[build.Extensions.Path] = true
+	}
+
+	var unreferenced []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !referenced[rel] && !buildMetadataFile(rel) {
+			unreferenced = append(unreferenced, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unreferenced)
+	return unreferenced, nil
+}
@@ -0,0 +1,152 @@
+package builds
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	sum := func(data []byte) string {
+		return fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw := []byte("qemu image content")
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	if _, err := z.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz := buf.Bytes()
+	write("qemu.qcow2.gz", gz)
+	write("ostree.tar", []byte("ostree"))
+	write("metal.raw", []byte("corrupted"))
+	write("stray.iso", []byte("stray"))
+	write(CosaMetaJSON, []byte("{}"))
+
+	build := &Build{
+		BuildID: "1",
+		BuildArtifacts: &BuildArtifacts{
+			Qemu: &Artifact{
+				Path:               "qemu.qcow2.gz",
+				Sha256:             sum(gz),
+				SizeInBytes:        float64(len(gz)),
+				UncompressedSha256: sum(raw),
+				UncompressedSize:   len(raw),
+			},
+			Ostree: Artifact{
+				Path:   "ostree.tar",
+				Sha256: sum([]byte("ostree")),
+			},
+			Metal: &Artifact{
+				Path:   "metal.raw",
+				Sha256: sum([]byte("metal")),
+			},
+			Aws: &Artifact{
+				Path:   "missing.vmdk",
+				Sha256: sum([]byte("aws")),
+			},
+		},
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, name))
+	}
+
+	checks, err := build.VerifyArtifacts(open, VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := map[string]bool{}
+	for _, c := range checks {
+		ok[c.Name] = c.OK()
+	}
+	expected := map[string]bool{"aws": false, "metal": false, "ostree": true, "qemu": true}
+	if !reflect.DeepEqual(ok, expected) {
+		t.Errorf("expected %v, found %v", expected, ok)
+	}
+
+	// a bad uncompressed checksum is only found when decompressing
+	build.BuildArtifacts.Qemu.UncompressedSha256 = sum([]byte("other"))
+	for _, tc := range []struct {
+		skipUncompressed bool
+		ok               bool
+	}{
+		{false, false},
+		{true, true},
+	} {
+		checks, err := build.VerifyArtifacts(open, VerifyOptions{
+			Artifacts:        []string{"qemu"},
+			SkipUncompressed: tc.skipUncompressed,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(checks) != 1 || checks[0].OK() != tc.ok {
+			t.Errorf("skip-uncompressed %v: unexpected checks %+v", tc.skipUncompressed, checks)
+		}
+	}
+
+	if _, err := build.VerifyArtifacts(open, VerifyOptions{Artifacts: []string{"gcp"}}); err == nil {
+		t.Error("expected an error verifying an undefined artifact")
+	}
+
+	unreferenced, err := build.UnreferencedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unreferenced, []string{"stray.iso"}) {
+		t.Errorf("unexpected unreferenced files %v", unreferenced)
+	}
+}
+
+func TestUnreferencedFilesBuildLayout(t *testing.T) {
+	// a build directory as cmd-build creates it, before any buildextend
+	dir := t.TempDir()
+	files := []string{
+		CosaMetaJSON,
+		CosaCommitMetaJSON,
+		"manifest.json",
+		"manifest-lock.generated.x86_64.json",
+		"coreos-assembler-config.tar.gz",
+		"coreos-assembler-config-git.json",
+		"coreos-assembler-yumrepos-git.json",
+		"ostree-commit-object",
+		"fedora-coreos-41.1-ostree.x86_64.ociarchive",
+		"fedora-coreos-41.1-ostree.x86_64-manifest.json",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := &Build{
+		BuildID: "41.1",
+		BuildArtifacts: &BuildArtifacts{
+			Ostree:      Artifact{Path: "fedora-coreos-41.1-ostree.x86_64.ociarchive"},
+			OciManifest: &Artifact{Path: "fedora-coreos-41.1-ostree.x86_64-manifest.json"},
+		},
+	}
+
+	unreferenced, err := build.UnreferencedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unreferenced) != 0 {
+		t.Errorf("unexpected unreferenced files %v", unreferenced)
+	}
+}
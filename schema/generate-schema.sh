@@ -35,6 +35,10 @@ ${schematyper} \
 # can vary depending on local checkout paths.
 sed -e "s|^// generated.*|// generated by 'make schema'\n// source hash: ${digest}|g"  -i ${tdir}/cosa_v1.go
 
+# The items of package and advisory diffs are tuples, which schematyper
+# can't type; they're defined in pkgdiff.go instead.
+sed -e '/^type \(AdvisoryDiffItems\|PackageSetDifferencesItems\) /,+1d' -i ${tdir}/cosa_v1.go
+
 cat > "${tdir}/schema_doc.go" <<EOM
 // Generated by ${0}
 // Source hash: ${digest}
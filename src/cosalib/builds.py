@@ -6,6 +6,8 @@ import json
 import os
 import gi
 import collections
+import contextlib
+import fcntl
 
 gi.require_version('OSTree', '1.0')
 from gi.repository import Gio, OSTree
@@ -29,6 +31,20 @@ BUILDFILES = {
 }
 
 
+@contextlib.contextmanager
+def lock_builds_json(builds_dir):
+    """
+    Lock builds.json in builds_dir for updates. Besides the flufl.lock of
+    write_json, builds.json is locked with flock(2) on .builds.json.flock,
+    the lock of the Go tooling (e.g. `cosa prune`, see UpdateBuildsJSON in
+    pkg/builds), so that Go and Python updates are serialized.
+    """
+    with open(os.path.join(builds_dir, '.builds.json.flock'), 'a') as f:
+        # closing the file releases the lock
+        fcntl.flock(f, fcntl.LOCK_EX)
+        yield
+
+
 class Builds:  # pragma: nocover
     def __init__(self, workdir=None):
         self._workdir = workdir
@@ -36,7 +52,8 @@ class Builds:  # pragma: nocover
         if not os.path.isdir(self._path("builds")):
             raise Exception("No builds/ dir found!")
         elif os.path.isfile(self._fn):
-            self._data = load_json(self._fn)
+            with lock_builds_json(self._path("builds")):
+                self._data = load_json(self._fn)
         else:
             # must be a new workdir; use new schema
             self._data = {
@@ -155,7 +172,8 @@ class Builds:  # pragma: nocover
         return self._data
 
     def flush(self):
-        write_json(self._fn, self._data)
+        with lock_builds_json(os.path.dirname(self._fn) or "."):
+            write_json(self._fn, self._data)
 
 
 def get_local_builds(builds_dir):